@@ -5,9 +5,12 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/quota"
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
 )
@@ -49,30 +52,93 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 		return nil, sdkaccess.ErrNoCredentials
 	}
 
+	store := mj3gc.DefaultStore()
 	value, source := extractAPIKey(r)
 	if value == "" {
+		if store.AnonymousEnabled() {
+			return &sdkaccess.Result{
+				Provider:  p.Identifier(),
+				Principal: mj3gc.AnonymousPrincipal,
+				Metadata:  map[string]string{"anonymous": "true"},
+			}, nil
+		}
 		return nil, sdkaccess.ErrNoCredentials
 	}
 
-	store := mj3gc.DefaultStore()
-	apiKey, ok := store.FindAPIKey(value)
-	if !ok {
-		return nil, sdkaccess.ErrInvalidCredential
+	metadata := map[string]string{}
+	var apiKey mj3gc.APIKey
+	if strings.HasPrefix(value, mj3gc.AccessTokenPrefix) {
+		if source != "authorization" {
+			return nil, sdkaccess.ErrInvalidCredential
+		}
+		key, err := store.ValidateAccessToken(value)
+		if err != nil {
+			return nil, sdkaccess.ErrInvalidCredential
+		}
+		apiKey = key
+		metadata["oauth"] = "true"
+	} else {
+		key, ok := store.FindAPIKey(value)
+		if !ok {
+			return nil, sdkaccess.ErrInvalidCredential
+		}
+		apiKey = key
 	}
 	if !apiKey.Enabled {
-		return nil, sdkaccess.ErrInvalidCredential
+		return nil, rejection(store, apiKey, apiKey.DisabledError())
+	}
+	// Expired and sunset keys are refused before maintenance gets to purge or suspend them.
+	now := time.Now()
+	if apiKey.Expired(now) {
+		return nil, rejection(store, apiKey, mj3gc.ErrKeyExpired)
+	}
+	if apiKey.SunsetPassed(now) {
+		return nil, rejection(store, apiKey, mj3gc.ErrKeySuspended)
 	}
 	if !apiKey.CompatibilityMode && !isStrictSource(source) {
 		return nil, sdkaccess.ErrInvalidCredential
 	}
+	if !apiKey.AllowsUserAgent(r.UserAgent()) {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	if !apiKey.AllowsOrigin(r.Header.Get("Origin"), r.Header.Get("Referer")) {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	if len(apiKey.AllowedIPs) > 0 && !apiKey.AllowsIP(store.ClientIP(r)) {
+		return nil, sdkaccess.ErrInvalidCredential
+	}
+	// Model listings and quota checks stay available with an exhausted quota.
+	if _, metered := mj3gc.RequiredScope(r.Method, r.URL.Path, ""); metered {
+		err := apiKey.QuotaError()
+		if err == nil {
+			err = store.ParentQuotaError(apiKey)
+		}
+		if err != nil {
+			return nil, rejection(store, apiKey, err)
+		}
+	}
 
-	metadata := map[string]string{}
 	if apiKey.UserID != "" {
 		metadata["user_id"] = apiKey.UserID
 	}
 	if apiKey.Label != "" {
 		metadata["label"] = apiKey.Label
 	}
+	if apiKey.Residency != "" {
+		metadata[cliproxyexecutor.ResidencyMetadataKey] = apiKey.Residency
+	}
+	if pool := store.UpstreamPool(apiKey); pool != "" {
+		metadata[cliproxyexecutor.PoolMetadataKey] = pool
+	}
+	if len(apiKey.PinnedAuths) > 0 {
+		metadata[cliproxyexecutor.PinnedAuthsMetadataKey] = strings.Join(apiKey.PinnedAuths, ",")
+	}
+	if len(apiKey.PinnedProviders) > 0 {
+		metadata[cliproxyexecutor.PinnedProvidersMetadataKey] = strings.Join(apiKey.PinnedProviders, ",")
+	}
+	if len(apiKey.Features) > 0 {
+		metadata[mj3gc.FeaturesMetadataKey] = strings.Join(apiKey.Features, ",")
+	}
 
 	return &sdkaccess.Result{
 		Provider:  p.Identifier(),
@@ -81,6 +147,25 @@ func (p *provider) Authenticate(_ context.Context, r *http.Request) (*sdkaccess.
 	}, nil
 }
 
+// rejection turns a quota error refusing key into an access rejection with its status
+// and code, the documentation link of mj3gc.error-docs and, for quotas that reset on a
+// schedule, when the key resets.
+func rejection(store *mj3gc.Store, key mj3gc.APIKey, err error) error {
+	code := quota.Code(err)
+	out := &sdkaccess.RejectionError{
+		Status:  quota.HTTPStatus(err),
+		Code:    code,
+		Message: err.Error(),
+		DocURL:  store.ErrorDocURL(code),
+	}
+	if out.Status == http.StatusTooManyRequests {
+		if resetsAt := key.ResetsAt(); !resetsAt.IsZero() {
+			out.RetryAfter = time.Until(resetsAt)
+		}
+	}
+	return out
+}
+
 func extractAPIKey(r *http.Request) (string, string) {
 	authHeader := r.Header.Get("Authorization")
 	if authHeader != "" {
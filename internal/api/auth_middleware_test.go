@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	gin "github.com/gin-gonic/gin"
+	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
+)
+
+type rejectingProvider struct{ err error }
+
+func (rejectingProvider) Identifier() string { return "rejecting" }
+
+func (p rejectingProvider) Authenticate(context.Context, *http.Request) (*sdkaccess.Result, error) {
+	return nil, p.err
+}
+
+func TestAuthMiddlewareReportsRejections(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	manager := sdkaccess.NewManager()
+	manager.SetProviders([]sdkaccess.Provider{
+		rejectingProvider{err: sdkaccess.ErrInvalidCredential},
+		rejectingProvider{err: &sdkaccess.RejectionError{
+			Status:     http.StatusTooManyRequests,
+			Code:       "quota_exceeded",
+			Message:    "quota exceeded",
+			DocURL:     "https://example.com/quota",
+			RetryAfter: 1500 * time.Millisecond,
+		}},
+	})
+	engine := gin.New()
+	engine.GET("/v1/chat", AuthMiddleware(manager), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	rec := httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/chat", nil))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Fatalf("status = %d, Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body["code"] != "quota_exceeded" || body["doc_url"] != "https://example.com/quota" {
+		t.Fatalf("body = %v", body)
+	}
+
+	manager.SetProviders([]sdkaccess.Provider{rejectingProvider{err: sdkaccess.ErrInvalidCredential}})
+	rec = httptest.NewRecorder()
+	engine.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/chat", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("plain invalid credential status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,243 @@
+package management
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+const (
+	defaultManagementRateLimit = 600
+	// adminFailureAuditStreak is the failed authentication streak that gets audited.
+	adminFailureAuditStreak = 3
+	adminContextKey         = "managementAdmin"
+	// adminIdleTTL is how long the activity of an admin or failing client IP is kept
+	// after its last request; idle entries are swept every adminPruneInterval.
+	adminIdleTTL       = 24 * time.Hour
+	adminPruneInterval = 10 * time.Minute
+)
+
+// adminStats is the activity of one management credential used from one client IP, or
+// of a client IP that failed to authenticate.
+type adminStats struct {
+	Admin           string    `json:"admin"`
+	Requests        int64     `json:"requests"`
+	Writes          int64     `json:"writes"`
+	RateLimited     int64     `json:"rate_limited"`
+	FailedAuth      int64     `json:"failed_auth"`
+	FailedStreak    int       `json:"failed_streak"`
+	MaxFailedStreak int       `json:"max_failed_streak"`
+	LastSeen        time.Time `json:"last_seen,omitempty"`
+	LastFailure     time.Time `json:"last_failure,omitempty"`
+	RequestsPerMin  float64   `json:"requests_per_minute"`
+
+	tokens   float64
+	refilled time.Time
+	minute   time.Time
+	inMinute int64
+}
+
+// adminActivity throttles and accounts management API usage per admin.
+type adminActivity struct {
+	mu     sync.Mutex
+	admins map[string]*adminStats
+	pruned time.Time
+}
+
+func (a *adminActivity) entry(admin string, now time.Time) *adminStats {
+	if a.admins == nil {
+		a.admins = make(map[string]*adminStats)
+	}
+	if now.Sub(a.pruned) >= adminPruneInterval {
+		a.pruneLocked(now)
+	}
+	stats, ok := a.admins[admin]
+	if !ok {
+		stats = &adminStats{Admin: admin}
+		a.admins[admin] = stats
+	}
+	return stats
+}
+
+// pruneLocked drops the entries idle for adminIdleTTL. The caller holds a.mu.
+func (a *adminActivity) pruneLocked(now time.Time) {
+	a.pruned = now
+	for admin, stats := range a.admins {
+		last := stats.LastSeen
+		if stats.LastFailure.After(last) {
+			last = stats.LastFailure
+		}
+		if now.Sub(last) >= adminIdleTTL {
+			delete(a.admins, admin)
+		}
+	}
+}
+
+// allow counts a request of admin and reports whether it fits the per-minute limit,
+// using a token bucket refilled continuously. A limit below zero disables throttling.
+func (a *adminActivity) allow(admin string, limit int, write bool, now time.Time) (bool, time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.entry(admin, now)
+	stats.LastSeen = now
+	if minute := now.Truncate(time.Minute); !minute.Equal(stats.minute) {
+		stats.minute, stats.inMinute = minute, 0
+	}
+	if limit >= 0 {
+		capacity := float64(limit)
+		if stats.refilled.IsZero() {
+			stats.tokens = capacity
+		} else {
+			stats.tokens = math.Min(capacity, stats.tokens+now.Sub(stats.refilled).Minutes()*capacity)
+		}
+		stats.refilled = now
+		if stats.tokens < 1 {
+			stats.RateLimited++
+			wait := time.Duration((1 - stats.tokens) / capacity * float64(time.Minute))
+			return false, wait
+		}
+		stats.tokens--
+	}
+	stats.Requests++
+	stats.inMinute++
+	if write {
+		stats.Writes++
+	}
+	return true, 0
+}
+
+// failed records a failed authentication from clientIP and returns the current streak.
+func (a *adminActivity) failed(clientIP string, now time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats := a.entry("unauthenticated@"+clientIP, now)
+	stats.FailedAuth++
+	stats.FailedStreak++
+	if stats.FailedStreak > stats.MaxFailedStreak {
+		stats.MaxFailedStreak = stats.FailedStreak
+	}
+	stats.LastFailure = now
+	return stats.FailedStreak
+}
+
+// succeeded ends the failed authentication streak of clientIP.
+func (a *adminActivity) succeeded(clientIP string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if stats, ok := a.admins["unauthenticated@"+clientIP]; ok {
+		stats.FailedStreak = 0
+	}
+}
+
+func (a *adminActivity) snapshot(now time.Time) []adminStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	out := make([]adminStats, 0, len(a.admins))
+	for _, stats := range a.admins {
+		entry := *stats
+		if entry.minute.Equal(now.Truncate(time.Minute)) {
+			entry.RequestsPerMin = float64(entry.inMinute)
+		}
+		out = append(out, entry)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Admin < out[j].Admin })
+	return out
+}
+
+func (h *Handler) managementRateLimit() int {
+	if h.cfg == nil || h.cfg.RemoteManagement.RateLimitPerMinute == 0 {
+		return defaultManagementRateLimit
+	}
+	return h.cfg.RemoteManagement.RateLimitPerMinute
+}
+
+// admitAdmin runs after a successful authentication with the named credential. It
+// throttles the admin, rejects mj3gc writes while the store is read-only, runs the rest
+// of the chain and audits writes outside the mj3gc routes, which audit their own changes.
+func (h *Handler) admitAdmin(c *gin.Context, credential string) {
+	clientIP := activityClientIP(c)
+	admin := credential + "@" + clientIP
+	write := c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead
+	h.activity.succeeded(clientIP)
+	ok, wait := h.activity.allow(admin, h.managementRateLimit(), write, time.Now())
+	if !ok {
+		mj3gc.DefaultStore().RecordAudit(mj3gc.AuditEntry{
+			Actor:   admin,
+			Action:  "management.rate_limited",
+			Target:  c.FullPath(),
+			Details: map[string]any{"method": c.Request.Method},
+		})
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "management rate limit exceeded"})
+		return
+	}
+	c.Set(adminContextKey, admin)
+	c.Set(adminCredentialContextKey, credential)
+	if write && isMJ3GCRoute(c.FullPath()) && mj3gc.DefaultStore().ReadOnly() {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": mj3gc.ErrReadOnly.Error()})
+		return
+	}
+	c.Next()
+	if write && !isMJ3GCRoute(c.FullPath()) {
+		mj3gc.DefaultStore().RecordAudit(mj3gc.AuditEntry{
+			Actor:   admin,
+			Action:  "management.request",
+			Target:  c.FullPath(),
+			Details: map[string]any{"method": c.Request.Method, "status": c.Writer.Status()},
+		})
+	}
+}
+
+// authFailed records a failed management authentication and audits streaks.
+func (h *Handler) authFailed(c *gin.Context) {
+	clientIP := activityClientIP(c)
+	streak := h.activity.failed(clientIP, time.Now())
+	if streak >= adminFailureAuditStreak {
+		mj3gc.DefaultStore().RecordAudit(mj3gc.AuditEntry{
+			Actor:   "unauthenticated@" + clientIP,
+			Action:  "management.auth_failed",
+			Target:  c.FullPath(),
+			Details: map[string]any{"streak": streak},
+		})
+	}
+}
+
+func isMJ3GCRoute(path string) bool {
+	return strings.HasPrefix(path, "/v0/management/mj3gc/")
+}
+
+// adminActor returns the authenticated admin of the request, falling back to the
+// client IP outside the management middleware.
+func adminActor(c *gin.Context) string {
+	if admin, ok := c.Get(adminContextKey); ok {
+		if name, ok := admin.(string); ok {
+			return name
+		}
+	}
+	return activityClientIP(c)
+}
+
+// activityClientIP returns the client IP of the request resolved through the mj3gc
+// trusted proxies, so forwarding headers of untrusted peers cannot pick the admin name.
+func activityClientIP(c *gin.Context) string {
+	if addr := mj3gc.DefaultStore().ClientIP(c.Request); addr.IsValid() {
+		return addr.String()
+	}
+	return c.RemoteIP()
+}
+
+// GetAdminActivity returns request, throttling and failed authentication counters per
+// admin credential and client IP.
+func (h *Handler) GetAdminActivity(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"rate_limit_per_minute": h.managementRateLimit(),
+		"admins":                h.activity.snapshot(time.Now()),
+	})
+}
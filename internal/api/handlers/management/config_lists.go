@@ -41,8 +41,7 @@ func (h *Handler) patchStringList(c *gin.Context, target *[]string, after func()
 		Index *int    `json:"index"`
 		Value *string `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
 		return
 	}
 	if body.Index != nil && body.Value != nil && *body.Index >= 0 && *body.Index < len(*target) {
@@ -158,8 +157,11 @@ func (h *Handler) PatchGeminiKey(c *gin.Context) {
 		Match *string         `json:"match"`
 		Value *geminiKeyPatch `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Value == nil {
+		respondInvalidBody(c, requiredField("value", "object"))
 		return
 	}
 	targetIndex := -1
@@ -285,8 +287,11 @@ func (h *Handler) PatchClaudeKey(c *gin.Context) {
 		Match *string         `json:"match"`
 		Value *claudeKeyPatch `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Value == nil {
+		respondInvalidBody(c, requiredField("value", "object"))
 		return
 	}
 	targetIndex := -1
@@ -407,8 +412,11 @@ func (h *Handler) PatchOpenAICompat(c *gin.Context) {
 		Index *int               `json:"index"`
 		Value *openAICompatPatch `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Value == nil {
+		respondInvalidBody(c, requiredField("value", "object"))
 		return
 	}
 	targetIndex := -1
@@ -518,8 +526,11 @@ func (h *Handler) PatchOAuthExcludedModels(c *gin.Context) {
 		Provider *string  `json:"provider"`
 		Models   []string `json:"models"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Provider == nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Provider == nil {
+		respondInvalidBody(c, requiredField("provider", "string"))
 		return
 	}
 	provider := strings.ToLower(strings.TrimSpace(*body.Provider))
@@ -625,8 +636,11 @@ func (h *Handler) PatchCodexKey(c *gin.Context) {
 		Match *string        `json:"match"`
 		Value *codexKeyPatch `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Value == nil {
+		respondInvalidBody(c, requiredField("value", "object"))
 		return
 	}
 	targetIndex := -1
@@ -839,8 +853,7 @@ func (h *Handler) PutAmpModelMappings(c *gin.Context) {
 	var body struct {
 		Value []config.AmpModelMapping `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
 		return
 	}
 	h.cfg.AmpCode.ModelMappings = body.Value
@@ -852,8 +865,7 @@ func (h *Handler) PatchAmpModelMappings(c *gin.Context) {
 	var body struct {
 		Value []config.AmpModelMapping `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(400, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
 		return
 	}
 
@@ -40,6 +40,8 @@ type Handler struct {
 	allowRemoteOverride bool
 	envSecret           string
 	logDir              string
+	activity            adminActivity
+	portalUsage         portalUsageCache
 }
 
 // NewHandler creates a new management handler instance.
@@ -174,6 +176,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			if !localClient {
 				fail()
 			}
+			h.authFailed(c)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing management key"})
 			return
 		}
@@ -181,7 +184,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 		if localClient {
 			if lp := h.localPassword; lp != "" {
 				if subtle.ConstantTimeCompare([]byte(provided), []byte(lp)) == 1 {
-					c.Next()
+					h.admitAdmin(c, "local-password")
 					return
 				}
 			}
@@ -196,7 +199,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 				}
 				h.attemptsMu.Unlock()
 			}
-			c.Next()
+			h.admitAdmin(c, "env-secret")
 			return
 		}
 
@@ -204,6 +207,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			if !localClient {
 				fail()
 			}
+			h.authFailed(c)
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid management key"})
 			return
 		}
@@ -217,7 +221,7 @@ func (h *Handler) Middleware() gin.HandlerFunc {
 			h.attemptsMu.Unlock()
 		}
 
-		c.Next()
+		h.admitAdmin(c, "secret-key")
 	}
 }
 
@@ -239,8 +243,11 @@ func (h *Handler) updateBoolField(c *gin.Context, set func(bool)) {
 	var body struct {
 		Value *bool `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Value == nil {
+		respondInvalidBody(c, requiredField("value", "boolean"))
 		return
 	}
 	set(*body.Value)
@@ -251,8 +258,11 @@ func (h *Handler) updateIntField(c *gin.Context, set func(int)) {
 	var body struct {
 		Value *int `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Value == nil {
+		respondInvalidBody(c, requiredField("value", "integer"))
 		return
 	}
 	set(*body.Value)
@@ -263,8 +273,11 @@ func (h *Handler) updateStringField(c *gin.Context, set func(string)) {
 	var body struct {
 		Value *string `json:"value"`
 	}
-	if err := c.ShouldBindJSON(&body); err != nil || body.Value == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if body.Value == nil {
+		respondInvalidBody(c, requiredField("value", "string"))
 		return
 	}
 	set(*body.Value)
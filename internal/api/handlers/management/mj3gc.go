@@ -1,6 +1,7 @@
 package management
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 	"strconv"
@@ -10,47 +11,104 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
 )
 
 type mj3gcUserRequest struct {
-	ID       string `json:"id"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Role     string `json:"role"`
-	Disabled *bool  `json:"disabled"`
+	ID       string             `json:"id"`
+	Username string             `json:"username"`
+	Password string             `json:"password"`
+	Role     string             `json:"role"`
+	Disabled *bool              `json:"disabled"`
+	Referral string             `json:"referral_code"`
+	Billing  *mj3gc.BillingInfo `json:"billing"`
+	Pool     *string            `json:"pool"`
+	MaxKeys  *int               `json:"max_keys"`
+	Reason   string             `json:"reason"`
 }
 
 type mj3gcKeyRequest struct {
-	ID                string `json:"id"`
-	Key               *string `json:"key"`
-	Label             *string `json:"label"`
-	UserID            *string `json:"user_id"`
-	Enabled           *bool  `json:"enabled"`
-	TotalLimit        *int64 `json:"total_limit"`
-	ConcurrencyLimit  *int   `json:"concurrency_limit"`
-	CompatibilityMode *bool  `json:"compatibility_mode"`
-	ResetUsage        bool   `json:"reset_usage"`
+	ID                string             `json:"id"`
+	Key               *string            `json:"key"`
+	Label             *string            `json:"label"`
+	UserID            *string            `json:"user_id"`
+	ParentID          *string            `json:"parent_id"`
+	Enabled           *bool              `json:"enabled"`
+	TotalLimit        *int64             `json:"total_limit"`
+	TokenLimit        *int64             `json:"token_limit"`
+	BudgetUSD         *float64           `json:"budget_usd"`
+	ConcurrencyLimit  *int               `json:"concurrency_limit"`
+	RPMLimit          *int               `json:"rpm_limit"`
+	MaxOutputTokens   *int               `json:"max_output_tokens"`
+	MaxBodyBytes      *int64             `json:"max_body_bytes"`
+	Streaming         *string            `json:"streaming"`
+	Schedule          *mj3gc.KeySchedule `json:"schedule"`
+	CompatibilityMode *bool              `json:"compatibility_mode"`
+	ShadowURL         *string            `json:"shadow_url"`
+	AllowedUserAgents *[]string          `json:"allowed_user_agents"`
+	AllowedIPs        *[]string          `json:"allowed_ips"`
+	AllowedOrigins    *[]string          `json:"allowed_origins"`
+	AllowedModels     *[]string          `json:"allowed_models"`
+	DeniedModels      *[]string          `json:"denied_models"`
+	ModelAliases      *map[string]string `json:"model_aliases"`
+	Priority          *string            `json:"priority"`
+	SunsetAt          *string            `json:"sunset_at"`
+	Features          *[]string          `json:"features"`
+	Scopes            *[]string          `json:"scopes"`
+	Tags              *map[string]string `json:"tags"`
+	CountPolicy       *string            `json:"count_policy"`
+	Residency         *string            `json:"residency"`
+	Pool              *string            `json:"pool"`
+	ResetInterval     *string            `json:"reset_interval"`
+	AlertThresholds   *[]int             `json:"alert_thresholds"`
+	PinnedAuths       *[]string          `json:"pinned_auths"`
+	PinnedProviders   *[]string          `json:"pinned_providers"`
+	ResetUsage        bool               `json:"reset_usage"`
+	Reason            string             `json:"reason"`
 }
 
 type mj3gcKeyUsage struct {
-	ID           string `json:"id"`
-	Key          string `json:"key"`
-	Label        string `json:"label"`
-	UserID       string `json:"user_id"`
-	TotalLimit   int64  `json:"total_limit"`
-	UsedCount    int64  `json:"used_count"`
-	Remaining    int64  `json:"remaining"`
-	Concurrency  int    `json:"concurrency_limit"`
-	CompatMode   bool   `json:"compatibility_mode"`
-	TotalRequest int64  `json:"total_requests"`
-	TotalTokens  int64  `json:"total_tokens"`
+	ID           string  `json:"id"`
+	Key          string  `json:"key"`
+	Label        string  `json:"label"`
+	UserID       string  `json:"user_id"`
+	TotalLimit   int64   `json:"total_limit"`
+	UsedCount    int64   `json:"used_count"`
+	Remaining    int64   `json:"remaining"`
+	TokenLimit   int64   `json:"token_limit,omitempty"`
+	UsedTokens   int64   `json:"used_tokens"`
+	BudgetUSD    float64 `json:"budget_usd,omitempty"`
+	SpentUSD     float64 `json:"spent_usd"`
+	Concurrency  int     `json:"concurrency_limit"`
+	CompatMode   bool    `json:"compatibility_mode"`
+	TotalRequest int64   `json:"total_requests"`
+	TotalTokens  int64   `json:"total_tokens"`
+	Aborted      int64   `json:"aborted_requests"`
+	Timeouts     int64   `json:"timeout_requests"`
+	LastUsedAt   string  `json:"last_used_at,omitempty"`
+	LastUsedIP   string  `json:"last_used_ip,omitempty"`
+	ResetPeriod  string  `json:"reset_interval,omitempty"`
+	ResetsAt     string  `json:"resets_at,omitempty"`
+	SunsetAt     string  `json:"sunset_at,omitempty"`
+	SunsetIn     int64   `json:"sunset_in_seconds,omitempty"`
+	// Consistency is "exact" or "cached"; AsOf is when the figures were read.
+	Consistency string `json:"consistency,omitempty"`
+	AsOf        string `json:"as_of,omitempty"`
+}
+
+type mj3gcReferralUsage struct {
+	mj3gc.ReferralSummary
+	TotalRequest int64 `json:"total_requests"`
+	TotalTokens  int64 `json:"total_tokens"`
 }
 
 type mj3gcLogEntry struct {
-	Timestamp int64            `json:"timestamp"`
-	Model     string           `json:"model"`
-	Failed    bool             `json:"failed"`
-	Tokens    usage.TokenStats `json:"tokens"`
+	Timestamp    int64            `json:"timestamp"`
+	TimestampISO string           `json:"timestamp_iso"`
+	Model        string           `json:"model"`
+	Failed       bool             `json:"failed"`
+	Tokens       usage.TokenStats `json:"tokens"`
+	LatencyMs    int64            `json:"latency_ms,omitempty"`
 }
 
 func (h *Handler) GetMJ3GCState(c *gin.Context) {
@@ -65,10 +123,13 @@ func (h *Handler) GetMJ3GCState(c *gin.Context) {
 		users = append(users, mj3gc.SanitizeUser(u))
 	}
 	c.JSON(http.StatusOK, gin.H{
-		"version":    data.Version,
-		"updated_at": data.UpdatedAt,
-		"users":      users,
-		"api_keys":   data.APIKeys,
+		"version":     data.Version,
+		"updated_at":  data.UpdatedAt,
+		"read_only":   store.ReadOnly(),
+		"persistence": store.PersistenceStats(),
+		"shadow":      mj3gc.CurrentShadowStats(),
+		"users":       users,
+		"api_keys":    h.visibleKeys(c, data.APIKeys),
 	})
 }
 
@@ -82,20 +143,69 @@ func (h *Handler) GetMJ3GCUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"users": out})
 }
 
-func (h *Handler) UpsertMJ3GCUser(c *gin.Context) {
+// mj3gcWriteMode selects how user and key writes treat an existing record.
+type mj3gcWriteMode int
+
+const (
+	// mj3gcUpsert updates the record with the given id or creates it.
+	mj3gcUpsert mj3gcWriteMode = iota
+	// mj3gcCreateOnly answers 409 when the given id already exists.
+	mj3gcCreateOnly
+	// mj3gcUpdateOnly answers 404 when the id in the path does not exist.
+	mj3gcUpdateOnly
+)
+
+// checkMJ3GCWriteMode answers the request and returns false when mode forbids writing
+// a record that exists (or does not).
+func checkMJ3GCWriteMode(c *gin.Context, mode mj3gcWriteMode, kind string, exists bool) bool {
+	switch {
+	case mode == mj3gcCreateOnly && exists:
+		c.JSON(http.StatusConflict, gin.H{"error": kind + " already exists"})
+		return false
+	case mode == mj3gcUpdateOnly && !exists:
+		c.JSON(http.StatusNotFound, gin.H{"error": kind + " not found"})
+		return false
+	}
+	return true
+}
+
+// UpsertMJ3GCUser creates the user or updates the one with the id in the body.
+func (h *Handler) UpsertMJ3GCUser(c *gin.Context) { h.writeMJ3GCUser(c, mj3gcUpsert) }
+
+// CreateMJ3GCUser creates a user and answers 409 when the id in the body is taken.
+func (h *Handler) CreateMJ3GCUser(c *gin.Context) { h.writeMJ3GCUser(c, mj3gcCreateOnly) }
+
+// UpdateMJ3GCUser updates the user with the id in the path and answers 404 when it
+// does not exist.
+func (h *Handler) UpdateMJ3GCUser(c *gin.Context) { h.writeMJ3GCUser(c, mj3gcUpdateOnly) }
+
+func (h *Handler) writeMJ3GCUser(c *gin.Context, mode mj3gcWriteMode) {
 	var body mj3gcUserRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
+		return
+	}
+	if problems := validateMJ3GCUserRequest(body); len(problems) > 0 {
+		respondInvalidBody(c, problems...)
 		return
 	}
+	if id := strings.TrimSpace(c.Param("id")); id != "" {
+		body.ID = id
+	}
 	store := mj3gc.DefaultStore()
-	var user mj3gc.User
+	var (
+		user   mj3gc.User
+		exists bool
+	)
 	if strings.TrimSpace(body.ID) != "" {
 		if existing, ok := store.FindUserByID(strings.TrimSpace(body.ID)); ok {
 			user = existing
+			exists = true
 		}
 		user.ID = strings.TrimSpace(body.ID)
 	}
+	if !checkMJ3GCWriteMode(c, mode, "user", exists) {
+		return
+	}
 	if strings.TrimSpace(body.Username) != "" {
 		user.Username = strings.TrimSpace(body.Username)
 	}
@@ -105,6 +215,23 @@ func (h *Handler) UpsertMJ3GCUser(c *gin.Context) {
 	if body.Disabled != nil {
 		user.Disabled = *body.Disabled
 	}
+	if body.Pool != nil {
+		user.Pool = mj3gc.NormalizePool(*body.Pool)
+	}
+	if body.MaxKeys != nil {
+		user.MaxKeys = *body.MaxKeys
+	}
+	if body.Billing != nil {
+		billing := body.Billing.Normalize()
+		if err := billing.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		user.Billing = nil
+		if !billing.IsZero() {
+			user.Billing = &billing
+		}
+	}
 	if strings.TrimSpace(body.Password) != "" {
 		hash, err := mj3gc.HashPassword(body.Password)
 		if err != nil {
@@ -118,16 +245,37 @@ func (h *Handler) UpsertMJ3GCUser(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "password required for new user"})
 		return
 	}
+	isNew := user.CreatedAt.IsZero()
+	if isNew && strings.TrimSpace(body.Referral) != "" {
+		referrer, ok := store.FindUserByReferralCode(body.Referral)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid referral code"})
+			return
+		}
+		user.ReferredBy = referrer.ID
+	}
 
 	updated, err := store.UpsertUser(user)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if isNew && updated.ReferredBy != "" {
+		if bonus := store.Settings().ReferralBonus; bonus > 0 {
+			if _, errGrant := store.GrantReferralBonus(updated.ReferredBy, bonus); errGrant != nil {
+				log.Debugf("mj3gc: referral bonus not granted to %s: %v", updated.ReferredBy, errGrant)
+			}
+		}
+	}
 	if err := store.Save(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
 		return
 	}
+	action := "user.update"
+	if isNew {
+		action = "user.create"
+	}
+	recordMJ3GCAudit(c, store, action, updated.ID, mj3gcChangeReason(c, body.Reason), nil)
 	c.JSON(http.StatusOK, gin.H{"user": mj3gc.SanitizeUser(updated)})
 }
 
@@ -138,6 +286,11 @@ func (h *Handler) DeleteMJ3GCUser(c *gin.Context) {
 		return
 	}
 	store := mj3gc.DefaultStore()
+	reason := mj3gcChangeReason(c, "")
+	if !requireMJ3GCReason(c, store, reason) {
+		return
+	}
+	backupBeforeMJ3GCChange(store, "user.delete")
 	if err := store.DeleteUser(id); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -146,31 +299,80 @@ func (h *Handler) DeleteMJ3GCUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
 		return
 	}
+	recordMJ3GCAudit(c, store, "user.delete", id, reason, nil)
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// GetMJ3GCKeys lists the keys. Each tag query parameter ("team:research", or "team"
+// for any value) narrows the list to keys carrying that tag.
 func (h *Handler) GetMJ3GCKeys(c *gin.Context) {
 	store := mj3gc.DefaultStore()
-	keys := store.ListAPIKeys()
-	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+	var filters []mj3gc.TagFilter
+	for _, raw := range c.QueryArray("tag") {
+		filter := mj3gc.ParseTagFilter(raw)
+		if filter.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid tag filter"})
+			return
+		}
+		filters = append(filters, filter)
+	}
+	keys := mj3gc.FilterKeysByTags(store.ListAPIKeys(), filters)
+	if include, _ := strconv.ParseBool(c.Query("include_archived")); include {
+		archived := mj3gc.FilterKeysByTags(store.ListArchivedAPIKeys(), filters)
+		c.JSON(http.StatusOK, gin.H{"api_keys": h.visibleKeys(c, keys), "archived_keys": h.visibleKeys(c, archived)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"api_keys": h.visibleKeys(c, keys)})
 }
 
-func (h *Handler) UpsertMJ3GCKey(c *gin.Context) {
+// UpsertMJ3GCKey creates the key or updates the one with the id in the body.
+func (h *Handler) UpsertMJ3GCKey(c *gin.Context) { h.writeMJ3GCKey(c, mj3gcUpsert) }
+
+// CreateMJ3GCKey creates a key and answers 409 when the id in the body is taken.
+func (h *Handler) CreateMJ3GCKey(c *gin.Context) { h.writeMJ3GCKey(c, mj3gcCreateOnly) }
+
+// UpdateMJ3GCKey updates the key with the id in the path and answers 404 when it does
+// not exist.
+func (h *Handler) UpdateMJ3GCKey(c *gin.Context) { h.writeMJ3GCKey(c, mj3gcUpdateOnly) }
+
+func (h *Handler) writeMJ3GCKey(c *gin.Context, mode mj3gcWriteMode) {
 	var body mj3gcKeyRequest
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+	if !bindJSON(c, &body) {
 		return
 	}
+	if problems := validateMJ3GCKeyRequest(mj3gc.DefaultStore().Settings(), body); len(problems) > 0 {
+		respondInvalidBody(c, problems...)
+		return
+	}
+	if id := strings.TrimSpace(c.Param("id")); id != "" {
+		body.ID = id
+	}
 	store := mj3gc.DefaultStore()
-	var key mj3gc.APIKey
+	var (
+		key      mj3gc.APIKey
+		previous mj3gc.APIKey
+		existed  bool
+	)
 	if strings.TrimSpace(body.ID) != "" {
 		if existing, ok := store.FindAPIKeyByID(strings.TrimSpace(body.ID)); ok {
 			key = existing
+			previous = existing
+			existed = true
 		}
 		key.ID = strings.TrimSpace(body.ID)
 	}
+	if !checkMJ3GCWriteMode(c, mode, "api key", existed) {
+		return
+	}
 	if body.Key != nil {
-		key.Key = strings.TrimSpace(*body.Key)
+		value := strings.TrimSpace(*body.Key)
+		if value != "" && value != key.Key {
+			if err := store.CheckKeySecret(value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		key.Key = value
 	}
 	if body.Label != nil {
 		key.Label = strings.TrimSpace(*body.Label)
@@ -178,6 +380,9 @@ func (h *Handler) UpsertMJ3GCKey(c *gin.Context) {
 	if body.UserID != nil {
 		key.UserID = strings.TrimSpace(*body.UserID)
 	}
+	if body.ParentID != nil {
+		key.ParentID = strings.TrimSpace(*body.ParentID)
+	}
 	if body.Enabled != nil {
 		key.Enabled = *body.Enabled
 	} else if key.ID == "" {
@@ -189,15 +394,185 @@ func (h *Handler) UpsertMJ3GCKey(c *gin.Context) {
 			key.TotalLimit = 0
 		}
 	}
+	if body.TokenLimit != nil {
+		key.TokenLimit = *body.TokenLimit
+		if key.TokenLimit < 0 {
+			key.TokenLimit = 0
+		}
+	}
+	if body.BudgetUSD != nil {
+		key.BudgetUSD = *body.BudgetUSD
+		if key.BudgetUSD < 0 {
+			key.BudgetUSD = 0
+		}
+	}
 	if body.ConcurrencyLimit != nil {
 		key.ConcurrencyLimit = *body.ConcurrencyLimit
 		if key.ConcurrencyLimit < 0 {
 			key.ConcurrencyLimit = 0
 		}
 	}
+	if body.RPMLimit != nil {
+		key.RPMLimit = *body.RPMLimit
+		if key.RPMLimit < 0 {
+			key.RPMLimit = 0
+		}
+	}
+	if body.Schedule != nil {
+		schedule, err := body.Schedule.Normalize()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		// An empty schedule object removes the schedule.
+		key.Schedule = nil
+		if schedule.Start != "" || len(schedule.Days) > 0 {
+			key.Schedule = &schedule
+		}
+	}
+	if body.MaxOutputTokens != nil {
+		key.MaxOutputTokens = *body.MaxOutputTokens
+		if key.MaxOutputTokens < 0 {
+			key.MaxOutputTokens = 0
+		}
+	}
+	if body.Streaming != nil {
+		policy, err := mj3gc.NormalizeStreamingPolicy(*body.Streaming)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.Streaming = policy
+	}
+	if body.MaxBodyBytes != nil {
+		key.MaxBodyBytes = *body.MaxBodyBytes
+		if key.MaxBodyBytes < 0 {
+			key.MaxBodyBytes = 0
+		}
+	}
 	if body.CompatibilityMode != nil {
 		key.CompatibilityMode = *body.CompatibilityMode
 	}
+	if body.ShadowURL != nil {
+		if err := mj3gc.ValidateShadowURL(*body.ShadowURL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.ShadowURL = strings.TrimSpace(*body.ShadowURL)
+	}
+	if body.AllowedUserAgents != nil {
+		key.AllowedUserAgents = mj3gc.NormalizePatterns(*body.AllowedUserAgents)
+	}
+	if body.AllowedIPs != nil {
+		ips, err := mj3gc.NormalizeCIDRs(*body.AllowedIPs)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.AllowedIPs = ips
+	}
+	if body.AllowedOrigins != nil {
+		key.AllowedOrigins = mj3gc.NormalizePatterns(*body.AllowedOrigins)
+	}
+	if body.AllowedModels != nil {
+		key.AllowedModels = mj3gc.NormalizePatterns(*body.AllowedModels)
+	}
+	if body.DeniedModels != nil {
+		key.DeniedModels = mj3gc.NormalizePatterns(*body.DeniedModels)
+	}
+	if body.ModelAliases != nil {
+		aliases, err := mj3gc.NormalizeModelAliases(*body.ModelAliases)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.ModelAliases = aliases
+	}
+	if body.Priority != nil {
+		key.Priority = strings.TrimSpace(*body.Priority)
+	}
+	if body.SunsetAt != nil {
+		var at time.Time
+		if raw := strings.TrimSpace(*body.SunsetAt); raw != "" {
+			parsed, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				respondInvalidBody(c, fieldError{Field: "sunset_at", Reason: "invalid timestamp", Got: raw, Expected: "an RFC 3339 timestamp, or \"\" to cancel the sunset"})
+				return
+			}
+			at = parsed
+		}
+		key = key.Sunset(at, time.Now())
+	}
+	if body.Features != nil {
+		features, err := mj3gc.NormalizeFeatures(*body.Features)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.Features = features
+	}
+	if body.Scopes != nil {
+		scopes, err := mj3gc.NormalizeScopes(*body.Scopes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.Scopes = scopes
+	}
+	if body.Tags != nil {
+		tags, err := mj3gc.NormalizeTags(*body.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.Tags = tags
+	}
+	if body.CountPolicy != nil {
+		policy, err := mj3gc.NormalizeCountPolicy(*body.CountPolicy)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.CountPolicy = policy
+	}
+	if body.Residency != nil {
+		key.Residency = strings.ToLower(strings.TrimSpace(*body.Residency))
+	}
+	if body.Pool != nil {
+		key.Pool = mj3gc.NormalizePool(*body.Pool)
+	}
+	if body.PinnedAuths != nil {
+		pins, err := mj3gc.NormalizePins(*body.PinnedAuths, false)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.PinnedAuths = pins
+	}
+	if body.PinnedProviders != nil {
+		pins, err := mj3gc.NormalizePins(*body.PinnedProviders, true)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.PinnedProviders = pins
+	}
+	if body.ResetInterval != nil {
+		interval, err := mj3gc.NormalizeResetInterval(*body.ResetInterval)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		key.ResetInterval = interval
+	}
+	if body.AlertThresholds != nil {
+		thresholds, err := mj3gc.NormalizeAlertThresholds(*body.AlertThresholds)
+		if err != nil {
+			respondInvalidBody(c, fieldError{Field: "alert_thresholds", Reason: "out of range", Got: *body.AlertThresholds, Expected: "percentages from 1 to 100"})
+			return
+		}
+		key.AlertThresholds = thresholds
+	}
 	if body.ResetUsage {
 		key.UsedCount = 0
 	}
@@ -209,16 +584,54 @@ func (h *Handler) UpsertMJ3GCKey(c *gin.Context) {
 		}
 		key.Key = generated
 	}
+	reason := mj3gcChangeReason(c, body.Reason)
+	if existed && mj3gcKeyChangeIsDestructive(previous, key) {
+		if !requireMJ3GCReason(c, store, reason) {
+			return
+		}
+		backupBeforeMJ3GCChange(store, "key.update")
+	}
 
 	updated, err := store.UpsertAPIKey(key)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		status := http.StatusBadRequest
+		if errors.Is(err, mj3gc.ErrKeyLimitReached) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
+	if body.ResetUsage {
+		if updated, err = store.ResetUsage(updated.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
 	if err := store.Save(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
 		return
 	}
+	action := "key.create"
+	var details map[string]any
+	if existed {
+		action = "key.update"
+		details = map[string]any{
+			"rotated":           previous.Key != updated.Key,
+			"total_limit":       []int64{previous.TotalLimit, updated.TotalLimit},
+			"token_limit":       []int64{previous.TokenLimit, updated.TokenLimit},
+			"budget_usd":        []float64{previous.BudgetUSD, updated.BudgetUSD},
+			"concurrency_limit": []int{previous.ConcurrencyLimit, updated.ConcurrencyLimit},
+			"rpm_limit":         []int{previous.RPMLimit, updated.RPMLimit},
+		}
+		if !previous.SunsetAt.Equal(updated.SunsetAt) {
+			details["sunset_at"] = []string{mj3gc.FormatTimestamp(previous.SunsetAt), mj3gc.FormatTimestamp(updated.SunsetAt)}
+		}
+	}
+	recordMJ3GCAudit(c, store, action, updated.ID, reason, details)
+	// A secret set or generated by this request is shown once to its author.
+	if existed && previous.Key == updated.Key {
+		updated = h.visibleKey(c, updated)
+	}
 	c.JSON(http.StatusOK, gin.H{"api_key": updated})
 }
 
@@ -229,6 +642,11 @@ func (h *Handler) DeleteMJ3GCKey(c *gin.Context) {
 		return
 	}
 	store := mj3gc.DefaultStore()
+	reason := mj3gcChangeReason(c, "")
+	if !requireMJ3GCReason(c, store, reason) {
+		return
+	}
+	backupBeforeMJ3GCChange(store, "key.delete")
 	if err := store.DeleteAPIKey(id); err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
@@ -237,9 +655,116 @@ func (h *Handler) DeleteMJ3GCKey(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
 		return
 	}
+	recordMJ3GCAudit(c, store, "key.delete", id, reason, nil)
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
 }
 
+// RotateMJ3GCKey replaces the secret of a key with a generated one, keeping its
+// settings and usage. With grace_period_seconds the old secret keeps working for that
+// long. Both secrets are returned once to the caller.
+func (h *Handler) RotateMJ3GCKey(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+	var body struct {
+		GracePeriodSeconds int64  `json:"grace_period_seconds"`
+		Reason             string `json:"reason"`
+	}
+	if c.Request.ContentLength != 0 {
+		if !bindJSON(c, &body) {
+			return
+		}
+	}
+	store := mj3gc.DefaultStore()
+	if _, ok := store.FindAPIKeyByID(id); !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+		return
+	}
+	reason := mj3gcChangeReason(c, body.Reason)
+	if !requireMJ3GCReason(c, store, reason) {
+		return
+	}
+	grace := time.Duration(body.GracePeriodSeconds) * time.Second
+	if grace < 0 || grace > mj3gc.MaxRotationGrace {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid grace_period_seconds"})
+		return
+	}
+	backupBeforeMJ3GCChange(store, "key.rotate")
+	updated, previous, err := store.RotateAPIKey(id, grace)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	details := map[string]any{"grace_period_seconds": body.GracePeriodSeconds}
+	recordMJ3GCAudit(c, store, "key.rotate", id, reason, details)
+	resp := gin.H{"api_key": updated, "previous_key": previous}
+	if !updated.PreviousKeyExpiresAt.IsZero() {
+		resp["previous_key_expires_at"] = updated.PreviousKeyExpiresAt
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// TransferMJ3GCKeyQuota moves remaining request quota from the key in the path to
+// another key of the same user. Without requests everything that remains is moved.
+func (h *Handler) TransferMJ3GCKeyQuota(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+	var body struct {
+		ToKeyID  string `json:"to_key_id"`
+		Requests int64  `json:"requests"`
+		Reason   string `json:"reason"`
+	}
+	if !bindJSON(c, &body) {
+		return
+	}
+	var problems []fieldError
+	if strings.TrimSpace(body.ToKeyID) == "" {
+		problems = append(problems, requiredField("to_key_id", "string"))
+	}
+	if body.Requests < 0 {
+		problems = append(problems, fieldError{Field: "requests", Reason: "negative", Got: body.Requests, Expected: "0 or more"})
+	}
+	if len(problems) > 0 {
+		respondInvalidBody(c, problems...)
+		return
+	}
+	store := mj3gc.DefaultStore()
+	reason := mj3gcChangeReason(c, body.Reason)
+	if !requireMJ3GCReason(c, store, reason) {
+		return
+	}
+	backupBeforeMJ3GCChange(store, "key.transfer_quota")
+	transfer, err := store.TransferQuota(id, strings.TrimSpace(body.ToKeyID), body.Requests)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, mj3gc.ErrKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "key.transfer_quota", id, reason, map[string]any{
+		"to":       transfer.To.ID,
+		"requests": transfer.Requests,
+	})
+	transfer.From = h.visibleKey(c, transfer.From)
+	transfer.To = h.visibleKey(c, transfer.To)
+	c.JSON(http.StatusOK, transfer)
+}
+
 func (h *Handler) ResetMJ3GCKeyUsage(c *gin.Context) {
 	id := strings.TrimSpace(c.Param("id"))
 	if id == "" {
@@ -252,8 +777,9 @@ func (h *Handler) ResetMJ3GCKeyUsage(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
 		return
 	}
-	key.UsedCount = 0
-	updated, err := store.UpsertAPIKey(key)
+	previousUsed := key.UsedCount
+	backupBeforeMJ3GCChange(store, "key.reset_usage")
+	updated, err := store.ResetUsage(key.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -262,7 +788,8 @@ func (h *Handler) ResetMJ3GCKeyUsage(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"api_key": updated})
+	recordMJ3GCAudit(c, store, "key.reset_usage", id, mj3gcChangeReason(c, ""), map[string]any{"used_count": previousUsed})
+	c.JSON(http.StatusOK, gin.H{"api_key": h.visibleKey(c, updated)})
 }
 
 func (h *Handler) GetMJ3GCUsage(c *gin.Context) {
@@ -274,11 +801,42 @@ func (h *Handler) GetMJ3GCUsage(c *gin.Context) {
 	}
 	out := make([]mj3gcKeyUsage, 0, len(keys))
 	for _, key := range keys {
-		out = append(out, buildKeyUsage(key, usageSnapshot))
+		entry := buildKeyUsage(store, key, usageSnapshot)
+		entry.Key = h.visibleKey(c, key).Key
+		out = append(out, entry)
+	}
+	if !h.canRevealSecrets(c) {
+		usageSnapshot = redactUsageSnapshot(usageSnapshot)
 	}
 	c.JSON(http.StatusOK, gin.H{
 		"keys":  out,
 		"stats": usageSnapshot,
+		"units": mj3gc.Units(store.Settings()),
+	})
+}
+
+func (h *Handler) GetMJ3GCReferrals(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	summaries := store.ReferralSummaries()
+	usageSnapshot := usage.StatisticsSnapshot{}
+	if h.usageStats != nil {
+		usageSnapshot = h.usageStats.Snapshot()
+	}
+	out := make([]mj3gcReferralUsage, 0, len(summaries))
+	for _, summary := range summaries {
+		entry := mj3gcReferralUsage{ReferralSummary: summary}
+		for _, userID := range summary.ReferredUserIDs {
+			for _, key := range store.ListAPIKeysByUser(userID) {
+				stats := usageSnapshot.APIs[key.Key]
+				entry.TotalRequest += stats.TotalRequests
+				entry.TotalTokens += stats.TotalTokens
+			}
+		}
+		out = append(out, entry)
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"referrals": out,
+		"bonus":     store.Settings().ReferralBonus,
 	})
 }
 
@@ -295,6 +853,56 @@ func (h *Handler) GetMJ3GCPortalMe(c *gin.Context) {
 	})
 }
 
+// CreateMJ3GCPortalKey lets a portal user create another key for themselves. The key
+// shares the quota of the calling key and counts against the user's key limit; its
+// secret is only shown in this response.
+func (h *Handler) CreateMJ3GCPortalKey(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	if ctx.Key == nil || ctx.User.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is not assigned to a user"})
+		return
+	}
+	var body struct {
+		Label *string `json:"label"`
+	}
+	if c.Request.ContentLength != 0 {
+		if !bindJSON(c, &body) {
+			return
+		}
+	}
+	store := mj3gc.DefaultStore()
+	if problems := validateMJ3GCKeyRequest(store.Settings(), mj3gcKeyRequest{Label: body.Label}); len(problems) > 0 {
+		respondInvalidBody(c, problems...)
+		return
+	}
+	label := ""
+	if body.Label != nil {
+		label = *body.Label
+	}
+	created, err := store.CreatePortalKey(*ctx.Key, label)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, mj3gc.ErrKeyLimitReached) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "key.create", created.ID, "", map[string]any{
+		"user_id":   created.UserID,
+		"parent_id": created.ParentID,
+		"portal":    true,
+	})
+	c.JSON(http.StatusCreated, gin.H{"api_key": created})
+}
+
 func (h *Handler) GetMJ3GCPortalUsage(c *gin.Context) {
 	ctx, ok := getPortalContext(c)
 	if !ok {
@@ -302,15 +910,41 @@ func (h *Handler) GetMJ3GCPortalUsage(c *gin.Context) {
 	}
 	store := mj3gc.DefaultStore()
 	keys := portalKeys(ctx, store)
-	usageSnapshot := usage.StatisticsSnapshot{}
-	if h.usageStats != nil {
-		usageSnapshot = h.usageStats.Snapshot()
+	ttl := mj3gc.PortalUsageCacheTTL(store.Settings())
+	var usageSnapshot *usage.StatisticsSnapshot
+	snapshot := func() usage.StatisticsSnapshot {
+		if usageSnapshot == nil {
+			usageSnapshot = &usage.StatisticsSnapshot{}
+			if h.usageStats != nil {
+				*usageSnapshot = h.usageStats.Snapshot()
+			}
+		}
+		return *usageSnapshot
 	}
+	now := time.Now()
 	out := make([]mj3gcKeyUsage, 0, len(keys))
 	for _, key := range keys {
-		out = append(out, buildKeyUsage(key, usageSnapshot))
+		if !key.HasFeature(mj3gc.FeatureExactUsage) {
+			if cached, ok := h.portalUsage.get(key.ID, now, ttl); ok {
+				out = append(out, cached)
+				continue
+			}
+			entry := buildKeyUsage(store, key, snapshot())
+			entry.Consistency, entry.AsOf = "cached", mj3gc.FormatTimestamp(now)
+			h.portalUsage.put(key.ID, entry, now, ttl)
+			out = append(out, entry)
+			continue
+		}
+		fresh, err := store.FreshUsage(key)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "exact usage is unavailable: " + err.Error()})
+			return
+		}
+		entry := buildKeyUsage(store, fresh, snapshot())
+		entry.Consistency, entry.AsOf = "exact", mj3gc.FormatTimestamp(now)
+		out = append(out, entry)
 	}
-	c.JSON(http.StatusOK, gin.H{"keys": out})
+	c.JSON(http.StatusOK, gin.H{"keys": out, "units": mj3gc.Units(store.Settings())})
 }
 
 func (h *Handler) GetMJ3GCPortalLogs(c *gin.Context) {
@@ -324,17 +958,144 @@ func (h *Handler) GetMJ3GCPortalLogs(c *gin.Context) {
 	if h.usageStats != nil {
 		usageSnapshot = h.usageStats.Snapshot()
 	}
+	query, err := mj3gc.ParseQuery(c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	since := parseSince(c.Query("since"))
 	limit := parsePortalLimit(c.Query("limit"))
 	items := make([]mj3gcLogEntry, 0, 128)
 	for _, key := range keys {
-		items = append(items, collectLogsForKey(key, usageSnapshot, since)...)
+		for _, entry := range collectLogsForKey(key, usageSnapshot, since) {
+			if query.Match(logQueryFields(key, entry)) {
+				items = append(items, entry)
+			}
+		}
 	}
 	sort.Slice(items, func(i, j int) bool { return items[i].Timestamp > items[j].Timestamp })
 	if limit > 0 && len(items) > limit {
 		items = items[:limit]
 	}
-	c.JSON(http.StatusOK, gin.H{"logs": items})
+	c.JSON(http.StatusOK, gin.H{"logs": items, "units": mj3gc.Units(store.Settings())})
+}
+
+// GetMJ3GCPortalAnalytics returns prompt-size distributions and the most expensive
+// requests (?top=, default 10) for each of the caller's keys.
+func (h *Handler) GetMJ3GCPortalAnalytics(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	store := mj3gc.DefaultStore()
+	keys := portalKeys(ctx, store)
+	usageSnapshot := usage.StatisticsSnapshot{}
+	if h.usageStats != nil {
+		usageSnapshot = h.usageStats.Snapshot()
+	}
+	top := 10
+	if raw := strings.TrimSpace(c.Query("top")); raw != "" {
+		if value, err := strconv.Atoi(raw); err == nil && value > 0 {
+			top = value
+		}
+	}
+	since := parseSince(c.Query("since"))
+	settings := store.Settings()
+	out := make([]mj3gc.KeyAnalytics, 0, len(keys))
+	for _, key := range keys {
+		samples := requestSamples(collectLogsForKey(key, usageSnapshot, since))
+		out = append(out, mj3gc.AnalyzeRequests(settings, key, samples, top))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"keys":   out,
+		"priced": len(settings.ModelPrices) > 0,
+		"units":  mj3gc.Units(settings),
+	})
+}
+
+// GetMJ3GCModelStats returns request counts, error rates, average tokens and p50/p95
+// latencies per model across all recorded requests since ?since=.
+func (h *Handler) GetMJ3GCModelStats(c *gin.Context) {
+	usageSnapshot := usage.StatisticsSnapshot{}
+	if h.usageStats != nil {
+		usageSnapshot = h.usageStats.Snapshot()
+	}
+	since := parseSince(c.Query("since"))
+	c.JSON(http.StatusOK, gin.H{"models": mj3gc.AggregateModelStats(requestSamples(collectAllLogs(usageSnapshot, since)))})
+}
+
+// defaultModelHealthWindow is how far back GetMJ3GCPortalModelHealth looks by default.
+const defaultModelHealthWindow = 15 * time.Minute
+
+// GetMJ3GCPortalModelHealth reports, for the models the caller's keys may use, the
+// success rate and median latency of all requests since ?since= (default the last 15
+// minutes), healthiest first, so clients can fail over to a better model. Models
+// without recent requests are not listed.
+func (h *Handler) GetMJ3GCPortalModelHealth(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	keys := portalKeys(ctx, mj3gc.DefaultStore())
+	usageSnapshot := usage.StatisticsSnapshot{}
+	if h.usageStats != nil {
+		usageSnapshot = h.usageStats.Snapshot()
+	}
+	since := parseSince(c.Query("since"))
+	if since.IsZero() {
+		since = time.Now().Add(-defaultModelHealthWindow)
+	}
+	out := make([]mj3gc.ModelHealth, 0)
+	for _, stats := range mj3gc.AggregateModelStats(requestSamples(collectAllLogs(usageSnapshot, since))) {
+		for _, key := range keys {
+			if key.AllowsModel(stats.Model) {
+				out = append(out, mj3gc.HealthOf(stats))
+				break
+			}
+		}
+	}
+	mj3gc.SortModelHealth(out)
+	c.JSON(http.StatusOK, gin.H{"models": out, "since": mj3gc.FormatTimestamp(since)})
+}
+
+// collectAllLogs returns the recorded requests of every key since since.
+func collectAllLogs(snapshot usage.StatisticsSnapshot, since time.Time) []mj3gcLogEntry {
+	entries := make([]mj3gcLogEntry, 0, 128)
+	for _, stats := range snapshot.APIs {
+		for model, modelStats := range stats.Models {
+			for _, detail := range modelStats.Details {
+				if !since.IsZero() && detail.Timestamp.Before(since) {
+					continue
+				}
+				entries = append(entries, mj3gcLogEntry{
+					Timestamp: detail.Timestamp.Unix(),
+					Model:     model,
+					Failed:    detail.Failed,
+					Tokens:    detail.Tokens,
+					LatencyMs: detail.LatencyMs,
+				})
+			}
+		}
+	}
+	return entries
+}
+
+func requestSamples(entries []mj3gcLogEntry) []mj3gc.RequestSample {
+	samples := make([]mj3gc.RequestSample, 0, len(entries))
+	for _, entry := range entries {
+		samples = append(samples, mj3gc.RequestSample{
+			Timestamp:       time.Unix(entry.Timestamp, 0),
+			Model:           entry.Model,
+			Failed:          entry.Failed,
+			InputTokens:     entry.Tokens.InputTokens,
+			OutputTokens:    entry.Tokens.OutputTokens,
+			ReasoningTokens: entry.Tokens.ReasoningTokens,
+			CachedTokens:    entry.Tokens.CachedTokens,
+			TotalTokens:     entry.Tokens.TotalTokens,
+			LatencyMs:       entry.LatencyMs,
+		})
+	}
+	return samples
 }
 
 func getPortalContext(c *gin.Context) (mj3gc.PortalContext, bool) {
@@ -367,7 +1128,7 @@ func portalKeys(ctx mj3gc.PortalContext, store *mj3gc.Store) []mj3gc.APIKey {
 	return store.ListAPIKeysByUser(ctx.User.ID)
 }
 
-func buildKeyUsage(key mj3gc.APIKey, snapshot usage.StatisticsSnapshot) mj3gcKeyUsage {
+func buildKeyUsage(store *mj3gc.Store, key mj3gc.APIKey, snapshot usage.StatisticsSnapshot) mj3gcKeyUsage {
 	remaining := int64(0)
 	if key.TotalLimit > 0 {
 		remaining = key.TotalLimit - key.UsedCount
@@ -376,6 +1137,11 @@ func buildKeyUsage(key mj3gc.APIKey, snapshot usage.StatisticsSnapshot) mj3gcKey
 		}
 	}
 	stats := snapshot.APIs[key.Key]
+	lastUsedAt := ""
+	if !key.LastUsedAt.IsZero() {
+		lastUsedAt = mj3gc.FormatTimestamp(key.LastUsedAt)
+	}
+	sunsetIn, _ := key.SunsetRemaining(time.Now())
 	return mj3gcKeyUsage{
 		ID:           key.ID,
 		Key:          key.Key,
@@ -384,10 +1150,22 @@ func buildKeyUsage(key mj3gc.APIKey, snapshot usage.StatisticsSnapshot) mj3gcKey
 		TotalLimit:   key.TotalLimit,
 		UsedCount:    key.UsedCount,
 		Remaining:    remaining,
+		TokenLimit:   key.TokenLimit,
+		UsedTokens:   key.UsedTokens,
+		BudgetUSD:    key.BudgetUSD,
+		SpentUSD:     key.SpentUSD,
 		Concurrency:  key.ConcurrencyLimit,
 		CompatMode:   key.CompatibilityMode,
 		TotalRequest: stats.TotalRequests,
 		TotalTokens:  stats.TotalTokens,
+		Aborted:      store.AbortedRequests(key.ID),
+		Timeouts:     store.TimeoutRequests(key.ID),
+		LastUsedAt:   lastUsedAt,
+		LastUsedIP:   key.LastUsedIP,
+		ResetPeriod:  key.ResetInterval,
+		ResetsAt:     mj3gc.FormatTimestamp(key.ResetsAt()),
+		SunsetAt:     mj3gc.FormatTimestamp(key.SunsetAt),
+		SunsetIn:     int64(sunsetIn.Seconds()),
 	}
 }
 
@@ -403,16 +1181,34 @@ func collectLogsForKey(key mj3gc.APIKey, snapshot usage.StatisticsSnapshot, sinc
 				continue
 			}
 			out = append(out, mj3gcLogEntry{
-				Timestamp: detail.Timestamp.Unix(),
-				Model:     model,
-				Failed:    detail.Failed,
-				Tokens:    detail.Tokens,
+				Timestamp:    detail.Timestamp.Unix(),
+				TimestampISO: mj3gc.FormatTimestamp(detail.Timestamp),
+				Model:        model,
+				Failed:       detail.Failed,
+				Tokens:       detail.Tokens,
+				LatencyMs:    detail.LatencyMs,
 			})
 		}
 	}
 	return out
 }
 
+// logQueryFields exposes a log entry and its key to mj3gc query expressions.
+func logQueryFields(key mj3gc.APIKey, entry mj3gcLogEntry) mj3gc.Fields {
+	return mj3gc.Fields{
+		"timestamp":        strconv.FormatInt(entry.Timestamp, 10),
+		"model":            entry.Model,
+		"failed":           strconv.FormatBool(entry.Failed),
+		"key_id":           key.ID,
+		"label":            key.Label,
+		"input_tokens":     strconv.FormatInt(entry.Tokens.InputTokens, 10),
+		"output_tokens":    strconv.FormatInt(entry.Tokens.OutputTokens, 10),
+		"reasoning_tokens": strconv.FormatInt(entry.Tokens.ReasoningTokens, 10),
+		"cached_tokens":    strconv.FormatInt(entry.Tokens.CachedTokens, 10),
+		"total_tokens":     strconv.FormatInt(entry.Tokens.TotalTokens, 10),
+	}
+}
+
 func parseSince(raw string) time.Time {
 	raw = strings.TrimSpace(raw)
 	if raw == "" {
@@ -439,3 +1235,24 @@ func parsePortalLimit(raw string) int {
 	}
 	return value
 }
+
+func (h *Handler) GetMJ3GCLockStats(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	c.JSON(http.StatusOK, store.LockReport())
+}
+
+func (h *Handler) GetMJ3GCKeyMetrics(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	metrics, since := store.KeyMetrics()
+	c.JSON(http.StatusOK, gin.H{"since": since, "keys": metrics})
+}
+
+func (h *Handler) GetMJ3GCAnonymousUsage(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	c.JSON(http.StatusOK, store.AnonymousUsage())
+}
+
+func (h *Handler) GetMJ3GCLoad(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	c.JSON(http.StatusOK, store.LoadStatus())
+}
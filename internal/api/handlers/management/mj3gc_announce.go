@@ -0,0 +1,126 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+type mj3gcAnnouncementRequest struct {
+	Title     string     `json:"title"`
+	Body      string     `json:"body"`
+	Pinned    bool       `json:"pinned"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	UserIDs   []string   `json:"user_ids"`
+}
+
+// GetMJ3GCAnnouncements lists announcements with their channel delivery status and
+// per-user delivery and read times.
+func (h *Handler) GetMJ3GCAnnouncements(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"announcements": mj3gc.DefaultStore().ListAnnouncements()})
+}
+
+// PostMJ3GCAnnouncement sends an announcement to all users, or to user_ids when given.
+func (h *Handler) PostMJ3GCAnnouncement(c *gin.Context) {
+	var body mj3gcAnnouncementRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+	store := mj3gc.DefaultStore()
+	announcement := mj3gc.Announcement{Title: body.Title, Body: body.Body, Pinned: body.Pinned}
+	if body.ExpiresAt != nil {
+		announcement.ExpiresAt = body.ExpiresAt.UTC()
+	}
+	for _, id := range body.UserIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if _, ok := store.FindUserByID(id); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown user " + id})
+			return
+		}
+		announcement.UserIDs = append(announcement.UserIDs, id)
+	}
+	announcement, err := store.CreateAnnouncement(announcement)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "announcement.create", announcement.ID, mj3gcChangeReason(c, ""), map[string]any{
+		"title":    announcement.Title,
+		"pinned":   announcement.Pinned,
+		"user_ids": announcement.UserIDs,
+	})
+	c.JSON(http.StatusCreated, gin.H{"announcement": announcement})
+}
+
+// DeleteMJ3GCAnnouncement removes an announcement from every portal feed.
+func (h *Handler) DeleteMJ3GCAnnouncement(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	store := mj3gc.DefaultStore()
+	if err := store.DeleteAnnouncement(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "announcement.delete", id, mj3gcChangeReason(c, ""), nil)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// GetMJ3GCPortalNotifications returns the caller's notification feed and marks the
+// announcements in it as delivered.
+func (h *Handler) GetMJ3GCPortalNotifications(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	store := mj3gc.DefaultStore()
+	items, changed := store.AnnouncementsForUser(ctx.User.ID)
+	if changed {
+		if err := store.Save(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+			return
+		}
+	}
+	unread := 0
+	for _, item := range items {
+		if !item.Read {
+			unread++
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"notifications": items, "unread": unread})
+}
+
+// MarkMJ3GCPortalNotificationRead marks one announcement read for the caller.
+func (h *Handler) MarkMJ3GCPortalNotificationRead(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	store := mj3gc.DefaultStore()
+	if err := store.MarkAnnouncementRead(strings.TrimSpace(c.Param("id")), ctx.User.ID); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, mj3gc.ErrAnnouncementNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,79 @@
+package management
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// GetMJ3GCArchivedKeys lists archived keys, or a single one when ?id= is given.
+func (h *Handler) GetMJ3GCArchivedKeys(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	if id := strings.TrimSpace(c.Query("id")); id != "" {
+		key, ok := store.FindArchivedAPIKeyByID(id)
+		if !ok {
+			c.JSON(http.StatusNotFound, gin.H{"error": "api key not found"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"api_key": h.visibleKey(c, key)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"archived_keys": h.visibleKeys(c, store.ListArchivedAPIKeys())})
+}
+
+// ArchiveMJ3GCKeys archives disabled keys immediately. ?days= overrides
+// mj3gc.archive-disabled-after-days.
+func (h *Handler) ArchiveMJ3GCKeys(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	days := store.Settings().ArchiveDisabledAfterDays
+	if raw := strings.TrimSpace(c.Query("days")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid days"})
+			return
+		}
+		days = parsed
+	}
+	if days <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archiving is disabled; pass days"})
+		return
+	}
+	archived := store.ArchiveDisabledKeys(time.Duration(days) * 24 * time.Hour)
+	if len(archived) > 0 {
+		if err := store.Save(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+			return
+		}
+		ids := make([]string, 0, len(archived))
+		for _, key := range archived {
+			ids = append(ids, key.ID)
+		}
+		recordMJ3GCAudit(c, store, "key.archive", strings.Join(ids, ","), mj3gcChangeReason(c, ""), map[string]any{"days": days})
+	}
+	c.JSON(http.StatusOK, gin.H{"archived_keys": h.visibleKeys(c, archived)})
+}
+
+// RestoreMJ3GCArchivedKey moves an archived key back to the active set, still disabled.
+func (h *Handler) RestoreMJ3GCArchivedKey(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+	store := mj3gc.DefaultStore()
+	key, err := store.RestoreArchivedKey(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "key.restore", id, mj3gcChangeReason(c, ""), nil)
+	c.JSON(http.StatusOK, gin.H{"api_key": h.visibleKey(c, key)})
+}
@@ -0,0 +1,87 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// mj3gcChangeReason resolves the change reason from the request body, the reason query
+// parameter or the X-Change-Reason header, in that order.
+func mj3gcChangeReason(c *gin.Context, bodyReason string) string {
+	if reason := strings.TrimSpace(bodyReason); reason != "" {
+		return reason
+	}
+	if reason := strings.TrimSpace(c.Query("reason")); reason != "" {
+		return reason
+	}
+	return strings.TrimSpace(c.GetHeader("X-Change-Reason"))
+}
+
+// requireMJ3GCReason writes a 400 response and returns false when strict mode is enabled
+// and a destructive operation arrives without a reason.
+func requireMJ3GCReason(c *gin.Context, store *mj3gc.Store, reason string) bool {
+	if reason != "" || !store.Settings().RequireChangeReason {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "reason required for this change"})
+	return false
+}
+
+func recordMJ3GCAudit(c *gin.Context, store *mj3gc.Store, action, target, reason string, details map[string]any) {
+	store.RecordAudit(mj3gc.AuditEntry{
+		Actor:   adminActor(c),
+		Action:  action,
+		Target:  target,
+		Reason:  reason,
+		Details: details,
+	})
+}
+
+// mj3gcKeyChangeIsDestructive reports whether updating previous to next replaces the
+// secret or lowers any limit.
+func mj3gcKeyChangeIsDestructive(previous, next mj3gc.APIKey) bool {
+	if previous.Key != next.Key {
+		return true
+	}
+	return limitReduced(previous.TotalLimit, next.TotalLimit) ||
+		limitReduced(previous.TokenLimit, next.TokenLimit) ||
+		budgetReduced(previous.BudgetUSD, next.BudgetUSD) ||
+		limitReduced(int64(previous.ConcurrencyLimit), int64(next.ConcurrencyLimit)) ||
+		limitReduced(int64(previous.RPMLimit), int64(next.RPMLimit))
+}
+
+// budgetReduced is limitReduced for monetary budgets.
+func budgetReduced(previous, next float64) bool {
+	if next == 0 {
+		return false
+	}
+	return previous == 0 || next < previous
+}
+
+// limitReduced treats zero as unlimited.
+func limitReduced(previous, next int64) bool {
+	if next == 0 {
+		return false
+	}
+	return previous == 0 || next < previous
+}
+
+func (h *Handler) GetMJ3GCAudit(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	query, err := mj3gc.ParseQuery(c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter := mj3gc.AuditFilter{
+		Action: strings.TrimSpace(c.Query("action")),
+		Target: strings.TrimSpace(c.Query("target")),
+		Since:  parseSince(c.Query("since")),
+		Query:  query,
+		Limit:  parsePortalLimit(c.Query("limit")),
+	}
+	c.JSON(http.StatusOK, gin.H{"entries": store.AuditEntries(filter)})
+}
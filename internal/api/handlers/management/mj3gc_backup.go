@@ -0,0 +1,72 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+	log "github.com/sirupsen/logrus"
+)
+
+type mj3gcRestoreRequest struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// backupBeforeMJ3GCChange snapshots the store ahead of a destructive change. Failures
+// are logged rather than blocking the change.
+func backupBeforeMJ3GCChange(store *mj3gc.Store, action string) {
+	if _, err := store.CreateBackup("pre-" + action); err != nil {
+		log.Warnf("mj3gc: backup before %s failed: %v", action, err)
+	}
+}
+
+func (h *Handler) GetMJ3GCBackups(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	backups, err := store.ListBackups()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"backups": backups})
+}
+
+func (h *Handler) CreateMJ3GCBackup(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	backup, err := store.CreateBackup("manual")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordMJ3GCAudit(c, store, "backup.create", backup.Name, mj3gcChangeReason(c, ""), nil)
+	c.JSON(http.StatusOK, gin.H{"backup": backup})
+}
+
+func (h *Handler) RestoreMJ3GCBackup(c *gin.Context) {
+	var body mj3gcRestoreRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+	name := strings.TrimSpace(body.Name)
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing name"})
+		return
+	}
+	store := mj3gc.DefaultStore()
+	reason := mj3gcChangeReason(c, body.Reason)
+	if !requireMJ3GCReason(c, store, reason) {
+		return
+	}
+	if err := store.RestoreBackup(name); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, mj3gc.ErrBackupNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	recordMJ3GCAudit(c, store, "backup.restore", name, reason, nil)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
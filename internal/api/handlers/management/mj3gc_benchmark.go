@@ -0,0 +1,36 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+const defaultMJ3GCBenchmarkDuration = 2 * time.Second
+
+// RunMJ3GCBenchmark measures quota path throughput on a copy of the live keys for
+// ?duration= (a Go duration, default 2s, at most 30s) per operation.
+func (h *Handler) RunMJ3GCBenchmark(c *gin.Context) {
+	duration := defaultMJ3GCBenchmarkDuration
+	if raw := strings.TrimSpace(c.Query("duration")); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid duration"})
+			return
+		}
+		if parsed > mj3gc.MaxBenchmarkDuration {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duration exceeds " + mj3gc.MaxBenchmarkDuration.String()})
+			return
+		}
+		duration = parsed
+	}
+	results := mj3gc.DefaultStore().Benchmark(duration)
+	if len(results) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "no api keys to benchmark"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
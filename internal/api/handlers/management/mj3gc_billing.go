@@ -0,0 +1,144 @@
+package management
+
+import (
+	"encoding/csv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+type mj3gcBillingRow struct {
+	UserID   string            `json:"user_id"`
+	Username string            `json:"username"`
+	Billing  mj3gc.BillingInfo `json:"billing"`
+	Keys     int               `json:"keys"`
+	Requests int64             `json:"requests"`
+	Tokens   int64             `json:"tokens"`
+	Cost     float64           `json:"cost"`
+	Limits   []mj3gcBillingKey `json:"limits"`
+}
+
+// mj3gcBillingKey is a key of a billing row with the limits it had at the end of the
+// reported period.
+type mj3gcBillingKey struct {
+	KeyID string `json:"key_id"`
+	Label string `json:"label"`
+	mj3gc.KeyLimits
+}
+
+func (h *Handler) GetMJ3GCPortalBilling(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	billing := mj3gc.BillingInfo{}
+	if ctx.User.Billing != nil {
+		billing = *ctx.User.Billing
+	}
+	c.JSON(http.StatusOK, gin.H{"billing": billing})
+}
+
+func (h *Handler) UpdateMJ3GCPortalBilling(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	if ctx.User.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is not assigned to a user"})
+		return
+	}
+	var body mj3gc.BillingInfo
+	if !bindJSON(c, &body) {
+		return
+	}
+	store := mj3gc.DefaultStore()
+	updated, err := store.UpdateUserBilling(ctx.User.ID, body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "user.billing", updated.ID, "", nil)
+	billing := mj3gc.BillingInfo{}
+	if updated.Billing != nil {
+		billing = *updated.Billing
+	}
+	c.JSON(http.StatusOK, gin.H{"billing": billing})
+}
+
+// GetMJ3GCBillingReport totals requests, tokens and estimated cost per user between
+// ?since= and ?until= (unix seconds) together with each user's invoicing details and
+// the key limits in force at the end of that period. Costs use the model prices that
+// were effective when each request was made. ?format=csv returns a CSV export.
+func (h *Handler) GetMJ3GCBillingReport(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	usageSnapshot := usage.StatisticsSnapshot{}
+	if h.usageStats != nil {
+		usageSnapshot = h.usageStats.Snapshot()
+	}
+	since := parseSince(c.Query("since"))
+	until := parseSince(c.Query("until"))
+	asOf := until
+	if asOf.IsZero() {
+		asOf = time.Now()
+	}
+	settings := store.Settings()
+	prices := settings.ModelPrices
+
+	rows := make([]mj3gcBillingRow, 0)
+	for _, user := range store.ListUsers() {
+		row := mj3gcBillingRow{UserID: user.ID, Username: user.Username, Limits: []mj3gcBillingKey{}}
+		if user.Billing != nil {
+			row.Billing = *user.Billing
+		}
+		for _, key := range store.ListAPIKeysByUser(user.ID) {
+			row.Keys++
+			row.Limits = append(row.Limits, mj3gcBillingKey{KeyID: key.ID, Label: key.Label, KeyLimits: key.LimitsAt(asOf)})
+			for _, sample := range requestSamples(collectLogsForKey(key, usageSnapshot, since)) {
+				if !until.IsZero() && !sample.Timestamp.Before(until) {
+					continue
+				}
+				row.Requests++
+				row.Tokens += sample.TotalTokens
+				row.Cost += mj3gc.EstimateCost(prices, sample)
+			}
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].Username < rows[j].Username })
+
+	if !strings.EqualFold(c.Query("format"), "csv") {
+		c.JSON(http.StatusOK, gin.H{"rows": rows, "units": mj3gc.Units(settings)})
+		return
+	}
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="mj3gc-billing.csv"`)
+	w := csv.NewWriter(c.Writer)
+	_ = w.Write([]string{"user_id", "username", "company_name", "address", "tax_id", "billing_email", "keys", "requests", "tokens", "cost", "currency"})
+	currency := mj3gc.Currency(settings)
+	for _, row := range rows {
+		_ = w.Write([]string{
+			row.UserID,
+			row.Username,
+			row.Billing.CompanyName,
+			row.Billing.Address,
+			row.Billing.TaxID,
+			row.Billing.Email,
+			strconv.Itoa(row.Keys),
+			strconv.FormatInt(row.Requests, 10),
+			strconv.FormatInt(row.Tokens, 10),
+			strconv.FormatFloat(row.Cost, 'f', 6, 64),
+			currency,
+		})
+	}
+	w.Flush()
+}
@@ -0,0 +1,60 @@
+package management
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// mj3gcCompactRequest overrides the configured compaction settings. Omitted fields
+// fall back to the mj3gc config.
+type mj3gcCompactRequest struct {
+	UnusedDays *int   `json:"unused_days"`
+	Orphaned   *bool  `json:"orphaned"`
+	DetailDays *int   `json:"detail_days"`
+	Reason     string `json:"reason"`
+}
+
+// CompactMJ3GCStore prunes unused and orphaned keys into the trash and compacts the
+// usage detail history, returning what was removed.
+func (h *Handler) CompactMJ3GCStore(c *gin.Context) {
+	var body mj3gcCompactRequest
+	if c.Request.ContentLength != 0 {
+		if !bindJSON(c, &body) {
+			return
+		}
+	}
+	store := mj3gc.DefaultStore()
+	opts := store.CompactOptions()
+	if body.UnusedDays != nil {
+		opts.UnusedFor = time.Duration(*body.UnusedDays) * 24 * time.Hour
+	}
+	if body.Orphaned != nil {
+		opts.Orphaned = *body.Orphaned
+	}
+	if body.DetailDays != nil {
+		opts.DetailsOlderThan = time.Duration(*body.DetailDays) * 24 * time.Hour
+	}
+	reason := mj3gcChangeReason(c, body.Reason)
+	if opts.UnusedFor > 0 || opts.Orphaned {
+		if !requireMJ3GCReason(c, store, reason) {
+			return
+		}
+		backupBeforeMJ3GCChange(store, "store.compact")
+	}
+	report := store.Compact(opts)
+	if report.Removed() {
+		if err := store.Save(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+			return
+		}
+	}
+	recordMJ3GCAudit(c, store, "store.compact", "", reason, map[string]any{
+		"unused_keys":       report.UnusedKeys,
+		"orphaned_keys":     report.OrphanedKeys,
+		"compacted_details": report.CompactedDetails,
+	})
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,57 @@
+package management
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// PostMJ3GCKeysCSV creates keys in bulk from a CSV upload, sent either as the "file"
+// form field or as the raw request body, and returns a per-row report.
+func (h *Handler) PostMJ3GCKeysCSV(c *gin.Context) {
+	var body io.Reader = c.Request.Body
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, errOpen := fileHeader.Open()
+		if errOpen != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+			return
+		}
+		defer func() { _ = file.Close() }()
+		body = file
+	}
+	raw, err := io.ReadAll(io.LimitReader(body, maxMJ3GCImportBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	if len(raw) > maxMJ3GCImportBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import too large"})
+		return
+	}
+
+	store := mj3gc.DefaultStore()
+	report, err := store.ImportKeysCSV(bytes.NewReader(raw))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, mj3gc.ErrInvalidCSV) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	created := make([]string, 0, report.Created)
+	for _, row := range report.Rows {
+		if row.Error == "" {
+			created = append(created, row.ID)
+		}
+	}
+	recordMJ3GCAudit(c, store, "key.import_csv", "", mj3gcChangeReason(c, ""), map[string]any{
+		"created": created,
+		"failed":  report.Failed,
+	})
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,57 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// GetMJ3GCDiff returns the users and keys added, modified or removed after revision
+// ?from= up to ?to= (default: the current revision). A 410 response means the history
+// no longer reaches back to from and the caller should resync from /mj3gc/export.
+func (h *Handler) GetMJ3GCDiff(c *gin.Context) {
+	from, err := parseMJ3GCRevision(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	to, err := parseMJ3GCRevision(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	diff, err := mj3gc.DefaultStore().Diff(from, to)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, mj3gc.ErrRevisionExpired) {
+			status = http.StatusGone
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range diff.APIKeys {
+		if diff.APIKeys[i].APIKey != nil {
+			key := h.visibleKey(c, *diff.APIKeys[i].APIKey)
+			diff.APIKeys[i].APIKey = &key
+		}
+	}
+	for i := range diff.Users {
+		if diff.Users[i].User != nil {
+			user := mj3gc.SanitizeUser(*diff.Users[i].User)
+			diff.Users[i].User = &user
+		}
+	}
+	c.JSON(http.StatusOK, diff)
+}
+
+func parseMJ3GCRevision(raw string) (int64, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(raw, 10, 64)
+}
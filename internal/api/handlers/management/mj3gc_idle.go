@@ -0,0 +1,45 @@
+package management
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// GetMJ3GCIdleKeys lists the keys disabled by the idle sweep of
+// mj3gc.disable-unused-keys-after-days that are still disabled.
+func (h *Handler) GetMJ3GCIdleKeys(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	c.JSON(http.StatusOK, gin.H{
+		"idle_keys":  h.visibleKeys(c, store.ListIdleDisabledKeys()),
+		"after_days": store.Settings().DisableUnusedKeysAfterDays,
+	})
+}
+
+// ReenableMJ3GCKey enables a disabled key and restarts its idle period.
+func (h *Handler) ReenableMJ3GCKey(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+	store := mj3gc.DefaultStore()
+	previous, ok := store.FindAPIKeyByID(id)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": mj3gc.ErrKeyNotFound.Error()})
+		return
+	}
+	key, err := store.ReenableAPIKey(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "key.reenable", id, mj3gcChangeReason(c, ""), map[string]any{"disabled_reason": previous.DisabledReason})
+	c.JSON(http.StatusOK, gin.H{"api_key": h.visibleKey(c, key)})
+}
@@ -0,0 +1,82 @@
+package management
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+const (
+	defaultLogTailLines = 100
+	maxLogTailLines     = 1000
+)
+
+// TailMJ3GCLogs streams the latest request events as newline-delimited JSON. Filters:
+// key (key ID), user (user ID or username), model (with '*' wildcards) and failed
+// (true/false); lines (default 100) bounds the backlog. With follow=true the response
+// stays open and new events are written as they finish.
+func (h *Handler) TailMJ3GCLogs(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	filter := mj3gc.RequestEventFilter{
+		KeyID: strings.TrimSpace(c.Query("key")),
+		Model: strings.TrimSpace(c.Query("model")),
+	}
+	if user := strings.TrimSpace(c.Query("user")); user != "" {
+		filter.UserID = user
+		if found, ok := store.FindUserByUsername(user); ok {
+			filter.UserID = found.ID
+		}
+	}
+	if raw := strings.TrimSpace(c.Query("failed")); raw != "" {
+		failed, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid failed"})
+			return
+		}
+		filter.Failed = &failed
+	}
+	lines := defaultLogTailLines
+	if raw := strings.TrimSpace(c.Query("lines")); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid lines"})
+			return
+		}
+		lines = value
+		if lines > maxLogTailLines {
+			lines = maxLogTailLines
+		}
+	}
+	follow, _ := strconv.ParseBool(c.Query("follow"))
+
+	recent, events, cancel := store.TailRequestEvents(filter, lines)
+	defer cancel()
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for _, ev := range recent {
+		if err := enc.Encode(ev); err != nil {
+			return
+		}
+	}
+	c.Writer.Flush()
+	if !follow {
+		return
+	}
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case ev := <-events:
+			if err := enc.Encode(ev); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+type mj3gcMigrationExportRequest struct {
+	PublicKey []byte   `json:"public_key"`
+	KeyIDs    []string `json:"key_ids"`
+}
+
+// GetMJ3GCMigrationKey returns the public key source deployments encrypt bundles to.
+func (h *Handler) GetMJ3GCMigrationKey(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	key, err := store.MigrationPublicKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"public_key": key})
+}
+
+// ExportMJ3GCMigration encrypts keys and their owners, secrets included, to the
+// target deployment's public key.
+func (h *Handler) ExportMJ3GCMigration(c *gin.Context) {
+	var body mj3gcMigrationExportRequest
+	if err := c.ShouldBindJSON(&body); err != nil || len(body.PublicKey) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "public_key required"})
+		return
+	}
+	if !h.canRevealSecrets(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this management key may not export secrets"})
+		return
+	}
+	store := mj3gc.DefaultStore()
+	bundle, err := store.ExportMigration(body.PublicKey, body.KeyIDs)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, mj3gc.ErrKeyNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	recordMJ3GCAudit(c, store, "migration.export", "", "", map[string]any{"key_ids": body.KeyIDs})
+	c.JSON(http.StatusOK, bundle)
+}
+
+// ImportMJ3GCMigration ingests a bundle produced by ExportMJ3GCMigration on another
+// deployment, merging its users and keys with their existing credentials.
+func (h *Handler) ImportMJ3GCMigration(c *gin.Context) {
+	var bundle mj3gc.MigrationBundle
+	if !bindJSON(c, &bundle) {
+		return
+	}
+	store := mj3gc.DefaultStore()
+	backupBeforeMJ3GCChange(store, "migration.import")
+	result, err := store.ImportMigration(bundle)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, mj3gc.ErrDuplicateAPIKey):
+			status = http.StatusConflict
+		case errors.Is(err, mj3gc.ErrInvalidMigration), errors.Is(err, mj3gc.ErrInvalidImport):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	recordMJ3GCAudit(c, store, "migration.import", "", "", map[string]any{
+		"users":    result.Users,
+		"api_keys": result.APIKeys,
+	})
+	c.JSON(http.StatusOK, result)
+}
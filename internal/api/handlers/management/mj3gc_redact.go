@@ -0,0 +1,66 @@
+package management
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+const adminCredentialContextKey = "managementCredential"
+
+// canRevealSecrets reports whether the management credential of the request holds the
+// reveal permission granted by remote-management.reveal-secrets. Without that list
+// every credential may reveal secrets.
+func (h *Handler) canRevealSecrets(c *gin.Context) bool {
+	if h.cfg == nil || len(h.cfg.RemoteManagement.RevealSecrets) == 0 {
+		return true
+	}
+	credential := c.GetString(adminCredentialContextKey)
+	for _, allowed := range h.cfg.RemoteManagement.RevealSecrets {
+		if strings.EqualFold(strings.TrimSpace(allowed), credential) {
+			return true
+		}
+	}
+	return false
+}
+
+// visibleKey returns key as the caller may see it.
+func (h *Handler) visibleKey(c *gin.Context, key mj3gc.APIKey) mj3gc.APIKey {
+	if h.canRevealSecrets(c) {
+		return key
+	}
+	return mj3gc.SanitizeKey(key)
+}
+
+// visibleKeys returns keys as the caller may see them.
+func (h *Handler) visibleKeys(c *gin.Context, keys []mj3gc.APIKey) []mj3gc.APIKey {
+	if h.canRevealSecrets(c) {
+		return keys
+	}
+	out := make([]mj3gc.APIKey, len(keys))
+	for i, key := range keys {
+		out[i] = mj3gc.SanitizeKey(key)
+	}
+	return out
+}
+
+// redactUsageSnapshot renames the per-key usage entries, which are keyed by the raw
+// secret, to the mj3gc key ID or, for other credentials, the masked secret.
+func redactUsageSnapshot(snapshot usage.StatisticsSnapshot) usage.StatisticsSnapshot {
+	if len(snapshot.APIs) == 0 {
+		return snapshot
+	}
+	store := mj3gc.DefaultStore()
+	apis := make(map[string]usage.APISnapshot, len(snapshot.APIs))
+	for value, stats := range snapshot.APIs {
+		name := mj3gc.MaskSecret(value)
+		if key, ok := store.FindAPIKeyByPrincipal(value); ok {
+			name = key.ID
+		}
+		apis[name] = stats
+	}
+	snapshot.APIs = apis
+	return snapshot
+}
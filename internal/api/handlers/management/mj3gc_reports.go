@@ -0,0 +1,144 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+type mj3gcReportRequest struct {
+	Frequency *string              `json:"frequency"`
+	Channel   *string              `json:"channel"`
+	Target    *string              `json:"target"`
+	Content   *mj3gc.ReportContent `json:"content"`
+}
+
+// apply copies the fields present in the request onto sub.
+func (r mj3gcReportRequest) apply(sub *mj3gc.ReportSubscription) {
+	if r.Frequency != nil {
+		sub.Frequency = *r.Frequency
+	}
+	if r.Channel != nil {
+		sub.Channel = *r.Channel
+	}
+	if r.Target != nil {
+		sub.Target = *r.Target
+	}
+	if r.Content != nil {
+		sub.Content = *r.Content
+	}
+}
+
+// GetMJ3GCPortalReports lists the caller's usage report subscriptions.
+func (h *Handler) GetMJ3GCPortalReports(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"subscriptions": mj3gc.DefaultStore().ListReportSubscriptions(ctx.User.ID)})
+}
+
+// CreateMJ3GCPortalReport subscribes the caller to weekly or monthly usage reports of
+// their keys, delivered by email or webhook.
+func (h *Handler) CreateMJ3GCPortalReport(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	if ctx.User.ID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is not assigned to a user"})
+		return
+	}
+	var body mj3gcReportRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+	sub := mj3gc.ReportSubscription{UserID: ctx.User.ID}
+	body.apply(&sub)
+	store := mj3gc.DefaultStore()
+	created, err := store.CreateReportSubscription(sub)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "report.subscribe", created.ID, "", map[string]any{
+		"user_id":   created.UserID,
+		"frequency": created.Frequency,
+		"channel":   created.Channel,
+	})
+	c.JSON(http.StatusCreated, gin.H{"subscription": created})
+}
+
+// UpdateMJ3GCPortalReport changes the fields given in the body of one of the caller's
+// report subscriptions.
+func (h *Handler) UpdateMJ3GCPortalReport(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	var body mj3gcReportRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	store := mj3gc.DefaultStore()
+	var sub mj3gc.ReportSubscription
+	found := false
+	for _, existing := range store.ListReportSubscriptions(ctx.User.ID) {
+		if existing.ID == id {
+			sub, found = existing, true
+			break
+		}
+	}
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": mj3gc.ErrReportNotFound.Error()})
+		return
+	}
+	body.apply(&sub)
+	updated, err := store.UpdateReportSubscription(sub)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, mj3gc.ErrReportNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "report.update", updated.ID, "", map[string]any{
+		"user_id":   updated.UserID,
+		"frequency": updated.Frequency,
+		"channel":   updated.Channel,
+	})
+	c.JSON(http.StatusOK, gin.H{"subscription": updated})
+}
+
+// DeleteMJ3GCPortalReport unsubscribes the caller from one usage report.
+func (h *Handler) DeleteMJ3GCPortalReport(c *gin.Context) {
+	ctx, ok := getPortalContext(c)
+	if !ok {
+		return
+	}
+	id := strings.TrimSpace(c.Param("id"))
+	store := mj3gc.DefaultStore()
+	if err := store.DeleteReportSubscription(ctx.User.ID, id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "report.unsubscribe", id, "", nil)
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -0,0 +1,62 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// GetMJ3GCRevisions lists the revisions the store can still be rolled back to.
+func (h *Handler) GetMJ3GCRevisions(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	c.JSON(http.StatusOK, gin.H{
+		"revision":  store.Revision(),
+		"revisions": store.ListRevisions(),
+	})
+}
+
+// RollbackMJ3GCStore restores users and keys to their state at revision :revision.
+func (h *Handler) RollbackMJ3GCStore(c *gin.Context) {
+	target, err := strconv.ParseInt(strings.TrimSpace(c.Param("revision")), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid revision"})
+		return
+	}
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if !bindJSON(c, &body) {
+			return
+		}
+	}
+	store := mj3gc.DefaultStore()
+	reason := mj3gcChangeReason(c, body.Reason)
+	if !requireMJ3GCReason(c, store, reason) {
+		return
+	}
+	backupBeforeMJ3GCChange(store, "store.rollback")
+	report, err := store.Rollback(target)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, mj3gc.ErrRevisionExpired) {
+			status = http.StatusGone
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "store.rollback", strconv.FormatInt(target, 10), reason, map[string]any{
+		"from":     report.From,
+		"users":    report.Users,
+		"api_keys": report.APIKeys,
+	})
+	c.JSON(http.StatusOK, gin.H{"report": report, "revision": store.Revision()})
+}
@@ -0,0 +1,75 @@
+package management
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+type mj3gcTemporaryKeyRequest struct {
+	UserID        string   `json:"user_id"`
+	TTLMinutes    int      `json:"ttl_minutes"`
+	TotalLimit    int64    `json:"total_limit"`
+	Label         string   `json:"label"`
+	AllowedModels []string `json:"allowed_models"`
+}
+
+// CreateMJ3GCTemporaryKey mints a short-lived key for a user, for demos and CI jobs. It
+// expires after ttl_minutes, carries total_limit requests (100 when omitted) and is
+// purged by the hourly maintenance once expired. The secret is only shown here.
+func (h *Handler) CreateMJ3GCTemporaryKey(c *gin.Context) {
+	var body mj3gcTemporaryKeyRequest
+	if !bindJSON(c, &body) {
+		return
+	}
+	maxMinutes := int(mj3gc.MaxTemporaryKeyTTL / time.Minute)
+	var problems []fieldError
+	if strings.TrimSpace(body.UserID) == "" {
+		problems = append(problems, requiredField("user_id", "string"))
+	}
+	if body.TTLMinutes < 1 || body.TTLMinutes > maxMinutes {
+		problems = append(problems, fieldError{Field: "ttl_minutes", Reason: "out of range", Got: body.TTLMinutes, Expected: fmt.Sprintf("1 to %d", maxMinutes)})
+	}
+	if body.TotalLimit < 0 {
+		problems = append(problems, fieldError{Field: "total_limit", Reason: "negative", Got: body.TotalLimit, Expected: "a positive request quota, or 0 for the default"})
+	}
+	store := mj3gc.DefaultStore()
+	problems = append(problems, validateMJ3GCKeyRequest(store.Settings(), mj3gcKeyRequest{Label: &body.Label})...)
+	if len(problems) > 0 {
+		respondInvalidBody(c, problems...)
+		return
+	}
+	created, err := store.CreateTemporaryKey(mj3gc.TemporaryKeyRequest{
+		UserID:        body.UserID,
+		Label:         body.Label,
+		TTL:           time.Duration(body.TTLMinutes) * time.Minute,
+		TotalLimit:    body.TotalLimit,
+		AllowedModels: body.AllowedModels,
+	})
+	if err != nil {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, mj3gc.ErrUserNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, mj3gc.ErrKeyLimitReached):
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "key.create_temporary", created.ID, "", map[string]any{
+		"user_id":     created.UserID,
+		"expires_at":  created.ExpiresAt,
+		"total_limit": created.TotalLimit,
+	})
+	c.JSON(http.StatusCreated, gin.H{"api_key": created})
+}
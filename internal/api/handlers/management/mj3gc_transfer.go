@@ -0,0 +1,139 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+	"gopkg.in/yaml.v3"
+)
+
+const maxMJ3GCImportBytes = 64 << 20
+
+// mj3gcTransferFormat picks "yaml" or "json" from ?format= or, failing that, from header.
+func mj3gcTransferFormat(c *gin.Context, header string) string {
+	if format := strings.ToLower(strings.TrimSpace(c.Query("format"))); format != "" {
+		if format == "yml" {
+			return "yaml"
+		}
+		return format
+	}
+	if strings.Contains(strings.ToLower(c.GetHeader(header)), "yaml") {
+		return "yaml"
+	}
+	return "json"
+}
+
+// GetMJ3GCExport returns the full store state as JSON or YAML. Password hashes and key
+// values are only included with ?include_password_hashes=true and ?include_key_values=true.
+func (h *Handler) GetMJ3GCExport(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	var opts mj3gc.ExportOptions
+	opts.PasswordHashes, _ = strconv.ParseBool(c.Query("include_password_hashes"))
+	opts.KeyValues, _ = strconv.ParseBool(c.Query("include_key_values"))
+	if (opts.KeyValues || opts.PasswordHashes) && !h.canRevealSecrets(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "this management key may not export secrets"})
+		return
+	}
+	format := mj3gcTransferFormat(c, "Accept")
+	if format != "json" && format != "yaml" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+		return
+	}
+
+	payload, err := json.MarshalIndent(store.Export(opts), "", "  ")
+	if err == nil && format == "yaml" {
+		// Round-trip through a generic value so YAML uses the JSON field names.
+		var doc any
+		if err = json.Unmarshal(payload, &doc); err == nil {
+			payload, err = yaml.Marshal(doc)
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	recordMJ3GCAudit(c, store, "store.export", "", "", map[string]any{
+		"format":                  format,
+		"include_password_hashes": opts.PasswordHashes,
+		"include_key_values":      opts.KeyValues,
+	})
+	contentType := "application/json"
+	if format == "yaml" {
+		contentType = "application/yaml"
+	}
+	c.Header("Content-Disposition", `attachment; filename="mj3gc-export.`+format+`"`)
+	c.Data(http.StatusOK, contentType, payload)
+}
+
+// PostMJ3GCImport loads an export produced by GetMJ3GCExport. ?mode=replace swaps the
+// whole state; the default merge adds or overwrites users and keys by ID.
+func (h *Handler) PostMJ3GCImport(c *gin.Context) {
+	mode := strings.ToLower(strings.TrimSpace(c.DefaultQuery("mode", "merge")))
+	if mode != "merge" && mode != "replace" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be merge or replace"})
+		return
+	}
+	raw, err := io.ReadAll(io.LimitReader(c.Request.Body, maxMJ3GCImportBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read body"})
+		return
+	}
+	if len(raw) > maxMJ3GCImportBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "import too large"})
+		return
+	}
+
+	switch mj3gcTransferFormat(c, "Content-Type") {
+	case "json":
+	case "yaml":
+		var doc any
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid yaml: " + err.Error()})
+			return
+		}
+		if raw, err = json.Marshal(doc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid yaml: " + err.Error()})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format"})
+		return
+	}
+	var data mj3gc.Data
+	if err := json.Unmarshal(raw, &data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: " + err.Error()})
+		return
+	}
+
+	store := mj3gc.DefaultStore()
+	reason := mj3gcChangeReason(c, "")
+	if mode == "replace" && !requireMJ3GCReason(c, store, reason) {
+		return
+	}
+	backupBeforeMJ3GCChange(store, "import")
+	result, err := store.Import(data, mode == "replace")
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, mj3gc.ErrDuplicateAPIKey):
+			status = http.StatusConflict
+		case errors.Is(err, mj3gc.ErrInvalidImport):
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	recordMJ3GCAudit(c, store, "store.import", mode, reason, map[string]any{
+		"users":          result.Users,
+		"api_keys":       result.APIKeys,
+		"archived_keys":  result.ArchivedKeys,
+		"generated_keys": len(result.GeneratedKeys),
+	})
+	c.JSON(http.StatusOK, result)
+}
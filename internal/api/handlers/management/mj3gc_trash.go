@@ -0,0 +1,70 @@
+package management
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// GetMJ3GCTrash lists deleted users and keys that can still be restored.
+func (h *Handler) GetMJ3GCTrash(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	users := store.ListDeletedUsers()
+	for i := range users {
+		users[i] = mj3gc.SanitizeUser(users[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"users": users, "api_keys": h.visibleKeys(c, store.ListDeletedAPIKeys())})
+}
+
+// RestoreMJ3GCDeletedUser moves a user out of the trash.
+func (h *Handler) RestoreMJ3GCDeletedUser(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+	store := mj3gc.DefaultStore()
+	user, err := store.RestoreDeletedUser(id)
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, mj3gc.ErrDuplicateUsername) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "user.undelete", id, mj3gcChangeReason(c, ""), nil)
+	c.JSON(http.StatusOK, gin.H{"user": mj3gc.SanitizeUser(user)})
+}
+
+// RestoreMJ3GCDeletedKey moves a key out of the trash with its previous secret.
+func (h *Handler) RestoreMJ3GCDeletedKey(c *gin.Context) {
+	id := strings.TrimSpace(c.Param("id"))
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "missing id"})
+		return
+	}
+	store := mj3gc.DefaultStore()
+	key, err := store.RestoreDeletedKey(id)
+	if err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, mj3gc.ErrDuplicateAPIKey) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if err := store.Save(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to persist store"})
+		return
+	}
+	recordMJ3GCAudit(c, store, "key.undelete", id, mj3gcChangeReason(c, ""), nil)
+	c.JSON(http.StatusOK, gin.H{"api_key": h.visibleKey(c, key)})
+}
@@ -0,0 +1,50 @@
+package management
+
+import (
+	"sync"
+	"time"
+)
+
+// portalUsageCache keeps recently built portal usage figures per key ID so dashboards
+// polling the portal do not snapshot the usage statistics on every call.
+type portalUsageCache struct {
+	mu      sync.Mutex
+	entries map[string]portalUsageEntry
+}
+
+type portalUsageEntry struct {
+	usage mj3gcKeyUsage
+	at    time.Time
+}
+
+// get returns the figures cached for keyID unless they are older than ttl.
+func (p *portalUsageCache) get(keyID string, now time.Time, ttl time.Duration) (mj3gcKeyUsage, bool) {
+	if ttl <= 0 {
+		return mj3gcKeyUsage{}, false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry, ok := p.entries[keyID]
+	if !ok || now.Sub(entry.at) >= ttl {
+		return mj3gcKeyUsage{}, false
+	}
+	return entry.usage, true
+}
+
+// put caches usage for keyID and drops entries that have expired.
+func (p *portalUsageCache) put(keyID string, usage mj3gcKeyUsage, now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.entries == nil {
+		p.entries = make(map[string]portalUsageEntry)
+	}
+	for id, entry := range p.entries {
+		if now.Sub(entry.at) >= ttl {
+			delete(p.entries, id)
+		}
+	}
+	p.entries[keyID] = portalUsageEntry{usage: usage, at: now}
+}
@@ -0,0 +1,18 @@
+package management
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+// GetMJ3GCValidation reports consistency problems in the served data together with the
+// records quarantined by load repair.
+func (h *Handler) GetMJ3GCValidation(c *gin.Context) {
+	store := mj3gc.DefaultStore()
+	c.JSON(http.StatusOK, gin.H{
+		"issues":     store.Validate(),
+		"quarantine": store.ListQuarantined(),
+	})
+}
@@ -0,0 +1,121 @@
+package management
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// maxMJ3GCLabelLength bounds key labels and usernames.
+const maxMJ3GCLabelLength = 128
+
+// fieldError describes one problem with a field of a management request body.
+type fieldError struct {
+	Field    string `json:"field"`
+	Reason   string `json:"reason"`
+	Got      any    `json:"got,omitempty"`
+	Expected string `json:"expected,omitempty"`
+}
+
+// respondInvalidBody answers 400 with the generic error and one detail per problem.
+func respondInvalidBody(c *gin.Context, details ...fieldError) {
+	c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body", "details": details})
+}
+
+// bindJSON decodes the request body into dst. When that fails it answers 400 saying
+// which field was wrong and how, and returns false.
+func bindJSON(c *gin.Context, dst any) bool {
+	if err := c.ShouldBindJSON(dst); err != nil {
+		respondInvalidBody(c, decodeError(err))
+		return false
+	}
+	return true
+}
+
+// requiredField is the detail of a field that is missing from the body.
+func requiredField(field, expected string) fieldError {
+	return fieldError{Field: field, Reason: "required", Expected: expected}
+}
+
+// decodeError turns a JSON decoding error into a field error.
+func decodeError(err error) fieldError {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return fieldError{
+			Field:    typeErr.Field,
+			Reason:   "wrong type",
+			Got:      typeErr.Value,
+			Expected: jsonTypeName(typeErr.Type),
+		}
+	case errors.As(err, &syntaxErr):
+		return fieldError{Reason: fmt.Sprintf("malformed JSON at offset %d: %v", syntaxErr.Offset, syntaxErr)}
+	case errors.Is(err, io.EOF):
+		return fieldError{Reason: "empty body", Expected: "a JSON object"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return fieldError{Reason: "truncated JSON"}
+	}
+	return fieldError{Reason: err.Error()}
+}
+
+// jsonTypeName names the JSON type a Go type is decoded from.
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array of " + jsonTypeName(t.Elem())
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return t.String()
+}
+
+// validateMJ3GCKeyRequest checks the fields of a key request against the limits of the
+// mj3gc configuration.
+func validateMJ3GCKeyRequest(settings config.MJ3GCConfig, body mj3gcKeyRequest) []fieldError {
+	var problems []fieldError
+	if body.Label != nil {
+		if n := utf8.RuneCountInString(strings.TrimSpace(*body.Label)); n > maxMJ3GCLabelLength {
+			problems = append(problems, fieldError{Field: "label", Reason: "too long", Got: n, Expected: fmt.Sprintf("at most %d characters", maxMJ3GCLabelLength)})
+		}
+	}
+	if body.ConcurrencyLimit != nil && settings.MaxConcurrencyLimit > 0 {
+		expected := fmt.Sprintf("1 to %d", settings.MaxConcurrencyLimit)
+		switch limit := *body.ConcurrencyLimit; {
+		case limit <= 0:
+			problems = append(problems, fieldError{Field: "concurrency_limit", Reason: "unlimited concurrency is not allowed", Got: limit, Expected: expected})
+		case limit > settings.MaxConcurrencyLimit:
+			problems = append(problems, fieldError{Field: "concurrency_limit", Reason: "above max-concurrency-limit", Got: limit, Expected: expected})
+		}
+	}
+	return problems
+}
+
+// validateMJ3GCUserRequest checks the fields of a user request.
+func validateMJ3GCUserRequest(body mj3gcUserRequest) []fieldError {
+	var problems []fieldError
+	if n := utf8.RuneCountInString(strings.TrimSpace(body.Username)); n > maxMJ3GCLabelLength {
+		problems = append(problems, fieldError{Field: "username", Reason: "too long", Got: n, Expected: fmt.Sprintf("at most %d characters", maxMJ3GCLabelLength)})
+	}
+	return problems
+}
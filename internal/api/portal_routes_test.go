@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+func TestServerMountsPortalNotifications(t *testing.T) {
+	server := newTestServer(t)
+	store := mj3gc.DefaultStore()
+	user, err := store.UpsertUser(mj3gc.User{Username: "portal-notifications"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.DeleteUser(user.ID) })
+	key, err := store.UpsertAPIKey(mj3gc.APIKey{Key: "sk-portal-notifications", UserID: user.ID, Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.DeleteAPIKey(key.ID) })
+	announcement, err := store.CreateAnnouncement(mj3gc.Announcement{Title: "Maintenance", UserIDs: []string{user.ID}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.DeleteAnnouncement(announcement.ID) })
+
+	do := func(method, path, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if value != "" {
+			req.Header.Set("Authorization", "Bearer "+value)
+		}
+		rec := httptest.NewRecorder()
+		server.engine.ServeHTTP(rec, req)
+		return rec
+	}
+	type feed struct {
+		Notifications []mj3gc.AnnouncementView `json:"notifications"`
+		Unread        int                      `json:"unread"`
+	}
+	fetch := func() feed {
+		t.Helper()
+		rec := do(http.MethodGet, "/v0/portal/notifications", key.Key)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("notifications: status %d: %s", rec.Code, rec.Body.String())
+		}
+		var got feed
+		if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+			t.Fatalf("decode notifications: %v", err)
+		}
+		return got
+	}
+
+	if rec := do(http.MethodGet, "/v0/portal/notifications", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("without a key: status %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+	got := fetch()
+	if got.Unread != 1 || len(got.Notifications) != 1 || got.Notifications[0].ID != announcement.ID {
+		t.Fatalf("feed before read = %+v", got)
+	}
+	if rec := do(http.MethodPost, "/v0/portal/notifications/"+announcement.ID+"/read", key.Key); rec.Code != http.StatusOK {
+		t.Fatalf("mark read: status %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := fetch(); got.Unread != 0 || len(got.Notifications) != 1 || !got.Notifications[0].Read {
+		t.Fatalf("feed after read = %+v", got)
+	}
+}
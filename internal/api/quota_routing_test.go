@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	configaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/config_access"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+)
+
+func TestServerMountsQuotaMiddleware(t *testing.T) {
+	configaccess.Register()
+	server := newTestServer(t)
+	store := mj3gc.DefaultStore()
+	key, err := store.UpsertAPIKey(mj3gc.APIKey{Key: "sk-routing-quota", Label: "routing", Enabled: true, RPMLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = store.DeleteAPIKey(key.ID) })
+
+	post := func(path, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"model":"test-model","messages":[]}`))
+		req.Header.Set("Authorization", "Bearer "+value)
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := post("/v1/chat/completions", key.Key); rec.Code == http.StatusTooManyRequests || rec.Code == http.StatusUnauthorized {
+		t.Fatalf("first request: status %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := post("/v1/chat/completions", key.Key)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("second request within the minute: status %d, Retry-After %q: %s", rec.Code, rec.Header().Get("Retry-After"), rec.Body.String())
+	}
+
+	// Keys of the config api-keys provider pass through unmetered.
+	for i := 0; i < 2; i++ {
+		if rec := post("/v1/chat/completions", "test-key"); rec.Code == http.StatusTooManyRequests || rec.Code == http.StatusUnauthorized {
+			t.Fatalf("config key request %d: status %d: %s", i, rec.Code, rec.Body.String())
+		}
+	}
+}
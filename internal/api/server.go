@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -19,6 +20,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/access"
+	mj3gcaccess "github.com/router-for-me/CLIProxyAPI/v6/internal/access/mj3gc_access"
 	managementHandlers "github.com/router-for-me/CLIProxyAPI/v6/internal/api/handlers/management"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/middleware"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/api/modules"
@@ -26,6 +28,7 @@ import (
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/logging"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/managementasset"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/util"
 	sdkaccess "github.com/router-for-me/CLIProxyAPI/v6/sdk/access"
@@ -245,6 +248,12 @@ func NewServer(cfg *config.Config, authManager *auth.Manager, accessManager *sdk
 		wsRoutes:            make(map[string]struct{}),
 	}
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
+	// The mj3gc provider type is registered here so embedders get it without extra setup.
+	mj3gcaccess.Register()
+	mj3gc.EnsureAccessProvider(cfg)
+	if err := mj3gc.DefaultStore().Start(cfg, configFilePath); err != nil {
+		log.Errorf("mj3gc: failed to open the key store: %v", err)
+	}
 	// Save initial YAML snapshot
 	s.oldConfigYaml, _ = yaml.Marshal(cfg)
 	s.applyAccessConfig(nil, cfg)
@@ -317,7 +326,7 @@ func (s *Server) setupRoutes() {
 
 	// OpenAI compatible API routes
 	v1 := s.engine.Group("/v1")
-	v1.Use(AuthMiddleware(s.accessManager))
+	v1.Use(AuthMiddleware(s.accessManager), mj3gc.QuotaMiddleware(mj3gc.DefaultStore()), mj3gc.BodyLimitMiddleware(mj3gc.DefaultStore()), mj3gc.ScopeMiddleware(mj3gc.DefaultStore()), mj3gc.ModelAliasMiddleware(mj3gc.DefaultStore()), mj3gc.ModelAccessMiddleware(mj3gc.DefaultStore()), mj3gc.OutputTokenCapMiddleware(mj3gc.DefaultStore()), mj3gc.StreamingMiddleware(mj3gc.DefaultStore()))
 	{
 		v1.GET("/models", s.unifiedModelsHandler(openaiHandlers, claudeCodeHandlers))
 		v1.POST("/chat/completions", openaiHandlers.ChatCompletions)
@@ -325,11 +334,13 @@ func (s *Server) setupRoutes() {
 		v1.POST("/messages", claudeCodeHandlers.ClaudeMessages)
 		v1.POST("/messages/count_tokens", claudeCodeHandlers.ClaudeCountTokens)
 		v1.POST("/responses", openaiResponsesHandlers.Responses)
+		v1.POST("/quota/check", mj3gc.QuotaCheckHandler(mj3gc.DefaultStore()))
+		v1.GET("/key/info", mj3gc.KeyInfoHandler(mj3gc.DefaultStore()))
 	}
 
 	// Gemini compatible API routes
 	v1beta := s.engine.Group("/v1beta")
-	v1beta.Use(AuthMiddleware(s.accessManager))
+	v1beta.Use(AuthMiddleware(s.accessManager), mj3gc.QuotaMiddleware(mj3gc.DefaultStore()), mj3gc.BodyLimitMiddleware(mj3gc.DefaultStore()), mj3gc.ScopeMiddleware(mj3gc.DefaultStore()), mj3gc.ModelAliasMiddleware(mj3gc.DefaultStore()), mj3gc.ModelAccessMiddleware(mj3gc.DefaultStore()), mj3gc.OutputTokenCapMiddleware(mj3gc.DefaultStore()), mj3gc.StreamingMiddleware(mj3gc.DefaultStore()))
 	{
 		v1beta.GET("/models", geminiHandlers.GeminiModels)
 		v1beta.POST("/models/*action", geminiHandlers.GeminiHandler)
@@ -348,6 +359,46 @@ func (s *Server) setupRoutes() {
 		})
 	})
 	s.engine.POST("/v1internal:method", geminiCLIHandlers.CLIHandler)
+	s.engine.POST("/oauth/token", mj3gc.OAuthTokenHandler(mj3gc.DefaultStore()))
+
+	// SCIM 2.0 user provisioning for identity providers.
+	scim := s.engine.Group("/scim/v2", mj3gc.SCIMAuth(mj3gc.DefaultStore()))
+	{
+		scim.GET("/Users", mj3gc.SCIMListUsersHandler(mj3gc.DefaultStore()))
+		scim.POST("/Users", mj3gc.SCIMCreateUserHandler(mj3gc.DefaultStore()))
+		scim.GET("/Users/:id", mj3gc.SCIMGetUserHandler(mj3gc.DefaultStore()))
+		scim.PUT("/Users/:id", mj3gc.SCIMReplaceUserHandler(mj3gc.DefaultStore()))
+		scim.PATCH("/Users/:id", mj3gc.SCIMPatchUserHandler(mj3gc.DefaultStore()))
+		scim.DELETE("/Users/:id", mj3gc.SCIMDeleteUserHandler(mj3gc.DefaultStore()))
+	}
+
+	// Internal API consumed by proxy instances using mj3gc storage "control-plane".
+	controlPlane := s.engine.Group("/internal/mj3gc", mj3gc.ControlPlaneAuth(mj3gc.DefaultStore()))
+	{
+		controlPlane.GET("/data", mj3gc.ControlPlaneDataHandler(mj3gc.DefaultStore()))
+		controlPlane.GET("/revision", mj3gc.ControlPlaneRevisionHandler(mj3gc.DefaultStore()))
+		controlPlane.POST("/usage", mj3gc.ControlPlaneUsageHandler(mj3gc.DefaultStore()))
+		controlPlane.POST("/usage/reset", mj3gc.ControlPlaneResetUsageHandler(mj3gc.DefaultStore()))
+	}
+
+	// Self-service API for mj3gc key holders, authenticated by their own API key.
+	portal := s.engine.Group("/v0/portal", mj3gc.PortalAuthMiddleware(mj3gc.DefaultStore()))
+	{
+		portal.GET("/me", s.mgmt.GetMJ3GCPortalMe)
+		portal.GET("/usage", s.mgmt.GetMJ3GCPortalUsage)
+		portal.GET("/logs", s.mgmt.GetMJ3GCPortalLogs)
+		portal.GET("/analytics", s.mgmt.GetMJ3GCPortalAnalytics)
+		portal.POST("/keys", s.mgmt.CreateMJ3GCPortalKey)
+		portal.GET("/billing", s.mgmt.GetMJ3GCPortalBilling)
+		portal.PUT("/billing", s.mgmt.UpdateMJ3GCPortalBilling)
+		portal.GET("/notifications", s.mgmt.GetMJ3GCPortalNotifications)
+		portal.POST("/notifications/:id/read", s.mgmt.MarkMJ3GCPortalNotificationRead)
+		portal.GET("/models/health", s.mgmt.GetMJ3GCPortalModelHealth)
+		portal.GET("/reports", s.mgmt.GetMJ3GCPortalReports)
+		portal.POST("/reports", s.mgmt.CreateMJ3GCPortalReport)
+		portal.PUT("/reports/:id", s.mgmt.UpdateMJ3GCPortalReport)
+		portal.DELETE("/reports/:id", s.mgmt.DeleteMJ3GCPortalReport)
+	}
 
 	// OAuth callback endpoints (reuse main server port)
 	// These endpoints receive provider redirects and persist
@@ -475,6 +526,7 @@ func (s *Server) registerManagementRoutes() {
 	mgmt := s.engine.Group("/v0/management")
 	mgmt.Use(s.managementAvailabilityMiddleware(), s.mgmt.Middleware())
 	{
+		mgmt.GET("/admin-activity", s.mgmt.GetAdminActivity)
 		mgmt.GET("/usage", s.mgmt.GetUsageStatistics)
 		mgmt.GET("/config", s.mgmt.GetConfig)
 		mgmt.GET("/config.yaml", s.mgmt.GetConfigYAML)
@@ -591,6 +643,60 @@ func (s *Server) registerManagementRoutes() {
 		mgmt.POST("/iflow-auth-url", s.mgmt.RequestIFlowCookieToken)
 		mgmt.POST("/oauth-callback", s.mgmt.PostOAuthCallback)
 		mgmt.GET("/get-auth-status", s.mgmt.GetAuthStatus)
+
+		mgmt.GET("/mj3gc/state", s.mgmt.GetMJ3GCState)
+		mgmt.GET("/mj3gc/users", s.mgmt.GetMJ3GCUsers)
+		mgmt.PUT("/mj3gc/users", s.mgmt.UpsertMJ3GCUser)
+		mgmt.POST("/mj3gc/users", s.mgmt.CreateMJ3GCUser)
+		mgmt.PUT("/mj3gc/users/:id", s.mgmt.UpdateMJ3GCUser)
+		mgmt.PATCH("/mj3gc/users/:id", s.mgmt.UpdateMJ3GCUser)
+		mgmt.DELETE("/mj3gc/users/:id", s.mgmt.DeleteMJ3GCUser)
+		mgmt.GET("/mj3gc/keys", s.mgmt.GetMJ3GCKeys)
+		mgmt.PUT("/mj3gc/keys", s.mgmt.UpsertMJ3GCKey)
+		mgmt.POST("/mj3gc/keys", s.mgmt.CreateMJ3GCKey)
+		mgmt.POST("/mj3gc/keys/temporary", s.mgmt.CreateMJ3GCTemporaryKey)
+		mgmt.PUT("/mj3gc/keys/:id", s.mgmt.UpdateMJ3GCKey)
+		mgmt.PATCH("/mj3gc/keys/:id", s.mgmt.UpdateMJ3GCKey)
+		mgmt.POST("/mj3gc/keys/import-csv", s.mgmt.PostMJ3GCKeysCSV)
+		mgmt.DELETE("/mj3gc/keys/:id", s.mgmt.DeleteMJ3GCKey)
+		mgmt.POST("/mj3gc/keys/:id/reset-usage", s.mgmt.ResetMJ3GCKeyUsage)
+		mgmt.POST("/mj3gc/keys/:id/rotate", s.mgmt.RotateMJ3GCKey)
+		mgmt.POST("/mj3gc/keys/:id/reenable", s.mgmt.ReenableMJ3GCKey)
+		mgmt.GET("/mj3gc/keys/idle", s.mgmt.GetMJ3GCIdleKeys)
+		mgmt.POST("/mj3gc/keys/:id/transfer-quota", s.mgmt.TransferMJ3GCKeyQuota)
+		mgmt.GET("/mj3gc/models/stats", s.mgmt.GetMJ3GCModelStats)
+		mgmt.POST("/mj3gc/benchmark", s.mgmt.RunMJ3GCBenchmark)
+		mgmt.GET("/mj3gc/logs/tail", s.mgmt.TailMJ3GCLogs)
+		mgmt.GET("/mj3gc/archive", s.mgmt.GetMJ3GCArchivedKeys)
+		mgmt.POST("/mj3gc/archive", s.mgmt.ArchiveMJ3GCKeys)
+		mgmt.POST("/mj3gc/archive/:id/restore", s.mgmt.RestoreMJ3GCArchivedKey)
+		mgmt.GET("/mj3gc/trash", s.mgmt.GetMJ3GCTrash)
+		mgmt.POST("/mj3gc/trash/users/:id/restore", s.mgmt.RestoreMJ3GCDeletedUser)
+		mgmt.POST("/mj3gc/trash/keys/:id/restore", s.mgmt.RestoreMJ3GCDeletedKey)
+		mgmt.POST("/mj3gc/compact", s.mgmt.CompactMJ3GCStore)
+		mgmt.GET("/mj3gc/validation", s.mgmt.GetMJ3GCValidation)
+		mgmt.GET("/mj3gc/announcements", s.mgmt.GetMJ3GCAnnouncements)
+		mgmt.POST("/mj3gc/announcements", s.mgmt.PostMJ3GCAnnouncement)
+		mgmt.DELETE("/mj3gc/announcements/:id", s.mgmt.DeleteMJ3GCAnnouncement)
+		mgmt.GET("/mj3gc/usage", s.mgmt.GetMJ3GCUsage)
+		mgmt.GET("/mj3gc/referrals", s.mgmt.GetMJ3GCReferrals)
+		mgmt.GET("/mj3gc/audit", s.mgmt.GetMJ3GCAudit)
+		mgmt.GET("/mj3gc/locks", s.mgmt.GetMJ3GCLockStats)
+		mgmt.GET("/mj3gc/metrics", s.mgmt.GetMJ3GCKeyMetrics)
+		mgmt.GET("/mj3gc/anonymous", s.mgmt.GetMJ3GCAnonymousUsage)
+		mgmt.GET("/mj3gc/load", s.mgmt.GetMJ3GCLoad)
+		mgmt.GET("/mj3gc/backups", s.mgmt.GetMJ3GCBackups)
+		mgmt.POST("/mj3gc/backups", s.mgmt.CreateMJ3GCBackup)
+		mgmt.POST("/mj3gc/backups/restore", s.mgmt.RestoreMJ3GCBackup)
+		mgmt.GET("/mj3gc/billing", s.mgmt.GetMJ3GCBillingReport)
+		mgmt.GET("/mj3gc/export", s.mgmt.GetMJ3GCExport)
+		mgmt.GET("/mj3gc/diff", s.mgmt.GetMJ3GCDiff)
+		mgmt.GET("/mj3gc/revisions", s.mgmt.GetMJ3GCRevisions)
+		mgmt.POST("/mj3gc/revisions/:revision/rollback", s.mgmt.RollbackMJ3GCStore)
+		mgmt.POST("/mj3gc/import", s.mgmt.PostMJ3GCImport)
+		mgmt.GET("/mj3gc/migration/public-key", s.mgmt.GetMJ3GCMigrationKey)
+		mgmt.POST("/mj3gc/migration/export", s.mgmt.ExportMJ3GCMigration)
+		mgmt.POST("/mj3gc/migration/import", s.mgmt.ImportMJ3GCMigration)
 	}
 }
 
@@ -791,6 +897,7 @@ func (s *Server) Stop(ctx context.Context) error {
 	if err := s.server.Shutdown(ctx); err != nil {
 		return fmt.Errorf("failed to shutdown HTTP server: %v", err)
 	}
+	mj3gc.DefaultStore().Stop()
 
 	log.Debug("API server stopped")
 	return nil
@@ -936,6 +1043,10 @@ func (s *Server) UpdateClients(cfg *config.Config) {
 		}
 	}
 
+	mj3gc.EnsureAccessProvider(cfg)
+	if err := mj3gc.DefaultStore().Reconfigure(cfg, s.configFilePath); err != nil {
+		log.Errorf("mj3gc: failed to reopen the key store: %v", err)
+	}
 	s.applyAccessConfig(oldCfg, cfg)
 	s.cfg = cfg
 	s.wsAuthEnabled.Store(cfg.WebsocketAuth)
@@ -1023,9 +1134,19 @@ func AuthMiddleware(manager *sdkaccess.Manager) gin.HandlerFunc {
 			return
 		}
 
+		var rejection *sdkaccess.RejectionError
 		switch {
 		case errors.Is(err, sdkaccess.ErrNoCredentials):
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Missing API key"})
+		case errors.As(err, &rejection):
+			body := gin.H{"error": rejection.Message, "code": rejection.Code}
+			if rejection.DocURL != "" {
+				body["doc_url"] = rejection.DocURL
+			}
+			if rejection.RetryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(int((rejection.RetryAfter+time.Second-1)/time.Second)))
+			}
+			c.AbortWithStatusJSON(rejection.Status, body)
 		case errors.Is(err, sdkaccess.ErrInvalidCredential):
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
 		default:
@@ -0,0 +1,322 @@
+// Package cmd contains CLI helpers. This file implements a terminal UI for the mj3gc
+// key store that talks to the management API of a running server, for operators who
+// manage servers over SSH without the web panel.
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/mj3gc"
+	log "github.com/sirupsen/logrus"
+)
+
+const mj3gcTUIRefreshInterval = 2 * time.Second
+
+// mj3gcTUIClient calls the mj3gc management endpoints of the local server.
+type mj3gcTUIClient struct {
+	baseURL string
+	key     string
+	client  *http.Client
+}
+
+func (c *mj3gcTUIClient) do(method, path string, body, out any) error {
+	var payload io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.key)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusBadRequest {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&apiErr)
+		if apiErr.Error == "" {
+			apiErr.Error = resp.Status
+		}
+		return fmt.Errorf("%s %s: %s", method, path, apiErr.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// mj3gcTUIState is everything the screen shows.
+type mj3gcTUIState struct {
+	keys     []mj3gc.APIKey
+	inflight map[string]int
+	selected int
+	status   string
+	updated  time.Time
+}
+
+func (c *mj3gcTUIClient) refresh(state *mj3gcTUIState) error {
+	var keys struct {
+		APIKeys []mj3gc.APIKey `json:"api_keys"`
+	}
+	if err := c.do(http.MethodGet, "/mj3gc/keys", nil, &keys); err != nil {
+		return err
+	}
+	var locks mj3gc.LockReport
+	if err := c.do(http.MethodGet, "/mj3gc/locks", nil, &locks); err != nil {
+		return err
+	}
+	sort.Slice(keys.APIKeys, func(i, j int) bool { return keys.APIKeys[i].ID < keys.APIKeys[j].ID })
+	state.keys = keys.APIKeys
+	state.inflight = locks.Inflight
+	state.updated = time.Now()
+	if state.selected >= len(state.keys) {
+		state.selected = len(state.keys) - 1
+	}
+	if state.selected < 0 {
+		state.selected = 0
+	}
+	return nil
+}
+
+// DoMJ3GCTUI runs the mj3gc terminal UI against the management API of the server
+// configured in cfg. The management key comes from -password or MANAGEMENT_PASSWORD.
+func DoMJ3GCTUI(cfg *config.Config, managementKey string) {
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+	managementKey = strings.TrimSpace(managementKey)
+	if managementKey == "" {
+		managementKey = strings.TrimSpace(os.Getenv("MANAGEMENT_PASSWORD"))
+	}
+	if managementKey == "" {
+		log.Errorf("mj3gc-tui: a management key is required (-password or MANAGEMENT_PASSWORD)")
+		return
+	}
+	scheme := "http"
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLS.Enable {
+		scheme = "https"
+		// The server is reached over loopback, usually with a self-signed certificate.
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &mj3gcTUIClient{
+		baseURL: fmt.Sprintf("%s://127.0.0.1:%d/v0/management", scheme, cfg.Port),
+		key:     managementKey,
+		client:  &http.Client{Timeout: 10 * time.Second, Transport: transport},
+	}
+	state := &mj3gcTUIState{}
+	if err := client.refresh(state); err != nil {
+		log.Errorf("mj3gc-tui: %v", err)
+		return
+	}
+
+	restore := enterRawTerminal()
+	defer restore()
+	defer fmt.Print("\x1b[?25h\n")
+	fmt.Print("\x1b[?25l")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	input := make(chan byte)
+	go func() {
+		reader := bufio.NewReader(os.Stdin)
+		for {
+			b, err := reader.ReadByte()
+			if err != nil {
+				cancel()
+				return
+			}
+			input <- b
+		}
+	}()
+
+	ticker := time.NewTicker(mj3gcTUIRefreshInterval)
+	defer ticker.Stop()
+	var pending byte
+	var escape []byte
+	for {
+		renderMJ3GCTUI(os.Stdout, state, pending)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := client.refresh(state); err != nil {
+				state.status = err.Error()
+			}
+		case b := <-input:
+			// Arrow keys arrive as ESC [ A / ESC [ B.
+			if b == 0x1b || len(escape) > 0 {
+				escape = append(escape, b)
+				if len(escape) < 3 {
+					continue
+				}
+				switch string(escape) {
+				case "\x1b[A":
+					b = 'k'
+				case "\x1b[B":
+					b = 'j'
+				}
+				escape = nil
+			}
+			if pending != 0 {
+				if b == 'y' || b == 'Y' {
+					state.status = client.apply(state, pending)
+					_ = client.refresh(state)
+				} else if b != '\n' && b != '\r' {
+					state.status = "cancelled"
+				} else {
+					continue
+				}
+				pending = 0
+				continue
+			}
+			switch b {
+			case 'q', 'Q', 0x03:
+				return
+			case 'j':
+				if state.selected < len(state.keys)-1 {
+					state.selected++
+				}
+			case 'k':
+				if state.selected > 0 {
+					state.selected--
+				}
+			case 'e', 'd', 'r':
+				if len(state.keys) > 0 {
+					pending = b
+				}
+			case 'g':
+				if err := client.refresh(state); err != nil {
+					state.status = err.Error()
+				}
+			}
+		}
+	}
+}
+
+// apply performs the confirmed action on the selected key and returns a status line.
+func (c *mj3gcTUIClient) apply(state *mj3gcTUIState, action byte) string {
+	key := state.keys[state.selected]
+	var resp struct {
+		APIKey mj3gc.APIKey `json:"api_key"`
+	}
+	if action == 'r' {
+		body := map[string]any{"reason": "rotated from mj3gc tui"}
+		if err := c.do(http.MethodPost, "/mj3gc/keys/"+url.PathEscape(key.ID)+"/rotate", body, &resp); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprintf("rotated %s, new key: %s", key.ID, resp.APIKey.Key)
+	}
+	body := map[string]any{"id": key.ID, "enabled": action == 'e'}
+	if err := c.do(http.MethodPut, "/mj3gc/keys", body, &resp); err != nil {
+		return err.Error()
+	}
+	if action == 'e' {
+		return "enabled " + key.ID
+	}
+	return "disabled " + key.ID
+}
+
+func renderMJ3GCTUI(w io.Writer, state *mj3gcTUIState, pending byte) {
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&sb, "mj3gc keys  %d total  updated %s\r\n\r\n", len(state.keys), state.updated.Format("15:04:05"))
+	fmt.Fprintf(&sb, "  %-24s %-20s %-8s %-21s %-9s %-16s %s\r\n", "ID", "LABEL", "STATE", "USED/LIMIT", "INFLIGHT", "LAST USED", "LAST IP")
+	for i, key := range state.keys {
+		line := fmt.Sprintf("  %-24s %-20s %-8s %-21s %-9s %-16s %s",
+			truncateTUI(key.ID, 24),
+			truncateTUI(key.Label, 20),
+			map[bool]string{true: "enabled", false: "disabled"}[key.Enabled],
+			fmt.Sprintf("%d/%s", key.UsedCount, limitTUI(key.TotalLimit)),
+			fmt.Sprintf("%d/%s", state.inflight[key.ID], limitTUI(int64(key.ConcurrencyLimit))),
+			lastUsedTUI(key.LastUsedAt),
+			key.LastUsedIP,
+		)
+		if i == state.selected {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		sb.WriteString(line + "\r\n")
+	}
+	sb.WriteString("\r\n")
+	switch pending {
+	case 'e', 'd', 'r':
+		verb := map[byte]string{'e': "enable", 'd': "disable", 'r': "rotate"}[pending]
+		fmt.Fprintf(&sb, "%s %s? [y/N]\r\n", verb, state.keys[state.selected].ID)
+	default:
+		sb.WriteString("j/k move  e enable  d disable  r rotate  g refresh  q quit\r\n")
+	}
+	if state.status != "" {
+		sb.WriteString(state.status + "\r\n")
+	}
+	_, _ = io.WriteString(w, sb.String())
+}
+
+func truncateTUI(value string, width int) string {
+	if len(value) <= width {
+		return value
+	}
+	return value[:width-1] + "~"
+}
+
+func limitTUI(limit int64) string {
+	if limit <= 0 {
+		return "-"
+	}
+	return fmt.Sprint(limit)
+}
+
+func lastUsedTUI(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return time.Since(t).Round(time.Second).String() + " ago"
+}
+
+// enterRawTerminal switches stdin to unbuffered, non-echoing input using stty and
+// returns a function restoring the previous mode. Without stty (for example on
+// Windows) keys are read after Enter instead.
+func enterRawTerminal() func() {
+	saved, err := stty("-g")
+	if err != nil {
+		return func() {}
+	}
+	if _, err := stty("-icanon", "-echo", "min", "1"); err != nil {
+		return func() {}
+	}
+	return func() { _, _ = stty(strings.TrimSpace(saved)) }
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -97,6 +97,9 @@ type Config struct {
 	// Payload defines default and override rules for provider payload parameters.
 	Payload PayloadConfig `yaml:"payload" json:"payload"`
 
+	// MJ3GC configures the built-in user and API key store.
+	MJ3GC MJ3GCConfig `yaml:"mj3gc" json:"mj3gc"`
+
 	legacyMigrationPending bool `yaml:"-" json:"-"`
 }
 
@@ -121,6 +124,13 @@ type RemoteManagement struct {
 	// PanelGitHubRepository overrides the GitHub repository used to fetch the management panel asset.
 	// Accepts either a repository URL (https://github.com/org/repo) or an API releases endpoint.
 	PanelGitHubRepository string `yaml:"panel-github-repository"`
+	// RateLimitPerMinute caps management requests per admin credential and client IP.
+	// Zero uses the default of 600; a negative value disables the limit.
+	RateLimitPerMinute int `yaml:"rate-limit-per-minute,omitempty"`
+	// RevealSecrets lists the management credentials (local-password, env-secret,
+	// secret-key) allowed to see raw mj3gc key secrets. Others get masked values.
+	// Empty keeps secrets visible to every credential.
+	RevealSecrets []string `yaml:"reveal-secrets,omitempty"`
 }
 
 // OAuthConfig defines OAuth client settings for providers using OAuth.
@@ -243,6 +253,15 @@ type ClaudeKey struct {
 
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// Residency tags the region this credential processes data in (e.g. "eu"). Requests
+	// from client keys bound to a residency are only routed to credentials with the same tag.
+	Residency string `yaml:"residency,omitempty" json:"residency,omitempty"`
+
+	// Pool names the upstream account pool this credential belongs to (e.g. a customer
+	// it is contractually assigned to). Requests from client keys bound to a pool are only
+	// routed to credentials of that pool.
+	Pool string `yaml:"pool,omitempty" json:"pool,omitempty"`
 }
 
 // ClaudeModel describes a mapping between an alias and the actual upstream model name.
@@ -275,6 +294,12 @@ type CodexKey struct {
 
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// Residency optionally tags the data region of this key (see ClaudeKey.Residency).
+	Residency string `yaml:"residency,omitempty" json:"residency,omitempty"`
+
+	// Pool optionally names the upstream account pool of this key (see ClaudeKey.Pool).
+	Pool string `yaml:"pool,omitempty" json:"pool,omitempty"`
 }
 
 // GeminiKey represents the configuration for a Gemini API key,
@@ -297,6 +322,12 @@ type GeminiKey struct {
 
 	// ExcludedModels lists model IDs that should be excluded for this provider.
 	ExcludedModels []string `yaml:"excluded-models,omitempty" json:"excluded-models,omitempty"`
+
+	// Residency optionally tags the data region of this key (see ClaudeKey.Residency).
+	Residency string `yaml:"residency,omitempty" json:"residency,omitempty"`
+
+	// Pool optionally names the upstream account pool of this key (see ClaudeKey.Pool).
+	Pool string `yaml:"pool,omitempty" json:"pool,omitempty"`
 }
 
 // OpenAICompatibility represents the configuration for OpenAI API compatibility
@@ -319,6 +350,12 @@ type OpenAICompatibility struct {
 
 	// Headers optionally adds extra HTTP headers for requests sent to this provider.
 	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+
+	// Residency optionally tags the data region of this provider (see ClaudeKey.Residency).
+	Residency string `yaml:"residency,omitempty" json:"residency,omitempty"`
+
+	// Pool optionally names the upstream account pool of this provider (see ClaudeKey.Pool).
+	Pool string `yaml:"pool,omitempty" json:"pool,omitempty"`
 }
 
 // OpenAICompatibilityAPIKey represents an API key configuration with optional proxy setting.
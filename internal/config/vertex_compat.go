@@ -30,6 +30,12 @@ type VertexCompatKey struct {
 
 	// Models defines the model configurations including aliases for routing.
 	Models []VertexCompatModel `yaml:"models,omitempty" json:"models,omitempty"`
+
+	// Residency optionally tags the data region of this key (see ClaudeKey.Residency).
+	Residency string `yaml:"residency,omitempty" json:"residency,omitempty"`
+
+	// Pool optionally names the upstream account pool of this key (see ClaudeKey.Pool).
+	Pool string `yaml:"pool,omitempty" json:"pool,omitempty"`
 }
 
 // VertexCompatModel represents a model configuration for Vertex compatibility,
@@ -0,0 +1,50 @@
+package mj3gc
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Count policies decide which finished requests consume a key's quota.
+const (
+	// CountSuccess counts responses below 400, including streams the client abandoned
+	// after a successful status was written. It is the default.
+	CountSuccess = "success"
+	// CountAll counts every admitted request regardless of its outcome.
+	CountAll = "all"
+	// Count2xx counts only 2xx responses.
+	Count2xx = "2xx"
+	// Count2xxAndAborts counts 2xx responses and requests the client abandoned
+	// mid-response, since upstream tokens were consumed either way.
+	Count2xxAndAborts = "2xx-and-aborts"
+)
+
+// NormalizeCountPolicy validates a count policy, mapping blank to the default.
+func NormalizeCountPolicy(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "", CountSuccess:
+		return "", nil
+	case CountAll, Count2xx, Count2xxAndAborts:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported count policy %q", policy)
+	}
+}
+
+// CountsRequest reports whether a request that finished with status, or was aborted
+// by the client, consumes quota under the key's count policy.
+func (k APIKey) CountsRequest(status int, aborted bool) bool {
+	is2xx := status >= http.StatusOK && status < http.StatusMultipleChoices
+	switch k.CountPolicy {
+	case CountAll:
+		return true
+	case Count2xx:
+		return is2xx && !aborted
+	case Count2xxAndAborts:
+		return is2xx || aborted
+	default:
+		return status < http.StatusBadRequest
+	}
+}
@@ -0,0 +1,43 @@
+package mj3gc
+
+import "testing"
+
+func TestAPIKeyCountsRequest(t *testing.T) {
+	cases := []struct {
+		policy  string
+		status  int
+		aborted bool
+		want    bool
+	}{
+		{"", 200, false, true},
+		{"", 302, false, true},
+		{"", 200, true, true},
+		{"", 502, false, false},
+		{CountAll, 502, false, true},
+		{CountAll, 429, true, true},
+		{Count2xx, 302, false, false},
+		{Count2xx, 200, true, false},
+		{Count2xx, 201, false, true},
+		{Count2xxAndAborts, 200, true, true},
+		{Count2xxAndAborts, 500, true, true},
+		{Count2xxAndAborts, 500, false, false},
+	}
+	for _, tc := range cases {
+		key := APIKey{CountPolicy: tc.policy}
+		if got := key.CountsRequest(tc.status, tc.aborted); got != tc.want {
+			t.Errorf("policy %q status %d aborted %v: got %v, want %v", tc.policy, tc.status, tc.aborted, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizeCountPolicy(t *testing.T) {
+	if policy, err := NormalizeCountPolicy(" Success "); err != nil || policy != "" {
+		t.Fatalf("NormalizeCountPolicy(success) = %q, %v", policy, err)
+	}
+	if policy, err := NormalizeCountPolicy("2XX-and-aborts"); err != nil || policy != Count2xxAndAborts {
+		t.Fatalf("NormalizeCountPolicy(2xx-and-aborts) = %q, %v", policy, err)
+	}
+	if _, err := NormalizeCountPolicy("never"); err == nil {
+		t.Fatal("expected error for unknown policy")
+	}
+}
@@ -0,0 +1,165 @@
+package mj3gc
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultAdmissionQueue = 10 * time.Second
+
+// ErrServerSaturated rejects a request that found mj3gc.max-concurrent-requests reached
+// and was shed by its priority class or waited longer than mj3gc.admission-queue-ms.
+var ErrServerSaturated = errors.New("server saturated, retry later")
+
+// admissionWaiter is a request queued for a slot of the admission gate.
+type admissionWaiter struct {
+	level    int
+	seq      uint64
+	ready    chan struct{}
+	admitted bool
+	index    int
+}
+
+// admissionQueue orders waiters by level, highest first, then by arrival.
+type admissionQueue []*admissionWaiter
+
+func (q admissionQueue) Len() int { return len(q) }
+
+func (q admissionQueue) Less(i, j int) bool {
+	if q[i].level != q[j].level {
+		return q[i].level > q[j].level
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q admissionQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+
+func (q *admissionQueue) Push(x any) {
+	w := x.(*admissionWaiter)
+	w.index = len(*q)
+	*q = append(*q, w)
+}
+
+func (q *admissionQueue) Pop() any {
+	old := *q
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*q = old[:len(old)-1]
+	return w
+}
+
+// admissionGate bounds the key requests in flight across the server. When it is full,
+// requests queue and freed slots go to the highest priority level waiting.
+type admissionGate struct {
+	mu       sync.Mutex
+	inflight int
+	queue    admissionQueue
+	seq      uint64
+}
+
+// dispatchLocked admits queued requests while slots are free. A limit of zero, left
+// by a reload that removed the cap, admits all of them.
+func (g *admissionGate) dispatchLocked(limit int) {
+	for g.queue.Len() > 0 && (limit <= 0 || g.inflight < limit) {
+		w := heap.Pop(&g.queue).(*admissionWaiter)
+		g.inflight++
+		w.admitted = true
+		close(w.ready)
+	}
+}
+
+// acquire takes a slot for a request of the given level, waiting up to wait while the
+// gate is full. shed rejects the request at once instead of queueing it.
+func (g *admissionGate) acquire(ctx context.Context, limit, level int, shed bool, wait time.Duration) error {
+	g.mu.Lock()
+	if g.inflight < limit && g.queue.Len() == 0 {
+		g.inflight++
+		g.mu.Unlock()
+		return nil
+	}
+	if shed {
+		g.mu.Unlock()
+		return ErrServerSaturated
+	}
+	g.seq++
+	w := &admissionWaiter{level: level, seq: g.seq, ready: make(chan struct{})}
+	heap.Push(&g.queue, w)
+	g.dispatchLocked(limit)
+	g.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	var err error
+	select {
+	case <-w.ready:
+		return nil
+	case <-timer.C:
+		err = ErrServerSaturated
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if w.admitted {
+		// The slot was handed over while the wait ended; keep it rather than lose it.
+		return nil
+	}
+	heap.Remove(&g.queue, w.index)
+	return err
+}
+
+// release frees a slot taken by acquire and hands it to the next queued request.
+func (g *admissionGate) release(limit int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inflight > 0 {
+		g.inflight--
+	}
+	g.dispatchLocked(limit)
+}
+
+// counts returns the requests holding and waiting for a slot.
+func (g *admissionGate) counts() (inflight, queued int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.inflight, g.queue.Len()
+}
+
+// Admit waits for a slot under mj3gc.max-concurrent-requests for a request of the key
+// authenticated as principal. Requests are admitted by the level of the key's priority
+// class, then in arrival order; classes with shed-when-saturated, and requests still
+// waiting after mj3gc.admission-queue-ms, get ErrServerSaturated. The returned release
+// must be called when the request ends. Without a cap every request is admitted.
+func (s *Store) Admit(ctx context.Context, principal string) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	settings := s.Settings()
+	if settings.MaxConcurrentRequests <= 0 {
+		return func() {}, nil
+	}
+	level, shed := 0, false
+	if key, ok := s.FindAPIKeyByPrincipal(principal); ok {
+		if class, found := priorityClass(settings, key); found {
+			level, shed = class.Level, class.ShedWhenSaturated
+		}
+	}
+	wait := defaultAdmissionQueue
+	if settings.AdmissionQueueMs > 0 {
+		wait = time.Duration(settings.AdmissionQueueMs) * time.Millisecond
+	}
+	if err := s.admission.acquire(ctx, settings.MaxConcurrentRequests, level, shed, wait); err != nil {
+		return nil, err
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() { s.admission.release(s.Settings().MaxConcurrentRequests) })
+	}, nil
+}
@@ -0,0 +1,97 @@
+package mj3gc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func newAdmissionStore(queueMs int) *Store {
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{
+		PriorityClasses: []config.MJ3GCPriorityClass{
+			{Name: "premium", Level: 2},
+			{Name: "standard", Level: 1},
+			{Name: "low", ShedWhenSaturated: true},
+		},
+		DefaultPriority:       "standard",
+		MaxConcurrentRequests: 1,
+		AdmissionQueueMs:      queueMs,
+	}})
+	s.data.APIKeys = []APIKey{
+		{ID: "key-premium", Key: "p", Enabled: true, Priority: "premium"},
+		{ID: "key-standard", Key: "s", Enabled: true},
+		{ID: "key-low", Key: "l", Enabled: true, Priority: "low"},
+	}
+	return s
+}
+
+func TestAdmitQueuesByPriorityLevel(t *testing.T) {
+	s := newAdmissionStore(5000)
+	ctx := context.Background()
+	release, err := s.Admit(ctx, "s")
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+
+	order := make(chan string, 2)
+	admit := func(principal string) {
+		next, err := s.Admit(ctx, principal)
+		if err != nil {
+			t.Errorf("%s: %v", principal, err)
+			return
+		}
+		order <- principal
+		next()
+	}
+	go admit("s")
+	waitQueued(t, s, 1)
+	go admit("p")
+	waitQueued(t, s, 2)
+
+	release()
+	if first, second := <-order, <-order; first != "p" || second != "s" {
+		t.Fatalf("admitted %s then %s, want the premium key first", first, second)
+	}
+	if inflight, queued := s.admission.counts(); inflight != 0 || queued != 0 {
+		t.Fatalf("in flight %d, queued %d after all requests ended", inflight, queued)
+	}
+}
+
+func TestAdmitShedsWhenSaturated(t *testing.T) {
+	s := newAdmissionStore(20)
+	release, err := s.Admit(context.Background(), "p")
+	if err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	defer release()
+
+	if _, err := s.Admit(context.Background(), "l"); !errors.Is(err, ErrServerSaturated) {
+		t.Fatalf("shed-when-saturated class: err=%v, want ErrServerSaturated", err)
+	}
+	start := time.Now()
+	if _, err := s.Admit(context.Background(), "s"); !errors.Is(err, ErrServerSaturated) {
+		t.Fatalf("queued past admission-queue-ms: err=%v, want ErrServerSaturated", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Fatalf("queued request gave up after %v", waited)
+	}
+	if _, queued := s.admission.counts(); queued != 0 {
+		t.Fatalf("timed out request still queued")
+	}
+}
+
+func waitQueued(t *testing.T, s *Store, want int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, queued := s.admission.counts(); queued == want {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("queue never reached %d requests", want)
+}
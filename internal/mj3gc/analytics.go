@@ -0,0 +1,231 @@
+package mj3gc
+
+import (
+	"sort"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// promptSizeBounds are the upper bounds, in input tokens, of the prompt-size buckets.
+var promptSizeBounds = []int64{256, 1024, 4096, 16384, 32768, 65536, 131072, 200000}
+
+// RequestSample is one completed request as seen by the usage statistics.
+type RequestSample struct {
+	Timestamp       time.Time `json:"timestamp"`
+	Model           string    `json:"model"`
+	Failed          bool      `json:"failed"`
+	InputTokens     int64     `json:"input_tokens"`
+	OutputTokens    int64     `json:"output_tokens"`
+	ReasoningTokens int64     `json:"reasoning_tokens"`
+	CachedTokens    int64     `json:"cached_tokens"`
+	TotalTokens     int64     `json:"total_tokens"`
+	LatencyMs       int64     `json:"latency_ms,omitempty"`
+}
+
+// CostedRequest is a request with its estimated cost.
+type CostedRequest struct {
+	RequestSample
+	Cost float64 `json:"cost"`
+}
+
+// PromptSizeStats summarises the distribution of prompt (input token) sizes. Counts has
+// one more entry than BoundsTokens for prompts above the last bound.
+type PromptSizeStats struct {
+	BoundsTokens []int64 `json:"bounds_tokens"`
+	Counts       []int64 `json:"counts"`
+	Mean         float64 `json:"mean"`
+	P50          int64   `json:"p50"`
+	P90          int64   `json:"p90"`
+	P99          int64   `json:"p99"`
+	Max          int64   `json:"max"`
+}
+
+// KeyAnalytics aggregates prompt sizes and the most expensive requests of one key.
+type KeyAnalytics struct {
+	KeyID      string          `json:"key_id"`
+	Label      string          `json:"label,omitempty"`
+	Requests   int             `json:"requests"`
+	TotalCost  float64         `json:"total_cost"`
+	PromptSize PromptSizeStats `json:"prompt_size"`
+	TopByCost  []CostedRequest `json:"top_by_cost"`
+}
+
+// EstimateCost prices a request with the first mj3gc.model-prices entry matching its
+// model and in force at its timestamp. Cached input tokens use the cached rate when one
+// is configured. Unpriced models cost zero.
+func EstimateCost(prices []config.MJ3GCModelPrice, sample RequestSample) float64 {
+	for _, price := range prices {
+		if !matchPattern(price.Model, sample.Model) || !priceInForce(price, sample.Timestamp) {
+			continue
+		}
+		cached := sample.CachedTokens
+		if price.CachedPerMillion <= 0 {
+			cached = 0
+		}
+		input := sample.InputTokens - cached
+		if input < 0 {
+			input = 0
+		}
+		output := sample.OutputTokens + sample.ReasoningTokens
+		return (float64(input)*price.InputPerMillion +
+			float64(cached)*price.CachedPerMillion +
+			float64(output)*price.OutputPerMillion) / 1e6
+	}
+	return 0
+}
+
+// AnalyzeRequests builds prompt-size and cost analytics for key from samples, keeping
+// the top most expensive requests.
+func AnalyzeRequests(settings config.MJ3GCConfig, key APIKey, samples []RequestSample, top int) KeyAnalytics {
+	out := KeyAnalytics{
+		KeyID:    key.ID,
+		Label:    key.Label,
+		Requests: len(samples),
+		PromptSize: PromptSizeStats{
+			BoundsTokens: promptSizeBounds,
+			Counts:       make([]int64, len(promptSizeBounds)+1),
+		},
+	}
+	if len(samples) == 0 {
+		out.TopByCost = []CostedRequest{}
+		return out
+	}
+	sizes := make([]int64, 0, len(samples))
+	costed := make([]CostedRequest, 0, len(samples))
+	var sum int64
+	for _, sample := range samples {
+		size := sample.InputTokens
+		sizes = append(sizes, size)
+		sum += size
+		idx := sort.Search(len(promptSizeBounds), func(i int) bool { return promptSizeBounds[i] >= size })
+		out.PromptSize.Counts[idx]++
+
+		cost := EstimateCost(settings.ModelPrices, sample)
+		out.TotalCost += cost
+		costed = append(costed, CostedRequest{RequestSample: sample, Cost: cost})
+	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	out.PromptSize.Mean = float64(sum) / float64(len(sizes))
+	out.PromptSize.P50 = percentile(sizes, 0.50)
+	out.PromptSize.P90 = percentile(sizes, 0.90)
+	out.PromptSize.P99 = percentile(sizes, 0.99)
+	out.PromptSize.Max = sizes[len(sizes)-1]
+
+	// Without prices, rank by total tokens so the list is still useful.
+	sort.SliceStable(costed, func(i, j int) bool {
+		if costed[i].Cost != costed[j].Cost {
+			return costed[i].Cost > costed[j].Cost
+		}
+		return costed[i].TotalTokens > costed[j].TotalTokens
+	})
+	if top > 0 && len(costed) > top {
+		costed = costed[:top]
+	}
+	out.TopByCost = costed
+	return out
+}
+
+// ModelStats aggregates the requests served by one model. Latency percentiles only
+// cover requests that reported a latency; average tokens only successful requests.
+type ModelStats struct {
+	Model        string  `json:"model"`
+	Requests     int64   `json:"requests"`
+	Failures     int64   `json:"failures"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgTokens    float64 `json:"avg_tokens"`
+	P50LatencyMs int64   `json:"p50_latency_ms"`
+	P95LatencyMs int64   `json:"p95_latency_ms"`
+}
+
+// AggregateModelStats groups samples by model and returns one entry per model, ordered
+// by request count and then by name.
+func AggregateModelStats(samples []RequestSample) []ModelStats {
+	type accumulator struct {
+		stats     ModelStats
+		tokens    int64
+		latencies []int64
+	}
+	byModel := make(map[string]*accumulator)
+	for _, sample := range samples {
+		acc, ok := byModel[sample.Model]
+		if !ok {
+			acc = &accumulator{stats: ModelStats{Model: sample.Model}}
+			byModel[sample.Model] = acc
+		}
+		acc.stats.Requests++
+		if sample.Failed {
+			acc.stats.Failures++
+		} else {
+			acc.tokens += sample.TotalTokens
+		}
+		if sample.LatencyMs > 0 {
+			acc.latencies = append(acc.latencies, sample.LatencyMs)
+		}
+	}
+	out := make([]ModelStats, 0, len(byModel))
+	for _, acc := range byModel {
+		stats := acc.stats
+		stats.ErrorRate = float64(stats.Failures) / float64(stats.Requests)
+		if succeeded := stats.Requests - stats.Failures; succeeded > 0 {
+			stats.AvgTokens = float64(acc.tokens) / float64(succeeded)
+		}
+		sort.Slice(acc.latencies, func(i, j int) bool { return acc.latencies[i] < acc.latencies[j] })
+		stats.P50LatencyMs = percentile(acc.latencies, 0.50)
+		stats.P95LatencyMs = percentile(acc.latencies, 0.95)
+		out = append(out, stats)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Requests != out[j].Requests {
+			return out[i].Requests > out[j].Requests
+		}
+		return out[i].Model < out[j].Model
+	})
+	return out
+}
+
+// ModelHealth is the recent success rate and median latency of a model.
+type ModelHealth struct {
+	Model        string  `json:"model"`
+	Requests     int64   `json:"requests"`
+	SuccessRate  float64 `json:"success_rate"`
+	P50LatencyMs int64   `json:"p50_latency_ms"`
+}
+
+// HealthOf returns the health summary of stats.
+func HealthOf(stats ModelStats) ModelHealth {
+	return ModelHealth{Model: stats.Model, Requests: stats.Requests, SuccessRate: 1 - stats.ErrorRate, P50LatencyMs: stats.P50LatencyMs}
+}
+
+// SortModelHealth orders models by success rate, then by median latency, with models
+// lacking a latency last among equals.
+func SortModelHealth(models []ModelHealth) {
+	sort.SliceStable(models, func(i, j int) bool {
+		a, b := models[i], models[j]
+		if a.SuccessRate != b.SuccessRate {
+			return a.SuccessRate > b.SuccessRate
+		}
+		if (a.P50LatencyMs == 0) != (b.P50LatencyMs == 0) {
+			return b.P50LatencyMs == 0
+		}
+		if a.P50LatencyMs != b.P50LatencyMs {
+			return a.P50LatencyMs < b.P50LatencyMs
+		}
+		return a.Model < b.Model
+	})
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(p*float64(len(sorted))+0.999999) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -0,0 +1,95 @@
+package mj3gc
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestAnalyzeRequests(t *testing.T) {
+	settings := config.MJ3GCConfig{ModelPrices: []config.MJ3GCModelPrice{
+		{Model: "big-*", InputPerMillion: 10, OutputPerMillion: 30, CachedPerMillion: 1},
+		{Model: "*", InputPerMillion: 1, OutputPerMillion: 2},
+	}}
+	samples := []RequestSample{
+		{Model: "small", InputTokens: 100, OutputTokens: 50},
+		{Model: "big-1", InputTokens: 5000, OutputTokens: 1000, CachedTokens: 1000},
+		{Model: "small", InputTokens: 300000, OutputTokens: 10},
+	}
+	got := AnalyzeRequests(settings, APIKey{ID: "key-1"}, samples, 2)
+
+	if got.Requests != 3 || len(got.TopByCost) != 2 {
+		t.Fatalf("requests=%d top=%d, want 3 and 2", got.Requests, len(got.TopByCost))
+	}
+	// 300000*1 + 10*2 = 300020 per million.
+	if top := got.TopByCost[0]; top.InputTokens != 300000 || math.Abs(top.Cost-0.30002) > 1e-9 {
+		t.Fatalf("top request = %+v, want the 300k prompt costing 0.30002", top)
+	}
+	// big-1: 4000*10 + 1000*1 + 1000*30 = 71000 per million.
+	if second := got.TopByCost[1]; second.Model != "big-1" || math.Abs(second.Cost-0.071) > 1e-9 {
+		t.Fatalf("second request = %+v, want big-1 costing 0.071", second)
+	}
+	counts := got.PromptSize.Counts
+	if counts[0] != 1 || counts[3] != 1 || counts[len(counts)-1] != 1 {
+		t.Fatalf("prompt size buckets = %v", counts)
+	}
+	if got.PromptSize.P50 != 5000 || got.PromptSize.Max != 300000 {
+		t.Fatalf("p50=%d max=%d, want 5000 and 300000", got.PromptSize.P50, got.PromptSize.Max)
+	}
+}
+
+func TestAggregateModelStats(t *testing.T) {
+	var samples []RequestSample
+	for i := int64(1); i <= 20; i++ {
+		samples = append(samples, RequestSample{Model: "fast", TotalTokens: 100, LatencyMs: i * 10})
+	}
+	samples = append(samples,
+		RequestSample{Model: "slow", TotalTokens: 300, LatencyMs: 900},
+		RequestSample{Model: "slow", Failed: true, LatencyMs: 50},
+		RequestSample{Model: "slow", TotalTokens: 100},
+	)
+
+	stats := AggregateModelStats(samples)
+	if len(stats) != 2 || stats[0].Model != "fast" || stats[1].Model != "slow" {
+		t.Fatalf("stats = %+v", stats)
+	}
+	fast := stats[0]
+	if fast.Requests != 20 || fast.ErrorRate != 0 || fast.AvgTokens != 100 {
+		t.Fatalf("fast = %+v", fast)
+	}
+	if fast.P50LatencyMs != 100 || fast.P95LatencyMs != 190 {
+		t.Fatalf("fast latency p50 = %d, p95 = %d", fast.P50LatencyMs, fast.P95LatencyMs)
+	}
+	slow := stats[1]
+	if slow.Requests != 3 || slow.Failures != 1 || slow.AvgTokens != 200 {
+		t.Fatalf("slow = %+v", slow)
+	}
+	if math.Abs(slow.ErrorRate-1.0/3) > 1e-9 {
+		t.Fatalf("slow error rate = %v", slow.ErrorRate)
+	}
+	if slow.P50LatencyMs != 50 || slow.P95LatencyMs != 900 {
+		t.Fatalf("slow latency p50 = %d, p95 = %d", slow.P50LatencyMs, slow.P95LatencyMs)
+	}
+}
+
+func TestSortModelHealth(t *testing.T) {
+	models := []ModelHealth{
+		HealthOf(ModelStats{Model: "flaky", Requests: 10, ErrorRate: 0.5, P50LatencyMs: 100}),
+		HealthOf(ModelStats{Model: "slow", Requests: 10, P50LatencyMs: 900}),
+		HealthOf(ModelStats{Model: "unmeasured", Requests: 1}),
+		HealthOf(ModelStats{Model: "fast", Requests: 10, P50LatencyMs: 200}),
+	}
+	SortModelHealth(models)
+	var order []string
+	for _, m := range models {
+		order = append(order, m.Model)
+	}
+	if got := strings.Join(order, ","); got != "fast,slow,unmeasured,flaky" {
+		t.Fatalf("order = %s", got)
+	}
+	if models[3].SuccessRate != 0.5 {
+		t.Fatalf("success rate = %v", models[3].SuccessRate)
+	}
+}
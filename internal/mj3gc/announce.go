@@ -0,0 +1,284 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const announcementWebhookTimeout = 10 * time.Second
+
+var (
+	ErrAnnouncementNotFound = errors.New("announcement not found")
+	ErrInvalidAnnouncement  = errors.New("invalid announcement")
+)
+
+// Announcement is an operator message to portal users, such as a maintenance window
+// or a pricing change. It is pushed to the configured notification channels and
+// listed in the portal notification feed, where pinned announcements sort first.
+type Announcement struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	// UserIDs restricts the audience; empty means every user.
+	UserIDs []string `json:"user_ids,omitempty"`
+	// DeliveredTo and ReadBy map user IDs to when the announcement was first shown
+	// in their feed and when they marked it read.
+	DeliveredTo map[string]time.Time `json:"delivered_to,omitempty"`
+	ReadBy      map[string]time.Time `json:"read_by,omitempty"`
+	Channels    []ChannelDelivery    `json:"channels,omitempty"`
+}
+
+// ChannelDelivery is the outcome of pushing an announcement to one notification channel.
+type ChannelDelivery struct {
+	Channel string    `json:"channel"`
+	Status  string    `json:"status"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Delivery statuses recorded in ChannelDelivery.
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// AnnouncementView is an announcement as seen by one portal user.
+type AnnouncementView struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Pinned    bool      `json:"pinned,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+	Read      bool      `json:"read"`
+}
+
+func (a Announcement) addressedTo(userID string) bool {
+	if len(a.UserIDs) == 0 {
+		return true
+	}
+	for _, id := range a.UserIDs {
+		if id == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (a Announcement) expired(now time.Time) bool {
+	return !a.ExpiresAt.IsZero() && !now.Before(a.ExpiresAt)
+}
+
+// CreateAnnouncement stores a new announcement and pushes it to every configured
+// notification channel in the background. Delivery results are recorded on the
+// announcement as they arrive.
+func (s *Store) CreateAnnouncement(a Announcement) (Announcement, error) {
+	if s == nil {
+		return Announcement{}, ErrInvalidConfiguration
+	}
+	a.Title = strings.TrimSpace(a.Title)
+	a.Body = strings.TrimSpace(a.Body)
+	if a.Title == "" {
+		return Announcement{}, fmt.Errorf("%w: title is required", ErrInvalidAnnouncement)
+	}
+	for _, channel := range s.Settings().NotificationWebhooks {
+		if err := ValidateShadowURL(channel); err != nil {
+			return Announcement{}, fmt.Errorf("%w: notification webhook %q: %v", ErrInvalidAnnouncement, channel, err)
+		}
+	}
+	now := time.Now().UTC()
+	a.ID = newID("ann")
+	a.CreatedAt = now
+	a.DeliveredTo = nil
+	a.ReadBy = nil
+	a.Channels = nil
+	for _, channel := range s.Settings().NotificationWebhooks {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			a.Channels = append(a.Channels, ChannelDelivery{Channel: channel, Status: DeliveryPending, At: now})
+		}
+	}
+	func() {
+		defer s.lock("CreateAnnouncement")()
+		s.data.Announcements = append(s.data.Announcements, a)
+	}()
+	for _, delivery := range a.Channels {
+		go s.deliverAnnouncement(a, delivery.Channel)
+	}
+	return a, nil
+}
+
+// ListAnnouncements returns every announcement, newest first.
+func (s *Store) ListAnnouncements() []Announcement {
+	if s == nil {
+		return nil
+	}
+	defer s.rlock("ListAnnouncements")()
+	out := make([]Announcement, len(s.data.Announcements))
+	copy(out, s.data.Announcements)
+	sort.SliceStable(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// DeleteAnnouncement removes an announcement from every feed.
+func (s *Store) DeleteAnnouncement(id string) error {
+	if s == nil {
+		return ErrInvalidConfiguration
+	}
+	defer s.lock("DeleteAnnouncement")()
+	for i, a := range s.data.Announcements {
+		if a.ID == id {
+			s.data.Announcements = append(s.data.Announcements[:i:i], s.data.Announcements[i+1:]...)
+			return nil
+		}
+	}
+	return ErrAnnouncementNotFound
+}
+
+// AnnouncementsForUser returns the unexpired announcements addressed to userID, pinned
+// first and then newest first, and records their delivery to the user. The boolean
+// reports whether delivery tracking changed and the store should be saved.
+func (s *Store) AnnouncementsForUser(userID string) ([]AnnouncementView, bool) {
+	if s == nil || userID == "" {
+		return nil, false
+	}
+	now := time.Now().UTC()
+	defer s.lock("AnnouncementsForUser")()
+	changed := false
+	out := make([]AnnouncementView, 0)
+	for i, a := range s.data.Announcements {
+		if a.expired(now) || !a.addressedTo(userID) {
+			continue
+		}
+		if _, ok := a.DeliveredTo[userID]; !ok {
+			// Replace rather than mutate the map so earlier snapshots stay unchanged.
+			s.data.Announcements[i].DeliveredTo = withUserTime(a.DeliveredTo, userID, now)
+			changed = true
+		}
+		_, read := a.ReadBy[userID]
+		out = append(out, AnnouncementView{
+			ID:        a.ID,
+			Title:     a.Title,
+			Body:      a.Body,
+			Pinned:    a.Pinned,
+			CreatedAt: a.CreatedAt,
+			ExpiresAt: a.ExpiresAt,
+			Read:      read,
+		})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Pinned != out[j].Pinned {
+			return out[i].Pinned
+		}
+		return out[i].CreatedAt.After(out[j].CreatedAt)
+	})
+	return out, changed
+}
+
+// MarkAnnouncementRead records that userID has read the announcement.
+func (s *Store) MarkAnnouncementRead(id, userID string) error {
+	if s == nil {
+		return ErrInvalidConfiguration
+	}
+	if userID == "" {
+		return ErrAnnouncementNotFound
+	}
+	now := time.Now().UTC()
+	defer s.lock("MarkAnnouncementRead")()
+	for i, a := range s.data.Announcements {
+		if a.ID != id || !a.addressedTo(userID) || a.expired(now) {
+			continue
+		}
+		if _, ok := a.DeliveredTo[userID]; !ok {
+			s.data.Announcements[i].DeliveredTo = withUserTime(a.DeliveredTo, userID, now)
+		}
+		if _, ok := a.ReadBy[userID]; !ok {
+			s.data.Announcements[i].ReadBy = withUserTime(a.ReadBy, userID, now)
+		}
+		return nil
+	}
+	return ErrAnnouncementNotFound
+}
+
+func withUserTime(in map[string]time.Time, userID string, at time.Time) map[string]time.Time {
+	out := make(map[string]time.Time, len(in)+1)
+	for k, v := range in {
+		out[k] = v
+	}
+	out[userID] = at
+	return out
+}
+
+// deliverAnnouncement posts the announcement as JSON to a webhook channel and records
+// the result.
+func (s *Store) deliverAnnouncement(a Announcement, channel string) {
+	payload, err := json.Marshal(map[string]any{
+		"type":         "announcement",
+		"announcement": AnnouncementView{ID: a.ID, Title: a.Title, Body: a.Body, Pinned: a.Pinned, CreatedAt: a.CreatedAt, ExpiresAt: a.ExpiresAt},
+		"user_ids":     a.UserIDs,
+	})
+	if err == nil {
+		err = postWebhook(channel, payload)
+	}
+	delivery := ChannelDelivery{Channel: channel, Status: DeliverySent, At: time.Now().UTC()}
+	if err != nil {
+		log.Warnf("mj3gc: announcement %s not delivered to %s: %v", a.ID, channel, err)
+		delivery.Status = DeliveryFailed
+		delivery.Error = err.Error()
+	}
+	s.recordAnnouncementDelivery(a.ID, delivery)
+	if err := s.Save(); err != nil {
+		log.Warnf("mj3gc: failed to persist delivery of announcement %s: %v", a.ID, err)
+	}
+}
+
+// postWebhook posts a JSON payload to endpoint and fails on error statuses.
+func postWebhook(endpoint string, payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), announcementWebhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("webhook returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (s *Store) recordAnnouncementDelivery(id string, delivery ChannelDelivery) {
+	defer s.lock("recordAnnouncementDelivery")()
+	for i, a := range s.data.Announcements {
+		if a.ID != id {
+			continue
+		}
+		channels := append([]ChannelDelivery(nil), a.Channels...)
+		for j := range channels {
+			if channels[j].Channel == delivery.Channel {
+				channels[j] = delivery
+			}
+		}
+		s.data.Announcements[i].Channels = channels
+		return
+	}
+}
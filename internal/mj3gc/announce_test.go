@@ -0,0 +1,59 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAnnouncementFeedTracksDeliveryAndReads(t *testing.T) {
+	s := NewStore()
+	old, err := s.CreateAnnouncement(Announcement{Title: "Pricing change", Body: "new prices from May"})
+	if err != nil {
+		t.Fatalf("CreateAnnouncement: %v", err)
+	}
+	old.CreatedAt = old.CreatedAt.Add(-time.Hour)
+	s.data.Announcements[0].CreatedAt = old.CreatedAt
+	pinned, _ := s.CreateAnnouncement(Announcement{Title: "Maintenance", Pinned: true})
+	_, _ = s.CreateAnnouncement(Announcement{Title: "For bob", UserIDs: []string{"bob"}})
+	_, _ = s.CreateAnnouncement(Announcement{Title: "Expired", ExpiresAt: time.Now().Add(-time.Minute)})
+	if _, err := s.CreateAnnouncement(Announcement{Title: "  "}); !errors.Is(err, ErrInvalidAnnouncement) {
+		t.Fatalf("err = %v, want ErrInvalidAnnouncement", err)
+	}
+
+	items, changed := s.AnnouncementsForUser("alice")
+	if !changed || len(items) != 2 || items[0].ID != pinned.ID || items[1].ID != old.ID {
+		t.Fatalf("feed = %+v changed=%v", items, changed)
+	}
+	if _, changed := s.AnnouncementsForUser("alice"); changed {
+		t.Fatal("second fetch changed delivery tracking")
+	}
+
+	if err := s.MarkAnnouncementRead(old.ID, "alice"); err != nil {
+		t.Fatalf("MarkAnnouncementRead: %v", err)
+	}
+	items, _ = s.AnnouncementsForUser("alice")
+	if items[0].Read || !items[1].Read {
+		t.Fatalf("read flags = %+v", items)
+	}
+	if err := s.MarkAnnouncementRead(old.ID, ""); !errors.Is(err, ErrAnnouncementNotFound) {
+		t.Fatalf("err = %v, want ErrAnnouncementNotFound", err)
+	}
+	for _, a := range s.ListAnnouncements() {
+		if a.Title == "For bob" {
+			if _, ok := a.DeliveredTo["alice"]; ok {
+				t.Fatal("targeted announcement delivered to another user")
+			}
+			if err := s.MarkAnnouncementRead(a.ID, "alice"); !errors.Is(err, ErrAnnouncementNotFound) {
+				t.Fatalf("err = %v, want ErrAnnouncementNotFound", err)
+			}
+		}
+	}
+
+	if err := s.DeleteAnnouncement(pinned.ID); err != nil {
+		t.Fatalf("DeleteAnnouncement: %v", err)
+	}
+	if items, _ := s.AnnouncementsForUser("alice"); len(items) != 1 {
+		t.Fatalf("feed after delete = %+v", items)
+	}
+}
@@ -0,0 +1,128 @@
+package mj3gc
+
+import (
+	"sync"
+	"time"
+)
+
+// AnonymousPrincipal is the principal the access provider assigns to key-less requests
+// admitted by the anonymous tier. It never matches a stored key.
+const AnonymousPrincipal = "mj3gc:anonymous"
+
+// anonymousKeyID labels anonymous traffic in telemetry.
+const anonymousKeyID = "anonymous"
+
+const defaultAnonymousWindow = 24 * time.Hour
+
+// anonymousOverflowIP is the shared budget entry of the client IPs seen after
+// maxAnonymousIPs others in the same window.
+const anonymousOverflowIP = "other"
+
+// maxAnonymousIPs bounds the per-IP budget entries kept in a window, so a flood of
+// spoofed or rotating addresses cannot grow the map without limit.
+var maxAnonymousIPs = 10000
+
+// AnonymousUsage reports consumption of the anonymous budget in the current window.
+type AnonymousUsage struct {
+	Enabled     bool           `json:"enabled"`
+	WindowStart time.Time      `json:"window_start"`
+	WindowEnd   time.Time      `json:"window_end"`
+	GlobalUsed  int            `json:"global_used"`
+	GlobalLimit int            `json:"global_limit"`
+	PerIPLimit  int            `json:"per_ip_limit"`
+	PerIP       map[string]int `json:"per_ip"`
+}
+
+type anonymousBudget struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	global      int
+	perIP       map[string]int
+}
+
+// AnonymousEnabled reports whether key-less requests may use the anonymous tier.
+func (s *Store) AnonymousEnabled() bool {
+	if s == nil {
+		return false
+	}
+	return s.Settings().AnonymousEnabled
+}
+
+// ErrorDocURL returns the documentation link mj3gc.error-docs configures for a rejection
+// code such as "quota_exceeded", or "".
+func (s *Store) ErrorDocURL(code string) string {
+	if s == nil || code == "" {
+		return ""
+	}
+	return s.Settings().ErrorDocs[code]
+}
+
+func (s *Store) anonymousWindow() time.Duration {
+	window := time.Duration(s.Settings().AnonymousWindowSeconds) * time.Second
+	if window <= 0 {
+		window = defaultAnonymousWindow
+	}
+	return window
+}
+
+// rollLocked starts a new budget window once the current one has elapsed.
+// The caller holds b.mu.
+func (b *anonymousBudget) rollLocked(now time.Time, window time.Duration) {
+	if b.perIP == nil || now.Sub(b.windowStart) >= window {
+		b.windowStart = now
+		b.global = 0
+		b.perIP = make(map[string]int)
+	}
+}
+
+// BeginAnonymous charges one request from ip against the anonymous budget. Requests
+// are counted when admitted so concurrent bursts cannot overshoot the limits.
+func (s *Store) BeginAnonymous(ip string) error {
+	if s == nil || !s.AnonymousEnabled() {
+		return ErrKeyNotFound
+	}
+	settings := s.Settings()
+	window := s.anonymousWindow()
+	b := &s.anonymous
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollLocked(time.Now(), window)
+	if _, tracked := b.perIP[ip]; !tracked && len(b.perIP) >= maxAnonymousIPs {
+		ip = anonymousOverflowIP
+	}
+	if settings.AnonymousGlobalLimit > 0 && b.global >= settings.AnonymousGlobalLimit {
+		return ErrQuotaExceeded
+	}
+	if settings.AnonymousPerIPLimit > 0 && b.perIP[ip] >= settings.AnonymousPerIPLimit {
+		return ErrQuotaExceeded
+	}
+	b.global++
+	b.perIP[ip]++
+	return nil
+}
+
+// AnonymousUsage returns a snapshot of the current anonymous budget window.
+func (s *Store) AnonymousUsage() AnonymousUsage {
+	if s == nil {
+		return AnonymousUsage{}
+	}
+	settings := s.Settings()
+	window := s.anonymousWindow()
+	b := &s.anonymous
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.rollLocked(time.Now(), window)
+	perIP := make(map[string]int, len(b.perIP))
+	for ip, n := range b.perIP {
+		perIP[ip] = n
+	}
+	return AnonymousUsage{
+		Enabled:     settings.AnonymousEnabled,
+		WindowStart: b.windowStart,
+		WindowEnd:   b.windowStart.Add(window),
+		GlobalUsed:  b.global,
+		GlobalLimit: settings.AnonymousGlobalLimit,
+		PerIPLimit:  settings.AnonymousPerIPLimit,
+		PerIP:       perIP,
+	}
+}
@@ -0,0 +1,64 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestBeginAnonymousBudgets(t *testing.T) {
+	s := NewStore()
+	if err := s.BeginAnonymous("10.0.0.1"); err == nil {
+		t.Fatal("anonymous request admitted while the tier is disabled")
+	}
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{
+		AnonymousEnabled:     true,
+		AnonymousGlobalLimit: 3,
+		AnonymousPerIPLimit:  2,
+	}})
+
+	for i := 0; i < 2; i++ {
+		if err := s.BeginAnonymous("10.0.0.1"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	if err := s.BeginAnonymous("10.0.0.1"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("per-IP limit: err = %v, want ErrQuotaExceeded", err)
+	}
+	if err := s.BeginAnonymous("10.0.0.2"); err != nil {
+		t.Fatalf("second IP: %v", err)
+	}
+	if err := s.BeginAnonymous("10.0.0.3"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("global limit: err = %v, want ErrQuotaExceeded", err)
+	}
+	if usage := s.AnonymousUsage(); usage.GlobalUsed != 3 || usage.PerIP["10.0.0.1"] != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestBeginAnonymousCapsTrackedIPs(t *testing.T) {
+	defer func(previous int) { maxAnonymousIPs = previous }(maxAnonymousIPs)
+	maxAnonymousIPs = 2
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{
+		AnonymousEnabled:    true,
+		AnonymousPerIPLimit: 2,
+	}})
+
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"} {
+		if err := s.BeginAnonymous(ip); err != nil {
+			t.Fatalf("%s: %v", ip, err)
+		}
+	}
+	if err := s.BeginAnonymous("10.0.0.5"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("overflow bucket: err = %v, want ErrQuotaExceeded", err)
+	}
+	if err := s.BeginAnonymous("10.0.0.1"); err != nil {
+		t.Fatalf("tracked IP: %v", err)
+	}
+	usage := s.AnonymousUsage()
+	if len(usage.PerIP) != 3 || usage.PerIP[anonymousOverflowIP] != 2 {
+		t.Fatalf("per-IP usage = %v, want two tracked IPs and the overflow entry", usage.PerIP)
+	}
+}
@@ -0,0 +1,94 @@
+package mj3gc
+
+import (
+	"strings"
+	"time"
+)
+
+// ArchiveDisabledKeys moves keys that have been disabled for longer than olderThan into
+// the archive section. Archived keys are no longer matched by FindAPIKey or listed by
+// ListAPIKeys but remain available through the archive accessors. Keys disabled before
+// DisabledAt was tracked are stamped now and become eligible after olderThan elapses.
+func (s *Store) ArchiveDisabledKeys(olderThan time.Duration) []APIKey {
+	if s == nil || olderThan <= 0 {
+		return nil
+	}
+	now := time.Now()
+	cutoff := now.Add(-olderThan)
+	defer s.lock("ArchiveDisabledKeys")()
+	var archived []APIKey
+	kept := make([]APIKey, 0, len(s.data.APIKeys))
+	for _, k := range s.data.APIKeys {
+		if k.Enabled {
+			kept = append(kept, k)
+			continue
+		}
+		if k.DisabledAt.IsZero() {
+			k.DisabledAt = now
+		}
+		if k.DisabledAt.After(cutoff) {
+			kept = append(kept, k)
+			continue
+		}
+		archived = append(archived, k)
+	}
+	if len(archived) == 0 {
+		s.data.APIKeys = kept
+		return nil
+	}
+	s.data.APIKeys = kept
+	s.data.ArchivedKeys = append(s.data.ArchivedKeys, archived...)
+	return archived
+}
+
+// ListArchivedAPIKeys returns every archived key.
+func (s *Store) ListArchivedAPIKeys() []APIKey {
+	if s == nil {
+		return nil
+	}
+	defer s.rlock("ListArchivedAPIKeys")()
+	out := make([]APIKey, len(s.data.ArchivedKeys))
+	copy(out, s.data.ArchivedKeys)
+	return out
+}
+
+// FindArchivedAPIKeyByID looks up an archived key by ID.
+func (s *Store) FindArchivedAPIKeyByID(id string) (APIKey, bool) {
+	if s == nil {
+		return APIKey{}, false
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return APIKey{}, false
+	}
+	defer s.rlock("FindArchivedAPIKeyByID")()
+	for _, k := range s.data.ArchivedKeys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// RestoreArchivedKey moves an archived key back into the active set. The key stays
+// disabled so it has to be re-enabled explicitly.
+func (s *Store) RestoreArchivedKey(id string) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidConfiguration
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return APIKey{}, ErrKeyNotFound
+	}
+	defer s.lock("RestoreArchivedKey")()
+	for i, k := range s.data.ArchivedKeys {
+		if k.ID != id {
+			continue
+		}
+		s.data.ArchivedKeys = append(s.data.ArchivedKeys[:i:i], s.data.ArchivedKeys[i+1:]...)
+		k.DisabledAt = time.Now()
+		s.data.APIKeys = append(s.data.APIKeys, k)
+		return k, nil
+	}
+	return APIKey{}, ErrKeyNotFound
+}
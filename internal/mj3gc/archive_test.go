@@ -0,0 +1,42 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestArchiveDisabledKeys(t *testing.T) {
+	s := NewStore()
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	s.data.APIKeys = []APIKey{
+		{ID: "key-active", Key: "a", Enabled: true},
+		{ID: "key-old", Key: "b", DisabledAt: old},
+		{ID: "key-recent", Key: "c", DisabledAt: time.Now()},
+	}
+
+	archived := s.ArchiveDisabledKeys(30 * 24 * time.Hour)
+	if len(archived) != 1 || archived[0].ID != "key-old" {
+		t.Fatalf("archived = %+v, want only key-old", archived)
+	}
+	if _, ok := s.FindAPIKey("b"); ok {
+		t.Fatal("archived key still resolvable by value")
+	}
+	if _, ok := s.FindArchivedAPIKeyByID("key-old"); !ok {
+		t.Fatal("archived key not queryable")
+	}
+	if _, err := s.UpsertAPIKey(APIKey{ID: "key-new", Key: "b"}); !errors.Is(err, ErrDuplicateAPIKey) {
+		t.Fatalf("upsert reusing archived secret: err = %v, want ErrDuplicateAPIKey", err)
+	}
+
+	restored, err := s.RestoreArchivedKey("key-old")
+	if err != nil {
+		t.Fatalf("RestoreArchivedKey: %v", err)
+	}
+	if restored.Enabled {
+		t.Fatal("restored key should stay disabled")
+	}
+	if len(s.ListArchivedAPIKeys()) != 0 || len(s.ListAPIKeys()) != 3 {
+		t.Fatalf("unexpected key sets after restore: active=%d archived=%d", len(s.ListAPIKeys()), len(s.ListArchivedAPIKeys()))
+	}
+}
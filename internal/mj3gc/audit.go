@@ -0,0 +1,162 @@
+package mj3gc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const auditMemoryLimit = 1000
+
+// AuditEntry records a single management change applied to the store.
+type AuditEntry struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Actor     string         `json:"actor,omitempty"`
+	Action    string         `json:"action"`
+	Target    string         `json:"target,omitempty"`
+	Reason    string         `json:"reason,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// QueryFields flattens the entry for Query matching. Details are exposed as "details.<name>".
+func (e AuditEntry) QueryFields() Fields {
+	fields := Fields{
+		"timestamp": strconv.FormatInt(e.Timestamp.Unix(), 10),
+		"actor":     e.Actor,
+		"action":    e.Action,
+		"target":    e.Target,
+		"reason":    e.Reason,
+	}
+	for name, value := range e.Details {
+		fields["details."+strings.ToLower(name)] = fmt.Sprint(value)
+	}
+	return fields
+}
+
+// AuditFilter narrows the entries returned by AuditEntries. Zero values match everything.
+type AuditFilter struct {
+	Action string
+	Target string
+	Since  time.Time
+	Query  Query
+	Limit  int
+}
+
+type auditLog struct {
+	mu      sync.Mutex
+	entries []AuditEntry
+}
+
+// AuditPath returns the append-only audit file stored next to the data file.
+func (s *Store) AuditPath() string {
+	path := s.Path()
+	if path == "" {
+		return ""
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "-audit.jsonl"
+}
+
+// RecordAudit appends an entry to the in-memory audit ring and the audit file.
+func (s *Store) RecordAudit(entry AuditEntry) {
+	if s == nil {
+		return
+	}
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	s.audit.mu.Lock()
+	defer s.audit.mu.Unlock()
+	s.audit.entries = append(s.audit.entries, entry)
+	if over := len(s.audit.entries) - auditMemoryLimit; over > 0 {
+		s.audit.entries = append([]AuditEntry(nil), s.audit.entries[over:]...)
+	}
+
+	path := s.AuditPath()
+	if path == "" {
+		return
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Warnf("mj3gc: failed to create audit directory: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.Warnf("mj3gc: failed to open audit log: %v", err)
+		return
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		log.Warnf("mj3gc: failed to write audit log: %v", err)
+	}
+}
+
+// AuditEntries returns matching audit entries, newest first.
+func (s *Store) AuditEntries(filter AuditFilter) []AuditEntry {
+	if s == nil {
+		return nil
+	}
+	s.audit.mu.Lock()
+	defer s.audit.mu.Unlock()
+	out := make([]AuditEntry, 0, len(s.audit.entries))
+	for i := len(s.audit.entries) - 1; i >= 0; i-- {
+		entry := s.audit.entries[i]
+		if filter.Action != "" && !strings.EqualFold(entry.Action, filter.Action) {
+			continue
+		}
+		if filter.Target != "" && entry.Target != filter.Target {
+			continue
+		}
+		if !filter.Since.IsZero() && entry.Timestamp.Before(filter.Since) {
+			continue
+		}
+		if !filter.Query.Match(entry.QueryFields()) {
+			continue
+		}
+		out = append(out, entry)
+		if filter.Limit > 0 && len(out) >= filter.Limit {
+			break
+		}
+	}
+	return out
+}
+
+// loadAudit restores the most recent audit entries from the audit file.
+func (s *Store) loadAudit() {
+	path := s.AuditPath()
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	entries := make([]AuditEntry, 0, auditMemoryLimit)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if len(entries) > auditMemoryLimit {
+			entries = entries[1:]
+		}
+	}
+	s.audit.mu.Lock()
+	s.audit.entries = entries
+	s.audit.mu.Unlock()
+}
@@ -0,0 +1,212 @@
+package mj3gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	storageFile     = "file"
+	storageSQLite   = "sqlite"
+	storagePostgres = "postgres"
+	storageEtcd     = "etcd"
+	storageConsul   = "consul"
+)
+
+// Backend persists the store data. Load returns an error wrapping fs.ErrNotExist
+// when nothing has been stored yet.
+type Backend interface {
+	Load() (Data, error)
+	Save(data Data) error
+}
+
+// UsageSaver is implemented by backends that can persist a key's usage counter
+// without rewriting the whole dataset.
+type UsageSaver interface {
+	SaveUsage(keyID string, usedCount int64) error
+}
+
+// UsageCounter is implemented by backends shared between several instances. Usage is
+// applied as increments so instances never overwrite each other's counters.
+type UsageCounter interface {
+	IncrementUsage(keyID string, delta int64) (int64, error)
+	ResetUsage(keyID string) error
+}
+
+// ChangeWatcher is implemented by backends that can notify about changes made by
+// other instances.
+type ChangeWatcher interface {
+	// WaitForChange blocks until the stored data changes after the last Load.
+	WaitForChange(ctx context.Context) error
+}
+
+// OpenBackend creates the persistence backend selected by settings.Storage.
+// dataPath is the resolved data file path, used for the file backend and to derive defaults.
+func OpenBackend(settings config.MJ3GCConfig, dataPath string) (Backend, error) {
+	switch strings.ToLower(strings.TrimSpace(settings.Storage)) {
+	case "", storageFile:
+		key, err := LoadEncryptionKey(settings)
+		if err != nil {
+			return nil, err
+		}
+		signKey, err := LoadSigningKey(settings)
+		if err != nil {
+			return nil, err
+		}
+		local := &fileBackend{path: dataPath, key: key, signKey: signKey, acceptUnsigned: settings.AcceptUnsignedData}
+		if strings.TrimSpace(settings.S3Mirror.Bucket) != "" {
+			return openS3Mirror(settings.S3Mirror, local)
+		}
+		return local, nil
+	case storageSQLite:
+		path := strings.TrimSpace(settings.SQLitePath)
+		if path == "" {
+			path = strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + ".db"
+		}
+		return OpenSQLiteBackend(settings.SQLiteDriver, path)
+	case storagePostgres:
+		dsn := strings.TrimSpace(settings.PostgresDSN)
+		if dsn == "" {
+			dsn = strings.TrimSpace(os.Getenv("MJ3GC_POSTGRES_DSN"))
+		}
+		return OpenPostgresBackend(dsn)
+	case storageEtcd:
+		return OpenEtcdBackend(settings)
+	case storageConsul:
+		return OpenConsulBackend(settings)
+	case storageSharded:
+		return OpenShardedBackend(settings, dataPath)
+	case storageControlPlane:
+		return OpenControlPlaneBackend(settings)
+	default:
+		return nil, errors.New("mj3gc: unsupported storage " + settings.Storage)
+	}
+}
+
+// SetBackend replaces the persistence backend. A nil backend restores the JSON file
+// backend at the store path.
+func (s *Store) SetBackend(backend Backend) {
+	if s == nil {
+		return
+	}
+	defer s.lock("SetBackend")()
+	s.backend = backend
+}
+
+func (s *Store) currentBackend() Backend {
+	defer s.rlock("currentBackend")()
+	if s.backend != nil {
+		return s.backend
+	}
+	if s.path == "" {
+		return nil
+	}
+	return s.fileBackendLocked(s.path)
+}
+
+// fileBackendLocked returns a file backend at path using the store's encryption
+// and signing keys. The caller holds the store lock.
+func (s *Store) fileBackendLocked(path string) *fileBackend {
+	return &fileBackend{
+		path:           path,
+		key:            s.dataKey,
+		signKey:        s.signKey,
+		acceptUnsigned: s.settings.AcceptUnsignedData,
+		keyErr:         s.dataKeyErr,
+	}
+}
+
+// fileBackend stores the whole dataset as a single JSON, YAML or TOML document (see
+// dataFormatFor), sealed with
+// AES-256-GCM when key is set and signed with HMAC-SHA256 when signKey is set.
+// Plaintext files are still read so enabling encryption takes effect on the next save;
+// unsigned files are only read with acceptUnsigned.
+type fileBackend struct {
+	path           string
+	key            []byte
+	signKey        []byte
+	acceptUnsigned bool
+	keyErr         error
+}
+
+// Load reads the data file. When the signature does not match, the decoded data is
+// still returned together with an error wrapping ErrSignatureMismatch so the store
+// can decide whether to start read-only.
+func (b *fileBackend) Load() (Data, error) {
+	if b.keyErr != nil {
+		return Data{}, b.keyErr
+	}
+	raw, err := os.ReadFile(b.path)
+	if err != nil {
+		return Data{}, err
+	}
+	raw, signed, verifyErr := verifyPayload(b.signKey, raw)
+	if raw == nil {
+		return Data{}, verifyErr
+	}
+	if verifyErr == nil && b.signKey != nil && !signed && !b.acceptUnsigned {
+		verifyErr = fmt.Errorf("%w: the file is not signed", ErrSignatureMismatch)
+	}
+	if isEncrypted(raw) {
+		if raw, err = openData(b.key, raw); err != nil {
+			return Data{}, err
+		}
+	}
+	var data Data
+	if err := decodeData(dataFormatFor(b.path), raw, &data); err != nil {
+		return Data{}, err
+	}
+	return data, verifyErr
+}
+
+func (b *fileBackend) Save(data Data) error {
+	if b.keyErr != nil {
+		return b.keyErr
+	}
+	payload, err := encodeData(dataFormatFor(b.path), data)
+	if err != nil {
+		return err
+	}
+	if b.key != nil {
+		if payload, err = sealData(b.key, payload); err != nil {
+			return err
+		}
+	}
+	if b.signKey != nil {
+		payload = signPayload(b.signKey, payload)
+	}
+	return writeFileAtomic(b.path, payload)
+}
+
+// writeFileAtomic writes payload to a temporary file next to path and renames it into place.
+func writeFileAtomic(path string, payload []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "mj3gc-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err := tmp.Write(payload); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func isNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,240 @@
+package mj3gc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultBackupKeep = 10
+	backupPrefix      = "mj3gc-"
+	backupSuffix      = ".bak.json"
+)
+
+// ErrBackupNotFound is returned when a named backup does not exist.
+var ErrBackupNotFound = errors.New("backup not found")
+
+var backupLabelSanitizer = regexp.MustCompile(`[^a-z0-9]+`)
+
+type backupState struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+// BackupInfo describes one stored snapshot.
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+}
+
+// BackupDir returns mj3gc.backup-dir or "backups" next to the data file.
+func (s *Store) BackupDir() string {
+	if dir := strings.TrimSpace(s.Settings().BackupDir); dir != "" {
+		return dir
+	}
+	path := s.Path()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "backups")
+}
+
+// CreateBackup snapshots the in-memory data into the backup directory and prunes old
+// copies beyond mj3gc.backup-keep. label is recorded in the file name, typically the
+// scheduled run or the management action that triggered it.
+func (s *Store) CreateBackup(label string) (BackupInfo, error) {
+	if s == nil {
+		return BackupInfo{}, ErrInvalidConfiguration
+	}
+	dir := s.BackupDir()
+	if dir == "" {
+		return BackupInfo{}, ErrInvalidConfiguration
+	}
+	label = strings.Trim(backupLabelSanitizer.ReplaceAllString(strings.ToLower(label), "-"), "-")
+	if label == "" {
+		label = "manual"
+	}
+	now := s.nextBackupTime()
+	name := fmt.Sprintf("%s%s-%s%s", backupPrefix, now.Format("20060102T150405.000Z"), label, backupSuffix)
+
+	unlock := s.rlock("CreateBackup")
+	data := s.snapshotLocked()
+	target := s.fileBackendLocked(filepath.Join(dir, name))
+	unlock()
+	data.UpdatedAt = now
+	span := s.startPersistSpan(PersistOpBackup, nil)
+	if err := target.Save(data); err != nil {
+		span.end(0, err)
+		return BackupInfo{}, err
+	}
+	info := BackupInfo{Name: name, CreatedAt: now}
+	if stat, err := os.Stat(target.path); err == nil {
+		info.Size = stat.Size()
+	}
+	span.end(info.Size, nil)
+	s.pruneBackups()
+	return info, nil
+}
+
+// ListBackups returns the stored backups, newest first.
+func (s *Store) ListBackups() ([]BackupInfo, error) {
+	if s == nil {
+		return nil, ErrInvalidConfiguration
+	}
+	dir := s.BackupDir()
+	if dir == "" {
+		return nil, ErrInvalidConfiguration
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	out := make([]BackupInfo, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, BackupInfo{Name: name, CreatedAt: info.ModTime().UTC(), Size: info.Size()})
+	}
+	// Names start with a sortable UTC timestamp.
+	sort.Slice(out, func(i, j int) bool { return out[i].Name > out[j].Name })
+	return out, nil
+}
+
+// RestoreBackup replaces the store data with the named backup and persists it. The
+// current data is backed up first so a restore can itself be undone.
+func (s *Store) RestoreBackup(name string) error {
+	if s == nil {
+		return ErrInvalidConfiguration
+	}
+	// A verified backup may replace tampered data, but never data of a read-only replica.
+	if s.Settings().ReadOnly {
+		return ErrReadOnly
+	}
+	dir := s.BackupDir()
+	if dir == "" {
+		return ErrInvalidConfiguration
+	}
+	if name != filepath.Base(name) || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return ErrBackupNotFound
+	}
+	unlock := s.rlock("RestoreBackup")
+	source := s.fileBackendLocked(filepath.Join(dir, name))
+	unlock()
+	data, err := source.Load()
+	if err != nil {
+		if isNotExist(err) {
+			return ErrBackupNotFound
+		}
+		return err
+	}
+	if _, err := migrateData(&data); err != nil {
+		return err
+	}
+	if _, err := s.CreateBackup("pre-restore"); err != nil {
+		return fmt.Errorf("mj3gc: back up current data before restore: %w", err)
+	}
+	unlock = s.lock("RestoreBackup")
+	// Keep counting revisions forward so sync clients see the restore as changes.
+	data.Revision, data.Changes = s.data.Revision, s.data.Changes
+	s.data = data
+	// The backup passed signature verification, so it replaces tampered data.
+	s.tampered = false
+	unlock()
+	s.resetSharedUsage(data.APIKeys)
+	return s.Save()
+}
+
+// resetSharedUsage sets shared usage counters to the values in keys. Save does not
+// overwrite shared counters, so wholesale replacements of the data apply them here.
+func (s *Store) resetSharedUsage(keys []APIKey) {
+	if counter, ok := s.currentBackend().(UsageCounter); ok {
+		for _, key := range keys {
+			if err := counter.ResetUsage(key.ID); err == nil && key.UsedCount > 0 {
+				_, _ = counter.IncrementUsage(key.ID, key.UsedCount)
+			}
+		}
+	}
+	if counters := s.counterBackend(); counters != nil {
+		for _, key := range keys {
+			if err := counters.ResetUsage(key.ID); err == nil {
+				_, _ = counters.AddUsage(key.ID, key.UsedCount)
+			}
+		}
+	}
+}
+
+// nextBackupTime returns a strictly increasing timestamp at millisecond resolution so
+// backup names never collide and sort in creation order.
+func (s *Store) nextBackupTime() time.Time {
+	s.backups.mu.Lock()
+	defer s.backups.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	if !now.After(s.backups.last) {
+		now = s.backups.last.Add(time.Millisecond)
+	}
+	s.backups.last = now
+	return now
+}
+
+func (s *Store) pruneBackups() {
+	keep := s.Settings().BackupKeep
+	if keep <= 0 {
+		keep = defaultBackupKeep
+	}
+	backups, err := s.ListBackups()
+	if err != nil || len(backups) <= keep {
+		return
+	}
+	dir := s.BackupDir()
+	for _, backup := range backups[keep:] {
+		if err := os.Remove(filepath.Join(dir, backup.Name)); err != nil {
+			log.Warnf("mj3gc: failed to remove old backup %s: %v", backup.Name, err)
+		}
+	}
+}
+
+// StartBackups snapshots the data every mj3gc.backup-interval-minutes until ctx is
+// cancelled. It does nothing when the interval is zero.
+func (s *Store) StartBackups(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	interval := time.Duration(s.Settings().BackupIntervalMinutes) * time.Minute
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := s.CreateBackup("scheduled"); err != nil {
+					log.Warnf("mj3gc: scheduled backup failed: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package mj3gc
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestBackupRotationAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore()
+	s.SetPath(filepath.Join(dir, "mj3gc.json"))
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{BackupKeep: 2}})
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-keep", Enabled: true})
+	if err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	first, err := s.CreateBackup("first")
+	if err != nil {
+		t.Fatalf("CreateBackup: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.CreateBackup("again"); err != nil {
+			t.Fatalf("CreateBackup: %v", err)
+		}
+	}
+	backups, err := s.ListBackups()
+	if err != nil || len(backups) != 2 {
+		t.Fatalf("ListBackups = %v, %v; want 2 after rotation", backups, err)
+	}
+	latest := backups[0].Name
+	if latest == first.Name {
+		t.Fatal("backups are not sorted newest first")
+	}
+
+	if err := s.DeleteAPIKey(key.ID); err != nil {
+		t.Fatalf("DeleteAPIKey: %v", err)
+	}
+	if err := s.RestoreBackup(latest); err != nil {
+		t.Fatalf("RestoreBackup: %v", err)
+	}
+	if _, ok := s.FindAPIKey("sk-keep"); !ok {
+		t.Fatal("restored data is missing the key")
+	}
+	if err := s.RestoreBackup("../mj3gc.json"); err == nil {
+		t.Fatal("restoring outside the backup directory should fail")
+	}
+}
@@ -0,0 +1,182 @@
+package mj3gc
+
+// Tx is a working copy of the store handed to a Batch callback. Changes made through it
+// become visible to other callers only when the callback returns nil.
+type Tx struct {
+	data        Data
+	deletedKeys []string
+	maxKeys     int
+	// users and keys hold the IDs of the records the transaction changed, which a
+	// failed Save reverts.
+	users map[string]bool
+	keys  map[string]bool
+}
+
+// UpsertUser creates or updates a user within the transaction.
+func (tx *Tx) UpsertUser(user User) (User, error) {
+	user, err := tx.data.upsertUser(user)
+	if err == nil {
+		tx.users[user.ID] = true
+	}
+	return user, err
+}
+
+// DeleteUser moves a user to the trash within the transaction.
+func (tx *Tx) DeleteUser(id string) error {
+	if err := tx.data.deleteUser(id); err != nil {
+		return err
+	}
+	tx.users[id] = true
+	return nil
+}
+
+// UpsertAPIKey creates or updates a key within the transaction.
+func (tx *Tx) UpsertAPIKey(key APIKey) (APIKey, error) {
+	if err := tx.data.checkKeyLimit(key, tx.maxKeys); err != nil {
+		return APIKey{}, err
+	}
+	key, err := tx.data.upsertAPIKey(key)
+	if err == nil {
+		tx.keys[key.ID] = true
+	}
+	return key, err
+}
+
+// DeleteAPIKey moves a key to the trash within the transaction.
+func (tx *Tx) DeleteAPIKey(id string) error {
+	if err := tx.data.deleteAPIKey(id); err != nil {
+		return err
+	}
+	tx.keys[id] = true
+	tx.deletedKeys = append(tx.deletedKeys, id)
+	return nil
+}
+
+// FindUserByID looks up a user as seen by the transaction.
+func (tx *Tx) FindUserByID(id string) (User, bool) {
+	for _, u := range tx.data.Users {
+		if u.ID == id {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// FindAPIKeyByID looks up a key as seen by the transaction.
+func (tx *Tx) FindAPIKeyByID(id string) (APIKey, bool) {
+	for _, k := range tx.data.APIKeys {
+		if k.ID == id {
+			return k, true
+		}
+	}
+	return APIKey{}, false
+}
+
+// Batch applies several changes atomically and persists them with a single Save. fn
+// works on a copy of the store; when it returns an error nothing is applied. When the
+// Save fails the users and keys the batch changed are reverted, keeping usage counted
+// in the meantime and the changes other callers made while the batch was saved, and
+// the error is returned.
+//
+// The store is locked while fn runs, so fn must not call other Store methods.
+func (s *Store) Batch(fn func(tx *Tx) error) error {
+	if s == nil {
+		return ErrInvalidConfiguration
+	}
+	unlock := s.lock("Batch")
+	tx := &Tx{
+		data:    s.snapshotLocked(),
+		maxKeys: s.settings.MaxKeysPerUser,
+		users:   make(map[string]bool),
+		keys:    make(map[string]bool),
+	}
+	if err := fn(tx); err != nil {
+		unlock()
+		return err
+	}
+	// The snapshot shares nothing with the store, so previous stays as it was.
+	previous := s.data
+	s.data = tx.data
+	s.revokeReplacedSecretsLocked(previous.APIKeys, s.data.APIKeys)
+	revoked := s.data.RevokedSecrets
+	unlock()
+
+	if err := s.Save(); err != nil {
+		defer s.lock("Batch")()
+		s.revertBatchLocked(previous, tx)
+		if s.data.RevokedSecrets == revoked {
+			s.data.RevokedSecrets = previous.RevokedSecrets
+		}
+		return err
+	}
+	unlock = s.lock("Batch")
+	for _, id := range tx.deletedKeys {
+		delete(s.inflight, id)
+	}
+	unlock()
+	return nil
+}
+
+// revertBatchLocked puts the users and keys tx changed back to their state in
+// previous, in the live lists and in the trash, and carries the usage counted since
+// over to the reverted keys. The caller holds the store lock.
+func (s *Store) revertBatchLocked(previous Data, tx *Tx) {
+	usage := make(map[string]APIKey, len(tx.keys))
+	for _, k := range s.data.APIKeys {
+		if tx.keys[k.ID] {
+			usage[k.ID] = k
+		}
+	}
+	userID := func(u User) string { return u.ID }
+	keyID := func(k APIKey) string { return k.ID }
+	s.data.Users = revertRecords(s.data.Users, previous.Users, tx.users, userID)
+	s.data.DeletedUsers = revertRecords(s.data.DeletedUsers, previous.DeletedUsers, tx.users, userID)
+	s.data.APIKeys = revertRecords(s.data.APIKeys, previous.APIKeys, tx.keys, keyID)
+	s.data.DeletedKeys = revertRecords(s.data.DeletedKeys, previous.DeletedKeys, tx.keys, keyID)
+	for i := range s.data.APIKeys {
+		k := &s.data.APIKeys[i]
+		if current, ok := usage[k.ID]; ok {
+			k.UsedCount = current.UsedCount
+			k.UsedTokens = current.UsedTokens
+			k.SpentUSD = current.SpentUSD
+			k.LastUsedAt = current.LastUsedAt
+			k.LastUsedIP = current.LastUsedIP
+			k.LastResetAt = current.LastResetAt
+		}
+	}
+}
+
+// revertRecords returns current with the records whose ID is in ids replaced by their
+// versions in previous. A record previous has no version of is dropped; versions that
+// are missing from current are appended in their order in previous.
+func revertRecords[T any](current, previous []T, ids map[string]bool, id func(T) string) []T {
+	if len(ids) == 0 {
+		return current
+	}
+	versions := make(map[string][]T)
+	for _, r := range previous {
+		if ids[id(r)] {
+			versions[id(r)] = append(versions[id(r)], r)
+		}
+	}
+	out := make([]T, 0, len(current))
+	placed := make(map[string]bool)
+	for _, r := range current {
+		rid := id(r)
+		if !ids[rid] {
+			out = append(out, r)
+			continue
+		}
+		if !placed[rid] {
+			out = append(out, versions[rid]...)
+			placed[rid] = true
+		}
+	}
+	for _, r := range previous {
+		if rid := id(r); ids[rid] && !placed[rid] {
+			out = append(out, versions[rid]...)
+			placed[rid] = true
+		}
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package mj3gc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestBatchAppliesChangesWithSingleSave(t *testing.T) {
+	s := NewStore()
+	s.SetPath(filepath.Join(t.TempDir(), "mj3gc.json"))
+	s.ApplyConfig(&config.Config{})
+
+	err := s.Batch(func(tx *Tx) error {
+		user, err := tx.UpsertUser(User{Username: "alice"})
+		if err != nil {
+			return err
+		}
+		_, err = tx.UpsertAPIKey(APIKey{Key: "secret", UserID: user.ID, Enabled: true})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Batch: %v", err)
+	}
+	if _, ok := s.FindAPIKey("secret"); !ok {
+		t.Fatal("key from batch not applied")
+	}
+
+	reloaded := NewStore()
+	reloaded.SetPath(s.Path())
+	reloaded.ApplyConfig(&config.Config{})
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := reloaded.FindUserByUsername("alice"); !ok {
+		t.Fatal("batch not persisted")
+	}
+}
+
+func TestBatchDiscardsChangesOnError(t *testing.T) {
+	s := NewStore()
+	s.data.Users = []User{{ID: "u1", Username: "alice"}}
+
+	err := s.Batch(func(tx *Tx) error {
+		if _, err := tx.UpsertUser(User{Username: "bob"}); err != nil {
+			return err
+		}
+		if err := tx.DeleteUser("u1"); err != nil {
+			return err
+		}
+		_, err := tx.UpsertUser(User{Username: "BOB"})
+		return err
+	})
+	if !errors.Is(err, ErrDuplicateUsername) {
+		t.Fatalf("err = %v, want ErrDuplicateUsername", err)
+	}
+	users := s.ListUsers()
+	if len(users) != 1 || users[0].ID != "u1" {
+		t.Fatalf("users = %+v, want only u1", users)
+	}
+	if len(s.ListDeletedUsers()) != 0 {
+		t.Fatal("failed batch left a user in the trash")
+	}
+}
+
+// blockingBackend holds Save until release is closed and then fails it.
+type blockingBackend struct {
+	saving  chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingBackend) Load() (Data, error) { return Data{}, nil }
+
+func (b *blockingBackend) Save(Data) error {
+	close(b.saving)
+	<-b.release
+	return errors.New("disk full")
+}
+
+func TestBatchRollbackKeepsConcurrentWrites(t *testing.T) {
+	s := NewStore()
+	s.data.Users = []User{{ID: "u1", Username: "alice"}}
+	s.data.APIKeys = []APIKey{
+		{ID: "k1", Key: "sk-one", UserID: "u1", Enabled: true, Label: "before", Tags: map[string]string{"team": "a"}},
+		{ID: "k2", Key: "sk-two", UserID: "u1", Enabled: true},
+	}
+	backend := &blockingBackend{saving: make(chan struct{}), release: make(chan struct{})}
+	s.SetBackend(backend)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Batch(func(tx *Tx) error {
+			key, _ := tx.FindAPIKeyByID("k1")
+			key.Label = "after"
+			key.Tags["team"] = "b"
+			if _, err := tx.UpsertAPIKey(key); err != nil {
+				return err
+			}
+			if err := tx.DeleteAPIKey("k2"); err != nil {
+				return err
+			}
+			_, err := tx.UpsertUser(User{Username: "bob"})
+			return err
+		})
+	}()
+
+	// Write while the batch is being saved.
+	<-backend.saving
+	carol, err := s.UpsertUser(User{Username: "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.setUsedCount("k1", 7)
+	close(backend.release)
+	if err := <-done; err == nil {
+		t.Fatal("Batch succeeded although Save failed")
+	}
+
+	if _, ok := s.FindUserByUsername("bob"); ok {
+		t.Fatal("user created by the failed batch was kept")
+	}
+	if _, ok := s.FindUserByID(carol.ID); !ok {
+		t.Fatal("user written during the batch was lost")
+	}
+	k1, ok := s.FindAPIKeyByID("k1")
+	if !ok || k1.Label != "before" || k1.Tags["team"] != "a" || k1.UsedCount != 7 {
+		t.Fatalf("k1 = %+v, want the label and tags before the batch and the usage counted since", k1)
+	}
+	if _, ok := s.FindAPIKeyByID("k2"); !ok {
+		t.Fatal("key deleted by the failed batch was not restored")
+	}
+	if trash := s.ListDeletedAPIKeys(); len(trash) != 0 {
+		t.Fatalf("trash = %+v, want empty", trash)
+	}
+}
@@ -0,0 +1,120 @@
+package mj3gc
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MaxBenchmarkDuration bounds how long Benchmark measures each operation.
+const MaxBenchmarkDuration = 30 * time.Second
+
+// BenchmarkResult is the measured throughput of one quota path operation.
+type BenchmarkResult struct {
+	Operation    string  `json:"operation"`
+	Keys         int     `json:"keys"`
+	Workers      int     `json:"workers"`
+	Operations   int64   `json:"operations"`
+	Errors       int64   `json:"errors"`
+	DurationMs   int64   `json:"duration_ms"`
+	OpsPerSecond float64 `json:"ops_per_second"`
+	NsPerOp      int64   `json:"ns_per_op"`
+}
+
+// Benchmark measures FindAPIKey and BeginRequest/EndRequest throughput over a copy of
+// the current keys, running each operation for d on one worker per CPU. The copy gets
+// synthetic secrets, hashed like the originals, and no limits, so live traffic,
+// counters and rate windows are left alone.
+func (s *Store) Benchmark(d time.Duration) []BenchmarkResult {
+	if s == nil {
+		return nil
+	}
+	if d <= 0 {
+		d = time.Second
+	}
+	d = min(d, MaxBenchmarkDuration)
+	clone, secrets := s.benchmarkClone()
+	if len(secrets) == 0 {
+		return nil
+	}
+	find := runBenchmark("find_api_key", secrets, d, func(secret string) bool {
+		_, ok := clone.FindAPIKey(secret)
+		return ok
+	})
+	begin := runBenchmark("begin_end_request", secrets, d, func(secret string) bool {
+		key, ok := clone.FindAPIKey(secret)
+		if !ok {
+			return false
+		}
+		if _, err := clone.BeginRequest(key.Key); err != nil {
+			return false
+		}
+		clone.EndRequest(key.Key, true, "")
+		return true
+	})
+	return []BenchmarkResult{find, begin}
+}
+
+// benchmarkClone copies the keys of s into a new in-memory store and returns it with
+// the secret of every copied key.
+func (s *Store) benchmarkClone() (*Store, []string) {
+	data := s.Snapshot()
+	clone := NewStore()
+	clone.settings = s.Settings()
+	clone.data.Users = data.Users
+	secrets := make([]string, 0, len(data.APIKeys))
+	for _, key := range data.APIKeys {
+		secret := "bench-" + key.ID
+		hashed := IsHashedSecret(key.Key)
+		key.Key, key.PreviousKey = secret, ""
+		if hashed {
+			key.Key = HashSecret(secret)
+		}
+		key.Enabled = true
+		key.TotalLimit, key.UsedCount, key.ConcurrencyLimit, key.RPMLimit = 0, 0, 0, 0
+		key.TokenLimit, key.BudgetUSD = 0, 0
+		clone.data.APIKeys = append(clone.data.APIKeys, key)
+		secrets = append(secrets, secret)
+	}
+	return clone, secrets
+}
+
+func runBenchmark(name string, secrets []string, d time.Duration, op func(secret string) bool) BenchmarkResult {
+	workers := runtime.GOMAXPROCS(0)
+	var ops, errs atomic.Int64
+	deadline := time.Now().Add(d)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(offset int) {
+			defer wg.Done()
+			for i := offset; ; i++ {
+				// Checking the clock every 64 operations keeps it out of the measurement.
+				if i%64 == 0 && time.Now().After(deadline) {
+					return
+				}
+				if !op(secrets[i%len(secrets)]) {
+					errs.Add(1)
+				}
+				ops.Add(1)
+			}
+		}(w * len(secrets) / workers)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	result := BenchmarkResult{
+		Operation:  name,
+		Keys:       len(secrets),
+		Workers:    workers,
+		Operations: ops.Load(),
+		Errors:     errs.Load(),
+		DurationMs: elapsed.Milliseconds(),
+	}
+	if result.Operations > 0 {
+		result.OpsPerSecond = float64(result.Operations) / elapsed.Seconds()
+		result.NsPerOp = elapsed.Nanoseconds() * int64(workers) / result.Operations
+	}
+	return result
+}
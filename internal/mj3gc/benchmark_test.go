@@ -0,0 +1,76 @@
+package mj3gc
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// benchmarkStore returns a store holding n keys and their secrets.
+func benchmarkStore(tb testing.TB, n int, hashed bool) (*Store, []string) {
+	tb.Helper()
+	s := NewStore()
+	secrets := make([]string, n)
+	for i := range secrets {
+		secrets[i] = fmt.Sprintf("sk-bench-%06d", i)
+		key := APIKey{ID: fmt.Sprintf("key-%06d", i), Key: secrets[i], Enabled: true, ConcurrencyLimit: 4}
+		if hashed {
+			key.Key = HashSecret(secrets[i])
+		}
+		s.data.APIKeys = append(s.data.APIKeys, key)
+	}
+	return s, secrets
+}
+
+func BenchmarkFindAPIKey(b *testing.B) {
+	for _, hashed := range []bool{false, true} {
+		b.Run(fmt.Sprintf("hashed=%v", hashed), func(b *testing.B) {
+			s, secrets := benchmarkStore(b, 20000, hashed)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if _, ok := s.FindAPIKey(secrets[i%len(secrets)]); !ok {
+						b.Error("key not found")
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkBeginRequest(b *testing.B) {
+	s, secrets := benchmarkStore(b, 20000, false)
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			secret := secrets[i%len(secrets)]
+			if _, err := s.BeginRequest(secret); err != nil {
+				b.Error(err)
+			}
+			s.EndRequest(secret, true, "")
+			i++
+		}
+	})
+}
+
+func TestStoreBenchmark(t *testing.T) {
+	s, _ := benchmarkStore(t, 50, true)
+	s.data.APIKeys[0].RPMLimit = 1
+	results := s.Benchmark(20 * time.Millisecond)
+	if len(results) != 2 {
+		t.Fatalf("got %d results", len(results))
+	}
+	for _, r := range results {
+		if r.Keys != 50 || r.Operations == 0 || r.Errors != 0 {
+			t.Fatalf("%s: %+v", r.Operation, r)
+		}
+	}
+	if s.data.APIKeys[0].UsedCount != 0 {
+		t.Fatal("benchmark changed live usage")
+	}
+}
@@ -0,0 +1,76 @@
+package mj3gc
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+const maxBillingFieldLength = 512
+
+// BillingInfo holds the invoicing details of a user.
+type BillingInfo struct {
+	CompanyName string `json:"company_name,omitempty"`
+	Address     string `json:"address,omitempty"`
+	TaxID       string `json:"tax_id,omitempty"`
+	Email       string `json:"email,omitempty"`
+}
+
+// Normalize trims every field.
+func (b BillingInfo) Normalize() BillingInfo {
+	return BillingInfo{
+		CompanyName: strings.TrimSpace(b.CompanyName),
+		Address:     strings.TrimSpace(b.Address),
+		TaxID:       strings.TrimSpace(b.TaxID),
+		Email:       strings.TrimSpace(b.Email),
+	}
+}
+
+// IsZero reports whether no field is set.
+func (b BillingInfo) IsZero() bool {
+	return b == BillingInfo{}
+}
+
+// Validate checks field lengths and the billing email address.
+func (b BillingInfo) Validate() error {
+	for name, value := range map[string]string{
+		"company_name": b.CompanyName,
+		"address":      b.Address,
+		"tax_id":       b.TaxID,
+		"email":        b.Email,
+	} {
+		if len(value) > maxBillingFieldLength {
+			return fmt.Errorf("billing %s too long", name)
+		}
+	}
+	if b.Email != "" {
+		if addr, err := mail.ParseAddress(b.Email); err != nil || addr.Address != b.Email {
+			return fmt.Errorf("invalid billing email")
+		}
+	}
+	return nil
+}
+
+// UpdateUserBilling replaces the billing details of a user. Empty details clear them.
+func (s *Store) UpdateUserBilling(userID string, billing BillingInfo) (User, error) {
+	if s == nil {
+		return User{}, ErrInvalidConfiguration
+	}
+	billing = billing.Normalize()
+	if err := billing.Validate(); err != nil {
+		return User{}, err
+	}
+	defer s.lock("UpdateUserBilling")()
+	for i := range s.data.Users {
+		if s.data.Users[i].ID != userID {
+			continue
+		}
+		if billing.IsZero() {
+			s.data.Users[i].Billing = nil
+		} else {
+			s.data.Users[i].Billing = &billing
+		}
+		return s.data.Users[i], nil
+	}
+	return User{}, ErrUserNotFound
+}
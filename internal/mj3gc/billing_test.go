@@ -0,0 +1,31 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestUpdateUserBilling(t *testing.T) {
+	s := NewStore()
+	s.data.Users = []User{{ID: "u1", Username: "alice"}}
+
+	user, err := s.UpdateUserBilling("u1", BillingInfo{CompanyName: "  Acme GmbH ", TaxID: "DE123", Email: "billing@acme.test"})
+	if err != nil {
+		t.Fatalf("UpdateUserBilling: %v", err)
+	}
+	if user.Billing == nil || user.Billing.CompanyName != "Acme GmbH" || user.Billing.TaxID != "DE123" {
+		t.Fatalf("billing = %+v", user.Billing)
+	}
+
+	if _, err := s.UpdateUserBilling("u1", BillingInfo{Email: "not an email"}); err == nil {
+		t.Fatal("expected invalid email to be rejected")
+	}
+	if _, err := s.UpdateUserBilling("missing", BillingInfo{}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("err = %v, want ErrUserNotFound", err)
+	}
+
+	user, err = s.UpdateUserBilling("u1", BillingInfo{})
+	if err != nil || user.Billing != nil {
+		t.Fatalf("clearing billing: user=%+v err=%v", user, err)
+	}
+}
@@ -0,0 +1,64 @@
+package mj3gc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimitMiddleware rejects requests whose body is larger than the max_body_bytes of
+// the authenticated key with 413 before anything reads or forwards the body. Bodies of
+// unknown length are read up to the limit. Keys without a limit, and other access
+// providers, pass through unchanged.
+func BodyLimitMiddleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if principal == "" || principal == AnonymousPrincipal {
+			c.Next()
+			return
+		}
+		key, ok := store.FindAPIKeyByPrincipal(principal)
+		if !ok || key.MaxBodyBytes <= 0 {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > key.MaxBodyBytes {
+			abortBodyTooLarge(c, key.MaxBodyBytes)
+			return
+		}
+		if c.Request.ContentLength < 0 {
+			raw, err := io.ReadAll(io.LimitReader(c.Request.Body, key.MaxBodyBytes+1))
+			_ = c.Request.Body.Close()
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+				return
+			}
+			if int64(len(raw)) > key.MaxBodyBytes {
+				abortBodyTooLarge(c, key.MaxBodyBytes)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+			c.Request.ContentLength = int64(len(raw))
+			c.Next()
+			return
+		}
+		// A declared length is trusted only as far as it goes.
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, key.MaxBodyBytes)
+		c.Next()
+	}
+}
+
+func abortBodyTooLarge(c *gin.Context, limit int64) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+		"error":          fmt.Sprintf("request body exceeds the %d bytes allowed for this API key", limit),
+		"code":           "body_too_large",
+		"max_body_bytes": limit,
+	})
+}
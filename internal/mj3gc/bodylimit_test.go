@@ -0,0 +1,61 @@
+package mj3gc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	store := NewStore()
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-small", Enabled: true, MaxBodyBytes: 16}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-open", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	withKey := func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	router.POST("/v1/chat/completions", withKey, BodyLimitMiddleware(store), func(c *gin.Context) {
+		raw, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", raw)
+	})
+
+	cases := []struct {
+		name, key, body string
+		chunked         bool
+		want            int
+	}{
+		{"within_limit", "sk-small", `{"model":"m"}`, false, http.StatusOK},
+		{"declared_too_large", "sk-small", `{"model":"m","prompt":"long"}`, false, http.StatusRequestEntityTooLarge},
+		{"chunked_within_limit", "sk-small", `{"model":"m"}`, true, http.StatusOK},
+		{"chunked_too_large", "sk-small", `{"model":"m","prompt":"long"}`, true, http.StatusRequestEntityTooLarge},
+		{"unlimited_key", "sk-open", `{"model":"m","prompt":"long"}`, false, http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(tc.body))
+			if tc.chunked {
+				req.ContentLength = -1
+			}
+			req.Header.Set("Authorization", "Bearer "+tc.key)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tc.want {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.want, rec.Body.String())
+			}
+			if tc.want == http.StatusOK && rec.Body.String() != tc.body {
+				t.Fatalf("body reached the handler as %q", rec.Body.String())
+			}
+			if tc.want != http.StatusOK && !strings.Contains(rec.Body.String(), "body_too_large") {
+				t.Fatalf("body = %s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,397 @@
+package mj3gc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// Change data capture sinks of mj3gc.cdc-sink.
+const (
+	CDCSinkFile  = "file"
+	CDCSinkHTTP  = "http"
+	CDCSinkKafka = "kafka"
+	CDCSinkNATS  = "nats"
+)
+
+const (
+	// changeCaptureQueue is how many revisions may wait for the sink before newer ones
+	// are dropped. Consumers notice the gap in revisions and resynchronise from
+	// /mj3gc/export.
+	changeCaptureQueue = 1024
+	defaultCDCSubject  = "mj3gc.changes"
+	cdcTimeout         = 10 * time.Second
+	cdcMaxBackoff      = time.Minute
+)
+
+// ChangeEvent is the change data capture form of a Change: the entity ("user" or
+// "api_key") with its state before and after the revision. Before is empty for added
+// records and After for removed ones. Secrets and password hashes are masked.
+type ChangeEvent struct {
+	Revision  int64     `json:"revision"`
+	Entity    string    `json:"entity"`
+	ID        string    `json:"id"`
+	Operation string    `json:"operation"`
+	At        time.Time `json:"at"`
+	Before    any       `json:"before,omitempty"`
+	After     any       `json:"after,omitempty"`
+}
+
+// changeSink delivers batches of change events, one revision per batch.
+type changeSink interface {
+	Publish(ctx context.Context, events []ChangeEvent) error
+	Close() error
+}
+
+// changeCapture hands recorded changes from the store to the sink goroutine.
+type changeCapture struct {
+	mu      sync.Mutex
+	queue   chan []ChangeEvent
+	dropped int64
+}
+
+// StartChangeCapture streams every revision of users and keys to mj3gc.cdc-sink until
+// ctx is cancelled, so analytics and backup systems can mirror the store without
+// polling the export. Usage counters are not captured; they change on every request.
+// It does nothing when no sink is configured.
+func (s *Store) StartChangeCapture(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	sink, err := newChangeSink(s.Settings())
+	if err != nil {
+		log.Warnf("mj3gc: change data capture disabled: %v", err)
+		return
+	}
+	if sink == nil {
+		return
+	}
+	queue := make(chan []ChangeEvent, changeCaptureQueue)
+	s.cdc.mu.Lock()
+	s.cdc.queue = queue
+	s.cdc.mu.Unlock()
+	go func() {
+		defer func() { _ = sink.Close() }()
+		for {
+			select {
+			case <-ctx.Done():
+				s.cdc.mu.Lock()
+				// A restart may already have installed the queue of the next run.
+				if s.cdc.queue == queue {
+					s.cdc.queue = nil
+				}
+				s.cdc.mu.Unlock()
+				return
+			case events := <-queue:
+				publishChanges(ctx, sink, events)
+			}
+		}
+	}()
+}
+
+// publishChanges retries a batch with backoff until the sink accepts it or ctx ends,
+// so a sink outage delays events instead of losing them while the queue has room.
+func publishChanges(ctx context.Context, sink changeSink, events []ChangeEvent) {
+	backoff := time.Second
+	for {
+		err := sink.Publish(ctx, events)
+		if err == nil {
+			return
+		}
+		log.Warnf("mj3gc: failed to publish changes of revision %d: %v", events[0].Revision, err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, cdcMaxBackoff)
+	}
+}
+
+// captureChangesLocked queues the events of changes when change data capture runs. users
+// and keys hold the records as of the new revision. The caller holds the write lock.
+func (s *Store) captureChangesLocked(changes []Change, users map[string]trackedRecord[User], keys map[string]trackedRecord[APIKey]) {
+	s.cdc.mu.Lock()
+	defer s.cdc.mu.Unlock()
+	if s.cdc.queue == nil {
+		return
+	}
+	events := make([]ChangeEvent, 0, len(changes))
+	for _, change := range changes {
+		ev := ChangeEvent{Revision: change.Revision, Entity: change.Kind, ID: change.ID, Operation: change.Op, At: change.At}
+		switch change.Kind {
+		case ChangeKindUser:
+			if change.PreviousUser != nil {
+				ev.Before = cdcUser(*change.PreviousUser)
+			}
+			if current, ok := users[change.ID]; ok && change.Op != ChangeRemoved {
+				ev.After = cdcUser(current.record)
+			}
+		case ChangeKindAPIKey:
+			if change.PreviousKey != nil {
+				ev.Before = SanitizeKey(*change.PreviousKey)
+			}
+			if current, ok := keys[change.ID]; ok && change.Op != ChangeRemoved {
+				ev.After = SanitizeKey(current.record)
+			}
+		}
+		events = append(events, ev)
+	}
+	select {
+	case s.cdc.queue <- events:
+	default:
+		s.cdc.dropped++
+		log.Warnf("mj3gc: change data capture queue full, dropped revision %d (%d dropped so far)", changes[0].Revision, s.cdc.dropped)
+	}
+}
+
+func cdcUser(u User) User {
+	if u.PasswordHash != "" {
+		u.PasswordHash = "****"
+	}
+	return u
+}
+
+func newChangeSink(settings config.MJ3GCConfig) (changeSink, error) {
+	kind := strings.ToLower(strings.TrimSpace(settings.CDCSink))
+	target := strings.TrimSpace(settings.CDCTarget)
+	if kind == "" {
+		return nil, nil
+	}
+	if target == "" {
+		return nil, fmt.Errorf("cdc-target is required for the %s sink", kind)
+	}
+	switch kind {
+	case CDCSinkFile:
+		return &fileChangeSink{path: target}, nil
+	case CDCSinkHTTP, CDCSinkKafka:
+		return &httpChangeSink{url: target, kafka: kind == CDCSinkKafka, headers: settings.CDCHeaders, client: &http.Client{Timeout: cdcTimeout}}, nil
+	case CDCSinkNATS:
+		subject := strings.TrimSpace(settings.CDCSubject)
+		if subject == "" {
+			subject = defaultCDCSubject
+		}
+		return newNATSChangeSink(target, subject)
+	}
+	return nil, fmt.Errorf("unknown cdc-sink %q, want file, http, kafka or nats", kind)
+}
+
+// fileChangeSink appends events as JSON lines.
+type fileChangeSink struct {
+	path string
+}
+
+func (f *fileChangeSink) Publish(_ context.Context, events []ChangeEvent) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, ev := range events {
+		if err := enc.Encode(ev); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(buf.Bytes()); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *fileChangeSink) Close() error { return nil }
+
+// httpChangeSink posts each batch as newline-delimited JSON, or in the record format of
+// the Kafka REST proxy (POST /topics/{topic}) keyed by entity and ID, so events of one
+// record land on the same partition.
+type httpChangeSink struct {
+	url     string
+	kafka   bool
+	headers map[string]string
+	client  *http.Client
+}
+
+func (h *httpChangeSink) Publish(ctx context.Context, events []ChangeEvent) error {
+	var body []byte
+	contentType := "application/x-ndjson"
+	if h.kafka {
+		type record struct {
+			Key   string      `json:"key"`
+			Value ChangeEvent `json:"value"`
+		}
+		records := make([]record, 0, len(events))
+		for _, ev := range events {
+			records = append(records, record{Key: ev.Entity + ":" + ev.ID, Value: ev})
+		}
+		raw, err := json.Marshal(map[string]any{"records": records})
+		if err != nil {
+			return err
+		}
+		body, contentType = raw, "application/vnd.kafka.json.v2+json"
+	} else {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, ev := range events {
+			if err := enc.Encode(ev); err != nil {
+				return err
+			}
+		}
+		body = buf.Bytes()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for name, value := range h.headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sink returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+func (h *httpChangeSink) Close() error { return nil }
+
+// natsChangeSink is a minimal NATS core publisher. Events of an entity go to
+// "<subject>.<entity>"; a PING after every batch confirms the server processed it.
+// The connection is re-dialled after any error.
+type natsChangeSink struct {
+	addr     string
+	subject  string
+	user     string
+	password string
+	conn     net.Conn
+	rd       *bufio.Reader
+}
+
+// newNATSChangeSink accepts "host:port" or "nats://[user:password@]host:port".
+func newNATSChangeSink(target, subject string) (*natsChangeSink, error) {
+	sink := &natsChangeSink{addr: target, subject: subject}
+	if strings.Contains(target, "://") {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme != "nats" || u.Host == "" {
+			return nil, fmt.Errorf("invalid NATS address %q", target)
+		}
+		sink.addr = u.Host
+		if u.User != nil {
+			sink.user = u.User.Username()
+			sink.password, _ = u.User.Password()
+		}
+	}
+	if _, _, err := net.SplitHostPort(sink.addr); err != nil {
+		sink.addr = net.JoinHostPort(sink.addr, "4222")
+	}
+	return sink, nil
+}
+
+func (n *natsChangeSink) connect() error {
+	conn, err := net.DialTimeout("tcp", n.addr, cdcTimeout)
+	if err != nil {
+		return err
+	}
+	_ = conn.SetDeadline(time.Now().Add(cdcTimeout))
+	rd := bufio.NewReader(conn)
+	info, err := rd.ReadString('\n')
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if !strings.HasPrefix(info, "INFO ") {
+		_ = conn.Close()
+		return fmt.Errorf("nats: unexpected greeting %q", strings.TrimSpace(info))
+	}
+	options := map[string]any{"verbose": false, "pedantic": false, "name": "mj3gc-cdc"}
+	if n.user != "" {
+		options["user"], options["pass"] = n.user, n.password
+	}
+	raw, _ := json.Marshal(options)
+	if _, err := fmt.Fprintf(conn, "CONNECT %s\r\n", raw); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	n.conn, n.rd = conn, rd
+	return nil
+}
+
+func (n *natsChangeSink) Publish(_ context.Context, events []ChangeEvent) error {
+	if n.conn == nil {
+		if err := n.connect(); err != nil {
+			return err
+		}
+	}
+	if err := n.publish(events); err != nil {
+		_ = n.Close()
+		return err
+	}
+	return nil
+}
+
+func (n *natsChangeSink) publish(events []ChangeEvent) error {
+	_ = n.conn.SetDeadline(time.Now().Add(cdcTimeout))
+	var buf bytes.Buffer
+	for _, ev := range events {
+		payload, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&buf, "PUB %s.%s %d\r\n", n.subject, ev.Entity, len(payload))
+		buf.Write(payload)
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("PING\r\n")
+	if _, err := n.conn.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	for {
+		line, err := n.rd.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(n.conn, "PONG\r\n"); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+func (n *natsChangeSink) Close() error {
+	if n.conn == nil {
+		return nil
+	}
+	err := n.conn.Close()
+	n.conn, n.rd = nil, nil
+	return err
+}
@@ -0,0 +1,119 @@
+package mj3gc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestChangeCaptureFileSink(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "changes.jsonl")
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{CDCSink: CDCSinkFile, CDCTarget: target}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartChangeCapture(ctx)
+	s.Revision()
+
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-cdc-secret-value", Label: "first", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Revision()
+	key.Label = "second"
+	if _, err := s.UpsertAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	s.Revision()
+
+	var lines []string
+	deadline := time.Now().Add(5 * time.Second)
+	for len(lines) < 2 && time.Now().Before(deadline) {
+		raw, _ := os.ReadFile(target)
+		lines = strings.Split(strings.TrimSpace(string(raw)), "\n")
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(lines) != 2 {
+		t.Fatalf("captured %d events, want 2", len(lines))
+	}
+	if strings.Contains(strings.Join(lines, "\n"), "sk-cdc-secret-value") {
+		t.Fatal("change events contain the key secret")
+	}
+	var added, modified struct {
+		Revision  int64           `json:"revision"`
+		Entity    string          `json:"entity"`
+		Operation string          `json:"operation"`
+		Before    *APIKey         `json:"before"`
+		After     json.RawMessage `json:"after"`
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &added); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &modified); err != nil {
+		t.Fatal(err)
+	}
+	if added.Entity != ChangeKindAPIKey || added.Operation != ChangeAdded || added.Before != nil || len(added.After) == 0 {
+		t.Fatalf("added event = %+v", added)
+	}
+	if modified.Operation != ChangeModified || modified.Revision != added.Revision+1 || modified.Before == nil || modified.Before.Label != "first" {
+		t.Fatalf("modified event = %+v", modified)
+	}
+}
+
+func TestNATSChangeSink(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		_, _ = conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		rd := bufio.NewReader(conn)
+		var lines []string
+		for {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			lines = append(lines, line)
+			if line == "PING" {
+				_, _ = conn.Write([]byte("PONG\r\n"))
+				received <- lines
+				return
+			}
+		}
+	}()
+
+	sink, err := newNATSChangeSink("nats://cdc:pw@"+ln.Addr().String(), "mj3gc.changes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = sink.Close() }()
+	ev := ChangeEvent{Revision: 3, Entity: ChangeKindUser, ID: "usr_1", Operation: ChangeRemoved}
+	if err := sink.Publish(context.Background(), []ChangeEvent{ev}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	lines := <-received
+	if len(lines) != 4 || !strings.Contains(lines[0], `"user":"cdc"`) || !strings.HasPrefix(lines[1], "PUB mj3gc.changes.user ") {
+		t.Fatalf("NATS protocol lines = %q", lines)
+	}
+	var got ChangeEvent
+	if err := json.Unmarshal([]byte(lines[2]), &got); err != nil || got.ID != "usr_1" || got.Revision != 3 {
+		t.Fatalf("payload %q: %v", lines[2], err)
+	}
+}
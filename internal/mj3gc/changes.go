@@ -0,0 +1,260 @@
+package mj3gc
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// maxRecordedChanges bounds Data.Changes. Clients further behind need a full export.
+const maxRecordedChanges = 5000
+
+// Change kinds and operations recorded in Data.Changes.
+const (
+	ChangeKindUser   = "user"
+	ChangeKindAPIKey = "api_key"
+
+	ChangeAdded    = "added"
+	ChangeModified = "modified"
+	ChangeRemoved  = "removed"
+)
+
+var (
+	ErrInvalidRevision = errors.New("invalid revision")
+	// ErrRevisionExpired means the changes after the requested revision are no longer
+	// retained and the caller must resynchronise from a full export.
+	ErrRevisionExpired = errors.New("revision no longer retained")
+)
+
+// Change records that a user or key was added, modified or removed at a revision.
+// Usage counters are not tracked; they change on every request. Modified and removed
+// records keep their previous state so Rollback can undo the change.
+type Change struct {
+	Revision     int64     `json:"revision"`
+	Kind         string    `json:"kind"`
+	ID           string    `json:"id"`
+	Op           string    `json:"op"`
+	At           time.Time `json:"at"`
+	PreviousUser *User     `json:"previous_user,omitempty"`
+	PreviousKey  *APIKey   `json:"previous_key,omitempty"`
+}
+
+// RecordChange is the net change of one record between two revisions. User or APIKey
+// holds the record in its current state unless it was removed.
+type RecordChange struct {
+	ID     string  `json:"id"`
+	Op     string  `json:"op"`
+	User   *User   `json:"user,omitempty"`
+	APIKey *APIKey `json:"api_key,omitempty"`
+}
+
+// DataDiff lists the users and keys that changed after From up to and including To.
+type DataDiff struct {
+	From     int64          `json:"from"`
+	To       int64          `json:"to"`
+	Revision int64          `json:"revision"`
+	Users    []RecordChange `json:"users"`
+	APIKeys  []RecordChange `json:"api_keys"`
+}
+
+// changeTracker remembers every user and key with its fingerprint as of the last
+// recorded revision. Writes mark it dirty; the comparison runs lazily on the next Save
+// or diff so bulk changes cost one pass. It is guarded by the store mutex.
+type changeTracker struct {
+	dirty bool
+	users map[string]trackedRecord[User]
+	keys  map[string]trackedRecord[APIKey]
+}
+
+type trackedRecord[T any] struct {
+	sum    uint64
+	record T
+}
+
+func fingerprint(v any) uint64 {
+	raw, _ := json.Marshal(v)
+	h := fnv.New64a()
+	_, _ = h.Write(raw)
+	return h.Sum64()
+}
+
+func currentFingerprints(data *Data) (map[string]trackedRecord[User], map[string]trackedRecord[APIKey]) {
+	users := make(map[string]trackedRecord[User], len(data.Users))
+	for _, u := range data.Users {
+		users[u.ID] = trackedRecord[User]{sum: fingerprint(u), record: u}
+	}
+	keys := make(map[string]trackedRecord[APIKey], len(data.APIKeys))
+	for _, k := range data.APIKeys {
+		record := k
+		k.UsedCount, k.UsedTokens, k.SpentUSD, k.LastUsedAt, k.LastUsedIP = 0, 0, 0, time.Time{}, ""
+		keys[k.ID] = trackedRecord[APIKey]{sum: fingerprint(k), record: record}
+	}
+	return users, keys
+}
+
+// resetChangesLocked takes the current data as the baseline without recording changes.
+// It is used when the data comes from storage rather than from a local write.
+func (s *Store) resetChangesLocked() {
+	s.changes.users, s.changes.keys = currentFingerprints(&s.data)
+	s.changes.dirty = false
+}
+
+// recordChangesLocked compares users and keys against the baseline and, when anything
+// changed, bumps Data.Revision and appends the changes. The caller holds the write lock.
+func (s *Store) recordChangesLocked() {
+	if !s.changes.dirty && s.changes.users != nil {
+		return
+	}
+	users, keys := currentFingerprints(&s.data)
+	if s.changes.users == nil {
+		s.changes.users, s.changes.keys, s.changes.dirty = users, keys, false
+		return
+	}
+	now := time.Now().UTC()
+	revision := s.data.Revision + 1
+	changes := diffRecords(ChangeKindUser, s.changes.users, users, func(c *Change, u User) { c.PreviousUser = &u })
+	changes = append(changes, diffRecords(ChangeKindAPIKey, s.changes.keys, keys, func(c *Change, k APIKey) { c.PreviousKey = &k })...)
+	for i := range changes {
+		changes[i].Revision, changes[i].At = revision, now
+	}
+	s.changes.users, s.changes.keys, s.changes.dirty = users, keys, false
+	if len(changes) == 0 {
+		return
+	}
+	s.captureChangesLocked(changes, users, keys)
+	s.data.Revision = revision
+	// Copy so snapshots taken earlier keep their own slice.
+	history := make([]Change, 0, len(s.data.Changes)+len(changes))
+	history = append(append(history, s.data.Changes...), changes...)
+	if len(history) > maxRecordedChanges {
+		// Drop whole revisions only, so every retained revision is complete. The latest
+		// revision is always kept, however large, so a bulk change can be rolled back.
+		cut := len(history) - maxRecordedChanges
+		for cut < len(history) && history[cut].Revision == history[cut-1].Revision {
+			cut++
+		}
+		if cut == len(history) {
+			cut = len(history) - len(changes)
+		}
+		history = history[cut:]
+	}
+	s.data.Changes = history
+}
+
+// Revision returns the current data revision, recording pending changes first.
+func (s *Store) Revision() int64 {
+	if s == nil {
+		return 0
+	}
+	defer s.lockUsage("Revision")()
+	s.recordChangesLocked()
+	return s.data.Revision
+}
+
+// Diff returns the net changes to users and keys after revision from up to and
+// including revision to; to <= 0 means the current revision. Records are returned in
+// their current state, which may be newer than to.
+func (s *Store) Diff(from, to int64) (DataDiff, error) {
+	if s == nil {
+		return DataDiff{}, ErrInvalidConfiguration
+	}
+	defer s.lockUsage("Diff")()
+	s.recordChangesLocked()
+	current := s.data.Revision
+	if to <= 0 {
+		to = current
+	}
+	if from < 0 || from > to || to > current {
+		return DataDiff{}, ErrInvalidRevision
+	}
+	out := DataDiff{From: from, To: to, Revision: current, Users: []RecordChange{}, APIKeys: []RecordChange{}}
+	if from == to {
+		return out, nil
+	}
+	if len(s.data.Changes) == 0 || s.data.Changes[0].Revision > from+1 {
+		return DataDiff{}, ErrRevisionExpired
+	}
+
+	type net struct{ first, last string }
+	ops := map[string]*net{}
+	var order []Change
+	for _, change := range s.data.Changes {
+		if change.Revision <= from || change.Revision > to {
+			continue
+		}
+		id := change.Kind + "\x00" + change.ID
+		if entry, ok := ops[id]; ok {
+			entry.last = change.Op
+			continue
+		}
+		ops[id] = &net{first: change.Op, last: change.Op}
+		order = append(order, change)
+	}
+	for _, change := range order {
+		entry := ops[change.Kind+"\x00"+change.ID]
+		op := ChangeModified
+		switch {
+		case entry.first == ChangeAdded && entry.last == ChangeRemoved:
+			continue
+		case entry.first == ChangeAdded:
+			op = ChangeAdded
+		case entry.last == ChangeRemoved:
+			op = ChangeRemoved
+		}
+		record := RecordChange{ID: change.ID, Op: op}
+		if change.Kind == ChangeKindUser {
+			if op != ChangeRemoved {
+				if i, ok := s.userIDIndexLocked(change.ID); ok {
+					user := s.data.Users[i]
+					record.User = &user
+				}
+			}
+			out.Users = append(out.Users, record)
+			continue
+		}
+		if op != ChangeRemoved {
+			if i, ok := s.keyIDIndexLocked(change.ID); ok {
+				key := s.data.APIKeys[i]
+				record.APIKey = &key
+			}
+		}
+		out.APIKeys = append(out.APIKeys, record)
+	}
+	return out, nil
+}
+
+// diffRecords lists the records added, modified or removed between before and after,
+// remembering the previous state of modified and removed ones with keep.
+func diffRecords[T any](kind string, before, after map[string]trackedRecord[T], keep func(*Change, T)) []Change {
+	var changes []Change
+	for _, id := range sortedIDs(after) {
+		old, ok := before[id]
+		switch {
+		case !ok:
+			changes = append(changes, Change{Kind: kind, ID: id, Op: ChangeAdded})
+		case old.sum != after[id].sum:
+			change := Change{Kind: kind, ID: id, Op: ChangeModified}
+			keep(&change, old.record)
+			changes = append(changes, change)
+		}
+	}
+	for _, id := range sortedIDs(before) {
+		if _, ok := after[id]; !ok {
+			change := Change{Kind: kind, ID: id, Op: ChangeRemoved}
+			keep(&change, before[id].record)
+			changes = append(changes, change)
+		}
+	}
+	return changes
+}
+
+func sortedIDs[T any](m map[string]T) []string {
+	out := make([]string, 0, len(m))
+	for id := range m {
+		out = append(out, id)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,51 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiffBetweenRevisions(t *testing.T) {
+	s := NewStore()
+	s.resetChangesLocked()
+	alice, _ := s.UpsertUser(User{Username: "alice"})
+	key, _ := s.UpsertAPIKey(APIKey{Key: "k1", UserID: alice.ID, Enabled: true})
+	if got := s.Revision(); got != 1 {
+		t.Fatalf("revision = %d, want 1", got)
+	}
+
+	s.EndRequest("k1", true, "")
+	if got := s.Revision(); got != 1 {
+		t.Fatalf("usage bumped revision to %d", got)
+	}
+
+	key.Label = "renamed"
+	_, _ = s.UpsertAPIKey(key)
+	bob, _ := s.UpsertUser(User{Username: "bob"})
+	_ = s.DeleteUser(bob.ID)
+	_ = s.Revision()
+	_ = s.DeleteUser(alice.ID)
+
+	diff, err := s.Diff(1, 0)
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if diff.To != 3 || len(diff.APIKeys) != 1 || diff.APIKeys[0].Op != ChangeModified || diff.APIKeys[0].APIKey.Label != "renamed" {
+		t.Fatalf("key changes = %+v (to %d)", diff.APIKeys, diff.To)
+	}
+	if len(diff.Users) != 1 || diff.Users[0].ID != alice.ID || diff.Users[0].Op != ChangeRemoved {
+		t.Fatalf("user changes = %+v", diff.Users)
+	}
+
+	full, _ := s.Diff(0, 1)
+	if len(full.Users) != 1 || full.Users[0].Op != ChangeAdded || len(full.APIKeys) != 1 {
+		t.Fatalf("diff 0..1 = %+v", full)
+	}
+	if _, err := s.Diff(2, 9); !errors.Is(err, ErrInvalidRevision) {
+		t.Fatalf("err = %v, want ErrInvalidRevision", err)
+	}
+	s.data.Changes = s.data.Changes[2:]
+	if _, err := s.Diff(0, 0); !errors.Is(err, ErrRevisionExpired) {
+		t.Fatalf("err = %v, want ErrRevisionExpired", err)
+	}
+}
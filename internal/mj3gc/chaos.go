@@ -0,0 +1,98 @@
+package mj3gc
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// ChaosHeader names the fault injected into a response by chaos mode.
+const ChaosHeader = "X-MJ3GC-Chaos"
+
+const (
+	defaultChaosRetryAfter = time.Second
+	defaultChaosLatency    = time.Second
+)
+
+// ErrChaosPersistence is the usage persistence failure injected by chaos mode.
+var ErrChaosPersistence = errors.New("chaos: injected persistence failure")
+
+var (
+	chaosMu   sync.Mutex
+	chaosRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// chaosRoll reports whether an event with probability rate happens.
+func chaosRoll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	chaosMu.Lock()
+	defer chaosMu.Unlock()
+	return chaosRand.Float64() < rate
+}
+
+// chaosRates returns the fault rates for the key with the given id when chaos mode is
+// enabled.
+func (s *Store) chaosRates(keyID string) (config.MJ3GCChaosRates, bool) {
+	chaos := s.Settings().Chaos
+	if !chaos.Enabled || keyID == "" {
+		return config.MJ3GCChaosRates{}, false
+	}
+	if rates, ok := chaos.Keys[keyID]; ok {
+		return rates, true
+	}
+	return chaos.MJ3GCChaosRates, true
+}
+
+// injectChaos delays the request of the key authenticated as principal or rejects it as
+// rate limited, at the configured chaos rates. It returns the name of the injected
+// fault, or "" when none was, and the rejection. The delay ends early when ctx is
+// cancelled.
+func (s *Store) injectChaos(ctx context.Context, principal string) (string, error) {
+	if !s.Settings().Chaos.Enabled {
+		return "", nil
+	}
+	key, found := s.FindAPIKeyByPrincipal(principal)
+	if !found {
+		return "", nil
+	}
+	rates, _ := s.chaosRates(key.ID)
+	fault := ""
+	if chaosRoll(rates.LatencyRate) {
+		fault = "latency"
+		delay := defaultChaosLatency
+		if rates.LatencyMs > 0 {
+			delay = time.Duration(rates.LatencyMs) * time.Millisecond
+		}
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+	if chaosRoll(rates.RejectRate) {
+		retryAfter := defaultChaosRetryAfter
+		if rates.RetryAfterSeconds > 0 {
+			retryAfter = time.Duration(rates.RetryAfterSeconds) * time.Second
+		}
+		return "reject", &RateLimitError{RetryAfter: retryAfter}
+	}
+	return fault, nil
+}
+
+// chaosPersistFailure reports whether chaos mode fails the usage persistence of the key
+// with the given id. The failure is recorded in the store health like a real one.
+func (s *Store) chaosPersistFailure(keyID string) bool {
+	rates, ok := s.chaosRates(keyID)
+	if !ok || !chaosRoll(rates.PersistFailureRate) {
+		return false
+	}
+	s.notePersist(ErrChaosPersistence)
+	return true
+}
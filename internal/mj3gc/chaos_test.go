@@ -0,0 +1,71 @@
+package mj3gc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestChaosMode(t *testing.T) {
+	store := NewStore()
+	calm, err := store.UpsertAPIKey(APIKey{Key: "sk-calm", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-chaos", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	chaos := config.MJ3GCChaos{
+		Enabled:         true,
+		MJ3GCChaosRates: config.MJ3GCChaosRates{RejectRate: 1, RetryAfterSeconds: 7, LatencyRate: 1, LatencyMs: 20, PersistFailureRate: 1},
+		Keys:            map[string]config.MJ3GCChaosRates{calm.ID: {}},
+	}
+	store.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{Chaos: chaos}})
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}, QuotaMiddleware(store))
+	router.POST("/v1/chat/completions", func(c *gin.Context) { c.Status(http.StatusOK) })
+	send := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	start := time.Now()
+	rec := send("sk-chaos")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "7" || rec.Header().Get(ChaosHeader) != "reject" {
+		t.Fatalf("chaos key = %d %v", rec.Code, rec.Header())
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Fatalf("injected latency missing, request took %s", elapsed)
+	}
+	if rec := send("sk-calm"); rec.Code != http.StatusOK || rec.Header().Get(ChaosHeader) != "" {
+		t.Fatalf("overridden key = %d %v", rec.Code, rec.Header())
+	}
+
+	if err := store.SaveUsage(calm.ID, 1); errors.Is(err, ErrChaosPersistence) {
+		t.Fatal("persistence failure injected for a key without chaos")
+	}
+	chaosKey, _ := store.FindAPIKey("sk-chaos")
+	if err := store.SaveUsage(chaosKey.ID, 1); !errors.Is(err, ErrChaosPersistence) {
+		t.Fatalf("SaveUsage = %v, want ErrChaosPersistence", err)
+	}
+	if health := store.Health(); health.Status != "degraded" {
+		t.Fatalf("health after injected failure = %s", health.Status)
+	}
+
+	store.ApplyConfig(&config.Config{})
+	if rec := send("sk-chaos"); rec.Code != http.StatusOK {
+		t.Fatalf("chaos disabled = %d", rec.Code)
+	}
+}
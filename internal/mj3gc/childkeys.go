@@ -0,0 +1,91 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidParent reports a parent_id that cannot be used. A child key (one with a
+// ParentID) is held to its own limits and to the request quota, token limit and budget
+// of its parent, and every request it makes is charged to both, so per-project keys can
+// share one customer allocation.
+var ErrInvalidParent = errors.New("invalid parent key")
+
+// checkParent validates key.ParentID against the keys in d. Parents are one level
+// deep: a child cannot have children of its own.
+func (d *Data) checkParent(key APIKey) error {
+	if key.ParentID == "" {
+		return nil
+	}
+	if key.ParentID == key.ID {
+		return fmt.Errorf("%w: a key cannot be its own parent", ErrInvalidParent)
+	}
+	found := false
+	for _, existing := range d.APIKeys {
+		switch {
+		case existing.ID == key.ParentID:
+			if existing.ParentID != "" {
+				return fmt.Errorf("%w: %s is itself a child key", ErrInvalidParent, key.ParentID)
+			}
+			found = true
+		case key.ID != "" && existing.ParentID == key.ID:
+			return fmt.Errorf("%w: a key with children cannot have a parent", ErrInvalidParent)
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s not found", ErrInvalidParent, key.ParentID)
+	}
+	return nil
+}
+
+// parentQuotaErrorLocked returns the error a request of the child key is refused with
+// because the request quota, token limit or budget it shares with its parent is used
+// up, or the parent is disabled or deleted. The caller holds the store lock.
+func (s *Store) parentQuotaErrorLocked(key APIKey) error {
+	if key.ParentID == "" {
+		return nil
+	}
+	i, ok := s.keyIDIndexLocked(key.ParentID)
+	if !ok || !s.data.APIKeys[i].Enabled {
+		return ErrKeyDisabled
+	}
+	return s.data.APIKeys[i].QuotaError()
+}
+
+// ParentQuotaError is parentQuotaErrorLocked for callers outside the store.
+func (s *Store) ParentQuotaError(key APIKey) error {
+	if s == nil || key.ParentID == "" {
+		return nil
+	}
+	defer s.rlock("ParentQuotaError")()
+	return s.parentQuotaErrorLocked(key)
+}
+
+// chargeParentLocked adds a counted request, tokens and cost of a child key to its
+// parent. The caller holds the usage lock.
+func (s *Store) chargeParentLocked(key APIKey, requests, tokens int64, cost float64) {
+	if key.ParentID == "" {
+		return
+	}
+	i, ok := s.keyIDIndexLocked(key.ParentID)
+	if !ok {
+		return
+	}
+	parent := &s.data.APIKeys[i]
+	parent.UsedCount += requests
+	parent.UsedTokens += max(tokens, 0)
+	parent.SpentUSD += max(cost, 0)
+}
+
+// saveParentUsage persists the usage EndRequest or AddConsumption charged to the parent
+// of key, if it has one.
+func (s *Store) saveParentUsage(key APIKey, delta int64) {
+	if key.ParentID == "" {
+		return
+	}
+	if err := s.SaveUsage(key.ParentID, delta); err != nil {
+		log.Warnf("mj3gc: failed to persist usage of parent key %s: %v", key.ParentID, err)
+	}
+}
@@ -0,0 +1,87 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestChildKeysShareParentLimits(t *testing.T) {
+	s := NewStore()
+	parent, err := s.UpsertAPIKey(APIKey{Key: "sk-parent-allocation", Enabled: true, TotalLimit: 3, TokenLimit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := s.UpsertAPIKey(APIKey{Key: "sk-child-project-a", Enabled: true, ParentID: parent.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpsertAPIKey(APIKey{Key: "sk-child-project-b", Enabled: true, ParentID: parent.ID, TotalLimit: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, value := range []string{"sk-child-project-a", "sk-child-project-b", "sk-child-project-a"} {
+		if _, err := s.BeginRequest(value); err != nil {
+			t.Fatalf("BeginRequest(%s): %v", value, err)
+		}
+		s.EndRequest(value, true, "")
+	}
+	if got, _ := s.FindAPIKeyByID(parent.ID); got.UsedCount != 3 {
+		t.Fatalf("parent used_count = %d, want 3", got.UsedCount)
+	}
+	if got, _ := s.FindAPIKeyByID(a.ID); got.UsedCount != 2 {
+		t.Fatalf("child used_count = %d, want 2", got.UsedCount)
+	}
+	if _, err := s.BeginRequest("sk-child-project-a"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("BeginRequest over the parent quota = %v", err)
+	}
+	if _, err := s.ResetUsage(parent.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.BeginRequest("sk-child-project-b"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("child's own limit not enforced: %v", err)
+	}
+
+	if _, ok := s.AddConsumption("sk-child-project-a", 150, 0); !ok {
+		t.Fatal("AddConsumption failed")
+	}
+	if got, _ := s.FindAPIKeyByID(parent.ID); got.UsedTokens != 150 {
+		t.Fatalf("parent used_tokens = %d", got.UsedTokens)
+	}
+	if _, err := s.BeginRequest("sk-child-project-a"); !errors.Is(err, ErrTokenQuotaExceeded) {
+		t.Fatalf("BeginRequest over the parent token limit = %v", err)
+	}
+	if err := s.ParentQuotaError(a); !errors.Is(err, ErrTokenQuotaExceeded) {
+		t.Fatalf("ParentQuotaError = %v", err)
+	}
+
+	parent.Enabled = false
+	parent.UsedTokens = 0
+	if _, err := s.UpsertAPIKey(parent); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.BeginRequest("sk-child-project-a"); !errors.Is(err, ErrKeyDisabled) {
+		t.Fatalf("BeginRequest with a disabled parent = %v", err)
+	}
+}
+
+func TestChildKeyParentValidation(t *testing.T) {
+	s := NewStore()
+	parent, _ := s.UpsertAPIKey(APIKey{Key: "sk-parent-allocation", Enabled: true})
+	child, _ := s.UpsertAPIKey(APIKey{Key: "sk-child-project-a", Enabled: true, ParentID: parent.ID})
+	cases := map[string]APIKey{
+		"missing":      {Key: "sk-orphan-child-key", ParentID: "key_missing"},
+		"nested":       {Key: "sk-grandchild-key-x", ParentID: child.ID},
+		"self":         {ID: parent.ID, Key: parent.Key, ParentID: parent.ID},
+		"has_children": {ID: parent.ID, Key: parent.Key, ParentID: "key_other"},
+	}
+	if other, err := s.UpsertAPIKey(APIKey{Key: "sk-other-allocation"}); err == nil {
+		c := cases["has_children"]
+		c.ParentID = other.ID
+		cases["has_children"] = c
+	}
+	for name, key := range cases {
+		if _, err := s.UpsertAPIKey(key); !errors.Is(err, ErrInvalidParent) {
+			t.Fatalf("%s: UpsertAPIKey = %v, want ErrInvalidParent", name, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package mj3gc
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+// CompactOptions selects what Compact removes. Zero durations skip the step.
+type CompactOptions struct {
+	// UnusedFor moves keys not used for this long into the trash. Keys that were never
+	// used are judged by their creation time.
+	UnusedFor time.Duration
+	// Orphaned moves keys whose user no longer exists into the trash.
+	Orphaned bool
+	// DetailsOlderThan drops per-request usage details older than this from the in-memory
+	// usage statistics. Aggregated totals are kept.
+	DetailsOlderThan time.Duration
+}
+
+// CompactReport lists what Compact removed.
+type CompactReport struct {
+	UnusedKeys       []string `json:"unused_keys"`
+	OrphanedKeys     []string `json:"orphaned_keys"`
+	CompactedDetails int      `json:"compacted_details"`
+}
+
+// Removed reports whether anything was removed from the store itself.
+func (r CompactReport) Removed() bool {
+	return len(r.UnusedKeys) > 0 || len(r.OrphanedKeys) > 0
+}
+
+// CompactOptions returns the options configured by mj3gc.prune-unused-keys-after-days,
+// mj3gc.prune-orphaned-keys and mj3gc.usage-detail-retention-days.
+func (s *Store) CompactOptions() CompactOptions {
+	settings := s.Settings()
+	return CompactOptions{
+		UnusedFor:        time.Duration(settings.PruneUnusedKeysAfterDays) * 24 * time.Hour,
+		Orphaned:         settings.PruneOrphanedKeys,
+		DetailsOlderThan: time.Duration(settings.UsageDetailRetentionDays) * 24 * time.Hour,
+	}
+}
+
+// Compact prunes stale keys into the trash, where they stay restorable until the trash
+// is purged, and compacts the usage detail history. Callers persist the store when
+// the report says keys were removed.
+func (s *Store) Compact(opts CompactOptions) CompactReport {
+	var report CompactReport
+	if s == nil {
+		return report
+	}
+	now := time.Now()
+	if opts.UnusedFor > 0 || opts.Orphaned {
+		unlock := s.lock("Compact")
+		users := make(map[string]struct{}, len(s.data.Users))
+		for _, u := range s.data.Users {
+			users[u.ID] = struct{}{}
+		}
+		kept := make([]APIKey, 0, len(s.data.APIKeys))
+		for _, k := range s.data.APIKeys {
+			_, hasUser := users[k.UserID]
+			switch {
+			case opts.Orphaned && k.UserID != "" && !hasUser:
+				report.OrphanedKeys = append(report.OrphanedKeys, k.ID)
+			case opts.UnusedFor > 0 && keyIdleSince(k).Before(now.Add(-opts.UnusedFor)):
+				report.UnusedKeys = append(report.UnusedKeys, k.ID)
+			default:
+				kept = append(kept, k)
+				continue
+			}
+			delete(s.inflight, k.ID)
+			k.DeletedAt = now
+			s.data.DeletedKeys = append(s.data.DeletedKeys, k)
+		}
+		s.data.APIKeys = kept
+		unlock()
+	}
+	if opts.DetailsOlderThan > 0 {
+		report.CompactedDetails = usage.GetRequestStatistics().PruneDetails(now.Add(-opts.DetailsOlderThan))
+	}
+	return report
+}
+
+// keyIdleSince returns when the key was last used, or created when it never was. A
+// later re-enabling restarts the idle period.
+func keyIdleSince(k APIKey) time.Time {
+	since := k.CreatedAt
+	if !k.LastUsedAt.IsZero() {
+		since = k.LastUsedAt
+	}
+	if k.ReenabledAt.After(since) {
+		since = k.ReenabledAt
+	}
+	return since
+}
@@ -0,0 +1,44 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCompactPrunesUnusedAndOrphanedKeys(t *testing.T) {
+	s := NewStore()
+	now := time.Now()
+	s.data.Users = []User{{ID: "u1", Username: "alice"}}
+	s.data.APIKeys = []APIKey{
+		{ID: "active", Key: "a", UserID: "u1", LastUsedAt: now},
+		{ID: "idle", Key: "b", UserID: "u1", LastUsedAt: now.Add(-100 * 24 * time.Hour)},
+		{ID: "never", Key: "c", CreatedAt: now.Add(-100 * 24 * time.Hour)},
+		{ID: "orphan", Key: "d", UserID: "gone", LastUsedAt: now},
+	}
+
+	report := s.Compact(CompactOptions{UnusedFor: 90 * 24 * time.Hour, Orphaned: true})
+	if len(report.UnusedKeys) != 2 || len(report.OrphanedKeys) != 1 || report.OrphanedKeys[0] != "orphan" {
+		t.Fatalf("report = %+v", report)
+	}
+	keys := s.ListAPIKeys()
+	if len(keys) != 1 || keys[0].ID != "active" {
+		t.Fatalf("remaining keys = %+v", keys)
+	}
+	if trashed := s.ListDeletedAPIKeys(); len(trashed) != 3 {
+		t.Fatalf("trash = %+v", trashed)
+	}
+	if _, err := s.RestoreDeletedKey("idle"); err != nil {
+		t.Fatalf("RestoreDeletedKey: %v", err)
+	}
+}
+
+func TestCompactZeroOptionsKeepsKeys(t *testing.T) {
+	s := NewStore()
+	s.data.APIKeys = []APIKey{{ID: "k", Key: "a", UserID: "gone"}}
+	if report := s.Compact(CompactOptions{}); report.Removed() {
+		t.Fatalf("report = %+v", report)
+	}
+	if len(s.ListAPIKeys()) != 1 {
+		t.Fatal("key removed without options")
+	}
+}
@@ -5,11 +5,14 @@ import (
 
 	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
 	sdkconfig "github.com/router-for-me/CLIProxyAPI/v6/sdk/config"
+	log "github.com/sirupsen/logrus"
 )
 
 const accessProviderType = "mj3gc-api-key"
 
 // EnsureAccessProvider injects the mj3gc access provider into the config if missing.
+// The inline api-keys provider is kept in front of it, since an explicit provider list
+// otherwise replaces it.
 func EnsureAccessProvider(cfg *config.Config) {
 	if cfg == nil {
 		return
@@ -19,8 +22,49 @@ func EnsureAccessProvider(cfg *config.Config) {
 			return
 		}
 	}
+	if len(cfg.Access.Providers) == 0 {
+		if inline := config.MakeInlineAPIKeyProvider(cfg.APIKeys); inline != nil {
+			cfg.Access.Providers = append(cfg.Access.Providers, *inline)
+		}
+	}
 	cfg.Access.Providers = append(cfg.Access.Providers, sdkconfig.AccessProvider{
 		Name: "mj3gc",
 		Type: accessProviderType,
 	})
 }
+
+// ApplyConfig updates the store settings from the mj3gc section of cfg.
+func (s *Store) ApplyConfig(cfg *config.Config) {
+	if s == nil || cfg == nil {
+		return
+	}
+	key, err := LoadEncryptionKey(cfg.MJ3GC)
+	if err != nil {
+		log.Errorf("mj3gc: %v; the data file will not be read or written", err)
+	}
+	signKey, errSign := LoadSigningKey(cfg.MJ3GC)
+	if errSign != nil {
+		log.Errorf("mj3gc: %v; the data file will not be read or written", errSign)
+		if err == nil {
+			err = errSign
+		}
+	}
+	if cfg.MJ3GC.Chaos.Enabled {
+		log.Warn("mj3gc: chaos mode is enabled, requests will see injected rejections, latency and persistence failures")
+	}
+	s.locks.timing.Store(cfg.MJ3GC.LockTiming)
+	unlock := s.lock("ApplyConfig")
+	s.settings = cfg.MJ3GC
+	s.dataKey, s.dataKeyErr = key, err
+	s.signKey = signKey
+	unlock()
+}
+
+// Settings returns the mj3gc settings currently applied to the store.
+func (s *Store) Settings() config.MJ3GCConfig {
+	if s == nil {
+		return config.MJ3GCConfig{}
+	}
+	defer s.rlock("Settings")()
+	return s.settings
+}
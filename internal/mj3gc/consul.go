@@ -0,0 +1,136 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// consulWait is the longest blocking query Consul accepts without clamping.
+const consulWait = "5m"
+
+// OpenConsulBackend stores users and keys in the Consul KV store at settings.KVEndpoint
+// (for example http://127.0.0.1:8500), authenticating with settings.KVToken when set.
+func OpenConsulBackend(settings config.MJ3GCConfig) (Backend, error) {
+	endpoint := strings.TrimRight(strings.TrimSpace(settings.KVEndpoint), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("mj3gc: kv-endpoint is required for consul storage")
+	}
+	kv := &consulKV{endpoint: endpoint, token: settings.KVToken, client: &http.Client{}}
+	ctx, cancel := context.WithTimeout(context.Background(), kvTimeout)
+	defer cancel()
+	if _, _, err := kv.List(ctx, defaultKVPrefix); err != nil {
+		return nil, fmt.Errorf("mj3gc: connect to consul: %w", err)
+	}
+	return newKVBackend(kv, settings.KVPrefix), nil
+}
+
+// consulKV is a minimal client for the Consul KV HTTP API. Changes are detected with
+// blocking queries on the prefix.
+type consulKV struct {
+	endpoint string
+	token    string
+	client   *http.Client
+}
+
+func consulKeyPath(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return "/v1/kv/" + strings.Join(parts, "/")
+}
+
+func (c *consulKV) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	target := c.endpoint + consulKeyPath(key)
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if c.token != "" {
+		req.Header.Set("X-Consul-Token", c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusNotFound {
+		defer func() { _ = resp.Body.Close() }()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("consul %s %s returned %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func consulIndex(resp *http.Response) uint64 {
+	index, _ := strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+	return index
+}
+
+func (c *consulKV) list(ctx context.Context, prefix string, query url.Values) (map[string][]byte, uint64, error) {
+	query.Set("recurse", "true")
+	resp, err := c.do(ctx, http.MethodGet, prefix, query, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	entries := make(map[string][]byte)
+	if resp.StatusCode == http.StatusNotFound {
+		return entries, consulIndex(resp), nil
+	}
+	var pairs []struct {
+		Key   string `json:"Key"`
+		Value []byte `json:"Value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&pairs); err != nil {
+		return nil, 0, err
+	}
+	for _, pair := range pairs {
+		entries[pair.Key] = pair.Value
+	}
+	return entries, consulIndex(resp), nil
+}
+
+func (c *consulKV) List(ctx context.Context, prefix string) (map[string][]byte, uint64, error) {
+	return c.list(ctx, prefix, url.Values{})
+}
+
+func (c *consulKV) Put(ctx context.Context, key string, value []byte) error {
+	resp, err := c.do(ctx, http.MethodPut, key, nil, value)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (c *consulKV) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (c *consulKV) Wait(ctx context.Context, prefix string, revision uint64) (uint64, error) {
+	query := url.Values{}
+	query.Set("index", strconv.FormatUint(revision, 10))
+	query.Set("wait", consulWait)
+	_, index, err := c.list(ctx, prefix, query)
+	if err != nil {
+		return revision, err
+	}
+	return index, nil
+}
@@ -0,0 +1,92 @@
+package mj3gc
+
+import (
+	"context"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	coreusage.RegisterPlugin(consumptionPlugin{})
+}
+
+// checkTokenBudget rejects new requests once the key has used its token limit or spent
+// its budget. Zero means unlimited. The request that crosses a limit still completes,
+// so usage may end slightly above it.
+func (k APIKey) checkTokenBudget() error {
+	if k.TokenLimit > 0 && k.UsedTokens >= k.TokenLimit {
+		return ErrTokenQuotaExceeded
+	}
+	if k.BudgetUSD > 0 && k.SpentUSD >= k.BudgetUSD {
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// QuotaError returns the error the next request of the key is refused with because its
+// request quota, token limit or budget is used up, or nil. Unlike BeginRequest it does
+// not look at concurrency, rate limits or shared counters.
+func (k APIKey) QuotaError() error {
+	if k.TotalLimit > 0 && k.UsedCount >= k.TotalLimit {
+		return ErrQuotaExceeded
+	}
+	return k.checkTokenBudget()
+}
+
+// limitsConsumption reports whether the key has a token limit or budget, so that its
+// consumption has to be persisted as it happens.
+func (k APIKey) limitsConsumption() bool {
+	return k.TokenLimit > 0 || k.BudgetUSD > 0
+}
+
+// AddConsumption adds the tokens and estimated cost of a finished request to the key
+// with the given principal and returns the updated key.
+func (s *Store) AddConsumption(principal string, tokens int64, cost float64) (APIKey, bool) {
+	if s == nil || principal == "" || (tokens <= 0 && cost <= 0) {
+		return APIKey{}, false
+	}
+	defer s.lockUsage("AddConsumption")()
+	i, ok := s.principalIndexLocked(principal)
+	if !ok {
+		return APIKey{}, false
+	}
+	key := &s.data.APIKeys[i]
+	key.UsedTokens += max(tokens, 0)
+	key.SpentUSD += max(cost, 0)
+	key.LastUsedAt = time.Now()
+	s.chargeParentLocked(*key, 0, tokens, cost)
+	return *key, true
+}
+
+// consumptionPlugin counts the tokens and estimated cost of finished requests against
+// their mj3gc key. Usage records arrive after the request has ended, so keys with a
+// token limit or budget are persisted here rather than by QuotaMiddleware.
+type consumptionPlugin struct{}
+
+func (consumptionPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	store := DefaultStore()
+	cost := EstimateCost(store.Settings().ModelPrices, RequestSample{
+		Model:           record.Model,
+		InputTokens:     record.Detail.InputTokens,
+		OutputTokens:    record.Detail.OutputTokens,
+		ReasoningTokens: record.Detail.ReasoningTokens,
+		CachedTokens:    record.Detail.CachedTokens,
+		TotalTokens:     record.Detail.TotalTokens,
+	})
+	key, ok := store.AddConsumption(record.APIKey, record.Detail.TotalTokens, cost)
+	if !ok {
+		return
+	}
+	store.RaiseUsageAlerts(key.ID, time.Now())
+	if parent, found := store.FindAPIKeyByID(key.ParentID); found && parent.limitsConsumption() {
+		store.saveParentUsage(key, 0)
+	}
+	if !key.limitsConsumption() {
+		return
+	}
+	if err := store.SaveUsage(key.ID, 0); err != nil {
+		log.Warnf("mj3gc: failed to persist consumption of key %s: %v", key.ID, err)
+	}
+}
@@ -0,0 +1,63 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTokenQuota(t *testing.T) {
+	s := NewStore()
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-token-limited", Enabled: true, TokenLimit: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.BeginRequest(key.Key); err != nil {
+		t.Fatalf("first request: %v", err)
+	}
+	s.EndRequest(key.Key, true, "")
+	if updated, ok := s.AddConsumption(key.Key, 600, 0); !ok || updated.UsedTokens != 600 {
+		t.Fatalf("AddConsumption = %+v, %v", updated, ok)
+	}
+	if _, err := s.BeginRequest(key.Key); err != nil {
+		t.Fatalf("request within the token budget: %v", err)
+	}
+	s.EndRequest(key.Key, true, "")
+	s.AddConsumption(key.Key, 500, 0)
+
+	if _, err := s.BeginRequest(key.Key); !errors.Is(err, ErrTokenQuotaExceeded) {
+		t.Fatalf("request over the token budget = %v, want ErrTokenQuotaExceeded", err)
+	}
+	check, err := s.CheckRequest(key.Key, 1)
+	if err != nil || check.Allowed || check.UsedTokens != 1100 {
+		t.Fatalf("CheckRequest = %+v, %v", check, err)
+	}
+	reset, err := s.ResetUsage(key.ID)
+	if err != nil || reset.UsedTokens != 0 {
+		t.Fatalf("ResetUsage = %+v, %v", reset, err)
+	}
+	if _, err := s.BeginRequest(key.Key); err != nil {
+		t.Fatalf("request after reset: %v", err)
+	}
+}
+
+func TestBudget(t *testing.T) {
+	s := NewStore()
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-budget-limited", Enabled: true, BudgetUSD: 0.01})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored, ok := s.AddConsumption(key.Key, 1500, 0.006); !ok || stored.SpentUSD != 0.006 {
+		t.Fatalf("AddConsumption = %+v, %v", stored, ok)
+	}
+	if _, err := s.BeginRequest(key.Key); err != nil {
+		t.Fatalf("request within budget: %v", err)
+	}
+	s.EndRequest(key.Key, true, "")
+	s.AddConsumption(key.Key, 1500, 0.006)
+	if _, err := s.BeginRequest(key.Key); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("request over budget = %v, want ErrBudgetExceeded", err)
+	}
+	if reset, _ := s.ResetUsage(key.ID); reset.SpentUSD != 0 {
+		t.Fatalf("ResetUsage kept spend %v", reset.SpentUSD)
+	}
+}
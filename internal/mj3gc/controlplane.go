@@ -0,0 +1,160 @@
+package mj3gc
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// controlPlaneWait bounds how long GET /internal/mj3gc/revision holds a request
+	// open waiting for a change.
+	controlPlaneWait = 55 * time.Second
+	// controlPlanePoll is how often a waiting request checks the revision.
+	controlPlanePoll = 250 * time.Millisecond
+	// controlPlaneRevisionHeader carries the data revision of GET /internal/mj3gc/data.
+	controlPlaneRevisionHeader = "X-MJ3GC-Revision"
+)
+
+// usageDelta is the body of POST /internal/mj3gc/usage and /internal/mj3gc/usage/reset.
+type usageDelta struct {
+	KeyID string `json:"key_id"`
+	Delta int64  `json:"delta,omitempty"`
+}
+
+// ControlPlaneAuth guards the internal API that proxy instances with storage
+// "control-plane" consume. It answers 404 unless mj3gc.control-plane-token is set and
+// requires that token as a bearer credential.
+func ControlPlaneAuth(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil || store.currentBackend() == nil {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		token := store.Settings().ControlPlaneToken
+		if token == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		got := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid control plane token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// ControlPlaneDataHandler serves GET /internal/mj3gc/data: the users, keys and settings
+// a proxy instance needs to authenticate and enforce quotas, without the change history.
+func ControlPlaneDataHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revision := store.Revision()
+		data := store.Snapshot()
+		data.Changes = nil
+		c.Header(controlPlaneRevisionHeader, strconv.FormatInt(revision, 10))
+		c.JSON(http.StatusOK, data)
+	}
+}
+
+// ControlPlaneRevisionHandler serves GET /internal/mj3gc/revision?after=N. When after
+// is the current revision the request waits for the next change and answers 304 if
+// none happens in time.
+func ControlPlaneRevisionHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revision := store.Revision()
+		raw := c.Query("after")
+		if raw == "" {
+			c.JSON(http.StatusOK, gin.H{"revision": revision})
+			return
+		}
+		after, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid after"})
+			return
+		}
+		deadline := time.NewTimer(controlPlaneWait)
+		defer deadline.Stop()
+		ticker := time.NewTicker(controlPlanePoll)
+		defer ticker.Stop()
+		for revision == after {
+			select {
+			case <-c.Request.Context().Done():
+				return
+			case <-deadline.C:
+				c.Status(http.StatusNotModified)
+				return
+			case <-ticker.C:
+				revision = store.Revision()
+			}
+		}
+		c.JSON(http.StatusOK, gin.H{"revision": revision})
+	}
+}
+
+// ControlPlaneUsageHandler serves POST /internal/mj3gc/usage, adding the usage a proxy
+// instance counted to the key and returning the new total.
+func ControlPlaneUsageHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body usageDelta
+		if err := c.ShouldBindJSON(&body); err != nil || body.KeyID == "" || body.Delta < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		total, err := store.AddUsage(body.KeyID, body.Delta)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err := store.SaveUsage(body.KeyID, body.Delta); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if key, ok := store.FindAPIKeyByID(body.KeyID); ok {
+			total = key.UsedCount
+		}
+		c.JSON(http.StatusOK, gin.H{"used_count": total})
+	}
+}
+
+// ControlPlaneResetUsageHandler serves POST /internal/mj3gc/usage/reset.
+func ControlPlaneResetUsageHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body usageDelta
+		if err := c.ShouldBindJSON(&body); err != nil || body.KeyID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		if _, err := store.ResetUsage(body.KeyID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if err := store.Save(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"used_count": 0})
+	}
+}
+
+// AddUsage adds delta requests counted elsewhere to the key with the given id and
+// returns its new total. Callers persist the change with SaveUsage.
+func (s *Store) AddUsage(id string, delta int64) (int64, error) {
+	if s == nil {
+		return 0, ErrInvalidConfiguration
+	}
+	defer s.lockUsage("AddUsage")()
+	i, ok := s.keyIDIndexLocked(id)
+	if !ok {
+		return 0, ErrKeyNotFound
+	}
+	s.data.APIKeys[i].UsedCount += delta
+	if delta > 0 {
+		s.data.APIKeys[i].LastUsedAt = time.Now()
+	}
+	return s.data.APIKeys[i].UsedCount, nil
+}
@@ -0,0 +1,105 @@
+package mj3gc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestControlPlaneBackend(t *testing.T) {
+	const token = "control-plane-token"
+	primary := NewStore()
+	primary.SetPath(filepath.Join(t.TempDir(), "mj3gc.json"))
+	primary.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{ControlPlaneToken: token}})
+	if err := primary.Load(); err != nil {
+		t.Fatal(err)
+	}
+	key, err := primary.UpsertAPIKey(APIKey{Key: "sk-control", Enabled: true, TotalLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	group := router.Group("/internal/mj3gc", ControlPlaneAuth(primary))
+	group.GET("/data", ControlPlaneDataHandler(primary))
+	group.GET("/revision", ControlPlaneRevisionHandler(primary))
+	group.POST("/usage", ControlPlaneUsageHandler(primary))
+	group.POST("/usage/reset", ControlPlaneResetUsageHandler(primary))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	if _, err := OpenControlPlaneBackend(config.MJ3GCConfig{ControlPlaneURL: server.URL}); err == nil {
+		t.Fatal("opening without a token should fail")
+	}
+	bad := &controlPlaneBackend{endpoint: server.URL, token: "wrong", client: server.Client()}
+	if _, err := bad.Load(); err == nil {
+		t.Fatal("Load with a wrong token should fail")
+	}
+
+	settings := config.MJ3GCConfig{Storage: storageControlPlane, ControlPlaneURL: server.URL, ControlPlaneToken: token}
+	backend, err := OpenBackend(settings, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	replica := NewStore()
+	replica.ApplyConfig(&config.Config{MJ3GC: settings})
+	replica.SetBackend(backend)
+	if err := replica.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !replica.ReadOnly() {
+		t.Fatal("control-plane store should be read-only")
+	}
+
+	if _, err := replica.BeginRequest("sk-control"); err != nil {
+		t.Fatalf("BeginRequest: %v", err)
+	}
+	replica.EndRequest("sk-control", true, "")
+	if err := replica.SaveUsage(key.ID, 1); err != nil {
+		t.Fatalf("SaveUsage: %v", err)
+	}
+	if got, _ := primary.FindAPIKeyByID(key.ID); got.UsedCount != 1 {
+		t.Fatalf("control plane used count = %d, want 1", got.UsedCount)
+	}
+	if err := replica.Save(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Save = %v, want ErrReadOnly", err)
+	}
+
+	changed := make(chan error, 1)
+	go func() { changed <- backend.(ChangeWatcher).WaitForChange(context.Background()) }()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := primary.UpsertAPIKey(APIKey{Key: "sk-added", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-changed:
+		if err != nil {
+			t.Fatalf("WaitForChange: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WaitForChange did not return after a change")
+	}
+	if err := replica.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := replica.FindAPIKey("sk-added"); !ok {
+		t.Fatal("replica did not pick up the new key")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/internal/mj3gc/data", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,237 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/quota"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	countersLocal = "local"
+	countersRedis = "redis"
+
+	defaultInflightTTL = 10 * time.Minute
+)
+
+// CounterBackend tracks in-flight requests and usage outside the process so concurrency
+// and quota limits hold across replicas. User and key configuration stays in the Backend.
+type CounterBackend interface {
+	quota.Counters
+	// ResetUsage sets the usage counter of keyID to zero.
+	ResetUsage(keyID string) error
+	// Seed initialises the usage counter of keyID unless it already exists.
+	Seed(keyID string, used int64) error
+}
+
+// OpenCounterBackend creates the counter backend selected by settings.Counters.
+// A nil backend means counters are kept in process.
+func OpenCounterBackend(settings config.MJ3GCConfig) (CounterBackend, error) {
+	switch strings.ToLower(strings.TrimSpace(settings.Counters)) {
+	case "", countersLocal:
+		return nil, nil
+	case countersRedis:
+		addr := strings.TrimSpace(settings.RedisAddr)
+		if addr == "" {
+			return nil, fmt.Errorf("mj3gc: redis-addr is required for redis counters")
+		}
+		ttl := time.Duration(settings.RedisInflightTTLSeconds) * time.Second
+		if ttl <= 0 {
+			ttl = defaultInflightTTL
+		}
+		counters := &redisCounters{
+			client: newRedisClient(addr, settings.RedisPassword, settings.RedisDB),
+			prefix: "mj3gc:",
+			ttl:    ttl,
+		}
+		if _, err := counters.client.do("PING"); err != nil {
+			_ = counters.client.Close()
+			return nil, fmt.Errorf("mj3gc: ping redis: %w", err)
+		}
+		return counters, nil
+	default:
+		return nil, errors.New("mj3gc: unsupported counters " + settings.Counters)
+	}
+}
+
+// SetCounterBackend routes BeginRequest/EndRequest accounting through counters and seeds
+// the shared usage counters with the values currently loaded.
+func (s *Store) SetCounterBackend(counters CounterBackend) {
+	if s == nil {
+		return
+	}
+	if counters != nil {
+		for _, key := range s.ListAPIKeys() {
+			if err := counters.Seed(key.ID, key.UsedCount); err != nil {
+				log.Warnf("mj3gc: failed to seed usage counter for %s: %v", key.ID, err)
+			}
+		}
+	}
+	defer s.lock("SetCounterBackend")()
+	s.counters = counters
+}
+
+func (s *Store) counterBackend() CounterBackend {
+	defer s.rlock("counterBackend")()
+	return s.counters
+}
+
+// sharedEnforcer applies quota semantics to counters, logging counter errors which
+// fail open so a Redis outage degrades enforcement instead of rejecting all traffic.
+func sharedEnforcer(counters CounterBackend) *quota.Enforcer {
+	return &quota.Enforcer{
+		Counters: counters,
+		OnError: func(op, id string, err error) {
+			log.Warnf("mj3gc: shared counter %s failed for %s: %v", op, id, err)
+		},
+	}
+}
+
+// beginShared admits a request using the shared counters.
+func (s *Store) beginShared(counters CounterBackend, value string) (APIKey, error) {
+	key, ok := s.FindAPIKeyByPrincipal(value)
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	// Token usage and rate windows are kept per replica; only the request quota and
+	// concurrency are shared.
+	now := time.Now()
+	if err := key.checkSchedule(now); err != nil {
+		return APIKey{}, err
+	}
+	if err := key.checkTokenBudget(); err != nil {
+		return APIKey{}, err
+	}
+	if err := s.checkSharedParent(counters, key); err != nil {
+		return APIKey{}, err
+	}
+	enforcer := sharedEnforcer(counters)
+	if err := enforcer.Begin(key.ID, key.Limits()); err != nil {
+		return APIKey{}, err
+	}
+	// The rate window is charged only once the shared checks passed; a rate rejection
+	// gives the concurrency slot back uncounted.
+	if err := s.rates.admit(key.ID, key.RPMLimit, now); err != nil {
+		enforcer.End(key.ID, key.Limits(), false)
+		return APIKey{}, err
+	}
+	return key, nil
+}
+
+func (s *Store) endShared(counters CounterBackend, value string, count bool, clientIP string) {
+	key, ok := s.FindAPIKeyByPrincipal(value)
+	if !ok {
+		return
+	}
+	total := sharedEnforcer(counters).End(key.ID, key.Limits(), count)
+	if !count {
+		return
+	}
+	if total < 0 {
+		total = key.UsedCount + 1
+	}
+	s.setLastUse(key.ID, total, clientIP)
+	if key.ParentID == "" {
+		return
+	}
+	parentTotal, err := counters.AddUsage(key.ParentID, 1)
+	if err != nil {
+		log.Warnf("mj3gc: shared counter add_usage failed for %s: %v", key.ParentID, err)
+		return
+	}
+	s.setUsedCount(key.ParentID, parentTotal)
+}
+
+// checkSharedParent checks the limits a child key shares with its parent against the
+// parent's shared usage counter. Counter errors fail open like sharedEnforcer.
+func (s *Store) checkSharedParent(counters CounterBackend, key APIKey) error {
+	if key.ParentID == "" {
+		return nil
+	}
+	parent, ok := s.FindAPIKeyByID(key.ParentID)
+	if !ok || !parent.Enabled {
+		return ErrKeyDisabled
+	}
+	if err := parent.checkTokenBudget(); err != nil {
+		return err
+	}
+	if parent.TotalLimit <= 0 {
+		return nil
+	}
+	used, err := counters.Usage(parent.ID)
+	if err != nil {
+		log.Warnf("mj3gc: shared counter usage failed for %s: %v", parent.ID, err)
+		return nil
+	}
+	return quota.Check(parent.Limits(), used)
+}
+
+// redisCounters implements CounterBackend with INCR/DECR on Redis keys. In-flight keys
+// carry a TTL so slots leaked by a crashed replica eventually expire.
+type redisCounters struct {
+	client *redisClient
+	prefix string
+	ttl    time.Duration
+}
+
+func (r *redisCounters) inflightKey(keyID string) string { return r.prefix + "inflight:" + keyID }
+func (r *redisCounters) usageKey(keyID string) string    { return r.prefix + "used:" + keyID }
+
+func (r *redisCounters) Acquire(keyID string, limit int) (bool, error) {
+	name := r.inflightKey(keyID)
+	current, err := r.client.int("INCR", name)
+	if err != nil {
+		return false, err
+	}
+	if _, err := r.client.do("EXPIRE", name, strconv.Itoa(int(r.ttl/time.Second))); err != nil {
+		return false, err
+	}
+	if current > int64(limit) {
+		_, _ = r.client.do("DECR", name)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (r *redisCounters) Release(keyID string) error {
+	current, err := r.client.int("DECR", r.inflightKey(keyID))
+	if err != nil {
+		return err
+	}
+	if current < 0 {
+		_, err = r.client.do("SET", r.inflightKey(keyID), "0")
+	}
+	return err
+}
+
+func (r *redisCounters) Usage(keyID string) (int64, error) {
+	used, err := r.client.int("GET", r.usageKey(keyID))
+	if errors.Is(err, errRedisNil) {
+		return 0, nil
+	}
+	return used, err
+}
+
+func (r *redisCounters) AddUsage(keyID string, delta int64) (int64, error) {
+	return r.client.int("INCRBY", r.usageKey(keyID), strconv.FormatInt(delta, 10))
+}
+
+func (r *redisCounters) ResetUsage(keyID string) error {
+	_, err := r.client.do("SET", r.usageKey(keyID), "0")
+	return err
+}
+
+func (r *redisCounters) Seed(keyID string, used int64) error {
+	_, err := r.client.do("SETNX", r.usageKey(keyID), strconv.FormatInt(used, 10))
+	return err
+}
+
+// Close releases pooled connections.
+func (r *redisCounters) Close() error {
+	return r.client.Close()
+}
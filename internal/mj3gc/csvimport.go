@@ -0,0 +1,146 @@
+package mj3gc
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidCSV is returned by ImportKeysCSV when the file itself cannot be used.
+var ErrInvalidCSV = errors.New("invalid csv")
+
+// csvKeyColumns are the columns ImportKeysCSV understands. user accepts a user ID or
+// username; a blank key generates a new secret.
+var csvKeyColumns = []string{"key", "label", "user", "total_limit", "concurrency_limit", "enabled"}
+
+// KeyImportRow is the outcome of one CSV row. Line is the 1-based line in the file.
+type KeyImportRow struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Label string `json:"label,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// KeyImportReport summarises ImportKeysCSV.
+type KeyImportReport struct {
+	Created int            `json:"created"`
+	Failed  int            `json:"failed"`
+	Rows    []KeyImportRow `json:"rows"`
+}
+
+// ImportKeysCSV creates one key per CSV row. The first row is a header naming the
+// columns in csvKeyColumns. Rows that fail validation are reported and skipped; the
+// remaining keys are created in a single batch.
+func (s *Store) ImportKeysCSV(r io.Reader) (KeyImportReport, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return KeyImportReport{}, fmt.Errorf("%w: read header: %v", ErrInvalidCSV, err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		known := false
+		for _, column := range csvKeyColumns {
+			known = known || column == name
+		}
+		if !known {
+			return KeyImportReport{}, fmt.Errorf("%w: unknown column %q", ErrInvalidCSV, name)
+		}
+		columns[name] = i
+	}
+
+	var records [][]string
+	var lines []int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return KeyImportReport{}, fmt.Errorf("%w: %v", ErrInvalidCSV, err)
+		}
+		line, _ := reader.FieldPos(0)
+		records = append(records, record)
+		lines = append(lines, line)
+	}
+
+	var report KeyImportReport
+	err = s.Batch(func(tx *Tx) error {
+		for i, record := range records {
+			field := func(name string) string {
+				if idx, ok := columns[name]; ok && idx < len(record) {
+					return strings.TrimSpace(record[idx])
+				}
+				return ""
+			}
+			row := KeyImportRow{Line: lines[i], Label: field("label")}
+			key, errRow := csvKey(tx, field)
+			if errRow == nil {
+				key, errRow = tx.UpsertAPIKey(key)
+			}
+			if errRow != nil {
+				row.Error = errRow.Error()
+				report.Failed++
+			} else {
+				row.ID, row.Key = key.ID, key.Key
+				report.Created++
+			}
+			report.Rows = append(report.Rows, row)
+		}
+		return nil
+	})
+	return report, err
+}
+
+// csvKey builds a new key from the fields of one row.
+func csvKey(tx *Tx, field func(string) string) (APIKey, error) {
+	key := APIKey{Key: field("key"), Label: field("label"), Enabled: true}
+	if key.Key == "" {
+		generated, err := NewAPIKey()
+		if err != nil {
+			return APIKey{}, err
+		}
+		key.Key = generated
+	}
+	if user := field("user"); user != "" {
+		found := false
+		for _, u := range tx.data.Users {
+			if u.ID == user || strings.EqualFold(u.Username, user) {
+				key.UserID, found = u.ID, true
+				break
+			}
+		}
+		if !found {
+			return APIKey{}, fmt.Errorf("%w: %s", ErrUserNotFound, user)
+		}
+	}
+	if raw := field("total_limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit < 0 {
+			return APIKey{}, fmt.Errorf("invalid total_limit %q", raw)
+		}
+		key.TotalLimit = limit
+	}
+	if raw := field("concurrency_limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return APIKey{}, fmt.Errorf("invalid concurrency_limit %q", raw)
+		}
+		key.ConcurrencyLimit = limit
+	}
+	if raw := field("enabled"); raw != "" {
+		enabled, err := strconv.ParseBool(raw)
+		if err != nil {
+			return APIKey{}, fmt.Errorf("invalid enabled %q", raw)
+		}
+		key.Enabled = enabled
+	}
+	return key, nil
+}
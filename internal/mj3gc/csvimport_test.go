@@ -0,0 +1,53 @@
+package mj3gc
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestImportKeysCSV(t *testing.T) {
+	s := NewStore()
+	s.SetPath(filepath.Join(t.TempDir(), "mj3gc.json"))
+	s.ApplyConfig(&config.Config{})
+	s.data.Users = []User{{ID: "u1", Username: "alice"}}
+	s.data.APIKeys = []APIKey{{ID: "existing", Key: "taken"}}
+
+	input := "key,label,user,total_limit,concurrency_limit\n" +
+		"sk-one,first,alice,100,2\n" +
+		",generated,u1,,\n" +
+		"taken,duplicate,,,\n" +
+		"sk-two,bad limit,,-5,\n" +
+		"sk-three,no user,bob,,\n"
+	report, err := s.ImportKeysCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ImportKeysCSV: %v", err)
+	}
+	if report.Created != 2 || report.Failed != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	if report.Rows[0].Line != 2 || report.Rows[2].Error == "" {
+		t.Fatalf("rows = %+v", report.Rows)
+	}
+	key, ok := s.FindAPIKey("sk-one")
+	if !ok || key.UserID != "u1" || key.TotalLimit != 100 || key.ConcurrencyLimit != 2 || !key.Enabled {
+		t.Fatalf("sk-one = %+v, %v", key, ok)
+	}
+	if report.Rows[1].Key == "" {
+		t.Fatal("generated key not reported")
+	}
+	if _, ok := s.FindAPIKey("sk-two"); ok {
+		t.Fatal("row with invalid limit was created")
+	}
+}
+
+func TestImportKeysCSVRejectsUnknownColumn(t *testing.T) {
+	s := NewStore()
+	_, err := s.ImportKeysCSV(strings.NewReader("key,secret\nsk,x\n"))
+	if !errors.Is(err, ErrInvalidCSV) {
+		t.Fatalf("err = %v, want ErrInvalidCSV", err)
+	}
+}
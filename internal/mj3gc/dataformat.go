@@ -0,0 +1,139 @@
+package mj3gc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// dataFormat is the encoding of a data file, chosen by its extension: .yaml and .yml
+// files hold YAML, .toml files TOML and everything else JSON. All formats use the
+// JSON field names of Data.
+type dataFormat string
+
+const (
+	formatJSON dataFormat = "json"
+	formatYAML dataFormat = "yaml"
+	formatTOML dataFormat = "toml"
+)
+
+func dataFormatFor(path string) dataFormat {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	default:
+		return formatJSON
+	}
+}
+
+// encodeData renders data in format. YAML and TOML go through the JSON encoding so
+// field names and time formats stay the same in every format.
+func encodeData(format dataFormat, data Data) ([]byte, error) {
+	raw, err := json.MarshalIndent(data, "", "  ")
+	if err != nil || format == formatJSON {
+		return raw, err
+	}
+	doc, err := genericDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case formatYAML:
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(doc); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case formatTOML:
+		// TOML has no null; absent values decode to the same zero values.
+		return toml.Marshal(dropNulls(doc))
+	}
+	return nil, fmt.Errorf("mj3gc: unknown data format %q", format)
+}
+
+// decodeData parses raw in format into data.
+func decodeData(format dataFormat, raw []byte, data *Data) error {
+	var doc any
+	switch format {
+	case formatJSON:
+		return json.Unmarshal(raw, data)
+	case formatYAML:
+		if err := yaml.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+	case formatTOML:
+		if err := toml.Unmarshal(raw, &doc); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("mj3gc: unknown data format %q", format)
+	}
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, data)
+}
+
+// genericDocument decodes JSON into maps and slices, keeping integers as int64 so
+// large counters survive the round trip.
+func genericDocument(raw []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return convertNumbers(doc), nil
+}
+
+func convertNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = convertNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = convertNumbers(item)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return value
+}
+
+func dropNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+				continue
+			}
+			v[key] = dropNulls(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = dropNulls(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,63 @@
+package mj3gc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDataFileFormats(t *testing.T) {
+	for _, name := range []string{"mj3gc.yaml", "mj3gc.yml", "mj3gc.toml", "mj3gc.json"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			s := NewStore()
+			s.SetPath(path)
+			if err := s.Load(); err != nil {
+				t.Fatal(err)
+			}
+			user, err := s.UpsertUser(User{Username: "ops", PasswordHash: "x"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := s.UpsertAPIKey(APIKey{
+				Key:           "sk-format-secret",
+				UserID:        user.ID,
+				Enabled:       true,
+				TotalLimit:    1 << 60,
+				BudgetUSD:     12.5,
+				AllowedModels: []string{"gpt-*"},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Save(); err != nil {
+				t.Fatal(err)
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.HasPrefix(strings.TrimSpace(string(raw)), "{") != (filepath.Ext(name) == ".json") {
+				t.Fatalf("%s written as:\n%s", name, raw)
+			}
+
+			reloaded := NewStore()
+			reloaded.SetPath(path)
+			if err := reloaded.Load(); err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			got, ok := reloaded.FindAPIKeyByID(key.ID)
+			if !ok {
+				t.Fatal("key lost")
+			}
+			if got.TotalLimit != 1<<60 || got.BudgetUSD != 12.5 || got.UserID != user.ID ||
+				len(got.AllowedModels) != 1 || !got.CreatedAt.Equal(key.CreatedAt) {
+				t.Fatalf("reloaded key = %+v, want %+v", got, key)
+			}
+			if _, ok := reloaded.FindAPIKey("sk-format-secret"); !ok {
+				t.Fatal("secret does not authenticate after reload")
+			}
+		})
+	}
+}
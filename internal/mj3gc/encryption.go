@@ -0,0 +1,93 @@
+package mj3gc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// encryptedHeader prefixes data files sealed with AES-256-GCM. The nonce follows the
+// header and the ciphertext follows the nonce.
+var encryptedHeader = []byte("MJ3GC-AES256GCM-1\n")
+
+var errEncryptedNoKey = errors.New("mj3gc: data file is encrypted but no encryption key is configured")
+
+// LoadEncryptionKey resolves the data encryption key from MJ3GC_ENCRYPTION_KEY or
+// mj3gc.encryption-key-file. Keys are 32 bytes, base64 or hex encoded. A nil key
+// means encryption is disabled.
+func LoadEncryptionKey(settings config.MJ3GCConfig) ([]byte, error) {
+	raw := strings.TrimSpace(os.Getenv("MJ3GC_ENCRYPTION_KEY"))
+	if raw == "" {
+		path := strings.TrimSpace(settings.EncryptionKeyFile)
+		if path == "" {
+			return nil, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("mj3gc: read encryption key file: %w", err)
+		}
+		raw = strings.TrimSpace(string(content))
+	}
+	if key, err := base64.StdEncoding.DecodeString(raw); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := hex.DecodeString(raw); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, errors.New("mj3gc: encryption key must be 32 bytes, base64 or hex encoded")
+}
+
+func sealData(key, plaintext []byte) ([]byte, error) {
+	aead, err := newDataAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(encryptedHeader)+len(nonce)+len(plaintext)+aead.Overhead())
+	out = append(out, encryptedHeader...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plaintext, encryptedHeader), nil
+}
+
+func openData(key, payload []byte) ([]byte, error) {
+	if key == nil {
+		return nil, errEncryptedNoKey
+	}
+	aead, err := newDataAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	body := payload[len(encryptedHeader):]
+	if len(body) < aead.NonceSize() {
+		return nil, errors.New("mj3gc: encrypted data file is truncated")
+	}
+	plaintext, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], encryptedHeader)
+	if err != nil {
+		return nil, fmt.Errorf("mj3gc: decrypt data file: %w", err)
+	}
+	return plaintext, nil
+}
+
+func isEncrypted(payload []byte) bool {
+	return bytes.HasPrefix(payload, encryptedHeader)
+}
+
+func newDataAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,58 @@
+package mj3gc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestEncryptedDataFileRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	if err := os.WriteFile(keyFile, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "mj3gc.json")
+	cfg := &config.Config{MJ3GC: config.MJ3GCConfig{EncryptionKeyFile: keyFile}}
+
+	s := NewStore()
+	s.SetPath(path)
+	s.ApplyConfig(cfg)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := s.UpsertAPIKey(APIKey{Key: "sk-secret", Enabled: true}); err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isEncrypted(raw) || bytes.Contains(raw, []byte("sk-secret")) {
+		t.Fatal("data file is not encrypted")
+	}
+
+	reloaded := NewStore()
+	reloaded.SetPath(path)
+	reloaded.ApplyConfig(cfg)
+	if err := reloaded.Load(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if _, ok := reloaded.FindAPIKey("sk-secret"); !ok {
+		t.Fatal("key missing after decrypting")
+	}
+
+	plain := NewStore()
+	plain.SetPath(path)
+	if err := plain.Load(); err == nil {
+		t.Fatal("loading an encrypted file without a key should fail")
+	}
+}
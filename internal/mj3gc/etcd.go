@@ -0,0 +1,210 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// OpenEtcdBackend stores users and keys in etcd through its v3 JSON gateway at
+// settings.KVEndpoint (for example http://127.0.0.1:2379).
+func OpenEtcdBackend(settings config.MJ3GCConfig) (Backend, error) {
+	endpoint := strings.TrimRight(strings.TrimSpace(settings.KVEndpoint), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("mj3gc: kv-endpoint is required for etcd storage")
+	}
+	kv := &etcdKV{
+		endpoint: endpoint,
+		username: settings.KVUsername,
+		password: settings.KVPassword,
+		client:   &http.Client{},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kvTimeout)
+	defer cancel()
+	if _, _, err := kv.List(ctx, defaultKVPrefix); err != nil {
+		return nil, fmt.Errorf("mj3gc: connect to etcd: %w", err)
+	}
+	return newKVBackend(kv, settings.KVPrefix), nil
+}
+
+// etcdKV is a minimal client for the etcd v3 JSON gateway. Byte fields are base64 in
+// the gateway mapping, which encoding/json does for []byte.
+type etcdKV struct {
+	endpoint string
+	username string
+	password string
+	client   *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+type etcdHeader struct {
+	Revision uint64 `json:"revision,string"`
+}
+
+type etcdKeyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// etcdRangeEnd returns the smallest key greater than every key starting with prefix.
+func etcdRangeEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return []byte{0}
+}
+
+const etcdAuthPath = "/v3/auth/authenticate"
+
+func (e *etcdKV) authToken(ctx context.Context) (string, error) {
+	if e.username == "" {
+		return "", nil
+	}
+	e.mu.Lock()
+	token := e.token
+	e.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	body := map[string]string{"name": e.username, "password": e.password}
+	if err := e.call(ctx, etcdAuthPath, body, &resp); err != nil {
+		return "", fmt.Errorf("authenticate: %w", err)
+	}
+	e.mu.Lock()
+	e.token = resp.Token
+	e.mu.Unlock()
+	return resp.Token, nil
+}
+
+// open posts body to path and returns the response for a successful status.
+func (e *etcdKV) open(ctx context.Context, path string, body any) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if path != etcdAuthPath {
+		token, err := e.authToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", token)
+		}
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < http.StatusBadRequest {
+		return resp, nil
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode == http.StatusUnauthorized {
+		// Tokens expire; authenticate again on the next call.
+		e.mu.Lock()
+		e.token = ""
+		e.mu.Unlock()
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("etcd %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+func (e *etcdKV) call(ctx context.Context, path string, body, out any) error {
+	resp, err := e.open(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (e *etcdKV) List(ctx context.Context, prefix string) (map[string][]byte, uint64, error) {
+	var resp struct {
+		Header etcdHeader     `json:"header"`
+		KVs    []etcdKeyValue `json:"kvs"`
+	}
+	body := map[string]any{"key": []byte(prefix), "range_end": etcdRangeEnd(prefix)}
+	if err := e.call(ctx, "/v3/kv/range", body, &resp); err != nil {
+		return nil, 0, err
+	}
+	entries := make(map[string][]byte, len(resp.KVs))
+	for _, kv := range resp.KVs {
+		entries[string(kv.Key)] = kv.Value
+	}
+	return entries, resp.Header.Revision, nil
+}
+
+func (e *etcdKV) Put(ctx context.Context, key string, value []byte) error {
+	return e.call(ctx, "/v3/kv/put", map[string]any{"key": []byte(key), "value": value}, nil)
+}
+
+func (e *etcdKV) Delete(ctx context.Context, key string) error {
+	return e.call(ctx, "/v3/kv/deleterange", map[string]any{"key": []byte(key)}, nil)
+}
+
+// Wait opens a watch stream starting after revision and returns once it delivers events.
+func (e *etcdKV) Wait(ctx context.Context, prefix string, revision uint64) (uint64, error) {
+	body := map[string]any{"create_request": map[string]any{
+		"key":            []byte(prefix),
+		"range_end":      etcdRangeEnd(prefix),
+		"start_revision": fmt.Sprint(revision + 1),
+	}}
+	resp, err := e.open(ctx, "/v3/watch", body)
+	if err != nil {
+		return revision, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Result struct {
+				Header          etcdHeader        `json:"header"`
+				Events          []json.RawMessage `json:"events"`
+				Canceled        bool              `json:"canceled"`
+				CompactRevision uint64            `json:"compact_revision,string"`
+			} `json:"result"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			return revision, err
+		}
+		switch {
+		case msg.Error != nil:
+			return revision, fmt.Errorf("etcd watch: %s", msg.Error.Message)
+		case msg.Result.Canceled && msg.Result.CompactRevision > 0:
+			// The revision was compacted away; report a change so the caller reloads.
+			return msg.Result.CompactRevision, nil
+		case msg.Result.Canceled:
+			return revision, fmt.Errorf("etcd watch cancelled")
+		case len(msg.Result.Events) > 0:
+			return msg.Result.Header.Revision, nil
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package mj3gc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// FeaturesMetadataKey is the access metadata entry carrying the comma-separated
+// feature flags of the authenticated key.
+const FeaturesMetadataKey = "features"
+
+const maxFeatureNameLength = 64
+
+// NormalizeFeatures lower-cases, de-duplicates and sorts feature flag names. Names may
+// contain letters, digits, '.', '_' and '-'.
+func NormalizeFeatures(features []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(features))
+	out := make([]string, 0, len(features))
+	for _, name := range features {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if len(name) > maxFeatureNameLength {
+			return nil, fmt.Errorf("feature %q is longer than %d characters", name, maxFeatureNameLength)
+		}
+		for _, r := range name {
+			if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '.' && r != '_' && r != '-' {
+				return nil, fmt.Errorf("feature %q contains invalid character %q", name, r)
+			}
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		out = append(out, name)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// HasFeature reports whether the key has the feature flag enabled.
+func (k APIKey) HasFeature(name string) bool {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, feature := range k.Features {
+		if feature == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FeatureEnabled reports whether the key that authenticated c has the feature flag
+// enabled. Middleware and handlers running after authentication use it to gate
+// experimental endpoints, models and response formats per customer.
+func FeatureEnabled(c *gin.Context, name string) bool {
+	if c == nil {
+		return false
+	}
+	raw, ok := c.Get("accessMetadata")
+	if !ok {
+		return false
+	}
+	metadata, _ := raw.(map[string]string)
+	name = strings.ToLower(strings.TrimSpace(name))
+	for _, feature := range strings.Split(metadata[FeaturesMetadataKey], ",") {
+		if feature != "" && feature == name {
+			return true
+		}
+	}
+	return false
+}
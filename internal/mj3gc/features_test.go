@@ -0,0 +1,40 @@
+package mj3gc
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeFeatures(t *testing.T) {
+	got, err := NormalizeFeatures([]string{" Beta-Models ", "experimental.endpoints", "beta-models", ""})
+	if err != nil {
+		t.Fatalf("NormalizeFeatures: %v", err)
+	}
+	want := []string{"beta-models", "experimental.endpoints"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("features = %v, want %v", got, want)
+	}
+	if _, err := NormalizeFeatures([]string{"new format"}); err == nil {
+		t.Fatal("expected error for feature with a space")
+	}
+}
+
+func TestFeatureEnabled(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	if FeatureEnabled(c, "beta-models") {
+		t.Fatal("feature enabled without access metadata")
+	}
+	c.Set("accessMetadata", map[string]string{FeaturesMetadataKey: "beta-models,new-format"})
+	if !FeatureEnabled(c, "Beta-Models") {
+		t.Fatal("beta-models not enabled")
+	}
+	if FeatureEnabled(c, "beta") {
+		t.Fatal("prefix of a feature matched")
+	}
+	if !(APIKey{Features: []string{"new-format"}}).HasFeature("new-format") {
+		t.Fatal("HasFeature(new-format) = false")
+	}
+}
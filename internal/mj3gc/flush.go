@@ -0,0 +1,202 @@
+package mj3gc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultFlushInterval   = 2 * time.Second
+	defaultFlushMaxPending = 100
+)
+
+// flusher batches usage persistence. While it runs, SaveUsage only marks keys dirty and
+// the dataset is written at most once per interval, or earlier once maxPending changes
+// have accumulated.
+type flusher struct {
+	mu         sync.Mutex
+	running    bool
+	maxPending int
+	interval   time.Duration
+	pending    int
+	oldest     time.Time
+	keys       map[string]struct{}
+	wake       chan struct{}
+}
+
+// StartFlusher persists usage counters in the background until ctx is cancelled, then
+// flushes once more. Without a running flusher SaveUsage persists synchronously.
+func (s *Store) StartFlusher(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	interval, maxPending := s.flushSettings()
+
+	s.flush.mu.Lock()
+	if s.flush.running {
+		s.flush.mu.Unlock()
+		return
+	}
+	s.flush.running = true
+	s.flush.maxPending = maxPending
+	s.flush.interval = interval
+	s.flush.wake = make(chan struct{}, 1)
+	wake := s.flush.wake
+	s.flush.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.flush.mu.Lock()
+				s.flush.running = false
+				s.flush.mu.Unlock()
+				if err := s.Flush(); err != nil {
+					log.Warnf("mj3gc: final usage flush failed: %v", err)
+				}
+				return
+			case <-ticker.C:
+			case <-wake:
+			}
+			if err := s.Flush(); err != nil {
+				log.Warnf("mj3gc: usage flush failed: %v", err)
+			}
+			s.flush.mu.Lock()
+			next := s.flush.interval
+			s.flush.mu.Unlock()
+			if next != interval {
+				interval = next
+				ticker.Reset(interval)
+			}
+		}
+	}()
+}
+
+// RetuneFlusher applies reloaded flush settings to a running flusher. A changed
+// interval takes effect after a flush that is triggered right away.
+func (s *Store) RetuneFlusher() {
+	if s == nil {
+		return
+	}
+	interval, maxPending := s.flushSettings()
+	s.flush.mu.Lock()
+	defer s.flush.mu.Unlock()
+	if !s.flush.running {
+		return
+	}
+	s.flush.maxPending = maxPending
+	if interval != s.flush.interval {
+		s.flush.interval = interval
+		select {
+		case s.flush.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Store) flushSettings() (time.Duration, int) {
+	settings := s.Settings()
+	interval := time.Duration(settings.FlushIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultFlushInterval
+	}
+	maxPending := settings.FlushMaxPending
+	if maxPending <= 0 {
+		maxPending = defaultFlushMaxPending
+	}
+	return interval, maxPending
+}
+
+// deferUsage marks keyID dirty when the background flusher is running and reports
+// whether the caller can skip persisting synchronously.
+func (s *Store) deferUsage(keyID string) bool {
+	s.flush.mu.Lock()
+	defer s.flush.mu.Unlock()
+	if !s.flush.running {
+		return false
+	}
+	if s.flush.keys == nil {
+		s.flush.keys = make(map[string]struct{})
+	}
+	s.flush.keys[keyID] = struct{}{}
+	if s.flush.oldest.IsZero() {
+		s.flush.oldest = time.Now()
+	}
+	s.flush.pending++
+	if s.flush.pending >= s.flush.maxPending {
+		select {
+		case s.flush.wake <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// Flush writes usage changes deferred by SaveUsage. Keys are marked dirty again when
+// persisting fails so the next flush retries them.
+func (s *Store) Flush() error {
+	if s == nil {
+		return nil
+	}
+	s.flush.mu.Lock()
+	keys, oldest := s.flush.keys, s.flush.oldest
+	s.flush.keys = nil
+	s.flush.pending = 0
+	s.flush.oldest = time.Time{}
+	s.flush.mu.Unlock()
+	if len(keys) == 0 {
+		return nil
+	}
+
+	err := s.persistUsage(keys)
+	if err != nil {
+		s.flush.mu.Lock()
+		if s.flush.keys == nil {
+			s.flush.keys = make(map[string]struct{}, len(keys))
+		}
+		for id := range keys {
+			s.flush.keys[id] = struct{}{}
+		}
+		s.flush.pending += len(keys)
+		if s.flush.oldest.IsZero() || oldest.Before(s.flush.oldest) {
+			s.flush.oldest = oldest
+		}
+		s.flush.mu.Unlock()
+	}
+	s.notePersist(err)
+	return err
+}
+
+// pendingUsage returns how many deferred usage changes await the flusher and when the
+// oldest of them was made.
+func (s *Store) pendingUsage() (int, time.Time) {
+	s.flush.mu.Lock()
+	defer s.flush.mu.Unlock()
+	return len(s.flush.keys), s.flush.oldest
+}
+
+func (s *Store) persistUsage(keys map[string]struct{}) error {
+	backend := s.currentBackend()
+	if backend == nil {
+		return ErrInvalidConfiguration
+	}
+	saver, ok := backend.(UsageSaver)
+	if !ok {
+		return s.Save()
+	}
+	for id := range keys {
+		key, found := s.FindAPIKeyByID(id)
+		if !found {
+			continue
+		}
+		if err := saver.SaveUsage(key.ID, key.UsedCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package mj3gc
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestSaveUsageDeferredUntilFlush(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	s := NewStore()
+	s.SetPath(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-test", Enabled: true})
+	if err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartFlusher(ctx)
+
+	s.setUsedCount(key.ID, 5)
+	if err := s.SaveUsage(key.ID, 1); err != nil {
+		t.Fatalf("SaveUsage: %v", err)
+	}
+	stored, err := (&fileBackend{path: path}).Load()
+	if err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+	if got := stored.APIKeys[0].UsedCount; got != 0 {
+		t.Fatalf("usage persisted before flush: used_count = %d", got)
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	stored, err = (&fileBackend{path: path}).Load()
+	if err != nil {
+		t.Fatalf("load file: %v", err)
+	}
+	if got := stored.APIKeys[0].UsedCount; got != 5 {
+		t.Fatalf("used_count after flush = %d, want 5", got)
+	}
+}
+
+func TestRetuneFlusherAppliesReloadedInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	s := NewStore()
+	s.SetPath(path)
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{FlushIntervalSeconds: 3600}})
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-test", Enabled: true})
+	if err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartFlusher(ctx)
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{FlushIntervalSeconds: 1}})
+	s.RetuneFlusher()
+
+	// The first change may be written by the flush the retune triggers; the second one
+	// only by the ticker running at the new interval.
+	for _, used := range []int64{5, 7} {
+		s.setUsedCount(key.ID, used)
+		if err := s.SaveUsage(key.ID, 1); err != nil {
+			t.Fatalf("SaveUsage: %v", err)
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			stored, err := (&fileBackend{path: path}).Load()
+			if err == nil && len(stored.APIKeys) == 1 && stored.APIKeys[0].UsedCount == used {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("used_count %d was not flushed at the reloaded interval", used)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultHeartbeatInterval = time.Minute
+	// heartbeatMaxLag is how long usage changes may wait for persistence before the
+	// store reports itself degraded.
+	heartbeatMaxLag = time.Minute
+	// HeartbeatSignatureHeader carries "t=<unix seconds>,v1=<hex HMAC-SHA256>" where the
+	// MAC covers "<unix seconds>.<body>" under mj3gc.heartbeat-secret.
+	HeartbeatSignatureHeader = "X-MJ3GC-Signature"
+)
+
+// Heartbeat is the health report posted to mj3gc.heartbeat-url.
+type Heartbeat struct {
+	Timestamp time.Time        `json:"timestamp"`
+	Status    string           `json:"status"`
+	Problems  []string         `json:"problems,omitempty"`
+	Store     StoreHealth      `json:"store"`
+	Requests  map[string]int64 `json:"requests"`
+}
+
+// StoreHealth describes the persistence state of the store.
+type StoreHealth struct {
+	Storage               string    `json:"storage"`
+	ReadOnly              bool      `json:"read_only"`
+	SignatureMismatch     bool      `json:"signature_mismatch"`
+	Users                 int       `json:"users"`
+	Keys                  int       `json:"keys"`
+	LastSaveAt            time.Time `json:"last_save_at,omitempty"`
+	LastSaveError         string    `json:"last_save_error,omitempty"`
+	LastSaveErrorAt       time.Time `json:"last_save_error_at,omitempty"`
+	SaveFailures          int64     `json:"save_failures"`
+	PendingUsage          int       `json:"pending_usage"`
+	PersistenceLagSeconds float64   `json:"persistence_lag_seconds"`
+}
+
+// persistHealth tracks the outcome of saves and usage flushes.
+type persistHealth struct {
+	mu          sync.Mutex
+	lastSave    time.Time
+	lastErr     string
+	lastErrAt   time.Time
+	failures    int64
+	lastSuccess bool
+}
+
+func (s *Store) notePersist(err error) {
+	h := &s.persisted
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now()
+	if err != nil {
+		h.lastErr, h.lastErrAt = err.Error(), now
+		h.failures++
+		h.lastSuccess = false
+		return
+	}
+	h.lastSave = now
+	h.lastSuccess = true
+}
+
+// Health reports the current state of the store together with request outcome totals
+// since startup. Status is "degraded" when the last save failed, usage changes are not
+// persisted in time or the data file failed signature verification.
+func (s *Store) Health() Heartbeat {
+	out := Heartbeat{Timestamp: time.Now().UTC(), Status: "ok", Requests: map[string]int64{}}
+	if s == nil {
+		return out
+	}
+	unlock := s.rlock("Health")
+	out.Store.Storage = strings.ToLower(strings.TrimSpace(s.settings.Storage))
+	out.Store.SignatureMismatch = s.tampered
+	out.Store.Users, out.Store.Keys = len(s.data.Users), len(s.data.APIKeys)
+	unlock()
+	if out.Store.Storage == "" {
+		out.Store.Storage = "file"
+	}
+	out.Store.ReadOnly = s.ReadOnly()
+
+	s.persisted.mu.Lock()
+	out.Store.LastSaveAt = s.persisted.lastSave
+	out.Store.LastSaveError, out.Store.LastSaveErrorAt = s.persisted.lastErr, s.persisted.lastErrAt
+	out.Store.SaveFailures = s.persisted.failures
+	saveFailing := s.persisted.failures > 0 && !s.persisted.lastSuccess
+	s.persisted.mu.Unlock()
+
+	pending, oldest := s.pendingUsage()
+	out.Store.PendingUsage = pending
+	if !oldest.IsZero() {
+		out.Store.PersistenceLagSeconds = time.Since(oldest).Seconds()
+	}
+
+	metrics, _ := s.KeyMetrics()
+	for _, m := range metrics {
+		for outcome, n := range m.Requests {
+			out.Requests[outcome] += n
+		}
+	}
+
+	if saveFailing {
+		out.Problems = append(out.Problems, "last save failed: "+out.Store.LastSaveError)
+	}
+	if out.Store.PersistenceLagSeconds > heartbeatMaxLag.Seconds() {
+		out.Problems = append(out.Problems, fmt.Sprintf("usage changes unpersisted for %.0fs", out.Store.PersistenceLagSeconds))
+	}
+	if out.Store.SignatureMismatch {
+		out.Problems = append(out.Problems, "data file signature mismatch")
+	}
+	if len(out.Problems) > 0 {
+		out.Status = "degraded"
+	}
+	return out
+}
+
+// StartHeartbeat posts Health to mj3gc.heartbeat-url at mj3gc.heartbeat-interval-seconds
+// until ctx is cancelled, so external monitors notice a degraded store and not only a
+// dead process. It does nothing when no URL is configured.
+func (s *Store) StartHeartbeat(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	settings := s.Settings()
+	endpoint := strings.TrimSpace(settings.HeartbeatURL)
+	if endpoint == "" {
+		return
+	}
+	interval := time.Duration(settings.HeartbeatIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultHeartbeatInterval
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := s.sendHeartbeat(ctx, client, endpoint, []byte(settings.HeartbeatSecret)); err != nil {
+				log.Warnf("mj3gc: heartbeat failed: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Store) sendHeartbeat(ctx context.Context, client *http.Client, endpoint string, secret []byte) error {
+	payload, err := json.Marshal(s.Health())
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(secret) > 0 {
+		req.Header.Set(HeartbeatSignatureHeader, SignHeartbeat(secret, time.Now(), payload))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("monitor returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// SignHeartbeat returns the HeartbeatSignatureHeader value for body sent at t.
+// Receivers recompute it and reject stale timestamps to prevent replays.
+func SignHeartbeat(secret []byte, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write([]byte(ts + "."))
+	_, _ = mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
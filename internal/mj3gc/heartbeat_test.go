@@ -0,0 +1,82 @@
+package mj3gc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestHeartbeatReportsFailingSaves(t *testing.T) {
+	dir := t.TempDir()
+	s := NewStore()
+	s.SetPath(filepath.Join(dir, "mj3gc.json"))
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Health(); got.Status != "ok" || got.Store.LastSaveAt.IsZero() {
+		t.Fatalf("healthy store reported %+v", got)
+	}
+
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s.SetPath(filepath.Join(blocker, "mj3gc.json"))
+	if err := s.Save(); err == nil {
+		t.Fatal("Save into a file path succeeded")
+	}
+	got := s.Health()
+	if got.Status != "degraded" || got.Store.SaveFailures != 1 || got.Store.LastSaveError == "" {
+		t.Fatalf("failing store reported %+v", got)
+	}
+
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer server.Close()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{HeartbeatURL: server.URL, HeartbeatSecret: "hb-secret"}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.StartHeartbeat(ctx)
+
+	var req *http.Request
+	select {
+	case req = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no heartbeat received")
+	}
+	body := <-bodies
+	signature := req.Header.Get(HeartbeatSignatureHeader)
+	ts, _, _ := strings.Cut(strings.TrimPrefix(signature, "t="), ",")
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		t.Fatalf("signature %q: %v", signature, err)
+	}
+	if want := SignHeartbeat([]byte("hb-secret"), time.Unix(unix, 0), body); signature != want {
+		t.Fatalf("signature = %q, want %q", signature, want)
+	}
+	var beat Heartbeat
+	if err := json.Unmarshal(body, &beat); err != nil {
+		t.Fatal(err)
+	}
+	if beat.Status != "degraded" || len(beat.Problems) == 0 {
+		t.Fatalf("heartbeat = %+v", beat)
+	}
+}
@@ -0,0 +1,74 @@
+package mj3gc
+
+import (
+	"fmt"
+	"time"
+)
+
+// DisabledReasonIdle marks keys disabled by DisableIdleKeys.
+const DisabledReasonIdle = "idle"
+
+// DisableIdleKeys disables enabled keys that have not been used for idleFor, judging
+// keys that were never used by their creation time and re-enabled keys by when that
+// happened. The keys are stamped with DisabledReasonIdle and an audit entry is written
+// for each; callers persist the store when any key was disabled.
+func (s *Store) DisableIdleKeys(idleFor time.Duration) []APIKey {
+	if s == nil || idleFor <= 0 {
+		return nil
+	}
+	now := time.Now()
+	cutoff := now.Add(-idleFor)
+	var disabled []APIKey
+	unlock := s.lock("DisableIdleKeys")
+	for i := range s.data.APIKeys {
+		k := &s.data.APIKeys[i]
+		if !k.Enabled || !keyIdleSince(*k).Before(cutoff) {
+			continue
+		}
+		k.Enabled = false
+		k.DisabledAt = now
+		k.DisabledReason = DisabledReasonIdle
+		disabled = append(disabled, *k)
+	}
+	unlock()
+	for _, k := range disabled {
+		s.RecordAudit(AuditEntry{
+			Actor:   "maintenance",
+			Action:  "key.disable",
+			Target:  k.ID,
+			Reason:  fmt.Sprintf("no usage for %s", idleFor),
+			Details: map[string]any{"disabled_reason": DisabledReasonIdle, "idle_since": keyIdleSince(k)},
+		})
+	}
+	return disabled
+}
+
+// ListIdleDisabledKeys returns the keys DisableIdleKeys disabled that are still disabled.
+func (s *Store) ListIdleDisabledKeys() []APIKey {
+	var out []APIKey
+	for _, k := range s.ListAPIKeys() {
+		if !k.Enabled && k.DisabledReason == DisabledReasonIdle {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// ReenableAPIKey enables a disabled key and restarts its idle period, so the next
+// sweep does not disable it again before it had a chance to be used.
+func (s *Store) ReenableAPIKey(id string) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidConfiguration
+	}
+	defer s.lock("ReenableAPIKey")()
+	i, ok := s.keyIDIndexLocked(id)
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	k := &s.data.APIKeys[i]
+	k.Enabled = true
+	k.DisabledAt = time.Time{}
+	k.DisabledReason = ""
+	k.ReenabledAt = time.Now()
+	return *k, nil
+}
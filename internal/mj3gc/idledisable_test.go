@@ -0,0 +1,43 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDisableIdleKeys(t *testing.T) {
+	s := NewStore()
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	idle, err := s.UpsertAPIKey(APIKey{Key: "sk-idle-key", Enabled: true, CreatedAt: old})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpsertAPIKey(APIKey{Key: "sk-busy-key", Enabled: true, CreatedAt: old, LastUsedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.UpsertAPIKey(APIKey{Key: "sk-new-key", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	disabled := s.DisableIdleKeys(30 * 24 * time.Hour)
+	if len(disabled) != 1 || disabled[0].ID != idle.ID || disabled[0].DisabledReason != DisabledReasonIdle {
+		t.Fatalf("disabled = %+v, want only the idle key", disabled)
+	}
+	if got := s.ListIdleDisabledKeys(); len(got) != 1 || got[0].ID != idle.ID {
+		t.Fatalf("ListIdleDisabledKeys = %+v", got)
+	}
+	if entries := s.AuditEntries(AuditFilter{Action: "key.disable", Target: idle.ID}); len(entries) != 1 {
+		t.Fatalf("audit entries = %+v", entries)
+	}
+
+	reenabled, err := s.ReenableAPIKey(idle.ID)
+	if err != nil || !reenabled.Enabled || reenabled.DisabledReason != "" {
+		t.Fatalf("ReenableAPIKey = %+v, %v", reenabled, err)
+	}
+	if again := s.DisableIdleKeys(30 * 24 * time.Hour); len(again) != 0 {
+		t.Fatalf("re-enabled key disabled again: %+v", again)
+	}
+	if got := s.ListIdleDisabledKeys(); len(got) != 0 {
+		t.Fatalf("ListIdleDisabledKeys after re-enabling = %+v", got)
+	}
+}
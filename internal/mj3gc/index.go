@@ -0,0 +1,148 @@
+package mj3gc
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// storeIndex maps key values, key IDs, user IDs and usernames to slice positions in
+// Data so lookups on the authentication path do not scan every key. It is rebuilt
+// lazily after any write that may reshape Users or APIKeys; see Store.lock.
+type storeIndex struct {
+	mu        sync.RWMutex
+	built     bool
+	keys      map[string]int
+	keyIDs    map[string]int
+	userIDs   map[string]int
+	usernames map[string]int
+}
+
+// invalidate forces the next lookup to rebuild the index.
+func (x *storeIndex) invalidate() {
+	x.mu.Lock()
+	x.built = false
+	x.mu.Unlock()
+}
+
+// rlockBuilt read-locks x.mu with the index built from data; callers release it with
+// x.mu.RUnlock. Only the first lookup after a write rebuilds under the write lock, so
+// lookups sharing the store read lock do not queue behind each other. The index cannot
+// be invalidated in between, since that takes the store write lock.
+func (x *storeIndex) rlockBuilt(data *Data) {
+	x.mu.RLock()
+	if x.built {
+		return
+	}
+	x.mu.RUnlock()
+	x.mu.Lock()
+	x.ensureLocked(data)
+	x.mu.Unlock()
+	x.mu.RLock()
+}
+
+// ensureLocked rebuilds the index from data if needed. Callers hold x.mu and at
+// least the store read lock, so data cannot change underneath. When records share
+// a value the first one wins, matching the order of a linear scan.
+func (x *storeIndex) ensureLocked(data *Data) {
+	if x.built {
+		return
+	}
+	x.keys = make(map[string]int, len(data.APIKeys))
+	x.keyIDs = make(map[string]int, len(data.APIKeys))
+	for i, k := range data.APIKeys {
+		if _, ok := x.keys[k.Key]; !ok {
+			x.keys[k.Key] = i
+		}
+		if _, ok := x.keyIDs[k.ID]; !ok {
+			x.keyIDs[k.ID] = i
+		}
+	}
+	// Secrets in a rotation grace period come second so a current secret always wins.
+	for i, k := range data.APIKeys {
+		if k.PreviousKey == "" {
+			continue
+		}
+		if _, ok := x.keys[k.PreviousKey]; !ok {
+			x.keys[k.PreviousKey] = i
+		}
+	}
+	x.userIDs = make(map[string]int, len(data.Users))
+	x.usernames = make(map[string]int, len(data.Users))
+	for i, u := range data.Users {
+		if _, ok := x.userIDs[u.ID]; !ok {
+			x.userIDs[u.ID] = i
+		}
+		name := strings.ToLower(u.Username)
+		if _, ok := x.usernames[name]; !ok {
+			x.usernames[name] = i
+		}
+	}
+	x.built = true
+}
+
+// keyIndexLocked returns the position of the key with the given secret value,
+// which may be a previous secret still in its rotation grace period. The caller
+// holds the store lock.
+func (s *Store) keyIndexLocked(value string) (int, bool) {
+	s.index.rlockBuilt(&s.data)
+	defer s.index.mu.RUnlock()
+	i, ok := s.index.keys[value]
+	if ok && s.data.APIKeys[i].Key != value && !time.Now().Before(s.data.APIKeys[i].PreviousKeyExpiresAt) {
+		return 0, false
+	}
+	return i, ok
+}
+
+// secretIndexLocked returns the position of the key authenticated by a secret a
+// client presented, matching plain secrets directly and hashed ones by digest. A
+// digest itself never matches, so a leaked data file does not yield credentials.
+func (s *Store) secretIndexLocked(value string) (int, bool) {
+	if IsHashedSecret(value) {
+		return 0, false
+	}
+	if i, ok := s.keyIndexLocked(value); ok {
+		return i, true
+	}
+	return s.keyIndexLocked(HashSecret(value))
+}
+
+// principalIndexLocked returns the position of the key named by an access provider
+// principal, the stored secret or digest. A plain secret that was hashed since the
+// request authenticated is still found by its digest.
+func (s *Store) principalIndexLocked(value string) (int, bool) {
+	if i, ok := s.keyIndexLocked(value); ok {
+		return i, true
+	}
+	if IsHashedSecret(value) {
+		return 0, false
+	}
+	return s.keyIndexLocked(HashSecret(value))
+}
+
+// keyIDIndexLocked returns the position of the key with the given ID. The caller
+// holds the store lock.
+func (s *Store) keyIDIndexLocked(id string) (int, bool) {
+	s.index.rlockBuilt(&s.data)
+	defer s.index.mu.RUnlock()
+	i, ok := s.index.keyIDs[id]
+	return i, ok
+}
+
+// userIDIndexLocked returns the position of the user with the given ID. The caller
+// holds the store lock.
+func (s *Store) userIDIndexLocked(id string) (int, bool) {
+	s.index.rlockBuilt(&s.data)
+	defer s.index.mu.RUnlock()
+	i, ok := s.index.userIDs[id]
+	return i, ok
+}
+
+// usernameIndexLocked returns the position of the user with the given username,
+// compared case-insensitively. The caller holds the store lock.
+func (s *Store) usernameIndexLocked(username string) (int, bool) {
+	s.index.rlockBuilt(&s.data)
+	defer s.index.mu.RUnlock()
+	i, ok := s.index.usernames[strings.ToLower(username)]
+	return i, ok
+}
@@ -0,0 +1,45 @@
+package mj3gc
+
+import "testing"
+
+func TestLookupIndexFollowsWrites(t *testing.T) {
+	s := NewStore()
+	user, err := s.UpsertUser(User{Username: "Alice"})
+	if err != nil {
+		t.Fatalf("UpsertUser: %v", err)
+	}
+	key, err := s.UpsertAPIKey(APIKey{Key: "old-secret", UserID: user.ID, Enabled: true})
+	if err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if _, ok := s.FindUserByUsername("alice"); !ok {
+		t.Fatal("username lookup is not case-insensitive")
+	}
+	if _, err := s.BeginRequest("old-secret"); err != nil {
+		t.Fatalf("BeginRequest: %v", err)
+	}
+	s.EndRequest("old-secret", true, "")
+
+	key, _ = s.FindAPIKeyByID(key.ID)
+	key.Key = "new-secret"
+	if _, err := s.UpsertAPIKey(key); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if _, ok := s.FindAPIKey("old-secret"); ok {
+		t.Fatal("rotated secret still found")
+	}
+	got, ok := s.FindAPIKey("new-secret")
+	if !ok || got.UsedCount != 1 {
+		t.Fatalf("FindAPIKey = %+v, %v", got, ok)
+	}
+
+	if err := s.DeleteAPIKey(key.ID); err != nil {
+		t.Fatalf("DeleteAPIKey: %v", err)
+	}
+	if _, ok := s.FindAPIKeyByID(key.ID); ok {
+		t.Fatal("deleted key still found by ID")
+	}
+	if _, err := s.BeginRequest("new-secret"); err != ErrKeyNotFound {
+		t.Fatalf("err = %v, want ErrKeyNotFound", err)
+	}
+}
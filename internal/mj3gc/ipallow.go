@@ -0,0 +1,124 @@
+package mj3gc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// NormalizeCIDRs validates an allowed_ips list. Entries are CIDRs or single addresses,
+// which become /32 or /128 prefixes; duplicates are dropped.
+func NormalizeCIDRs(entries []string) ([]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	seen := make(map[netip.Prefix]struct{}, len(entries))
+	out := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, err := parsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip range %q", entry)
+		}
+		if _, ok := seen[prefix]; ok {
+			continue
+		}
+		seen[prefix] = struct{}{}
+		out = append(out, prefix.String())
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+func parsePrefix(value string) (netip.Prefix, error) {
+	if !strings.Contains(value, "/") {
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	if prefix.Addr().Is4In6() {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	return prefix.Masked(), nil
+}
+
+// inPrefixes reports whether addr lies in one of the prefixes. Unparsable entries
+// never match.
+func inPrefixes(prefixes []string, addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, entry := range prefixes {
+		if prefix, err := parsePrefix(entry); err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsIP reports whether the key may be used from addr. Keys without allowed_ips
+// accept any address.
+func (k APIKey) AllowsIP(addr netip.Addr) bool {
+	return len(k.AllowedIPs) == 0 || inPrefixes(k.AllowedIPs, addr)
+}
+
+// ClientIP returns the address a request came from. X-Forwarded-For and X-Real-IP are
+// only honored when the connection comes from one of mj3gc.trusted-proxies; the
+// forwarded chain is then walked from the right, skipping further trusted proxies, so
+// clients cannot claim an address by sending the headers themselves.
+func (s *Store) ClientIP(r *http.Request) netip.Addr {
+	if r == nil {
+		return netip.Addr{}
+	}
+	remote := remoteAddr(r.RemoteAddr)
+	trusted := s.Settings().TrustedProxies
+	if len(trusted) == 0 || !inPrefixes(trusted, remote) {
+		return remote
+	}
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		client := remote
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			client = addr.Unmap()
+			if !inPrefixes(trusted, client) {
+				break
+			}
+		}
+		return client
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap()
+	}
+	return remote
+}
+
+func remoteAddr(value string) netip.Addr {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(value))
+	if err != nil {
+		host = strings.TrimSpace(value)
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
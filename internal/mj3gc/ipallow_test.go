@@ -0,0 +1,73 @@
+package mj3gc
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestNormalizeCIDRs(t *testing.T) {
+	got, err := NormalizeCIDRs([]string{" 10.1.2.3/8 ", "192.168.1.7", "10.0.0.0/8", "", "2001:db8::1/32"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"10.0.0.0/8", "192.168.1.7/32", "2001:db8::/32"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("NormalizeCIDRs = %v, want %v", got, want)
+	}
+	if _, err := NormalizeCIDRs([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("invalid prefix accepted")
+	}
+}
+
+func TestAllowsIP(t *testing.T) {
+	key := APIKey{AllowedIPs: []string{"10.0.0.0/8", "2001:db8::/32"}}
+	for addr, want := range map[string]bool{
+		"10.20.30.40":     true,
+		"::ffff:10.0.0.1": true,
+		"2001:db8::42":    true,
+		"192.168.0.1":     false,
+		"2001:db9::1":     false,
+	} {
+		if got := key.AllowsIP(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("AllowsIP(%s) = %v, want %v", addr, got, want)
+		}
+	}
+	if key.AllowsIP(netip.Addr{}) {
+		t.Error("unknown address allowed")
+	}
+	if !(APIKey{}).AllowsIP(netip.Addr{}) {
+		t.Error("key without allowed_ips rejected")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{TrustedProxies: []string{"172.16.0.0/12"}}})
+	cases := []struct {
+		name, remote, forwarded, realIP, want string
+	}{
+		{"direct", "203.0.113.5:4000", "", "", "203.0.113.5"},
+		{"spoofed header from untrusted peer", "203.0.113.5:4000", "10.0.0.1", "", "203.0.113.5"},
+		{"trusted proxy", "172.16.0.2:4000", "10.0.0.1", "", "10.0.0.1"},
+		{"client prepends a fake hop", "172.16.0.2:4000", "10.0.0.1, 198.51.100.9", "", "198.51.100.9"},
+		{"proxy chain", "172.16.0.2:4000", "198.51.100.9, 172.20.0.1", "", "198.51.100.9"},
+		{"real ip", "172.16.0.2:4000", "", "198.51.100.9", "198.51.100.9"},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/v1/models", nil)
+		r.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", tc.forwarded)
+		}
+		if tc.realIP != "" {
+			r.Header.Set("X-Real-IP", tc.realIP)
+		}
+		if got := s.ClientIP(r).String(); got != tc.want {
+			t.Errorf("%s: ClientIP = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package mj3gc
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// journalSyncInterval bounds how much counter data a machine crash can lose.
+	journalSyncInterval = time.Second
+	// journalCompactBytes triggers a compaction once the journal grows past this size.
+	journalCompactBytes = 8 << 20
+)
+
+// usageRecord is one journal line. Records carry absolute values so replay is idempotent
+// and the latest record of a key wins.
+type usageRecord struct {
+	KeyID      string    `json:"key_id"`
+	UsedCount  int64     `json:"used_count"`
+	UsedTokens int64     `json:"used_tokens,omitempty"`
+	SpentUSD   float64   `json:"spent_usd,omitempty"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string    `json:"last_used_ip,omitempty"`
+}
+
+// usageJournal appends usage changes to a JSONL file next to the data file so request
+// accounting never rewrites the whole dataset. A full Save compacts the journal.
+type usageJournal struct {
+	mu         sync.Mutex
+	file       *os.File
+	size       int64
+	lastSync   time.Time
+	compacting bool
+}
+
+// UsageJournalPath returns the usage journal stored next to the data file.
+func (s *Store) UsageJournalPath() string {
+	path := s.Path()
+	if path == "" {
+		return ""
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "-usage.jsonl"
+}
+
+// journalEnabled reports whether usage is journaled. Only the file backend uses the
+// journal; database backends already update counters in place.
+func (s *Store) journalEnabled() bool {
+	if !s.Settings().UsageJournal || s.UsageJournalPath() == "" {
+		return false
+	}
+	_, ok := s.currentBackend().(*fileBackend)
+	return ok
+}
+
+// appendUsage records the current usage of keyID in the journal.
+func (s *Store) appendUsage(keyID string) error {
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+	key, ok := s.FindAPIKeyByID(keyID)
+	if !ok {
+		return ErrKeyNotFound
+	}
+	if s.journal.file == nil {
+		path := s.UsageJournalPath()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		s.journal.file = f
+		s.journal.size = info.Size()
+	}
+	line, err := json.Marshal(usageRecord{KeyID: key.ID, UsedCount: key.UsedCount, UsedTokens: key.UsedTokens, SpentUSD: key.SpentUSD, LastUsedAt: key.LastUsedAt, LastUsedIP: key.LastUsedIP})
+	if err != nil {
+		return err
+	}
+	n, err := s.journal.file.Write(append(line, '\n'))
+	s.journal.size += int64(n)
+	if err != nil {
+		return err
+	}
+	if time.Since(s.journal.lastSync) >= journalSyncInterval {
+		s.journal.lastSync = time.Now()
+		if err := s.journal.file.Sync(); err != nil {
+			return err
+		}
+	}
+	if s.journal.size >= journalCompactBytes && !s.journal.compacting {
+		s.journal.compacting = true
+		go func() {
+			if err := s.Save(); err != nil {
+				log.Warnf("mj3gc: usage journal compaction failed: %v", err)
+				s.journal.mu.Lock()
+				s.journal.compacting = false
+				s.journal.mu.Unlock()
+			}
+		}()
+	}
+	return nil
+}
+
+// truncateJournalLocked discards journal records once a full Save has captured them.
+// The caller holds s.journal.mu.
+func (s *Store) truncateJournalLocked() error {
+	s.journal.compacting = false
+	if s.journal.file != nil {
+		_ = s.journal.file.Close()
+		s.journal.file = nil
+	}
+	s.journal.size = 0
+	err := os.Remove(s.UsageJournalPath())
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) journalSize() int64 {
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+	return s.journal.size
+}
+
+// replayUsageJournal applies journaled usage on top of the loaded data.
+func (s *Store) replayUsageJournal() {
+	path := s.UsageJournalPath()
+	if path == "" {
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = f.Close() }()
+	latest := make(map[string]usageRecord)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record usageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil || record.KeyID == "" {
+			// A torn final line after a crash is expected; skip it.
+			continue
+		}
+		latest[record.KeyID] = record
+	}
+	if len(latest) == 0 {
+		return
+	}
+	defer s.lock("replayUsageJournal")()
+	for i := range s.data.APIKeys {
+		record, ok := latest[s.data.APIKeys[i].ID]
+		if !ok {
+			continue
+		}
+		s.data.APIKeys[i].UsedCount = record.UsedCount
+		if record.UsedTokens > 0 {
+			s.data.APIKeys[i].UsedTokens = record.UsedTokens
+		}
+		if record.SpentUSD > 0 {
+			s.data.APIKeys[i].SpentUSD = record.SpentUSD
+		}
+		if record.LastUsedAt.After(s.data.APIKeys[i].LastUsedAt) {
+			s.data.APIKeys[i].LastUsedAt = record.LastUsedAt
+			if record.LastUsedIP != "" {
+				s.data.APIKeys[i].LastUsedIP = record.LastUsedIP
+			}
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package mj3gc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestUsageJournalReplayAndCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	newStore := func() *Store {
+		s := NewStore()
+		s.SetPath(path)
+		s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{UsageJournal: true}})
+		if err := s.Load(); err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		return s
+	}
+
+	s := newStore()
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-journal", Enabled: true})
+	if err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		s.EndRequest(key.Key, true, "203.0.113.7")
+		if err := s.SaveUsage(key.ID, 1); err != nil {
+			t.Fatalf("SaveUsage: %v", err)
+		}
+	}
+
+	reloaded := newStore()
+	got, _ := reloaded.FindAPIKeyByID(key.ID)
+	if got.UsedCount != 3 || got.LastUsedAt.IsZero() || got.LastUsedIP != "203.0.113.7" {
+		t.Fatalf("replayed key = used %d last_used %v from %q, want 3 and a timestamp from 203.0.113.7", got.UsedCount, got.LastUsedAt, got.LastUsedIP)
+	}
+
+	if err := reloaded.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(reloaded.UsageJournalPath()); !os.IsNotExist(err) {
+		t.Fatalf("journal not compacted: %v", err)
+	}
+	got, _ = newStore().FindAPIKeyByID(key.ID)
+	if got.UsedCount != 3 {
+		t.Fatalf("used_count after compaction = %d, want 3", got.UsedCount)
+	}
+}
@@ -0,0 +1,108 @@
+package mj3gc
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyInfo describes the presented key to its holder, so customers can check that an
+// integration uses the key they expect. Key is masked. Remaining fields are -1 when
+// the key has no such limit, and empty model lists allow every model.
+type KeyInfo struct {
+	ID               string            `json:"id"`
+	Key              string            `json:"key"`
+	Label            string            `json:"label"`
+	Enabled          bool              `json:"enabled"`
+	Scopes           []string          `json:"scopes,omitempty"`
+	TotalLimit       int64             `json:"total_limit,omitempty"`
+	UsedCount        int64             `json:"used_count"`
+	Remaining        int64             `json:"remaining"`
+	TokenLimit       int64             `json:"token_limit,omitempty"`
+	UsedTokens       int64             `json:"used_tokens,omitempty"`
+	RemainingTokens  int64             `json:"remaining_tokens"`
+	BudgetUSD        float64           `json:"budget_usd,omitempty"`
+	SpentUSD         float64           `json:"spent_usd,omitempty"`
+	ConcurrencyLimit int               `json:"concurrency_limit,omitempty"`
+	RPMLimit         int               `json:"rpm_limit,omitempty"`
+	MaxOutputTokens  int               `json:"max_output_tokens,omitempty"`
+	ResetInterval    string            `json:"reset_interval,omitempty"`
+	ResetsAt         string            `json:"resets_at,omitempty"`
+	ExpiresAt        string            `json:"expires_at,omitempty"`
+	SunsetAt         string            `json:"sunset_at,omitempty"`
+	AllowedModels    []string          `json:"allowed_models,omitempty"`
+	DeniedModels     []string          `json:"denied_models,omitempty"`
+	ModelAliases     map[string]string `json:"model_aliases,omitempty"`
+	// QuotaError is why new requests are refused once a quota or budget is used up.
+	QuotaError string `json:"quota_error,omitempty"`
+}
+
+// DescribeKey returns the KeyInfo of the key an access provider authenticated. Shared
+// counters, when configured, supply the request usage.
+func (s *Store) DescribeKey(principal string) (KeyInfo, error) {
+	if s == nil {
+		return KeyInfo{}, ErrInvalidConfiguration
+	}
+	key, ok := s.FindAPIKeyByPrincipal(principal)
+	if !ok {
+		return KeyInfo{}, ErrKeyNotFound
+	}
+	if counters := s.counterBackend(); counters != nil {
+		if used, err := counters.Usage(key.ID); err == nil {
+			key.UsedCount = used
+		}
+	}
+	info := KeyInfo{
+		ID:               key.ID,
+		Key:              SanitizeKey(key).Key,
+		Label:            key.Label,
+		Enabled:          key.Enabled,
+		Scopes:           key.Scopes,
+		TotalLimit:       key.TotalLimit,
+		UsedCount:        key.UsedCount,
+		Remaining:        -1,
+		TokenLimit:       key.TokenLimit,
+		UsedTokens:       key.UsedTokens,
+		RemainingTokens:  -1,
+		BudgetUSD:        key.BudgetUSD,
+		SpentUSD:         key.SpentUSD,
+		ConcurrencyLimit: key.ConcurrencyLimit,
+		RPMLimit:         key.RPMLimit,
+		MaxOutputTokens:  key.MaxOutputTokens,
+		ResetInterval:    key.ResetInterval,
+		ResetsAt:         FormatTimestamp(key.ResetsAt()),
+		ExpiresAt:        FormatTimestamp(key.ExpiresAt),
+		SunsetAt:         FormatTimestamp(key.SunsetAt),
+		AllowedModels:    key.AllowedModels,
+		DeniedModels:     key.DeniedModels,
+		ModelAliases:     key.ModelAliases,
+	}
+	if key.TotalLimit > 0 {
+		info.Remaining = max(key.TotalLimit-key.UsedCount, 0)
+	}
+	if key.TokenLimit > 0 {
+		info.RemainingTokens = max(key.TokenLimit-key.UsedTokens, 0)
+	}
+	if err := key.QuotaError(); err != nil {
+		info.QuotaError = err.Error()
+	}
+	return info, nil
+}
+
+// KeyInfoHandler serves GET /v1/key/info. It answers for the presented key only and
+// proxies nothing, so it does not count against the quota.
+func KeyInfoHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := c.GetString("apiKey")
+		if principal == "" || principal == AnonymousPrincipal {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "api key required"})
+			return
+		}
+		info, err := store.DescribeKey(principal)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, info)
+	}
+}
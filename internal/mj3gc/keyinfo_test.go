@@ -0,0 +1,68 @@
+package mj3gc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestKeyInfoDescribesPresentedKey(t *testing.T) {
+	store := NewStore()
+	key, err := store.UpsertAPIKey(APIKey{
+		Key:           "sk-integration-1234",
+		Label:         "billing-service",
+		Enabled:       true,
+		TotalLimit:    10,
+		Scopes:        []string{ScopeEmbeddings},
+		AllowedModels: []string{"text-embedding-*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.BeginRequest(key.Key); err != nil {
+		t.Fatal(err)
+	}
+	store.EndRequest(key.Key, true, "")
+
+	router := gin.New()
+	withKey := func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	router.GET("/v1/key/info", withKey, ScopeMiddleware(store), KeyInfoHandler(store))
+	get := func(value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/v1/key/info", nil)
+		req.Header.Set("Authorization", "Bearer "+value)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get(key.Key)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	var info KeyInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != key.ID || info.Label != "billing-service" || info.UsedCount != 1 || info.Remaining != 9 || info.RemainingTokens != -1 {
+		t.Fatalf("info = %+v", info)
+	}
+	if strings.Contains(info.Key, "integration") || !strings.HasSuffix(info.Key, "1234") {
+		t.Fatalf("key %q is not masked", info.Key)
+	}
+	if len(info.Scopes) != 1 || len(info.AllowedModels) != 1 || info.AllowedModels[0] != "text-embedding-*" {
+		t.Fatalf("scopes %v, allowed models %v", info.Scopes, info.AllowedModels)
+	}
+	if used, _ := store.FindAPIKeyByID(key.ID); used.UsedCount != 1 {
+		t.Fatalf("key info counted against the quota: used %d", used.UsedCount)
+	}
+
+	if rec := get(AnonymousPrincipal); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("anonymous: status %d", rec.Code)
+	}
+}
@@ -0,0 +1,107 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// ErrKeyLimitReached refuses a key that would give its user more keys than allowed by
+// User.MaxKeys or mj3gc.max-keys-per-user.
+var ErrKeyLimitReached = errors.New("key limit reached")
+
+// keyLimit returns how many keys the user with the given id may hold, or 0 for no
+// limit. A positive User.MaxKeys overrides the global maximum and a negative one
+// exempts the user.
+func (d *Data) keyLimit(userID string, globalMax int) int {
+	for _, u := range d.Users {
+		if u.ID != userID {
+			continue
+		}
+		switch {
+		case u.MaxKeys > 0:
+			return u.MaxKeys
+		case u.MaxKeys < 0:
+			return 0
+		}
+		break
+	}
+	return max(globalMax, 0)
+}
+
+// checkKeyLimit refuses key when it is new to its user, by creation or reassignment,
+// and the user already holds as many keys as allowed. Updates to keys the user already
+// holds pass even when a lowered limit is exceeded.
+func (d *Data) checkKeyLimit(key APIKey, globalMax int) error {
+	userID := strings.TrimSpace(key.UserID)
+	if userID == "" {
+		return nil
+	}
+	limit := d.keyLimit(userID, globalMax)
+	if limit == 0 {
+		return nil
+	}
+	held := 0
+	for _, existing := range d.APIKeys {
+		if key.ID != "" && existing.ID == key.ID {
+			if existing.UserID == userID {
+				return nil
+			}
+			continue
+		}
+		if existing.UserID == userID {
+			held++
+		}
+	}
+	if held >= limit {
+		return fmt.Errorf("%w: user %s already has %d of %d keys", ErrKeyLimitReached, userID, held, limit)
+	}
+	return nil
+}
+
+// CreatePortalKey creates a key for the owner of caller from the portal. The new key
+// is a child of caller, or of caller's parent, so it shares that key's request quota,
+// token limit and budget, and it inherits caller's restrictions. It counts against
+// the user's key limit. The returned key holds the generated secret.
+func (s *Store) CreatePortalKey(caller APIKey, label string) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidConfiguration
+	}
+	if caller.UserID == "" {
+		return APIKey{}, fmt.Errorf("key is not assigned to a user")
+	}
+	secret, err := NewAPIKey()
+	if err != nil {
+		return APIKey{}, err
+	}
+	parent := caller.ID
+	if caller.ParentID != "" {
+		parent = caller.ParentID
+	}
+	key := APIKey{
+		Key:               secret,
+		Label:             strings.TrimSpace(label),
+		UserID:            caller.UserID,
+		ParentID:          parent,
+		Enabled:           true,
+		ConcurrencyLimit:  caller.ConcurrencyLimit,
+		RPMLimit:          caller.RPMLimit,
+		MaxOutputTokens:   caller.MaxOutputTokens,
+		MaxBodyBytes:      caller.MaxBodyBytes,
+		Streaming:         caller.Streaming,
+		Priority:          caller.Priority,
+		CountPolicy:       caller.CountPolicy,
+		Residency:         caller.Residency,
+		Pool:              caller.Pool,
+		AllowedUserAgents: slices.Clone(caller.AllowedUserAgents),
+		AllowedIPs:        slices.Clone(caller.AllowedIPs),
+		AllowedOrigins:    slices.Clone(caller.AllowedOrigins),
+		AllowedModels:     slices.Clone(caller.AllowedModels),
+		DeniedModels:      slices.Clone(caller.DeniedModels),
+		Scopes:            slices.Clone(caller.Scopes),
+		PinnedAuths:       slices.Clone(caller.PinnedAuths),
+		PinnedProviders:   slices.Clone(caller.PinnedProviders),
+	}
+	return s.UpsertAPIKey(key)
+}
@@ -0,0 +1,70 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestUpsertAPIKeyEnforcesKeyLimit(t *testing.T) {
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{MaxKeysPerUser: 2}})
+	s.data.Users = []User{{ID: "usr-a", Username: "a"}, {ID: "usr-b", Username: "b", MaxKeys: 1}, {ID: "usr-c", Username: "c", MaxKeys: -1}}
+
+	for i, secret := range []string{"a-first-secret", "a-second-secret"} {
+		if _, err := s.UpsertAPIKey(APIKey{Key: secret, UserID: "usr-a", Enabled: true}); err != nil {
+			t.Fatalf("key %d: %v", i, err)
+		}
+	}
+	if _, err := s.UpsertAPIKey(APIKey{Key: "a-third-secret", UserID: "usr-a", Enabled: true}); !errors.Is(err, ErrKeyLimitReached) {
+		t.Fatalf("third key: err=%v, want ErrKeyLimitReached", err)
+	}
+
+	b, err := s.UpsertAPIKey(APIKey{Key: "b-first-secret", UserID: "usr-b", Enabled: true})
+	if err != nil {
+		t.Fatalf("per-user limit key: %v", err)
+	}
+	b.Label = "renamed"
+	if _, err := s.UpsertAPIKey(b); err != nil {
+		t.Fatalf("updating a held key: %v", err)
+	}
+	moved, err := s.UpsertAPIKey(APIKey{Key: "unassigned-secret", Enabled: true})
+	if err != nil {
+		t.Fatalf("unassigned key: %v", err)
+	}
+	moved.UserID = "usr-b"
+	if _, err := s.UpsertAPIKey(moved); !errors.Is(err, ErrKeyLimitReached) {
+		t.Fatalf("reassigning past the per-user limit: err=%v, want ErrKeyLimitReached", err)
+	}
+
+	for i, secret := range []string{"c-first-secret", "c-second-secret", "c-third-secret"} {
+		if _, err := s.UpsertAPIKey(APIKey{Key: secret, UserID: "usr-c", Enabled: true}); err != nil {
+			t.Fatalf("exempt user key %d: %v", i, err)
+		}
+	}
+}
+
+func TestCreatePortalKeySharesCallerQuota(t *testing.T) {
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{MaxKeysPerUser: 2}})
+	s.data.Users = []User{{ID: "usr-a", Username: "a"}}
+	caller, err := s.UpsertAPIKey(APIKey{Key: "caller-secret", UserID: "usr-a", Enabled: true, AllowedModels: []string{"gpt-4o"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := s.CreatePortalKey(caller, " ci ")
+	if err != nil {
+		t.Fatalf("CreatePortalKey: %v", err)
+	}
+	if created.ParentID != caller.ID || created.UserID != "usr-a" || created.Label != "ci" || created.Key == "" {
+		t.Fatalf("created key = %+v, want a labelled child of the caller", created)
+	}
+	if len(created.AllowedModels) != 1 || created.AllowedModels[0] != "gpt-4o" {
+		t.Fatalf("allowed models = %v, want the caller's", created.AllowedModels)
+	}
+	if _, err := s.CreatePortalKey(created, "again"); !errors.Is(err, ErrKeyLimitReached) {
+		t.Fatalf("key past the limit: err=%v, want ErrKeyLimitReached", err)
+	}
+}
@@ -0,0 +1,208 @@
+package mj3gc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	kvTimeout       = 30 * time.Second
+	kvRetryDelay    = 5 * time.Second
+	defaultKVPrefix = "mj3gc/"
+	kvDocumentKey   = "document"
+	kvUsersPrefix   = "users/"
+	kvAPIKeysPrefix = "keys/"
+)
+
+// kvStore is the subset of a distributed key-value store used by kvBackend.
+type kvStore interface {
+	// List returns every entry under prefix and the store revision the listing reflects.
+	List(ctx context.Context, prefix string) (map[string][]byte, uint64, error)
+	Put(ctx context.Context, key string, value []byte) error
+	Delete(ctx context.Context, key string) error
+	// Wait blocks until an entry under prefix changes after revision and returns the
+	// new revision. It may return revision unchanged when the server times out the wait.
+	Wait(ctx context.Context, prefix string, revision uint64) (uint64, error)
+}
+
+// kvBackend stores each user and API key as its own entry ("users/<id>", "keys/<id>")
+// under a prefix in etcd or Consul, with everything else in a "document" entry. Saves
+// only write entries that changed since they were last read or written, so instances
+// editing different users and keys do not overwrite each other.
+type kvBackend struct {
+	kv     kvStore
+	prefix string
+
+	mu       sync.Mutex
+	known    map[string]string
+	revision uint64
+}
+
+func newKVBackend(kv kvStore, prefix string) *kvBackend {
+	prefix = strings.TrimLeft(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		prefix = defaultKVPrefix
+	}
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &kvBackend{kv: kv, prefix: prefix, known: make(map[string]string)}
+}
+
+func (b *kvBackend) Load() (Data, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvTimeout)
+	defer cancel()
+	entries, revision, err := b.kv.List(ctx, b.prefix)
+	if err != nil {
+		return Data{}, err
+	}
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var data Data
+	known := make(map[string]string, len(entries))
+	for _, name := range names {
+		raw := entries[name]
+		rel := strings.TrimPrefix(name, b.prefix)
+		switch {
+		case rel == kvDocumentKey:
+			var rest Data
+			if err := json.Unmarshal(raw, &rest); err != nil {
+				return Data{}, fmt.Errorf("mj3gc: decode document: %w", err)
+			}
+			rest.Users, rest.APIKeys = data.Users, data.APIKeys
+			data = rest
+		case strings.HasPrefix(rel, kvUsersPrefix):
+			var user User
+			if err := json.Unmarshal(raw, &user); err != nil {
+				return Data{}, fmt.Errorf("mj3gc: decode user %s: %w", rel, err)
+			}
+			data.Users = append(data.Users, user)
+		case strings.HasPrefix(rel, kvAPIKeysPrefix):
+			var key APIKey
+			if err := json.Unmarshal(raw, &key); err != nil {
+				return Data{}, fmt.Errorf("mj3gc: decode api key %s: %w", rel, err)
+			}
+			data.APIKeys = append(data.APIKeys, key)
+		default:
+			continue
+		}
+		known[name] = string(raw)
+	}
+
+	b.mu.Lock()
+	b.known = known
+	b.revision = revision
+	b.mu.Unlock()
+	if len(known) == 0 {
+		return Data{}, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (b *kvBackend) Save(data Data) error {
+	desired := make(map[string]string, len(data.Users)+len(data.APIKeys)+1)
+	for _, u := range data.Users {
+		raw, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		desired[b.prefix+kvUsersPrefix+u.ID] = string(raw)
+	}
+	for _, k := range data.APIKeys {
+		raw, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+		desired[b.prefix+kvAPIKeysPrefix+k.ID] = string(raw)
+	}
+	rest := data
+	rest.Users, rest.APIKeys = nil, nil
+	document, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	desired[b.prefix+kvDocumentKey] = string(document)
+
+	ctx, cancel := context.WithTimeout(context.Background(), kvTimeout)
+	defer cancel()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for name, value := range desired {
+		if previous, ok := b.known[name]; ok && previous == value {
+			continue
+		}
+		if err := b.kv.Put(ctx, name, []byte(value)); err != nil {
+			return fmt.Errorf("mj3gc: put %s: %w", name, err)
+		}
+		b.known[name] = value
+	}
+	for name := range b.known {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if err := b.kv.Delete(ctx, name); err != nil {
+			return fmt.Errorf("mj3gc: delete %s: %w", name, err)
+		}
+		delete(b.known, name)
+	}
+	return nil
+}
+
+// WaitForChange blocks until an entry under the prefix changes after the last Load.
+func (b *kvBackend) WaitForChange(ctx context.Context) error {
+	b.mu.Lock()
+	revision := b.revision
+	b.mu.Unlock()
+	for {
+		next, err := b.kv.Wait(ctx, b.prefix, revision)
+		if err != nil {
+			return err
+		}
+		if next != revision {
+			return nil
+		}
+	}
+}
+
+// StartBackendWatch refreshes the store whenever its backend reports a change, until
+// ctx is cancelled. It does nothing for backends without change notifications.
+func (s *Store) StartBackendWatch(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	watcher, ok := s.currentBackend().(ChangeWatcher)
+	if !ok {
+		return
+	}
+	go func() {
+		for {
+			err := watcher.WaitForChange(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			if err == nil {
+				if err = s.Refresh(); err == nil {
+					continue
+				}
+			}
+			log.Warnf("mj3gc: backend watch failed: %v", err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(kvRetryDelay):
+			}
+		}
+	}()
+}
@@ -0,0 +1,143 @@
+package mj3gc
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryKV is an in-process kvStore for tests.
+type memoryKV struct {
+	mu       sync.Mutex
+	entries  map[string][]byte
+	revision uint64
+	puts     int
+	changed  chan struct{}
+}
+
+func newMemoryKV() *memoryKV {
+	return &memoryKV{entries: make(map[string][]byte), changed: make(chan struct{})}
+}
+
+func (m *memoryKV) bump() {
+	m.revision++
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+func (m *memoryKV) List(_ context.Context, prefix string) (map[string][]byte, uint64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string][]byte)
+	for k, v := range m.entries {
+		if len(k) >= len(prefix) && k[:len(prefix)] == prefix {
+			out[k] = append([]byte(nil), v...)
+		}
+	}
+	return out, m.revision, nil
+}
+
+func (m *memoryKV) Put(_ context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = append([]byte(nil), value...)
+	m.puts++
+	m.bump()
+	return nil
+}
+
+func (m *memoryKV) Delete(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.entries, key)
+	m.bump()
+	return nil
+}
+
+func (m *memoryKV) Wait(ctx context.Context, _ string, revision uint64) (uint64, error) {
+	m.mu.Lock()
+	if m.revision != revision {
+		defer m.mu.Unlock()
+		return m.revision, nil
+	}
+	changed := m.changed
+	m.mu.Unlock()
+	select {
+	case <-ctx.Done():
+		return revision, ctx.Err()
+	case <-changed:
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.revision, nil
+}
+
+func TestKVBackendSavesOnlyChangedEntries(t *testing.T) {
+	kv := newMemoryKV()
+	backend := newKVBackend(kv, "")
+	data := Data{
+		Version: CurrentDataVersion,
+		Users:   []User{{ID: "u1", Username: "alice"}},
+		APIKeys: []APIKey{{ID: "k1", Key: "a", UserID: "u1"}, {ID: "k2", Key: "b", UserID: "u1"}},
+	}
+	if err := backend.Save(data); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if kv.puts != 4 {
+		t.Fatalf("puts = %d, want 4 (document, user, two keys)", kv.puts)
+	}
+
+	data.APIKeys[0].UsedCount = 3
+	data.APIKeys = data.APIKeys[:1]
+	if err := backend.Save(data); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if kv.puts != 5 {
+		t.Fatalf("puts = %d, want a single rewrite", kv.puts)
+	}
+	if _, ok := kv.entries["mj3gc/keys/k2"]; ok {
+		t.Fatal("deleted key still stored")
+	}
+
+	loaded, err := newKVBackend(kv, "mj3gc").Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(loaded.Users) != 1 || len(loaded.APIKeys) != 1 || loaded.APIKeys[0].UsedCount != 3 || loaded.Version != CurrentDataVersion {
+		t.Fatalf("loaded = %+v", loaded)
+	}
+}
+
+func TestStartBackendWatchRefreshesOnRemoteChange(t *testing.T) {
+	kv := newMemoryKV()
+	writer := NewStore()
+	writer.SetBackend(newKVBackend(kv, ""))
+	reader := NewStore()
+	reader.SetBackend(newKVBackend(kv, ""))
+	if err := reader.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader.StartBackendWatch(ctx)
+
+	if _, err := writer.UpsertAPIKey(APIKey{ID: "k1", Key: "secret", Enabled: true}); err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := writer.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, ok := reader.FindAPIKey("secret"); ok {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("reader did not pick up the new key")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
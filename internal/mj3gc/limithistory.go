@@ -0,0 +1,94 @@
+package mj3gc
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// KeyLimits are the consumption limits of a key that billing cares about.
+type KeyLimits struct {
+	TotalLimit int64   `json:"total_limit"`
+	TokenLimit int64   `json:"token_limit,omitempty"`
+	BudgetUSD  float64 `json:"budget_usd,omitempty"`
+	RPMLimit   int     `json:"rpm_limit,omitempty"`
+}
+
+// LimitPeriod records the limits a key had from From until Until. The limits in force
+// now are the fields of the key itself, effective since the Until of the last period.
+type LimitPeriod struct {
+	From  time.Time `json:"from"`
+	Until time.Time `json:"until"`
+	KeyLimits
+}
+
+// CurrentLimits returns the limits the key has now.
+func (k APIKey) CurrentLimits() KeyLimits {
+	return KeyLimits{TotalLimit: k.TotalLimit, TokenLimit: k.TokenLimit, BudgetUSD: k.BudgetUSD, RPMLimit: k.RPMLimit}
+}
+
+// LimitsAt returns the limits that were in force at t. Times before the first recorded
+// change, including times before the key existed, resolve to the earliest limits.
+func (k APIKey) LimitsAt(t time.Time) KeyLimits {
+	for _, period := range k.LimitHistory {
+		if t.Before(period.Until) {
+			return period.KeyLimits
+		}
+	}
+	return k.CurrentLimits()
+}
+
+// closeLimitPeriod records the current limits as ending at now. Callers change the
+// limits afterwards.
+func (k *APIKey) closeLimitPeriod(now time.Time) {
+	from := k.CreatedAt
+	if n := len(k.LimitHistory); n > 0 {
+		from = k.LimitHistory[n-1].Until
+	}
+	if !now.After(from) {
+		// Several changes at the same instant only keep the last limits.
+		return
+	}
+	k.LimitHistory = append(k.LimitHistory, LimitPeriod{From: from, Until: now, KeyLimits: k.CurrentLimits()})
+}
+
+// trackLimitChange carries the limit history of previous over to next, closing the
+// current period first when next changes the limits. The history is owned by the store,
+// so a history passed in by the caller is ignored.
+func trackLimitChange(previous APIKey, next *APIKey, now time.Time) {
+	if previous.CurrentLimits() != next.CurrentLimits() {
+		previous.closeLimitPeriod(now)
+	}
+	next.LimitHistory = previous.LimitHistory
+}
+
+// priceInForce reports whether a model-prices entry applies at t. Entries with dates
+// that do not parse never apply; a zero t ignores the dates.
+func priceInForce(price config.MJ3GCModelPrice, t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	if price.EffectiveFrom != "" {
+		from, ok := parsePriceDate(price.EffectiveFrom)
+		if !ok || t.Before(from) {
+			return false
+		}
+	}
+	if price.EffectiveUntil != "" {
+		until, ok := parsePriceDate(price.EffectiveUntil)
+		if !ok || !t.Before(until) {
+			return false
+		}
+	}
+	return true
+}
+
+// parsePriceDate accepts RFC 3339 timestamps and YYYY-MM-DD dates (midnight UTC).
+func parsePriceDate(raw string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
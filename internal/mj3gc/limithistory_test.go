@@ -0,0 +1,64 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestLimitHistory(t *testing.T) {
+	s := NewStore()
+	created := time.Now().Add(-48 * time.Hour)
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-history", Enabled: true, TotalLimit: 100, CreatedAt: created})
+	if err != nil {
+		t.Fatal(err)
+	}
+	key.Label = "renamed"
+	if key, err = s.UpsertAPIKey(key); err != nil || len(key.LimitHistory) != 0 {
+		t.Fatalf("label change recorded history %v, %v", key.LimitHistory, err)
+	}
+
+	before := time.Now()
+	key.TotalLimit, key.BudgetUSD = 500, 20
+	key.LimitHistory = nil
+	if key, err = s.UpsertAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if len(key.LimitHistory) != 1 {
+		t.Fatalf("history = %+v, want one period", key.LimitHistory)
+	}
+	if got := key.LimitsAt(created.Add(time.Hour)); got.TotalLimit != 100 || got.BudgetUSD != 0 {
+		t.Fatalf("limits before the change = %+v", got)
+	}
+	if got := key.LimitsAt(time.Now()); got.TotalLimit != 500 || got.BudgetUSD != 20 {
+		t.Fatalf("limits after the change = %+v", got)
+	}
+	if period := key.LimitHistory[0]; !period.From.Equal(created) || period.Until.Before(before) {
+		t.Fatalf("period = %+v", period)
+	}
+}
+
+func TestEstimateCostEffectivePrices(t *testing.T) {
+	prices := []config.MJ3GCModelPrice{
+		{Model: "m", InputPerMillion: 1, EffectiveUntil: "2025-06-01"},
+		{Model: "m", InputPerMillion: 2, EffectiveFrom: "2025-06-01"},
+		{Model: "broken", InputPerMillion: 5, EffectiveFrom: "june"},
+	}
+	sample := RequestSample{Model: "m", InputTokens: 1_000_000}
+	for _, tc := range []struct {
+		at   time.Time
+		want float64
+	}{
+		{time.Date(2025, 5, 31, 23, 0, 0, 0, time.UTC), 1},
+		{time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC), 2},
+	} {
+		sample.Timestamp = tc.at
+		if got := EstimateCost(prices, sample); got != tc.want {
+			t.Errorf("cost at %s = %v, want %v", tc.at, got, tc.want)
+		}
+	}
+	if got := EstimateCost(prices, RequestSample{Model: "broken", InputTokens: 1_000_000, Timestamp: time.Now()}); got != 0 {
+		t.Errorf("price with an invalid date applied: %v", got)
+	}
+}
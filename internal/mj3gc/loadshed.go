@@ -0,0 +1,143 @@
+package mj3gc
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	// latencyHalfLife controls how quickly the latency estimate follows new samples and,
+	// without traffic, decays so shed classes are readmitted.
+	latencyHalfLife       = 10 * time.Second
+	defaultShedRetryAfter = 5
+)
+
+// LoadStatus reports the observed request latency and which priority classes are shed.
+type LoadStatus struct {
+	LatencyMs float64       `json:"latency_ms"`
+	Classes   []ClassStatus `json:"classes"`
+	// InFlight and Queued count the requests holding and waiting for a slot under
+	// MaxConcurrent, which is zero without mj3gc.max-concurrent-requests.
+	InFlight      int `json:"in_flight"`
+	Queued        int `json:"queued"`
+	MaxConcurrent int `json:"max_concurrent,omitempty"`
+}
+
+// ClassStatus is the shedding state of one priority class.
+type ClassStatus struct {
+	Name            string `json:"name"`
+	LatencyBudgetMs int    `json:"latency_budget_ms"`
+	Shedding        bool   `json:"shedding"`
+}
+
+// loadMonitor keeps a time-weighted moving average of end-to-end request latency
+// (queueing plus upstream time).
+type loadMonitor struct {
+	mu        sync.Mutex
+	latencyMs float64
+	updated   time.Time
+}
+
+func (m *loadMonitor) decayLocked(now time.Time) {
+	if m.updated.IsZero() {
+		return
+	}
+	elapsed := now.Sub(m.updated)
+	m.latencyMs *= math.Pow(0.5, float64(elapsed)/float64(latencyHalfLife))
+	m.updated = now
+}
+
+func (m *loadMonitor) observe(latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	ms := durationMs(latency)
+	if m.updated.IsZero() {
+		m.latencyMs, m.updated = ms, now
+		return
+	}
+	// Weight the sample by the time since the previous one so bursts and idle
+	// periods contribute proportionally.
+	weight := 1 - math.Pow(0.5, float64(now.Sub(m.updated))/float64(latencyHalfLife))
+	weight = math.Max(weight, 0.05)
+	m.decayLocked(now)
+	m.latencyMs += (ms - m.latencyMs) * weight
+}
+
+func (m *loadMonitor) current() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.decayLocked(time.Now())
+	return m.latencyMs
+}
+
+// priorityClass returns the configured class of key, falling back to mj3gc.default-priority.
+func priorityClass(settings config.MJ3GCConfig, key APIKey) (config.MJ3GCPriorityClass, bool) {
+	name := strings.TrimSpace(key.Priority)
+	if name == "" {
+		name = strings.TrimSpace(settings.DefaultPriority)
+	}
+	for _, class := range settings.PriorityClasses {
+		if strings.EqualFold(class.Name, name) {
+			return class, true
+		}
+	}
+	return config.MJ3GCPriorityClass{}, false
+}
+
+// ShouldShed reports whether a request for the key value must be rejected because the
+// observed latency exceeds its priority class budget, together with the Retry-After
+// delay in seconds. Classes without a budget are never shed.
+func (s *Store) ShouldShed(value string) (bool, int) {
+	if s == nil {
+		return false, 0
+	}
+	settings := s.Settings()
+	if len(settings.PriorityClasses) == 0 {
+		return false, 0
+	}
+	key, ok := s.FindAPIKeyByPrincipal(value)
+	if !ok {
+		return false, 0
+	}
+	class, ok := priorityClass(settings, key)
+	if !ok || class.LatencyBudgetMs <= 0 {
+		return false, 0
+	}
+	if s.load.current() <= float64(class.LatencyBudgetMs) {
+		return false, 0
+	}
+	return true, s.shedRetryAfter()
+}
+
+// shedRetryAfter is the Retry-After delay in seconds sent with shed requests.
+func (s *Store) shedRetryAfter() int {
+	if retry := s.Settings().ShedRetryAfterSeconds; retry > 0 {
+		return retry
+	}
+	return defaultShedRetryAfter
+}
+
+// LoadStatus returns the current latency estimate and the shedding state per class.
+func (s *Store) LoadStatus() LoadStatus {
+	if s == nil {
+		return LoadStatus{}
+	}
+	latency := s.load.current()
+	settings := s.Settings()
+	classes := settings.PriorityClasses
+	out := LoadStatus{LatencyMs: latency, Classes: make([]ClassStatus, 0, len(classes)), MaxConcurrent: max(settings.MaxConcurrentRequests, 0)}
+	out.InFlight, out.Queued = s.admission.counts()
+	for _, class := range classes {
+		out.Classes = append(out.Classes, ClassStatus{
+			Name:            class.Name,
+			LatencyBudgetMs: class.LatencyBudgetMs,
+			Shedding:        class.LatencyBudgetMs > 0 && latency > float64(class.LatencyBudgetMs),
+		})
+	}
+	return out
+}
@@ -0,0 +1,34 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestShouldShedByPriorityClass(t *testing.T) {
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{
+		PriorityClasses: []config.MJ3GCPriorityClass{
+			{Name: "premium"},
+			{Name: "low", LatencyBudgetMs: 1000},
+		},
+		DefaultPriority: "low",
+	}})
+	s.data.APIKeys = []APIKey{
+		{ID: "key-premium", Key: "p", Enabled: true, Priority: "premium"},
+		{ID: "key-default", Key: "d", Enabled: true},
+	}
+
+	if shed, _ := s.ShouldShed("d"); shed {
+		t.Fatal("shed before any latency was observed")
+	}
+	s.RecordRequest("key-premium", outcomeSuccess, 5*time.Second)
+	if shed, retry := s.ShouldShed("d"); !shed || retry != defaultShedRetryAfter {
+		t.Fatalf("default-priority key: shed=%v retry=%d, want shed with default retry", shed, retry)
+	}
+	if shed, _ := s.ShouldShed("p"); shed {
+		t.Fatal("class without a budget must never be shed")
+	}
+}
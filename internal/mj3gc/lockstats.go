@@ -0,0 +1,208 @@
+package mj3gc
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LockOpStats aggregates wait and hold times of the store mutex for one operation.
+type LockOpStats struct {
+	Op           string  `json:"op"`
+	Acquisitions int64   `json:"acquisitions"`
+	TotalWaitMs  float64 `json:"total_wait_ms"`
+	MaxWaitMs    float64 `json:"max_wait_ms"`
+	AvgWaitMs    float64 `json:"avg_wait_ms"`
+	TotalHoldMs  float64 `json:"total_hold_ms"`
+	MaxHoldMs    float64 `json:"max_hold_ms"`
+}
+
+// LockOp describes an operation currently waiting for or holding the store mutex.
+type LockOp struct {
+	Op      string    `json:"op"`
+	Mode    string    `json:"mode"`
+	State   string    `json:"state"`
+	Since   time.Time `json:"since"`
+	Elapsed float64   `json:"elapsed_ms"`
+}
+
+// LockReport is a point-in-time view of store mutex contention. Wait and hold times
+// and the active operations are only recorded with mj3gc.lock-timing enabled.
+type LockReport struct {
+	Timing   bool           `json:"timing"`
+	Ops      []LockOpStats  `json:"ops"`
+	Active   []LockOp       `json:"active"`
+	Inflight map[string]int `json:"inflight"`
+}
+
+// lockOpCounters holds the nanosecond totals and maxima of one operation.
+type lockOpCounters struct {
+	acquisitions atomic.Int64
+	totalWait    atomic.Int64
+	maxWait      atomic.Int64
+	totalHold    atomic.Int64
+	maxHold      atomic.Int64
+}
+
+// lockStats records store lock usage without a lock of its own, so the accounting
+// does not serialise lookups that share the store read lock.
+type lockStats struct {
+	timing atomic.Bool
+	ops    sync.Map // op -> *lockOpCounters
+	active sync.Map // id -> LockOp
+	nextID atomic.Uint64
+}
+
+// lockTrace follows one acquisition of the store lock. id is zero when timing is off.
+type lockTrace struct {
+	op    string
+	mode  string
+	id    uint64
+	since time.Time
+}
+
+func (l *lockStats) begin(op, mode string) lockTrace {
+	trace := lockTrace{op: op, mode: mode}
+	if !l.timing.Load() {
+		return trace
+	}
+	trace.id = l.nextID.Add(1)
+	trace.since = time.Now()
+	l.active.Store(trace.id, LockOp{Op: op, Mode: mode, State: "waiting", Since: trace.since})
+	return trace
+}
+
+func (l *lockStats) acquired(trace *lockTrace) {
+	counters := l.counters(trace.op)
+	counters.acquisitions.Add(1)
+	if trace.id == 0 {
+		return
+	}
+	now := time.Now()
+	wait := int64(now.Sub(trace.since))
+	counters.totalWait.Add(wait)
+	storeMax(&counters.maxWait, wait)
+	trace.since = now
+	l.active.Store(trace.id, LockOp{Op: trace.op, Mode: trace.mode, State: "holding", Since: now})
+}
+
+func (l *lockStats) released(trace lockTrace) {
+	if trace.id == 0 {
+		return
+	}
+	hold := int64(time.Since(trace.since))
+	l.active.Delete(trace.id)
+	counters := l.counters(trace.op)
+	counters.totalHold.Add(hold)
+	storeMax(&counters.maxHold, hold)
+}
+
+func (l *lockStats) counters(op string) *lockOpCounters {
+	if counters, ok := l.ops.Load(op); ok {
+		return counters.(*lockOpCounters)
+	}
+	counters, _ := l.ops.LoadOrStore(op, &lockOpCounters{})
+	return counters.(*lockOpCounters)
+}
+
+// storeMax raises v to value unless it already is larger.
+func storeMax(v *atomic.Int64, value int64) {
+	for {
+		current := v.Load()
+		if value <= current || v.CompareAndSwap(current, value) {
+			return
+		}
+	}
+}
+
+// lock acquires the store write lock on behalf of op and returns the matching unlock.
+func (s *Store) lock(op string) func() {
+	trace := s.locks.begin(op, "write")
+	s.mu.Lock()
+	s.locks.acquired(&trace)
+	return func() {
+		// Any write may reshape users or keys, so lookups rebuild the index next time
+		// and the next Save compares records to bump the revision.
+		s.index.invalidate()
+		s.changes.dirty = true
+		s.mu.Unlock()
+		s.locks.released(trace)
+	}
+}
+
+// lockUsage acquires the store write lock for op like lock, but keeps the lookup
+// index and pending change detection. It is reserved for the request path, which only
+// updates usage counters and in-flight slots of existing keys, and for bookkeeping
+// that does not touch users or keys.
+func (s *Store) lockUsage(op string) func() {
+	trace := s.locks.begin(op, "write")
+	s.mu.Lock()
+	s.locks.acquired(&trace)
+	return func() {
+		s.mu.Unlock()
+		s.locks.released(trace)
+	}
+}
+
+// rlock acquires the store read lock on behalf of op and returns the matching unlock.
+func (s *Store) rlock(op string) func() {
+	trace := s.locks.begin(op, "read")
+	s.mu.RLock()
+	s.locks.acquired(&trace)
+	return func() {
+		s.mu.RUnlock()
+		s.locks.released(trace)
+	}
+}
+
+// LockReport returns per-operation mutex wait statistics together with the operations
+// currently waiting for or holding the lock and the in-flight request counters.
+func (s *Store) LockReport() LockReport {
+	if s == nil {
+		return LockReport{}
+	}
+	report := LockReport{Timing: s.locks.timing.Load()}
+	now := time.Now()
+	s.locks.ops.Range(func(op, value any) bool {
+		counters := value.(*lockOpCounters)
+		stats := LockOpStats{
+			Op:           op.(string),
+			Acquisitions: counters.acquisitions.Load(),
+			TotalWaitMs:  durationMs(time.Duration(counters.totalWait.Load())),
+			MaxWaitMs:    durationMs(time.Duration(counters.maxWait.Load())),
+			TotalHoldMs:  durationMs(time.Duration(counters.totalHold.Load())),
+			MaxHoldMs:    durationMs(time.Duration(counters.maxHold.Load())),
+		}
+		if stats.Acquisitions > 0 {
+			stats.AvgWaitMs = stats.TotalWaitMs / float64(stats.Acquisitions)
+		}
+		report.Ops = append(report.Ops, stats)
+		return true
+	})
+	s.locks.active.Range(func(_, value any) bool {
+		op := value.(LockOp)
+		op.Elapsed = durationMs(now.Sub(op.Since))
+		report.Active = append(report.Active, op)
+		return true
+	})
+
+	sort.Slice(report.Ops, func(i, j int) bool { return report.Ops[i].TotalWaitMs > report.Ops[j].TotalWaitMs })
+	sort.Slice(report.Active, func(i, j int) bool { return report.Active[i].Since.Before(report.Active[j].Since) })
+
+	// TryRLock keeps the report from blocking behind a long-held store lock.
+	if s.mu.TryRLock() {
+		report.Inflight = make(map[string]int, len(s.inflight))
+		for id, count := range s.inflight {
+			if count > 0 {
+				report.Inflight[id] = count
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return report
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
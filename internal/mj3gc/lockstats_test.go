@@ -0,0 +1,40 @@
+package mj3gc
+
+import (
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestLockTimingIsOptIn(t *testing.T) {
+	s := NewStore()
+	s.Settings()
+	report := s.LockReport()
+	if report.Timing || len(report.Active) != 0 {
+		t.Fatalf("report = %+v, want timing off", report)
+	}
+	settings := lockOp(report, "Settings")
+	if settings.Acquisitions != 1 || settings.TotalWaitMs != 0 || settings.MaxHoldMs != 0 {
+		t.Fatalf("Settings stats = %+v, want one untimed acquisition", settings)
+	}
+
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{LockTiming: true}})
+	unlock := s.rlock("Held")
+	report = s.LockReport()
+	unlock()
+	if !report.Timing || len(report.Active) != 1 || report.Active[0].Op != "Held" || report.Active[0].State != "holding" {
+		t.Fatalf("report = %+v, want the held read lock listed", report)
+	}
+	if held := lockOp(s.LockReport(), "Held"); held.Acquisitions != 1 || held.TotalHoldMs <= 0 {
+		t.Fatalf("Held stats = %+v, want a timed hold", held)
+	}
+}
+
+func lockOp(report LockReport, op string) LockOpStats {
+	for _, stats := range report.Ops {
+		if stats.Op == op {
+			return stats
+		}
+	}
+	return LockOpStats{}
+}
@@ -0,0 +1,147 @@
+package mj3gc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	coreusage "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/usage"
+)
+
+// requestEventBuffer is how many recent request events the store keeps for tailing.
+const requestEventBuffer = 1000
+
+func init() {
+	coreusage.RegisterPlugin(requestEventPlugin{})
+}
+
+// RequestEvent is one finished request as reported by the usage pipeline, resolved to
+// the mj3gc key and user when an mj3gc key authenticated it.
+type RequestEvent struct {
+	Timestamp    time.Time `json:"timestamp"`
+	KeyID        string    `json:"key_id,omitempty"`
+	UserID       string    `json:"user_id,omitempty"`
+	Provider     string    `json:"provider,omitempty"`
+	Model        string    `json:"model"`
+	Failed       bool      `json:"failed"`
+	LatencyMs    int64     `json:"latency_ms,omitempty"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	TotalTokens  int64     `json:"total_tokens"`
+}
+
+// RequestEventFilter selects request events. Empty fields match everything; Model may
+// use '*' wildcards.
+type RequestEventFilter struct {
+	KeyID  string
+	UserID string
+	Model  string
+	Failed *bool
+}
+
+// Match reports whether ev passes the filter.
+func (f RequestEventFilter) Match(ev RequestEvent) bool {
+	switch {
+	case f.KeyID != "" && ev.KeyID != f.KeyID:
+		return false
+	case f.UserID != "" && ev.UserID != f.UserID:
+		return false
+	case f.Model != "" && !matchPattern(f.Model, ev.Model):
+		return false
+	case f.Failed != nil && ev.Failed != *f.Failed:
+		return false
+	}
+	return true
+}
+
+// requestEventFeed keeps the latest request events in a ring and fans new ones out to
+// subscribers. Slow subscribers miss events rather than block the usage pipeline.
+type requestEventFeed struct {
+	mu          sync.Mutex
+	ring        []RequestEvent
+	next        int
+	subscribers map[chan RequestEvent]RequestEventFilter
+}
+
+// PublishRequestEvent records ev and delivers it to matching subscribers.
+func (s *Store) PublishRequestEvent(ev RequestEvent) {
+	if s == nil {
+		return
+	}
+	feed := &s.events
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	if len(feed.ring) < requestEventBuffer {
+		feed.ring = append(feed.ring, ev)
+	} else {
+		feed.ring[feed.next] = ev
+	}
+	feed.next = (feed.next + 1) % requestEventBuffer
+	for ch, filter := range feed.subscribers {
+		if !filter.Match(ev) {
+			continue
+		}
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// TailRequestEvents returns up to backlog of the latest events matching filter, oldest
+// first, and a channel receiving matching events published afterwards. Callers must
+// call cancel when done; the channel is not closed.
+func (s *Store) TailRequestEvents(filter RequestEventFilter, backlog int) ([]RequestEvent, <-chan RequestEvent, func()) {
+	if s == nil {
+		return nil, nil, func() {}
+	}
+	feed := &s.events
+	feed.mu.Lock()
+	defer feed.mu.Unlock()
+	var recent []RequestEvent
+	for i := 0; i < len(feed.ring) && len(recent) < backlog; i++ {
+		// Walk backwards from the newest event.
+		ev := feed.ring[(feed.next-1-i+len(feed.ring))%len(feed.ring)]
+		if filter.Match(ev) {
+			recent = append(recent, ev)
+		}
+	}
+	for i, j := 0, len(recent)-1; i < j; i, j = i+1, j-1 {
+		recent[i], recent[j] = recent[j], recent[i]
+	}
+	ch := make(chan RequestEvent, 64)
+	if feed.subscribers == nil {
+		feed.subscribers = make(map[chan RequestEvent]RequestEventFilter)
+	}
+	feed.subscribers[ch] = filter
+	cancel := func() {
+		feed.mu.Lock()
+		delete(feed.subscribers, ch)
+		feed.mu.Unlock()
+	}
+	return recent, ch, cancel
+}
+
+// requestEventPlugin feeds usage records into the default store's request events.
+type requestEventPlugin struct{}
+
+func (requestEventPlugin) HandleUsage(_ context.Context, record coreusage.Record) {
+	store := DefaultStore()
+	ev := RequestEvent{
+		Timestamp:    record.RequestedAt,
+		Provider:     record.Provider,
+		Model:        record.Model,
+		Failed:       record.Failed,
+		LatencyMs:    record.Latency.Milliseconds(),
+		InputTokens:  record.Detail.InputTokens,
+		OutputTokens: record.Detail.OutputTokens,
+		TotalTokens:  record.Detail.TotalTokens,
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now()
+	}
+	if key, ok := store.FindAPIKeyByPrincipal(record.APIKey); ok {
+		ev.KeyID, ev.UserID = key.ID, key.UserID
+	}
+	store.PublishRequestEvent(ev)
+}
@@ -0,0 +1,55 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTailRequestEvents(t *testing.T) {
+	s := NewStore()
+	base := time.Now()
+	for i := 0; i < requestEventBuffer+5; i++ {
+		ev := RequestEvent{Timestamp: base.Add(time.Duration(i) * time.Millisecond), KeyID: "k1", Model: "gpt-4o"}
+		if i%2 == 1 {
+			ev.KeyID, ev.Model, ev.Failed = "k2", "claude-sonnet-4", true
+		}
+		s.PublishRequestEvent(ev)
+	}
+
+	recent, _, cancel := s.TailRequestEvents(RequestEventFilter{}, 3)
+	cancel()
+	if len(recent) != 3 || !recent[0].Timestamp.Before(recent[2].Timestamp) {
+		t.Fatalf("recent = %+v", recent)
+	}
+	if want := base.Add(time.Duration(requestEventBuffer+4) * time.Millisecond); !recent[2].Timestamp.Equal(want) {
+		t.Fatalf("newest event at %v, want %v", recent[2].Timestamp, want)
+	}
+
+	failed := true
+	recent, events, cancel := s.TailRequestEvents(RequestEventFilter{Model: "claude-*", Failed: &failed}, requestEventBuffer)
+	defer cancel()
+	if len(recent) != requestEventBuffer/2 {
+		t.Fatalf("filtered backlog has %d events, want %d", len(recent), requestEventBuffer/2)
+	}
+	for _, ev := range recent {
+		if ev.KeyID != "k2" {
+			t.Fatalf("filter let through %+v", ev)
+		}
+	}
+
+	s.PublishRequestEvent(RequestEvent{KeyID: "k1", Model: "gpt-4o"})
+	s.PublishRequestEvent(RequestEvent{KeyID: "k2", Model: "claude-opus-4", Failed: true})
+	select {
+	case ev := <-events:
+		if ev.Model != "claude-opus-4" {
+			t.Fatalf("subscriber received %+v", ev)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("subscriber received nothing")
+	}
+	select {
+	case ev := <-events:
+		t.Fatalf("subscriber received unmatched %+v", ev)
+	default:
+	}
+}
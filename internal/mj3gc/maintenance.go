@@ -0,0 +1,110 @@
+package mj3gc
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const maintenanceInterval = time.Hour
+
+// StartMaintenance runs periodic housekeeping until ctx is cancelled. Tasks read the
+// current settings on every run so config reloads take effect without a restart.
+func (s *Store) StartMaintenance(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	go func() {
+		s.runMaintenance()
+		ticker := time.NewTicker(maintenanceInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.runMaintenance()
+			}
+		}
+	}()
+}
+
+func (s *Store) runMaintenance() {
+	s.rates.prune(time.Now())
+	if s.ReadOnly() {
+		return
+	}
+	if reset := s.ResetDueKeys(time.Now()); reset > 0 {
+		log.Infof("mj3gc: reset the usage of %d keys at the end of their reset period", reset)
+	}
+	settings := s.Settings()
+	if days := settings.DisableUnusedKeysAfterDays; days > 0 {
+		disabled := s.DisableIdleKeys(time.Duration(days) * 24 * time.Hour)
+		if len(disabled) > 0 {
+			if err := s.Save(); err != nil {
+				log.Warnf("mj3gc: failed to save after disabling idle keys: %v", err)
+			} else {
+				log.Infof("mj3gc: disabled %d keys unused for more than %d days", len(disabled), days)
+			}
+		}
+	}
+	if days := settings.ArchiveDisabledAfterDays; days > 0 {
+		archived := s.ArchiveDisabledKeys(time.Duration(days) * 24 * time.Hour)
+		if len(archived) > 0 {
+			if err := s.Save(); err != nil {
+				log.Warnf("mj3gc: failed to save after archiving keys: %v", err)
+			} else {
+				log.Infof("mj3gc: archived %d keys disabled for more than %d days", len(archived), days)
+			}
+		}
+	}
+	if suspended := s.SuspendSunsetKeys(time.Now()); len(suspended) > 0 {
+		if err := s.Save(); err != nil {
+			log.Warnf("mj3gc: failed to save after suspending sunset keys: %v", err)
+		} else {
+			log.Infof("mj3gc: suspended %d keys that reached their sunset date", len(suspended))
+		}
+	}
+	if purged := s.PurgeExpiredKeys(time.Now()); len(purged) > 0 {
+		if err := s.Save(); err != nil {
+			log.Warnf("mj3gc: failed to save after purging expired keys: %v", err)
+		} else {
+			log.Infof("mj3gc: purged %d expired temporary keys", len(purged))
+		}
+	}
+	if expired := s.ExpireRotatedKeys(); expired > 0 {
+		if err := s.Save(); err != nil {
+			log.Warnf("mj3gc: failed to save after expiring rotated secrets: %v", err)
+		} else {
+			log.Infof("mj3gc: %d rotated secrets reached the end of their grace period", expired)
+		}
+	}
+	if purged := s.PurgeTrash(s.trashRetention()); purged > 0 {
+		if err := s.Save(); err != nil {
+			log.Warnf("mj3gc: failed to save after purging the trash: %v", err)
+		} else {
+			log.Infof("mj3gc: purged %d deleted users and keys from the trash", purged)
+		}
+	}
+	if report := s.Compact(s.CompactOptions()); report.Removed() || report.CompactedDetails > 0 {
+		if report.Removed() {
+			if err := s.Save(); err != nil {
+				log.Warnf("mj3gc: failed to save after pruning keys: %v", err)
+			}
+		}
+		log.Infof("mj3gc: pruned %d unused and %d orphaned keys, compacted %d usage details",
+			len(report.UnusedKeys), len(report.OrphanedKeys), report.CompactedDetails)
+	}
+	if raised := s.CheckUsageAlerts(time.Now()); raised > 0 {
+		log.Infof("mj3gc: raised %d usage alerts", raised)
+	}
+	if sent := s.SendDueReports(time.Now()); sent > 0 {
+		log.Infof("mj3gc: sent %d scheduled usage reports", sent)
+	}
+	if s.journalEnabled() && s.journalSize() > 0 {
+		if err := s.Save(); err != nil {
+			log.Warnf("mj3gc: usage journal compaction failed: %v", err)
+		}
+	}
+}
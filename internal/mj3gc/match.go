@@ -0,0 +1,72 @@
+package mj3gc
+
+import "strings"
+
+// matchPattern performs case-insensitive glob matching where '*' matches zero or more characters.
+func matchPattern(pattern, value string) bool {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	value = strings.ToLower(value)
+	if pattern == "" {
+		return false
+	}
+	pi, si := 0, 0
+	starIdx, matchIdx := -1, 0
+	for si < len(value) {
+		if pi < len(pattern) && pattern[pi] == value[si] {
+			pi++
+			si++
+			continue
+		}
+		if pi < len(pattern) && pattern[pi] == '*' {
+			starIdx = pi
+			matchIdx = si
+			pi++
+			continue
+		}
+		if starIdx != -1 {
+			pi = starIdx + 1
+			matchIdx++
+			si = matchIdx
+			continue
+		}
+		return false
+	}
+	for pi < len(pattern) && pattern[pi] == '*' {
+		pi++
+	}
+	return pi == len(pattern)
+}
+
+// matchAny reports whether value matches at least one of patterns.
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizePatterns trims entries and drops empty and duplicate patterns.
+func NormalizePatterns(patterns []string) []string {
+	if len(patterns) == 0 {
+		return nil
+	}
+	seen := make(map[string]struct{}, len(patterns))
+	out := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if _, ok := seen[strings.ToLower(pattern)]; ok {
+			continue
+		}
+		seen[strings.ToLower(pattern)] = struct{}{}
+		out = append(out, pattern)
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
@@ -2,11 +2,15 @@ package mj3gc
 
 import (
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/quota"
 )
 
-// QuotaMiddleware enforces per-key quota and concurrency limits.
+// QuotaMiddleware enforces per-key quota, rate and concurrency limits. Finished requests are
+// counted against the quota according to the key's count policy.
 func QuotaMiddleware(store *Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if store == nil {
@@ -23,26 +27,107 @@ func QuotaMiddleware(store *Store) gin.HandlerFunc {
 			c.Next()
 			return
 		}
-		_, err := store.BeginRequest(keyValue)
+		if keyValue == AnonymousPrincipal {
+			serveAnonymous(c, store)
+			return
+		}
+		// Keys of other access providers, such as the config api-keys, are not metered
+		// here, and neither are model listings, quota checks and key info.
+		if _, metered := RequiredScope(c.Request.Method, c.Request.URL.Path, c.Param("action")); !metered {
+			c.Next()
+			return
+		}
+		if _, found := store.FindAPIKeyByPrincipal(keyValue); !found {
+			c.Next()
+			return
+		}
+		if shed, retryAfter := store.ShouldShed(keyValue); shed {
+			if rejected, found := store.FindAPIKeyByPrincipal(keyValue); found {
+				store.RecordRequest(rejected.ID, outcomeShed, 0)
+			}
+			c.Header("Retry-After", strconv.Itoa(retryAfter))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server overloaded, retry later"})
+			return
+		}
+		release, err := store.Admit(c.Request.Context(), keyValue)
+		if err != nil {
+			if rejected, found := store.FindAPIKeyByPrincipal(keyValue); found {
+				store.RecordRequest(rejected.ID, outcomeShed, 0)
+			}
+			c.Header("Retry-After", strconv.Itoa(store.shedRetryAfter()))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "server overloaded, retry later"})
+			return
+		}
+		defer release()
+		start := time.Now()
+		fault, err := store.injectChaos(c.Request.Context(), keyValue)
+		if fault != "" {
+			c.Header(ChaosHeader, fault)
+		}
+		var key APIKey
+		if err == nil {
+			key, err = store.BeginRequest(keyValue)
+		}
 		if err != nil {
-			status := http.StatusUnauthorized
-			switch err {
-			case ErrQuotaExceeded, ErrConcurrencyExceeded:
-				status = http.StatusTooManyRequests
-			case ErrKeyNotFound, ErrKeyDisabled:
-				status = http.StatusUnauthorized
-			default:
+			if rejected, found := store.FindAPIKeyByPrincipal(keyValue); found {
+				store.RecordRequest(rejected.ID, outcomeRejected, 0)
+			}
+			status := quota.HTTPStatus(err)
+			if status == 0 {
 				status = http.StatusForbidden
 			}
-			c.AbortWithStatusJSON(status, gin.H{"error": err.Error()})
+			if retryAfter := RetryAfterSeconds(err); retryAfter > 0 {
+				c.Header("Retry-After", strconv.Itoa(retryAfter))
+			}
+			body := gin.H{"error": err.Error(), "code": quota.Code(err)}
+			if doc := store.ErrorDocURL(quota.Code(err)); doc != "" {
+				body["doc_url"] = doc
+			}
+			c.AbortWithStatusJSON(status, body)
 			return
 		}
+		setSunsetHeaders(c, key)
+
+		var shadowBody []byte
+		shadow := key.ShadowURL != ""
+		if shadow {
+			shadowBody, shadow = captureShadowBody(c.Request)
+		}
 
 		c.Next()
-		success := c.Writer.Status() < http.StatusBadRequest
-		store.EndRequest(keyValue, success)
-		if success {
-			_ = store.Save()
+		if shadow {
+			dispatchShadow(key, c.Request, shadowBody)
+		}
+		status := c.Writer.Status()
+		outcome := requestOutcome(status, c.Request.Context().Err())
+		count := key.CountsRequest(status, outcome == outcomeAborted)
+		store.RecordRequest(key.ID, outcome, time.Since(start))
+		clientIP := ""
+		if addr := store.ClientIP(c.Request); addr.IsValid() {
+			clientIP = addr.String()
 		}
+		store.EndRequest(keyValue, count, clientIP)
+		if count {
+			_ = store.SaveUsage(key.ID, 1)
+			store.saveParentUsage(key, 1)
+			store.RaiseUsageAlerts(key.ID, time.Now())
+		}
+	}
+}
+
+// serveAnonymous admits a key-less request against the anonymous budget of its client IP,
+// resolved through the mj3gc trusted proxies.
+func serveAnonymous(c *gin.Context, store *Store) {
+	ip := ""
+	if addr := store.ClientIP(c.Request); addr.IsValid() {
+		ip = addr.String()
+	}
+	if err := store.BeginAnonymous(ip); err != nil {
+		store.RecordRequest(anonymousKeyID, outcomeRejected, 0)
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "anonymous quota exceeded"})
+		return
 	}
+	start := time.Now()
+	c.Next()
+	store.RecordRequest(anonymousKeyID, requestOutcome(c.Writer.Status(), c.Request.Context().Err()), time.Since(start))
 }
@@ -0,0 +1,98 @@
+package mj3gc
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// migrations upgrades the data layout one version at a time. migrations[n] turns a
+// version n document into version n+1. Register new steps here and bump
+// CurrentDataVersion; never edit a step that has shipped.
+var migrations = map[int]func(*Data) error{
+	1: migrateV1ToV2,
+}
+
+// CurrentDataVersion is the data layout written by this build.
+const CurrentDataVersion = 2
+
+// migrateData upgrades data to CurrentDataVersion in place and reports whether it changed.
+func migrateData(data *Data) (bool, error) {
+	return migrateDataSteps(data, nil)
+}
+
+// migrateDataSteps is migrateData calling onStep, when set, after each step with the
+// version it started from, its start time and its error.
+func migrateDataSteps(data *Data, onStep func(from int, start time.Time, err error)) (bool, error) {
+	if data.Version == 0 {
+		data.Version = 1
+	}
+	if data.Version > CurrentDataVersion {
+		return false, fmt.Errorf("mj3gc: data version %d is newer than supported version %d", data.Version, CurrentDataVersion)
+	}
+	migrated := false
+	for data.Version < CurrentDataVersion {
+		step, ok := migrations[data.Version]
+		if !ok {
+			return migrated, fmt.Errorf("mj3gc: no migration from data version %d", data.Version)
+		}
+		start := time.Now()
+		err := step(data)
+		if onStep != nil {
+			onStep(data.Version, start, err)
+		}
+		if err != nil {
+			return migrated, fmt.Errorf("mj3gc: migrate data v%d to v%d: %w", data.Version, data.Version+1, err)
+		}
+		data.Version++
+		migrated = true
+	}
+	return migrated, nil
+}
+
+// migrateDataTraced is migrateData recording a migration span, with a child span per
+// step, when data is not at CurrentDataVersion.
+func (s *Store) migrateDataTraced(data *Data) (bool, error) {
+	if data.Version == CurrentDataVersion {
+		return false, nil
+	}
+	span := s.startPersistSpan(PersistOpMigration, nil)
+	migrated, err := migrateDataSteps(data, func(from int, start time.Time, err error) {
+		step := s.startPersistSpan(fmt.Sprintf("%s.v%d-v%d", PersistOpMigration, from, from+1), span)
+		step.span.Start = start
+		step.end(0, err)
+	})
+	span.end(0, err)
+	return migrated, err
+}
+
+// backupData writes data, as loaded before migrating, next to the data file.
+func (s *Store) backupData(data Data) (string, error) {
+	path := s.Path()
+	if path == "" {
+		return "", nil
+	}
+	backup := fmt.Sprintf("%s.v%d-%s.bak.json", strings.TrimSuffix(path, filepath.Ext(path)), data.Version, time.Now().UTC().Format("20060102T150405Z"))
+	unlock := s.rlock("backupData")
+	target := s.fileBackendLocked(backup)
+	unlock()
+	return backup, target.Save(data)
+}
+
+// migrateV1ToV2 backfills referral codes for users and disable timestamps for disabled
+// keys created before those fields existed.
+func migrateV1ToV2(data *Data) error {
+	now := time.Now()
+	for i := range data.Users {
+		if data.Users[i].ReferralCode == "" {
+			data.Users[i].ReferralCode = newReferralCode()
+		}
+	}
+	for i := range data.APIKeys {
+		if !data.APIKeys[i].Enabled && data.APIKeys[i].DisabledAt.IsZero() {
+			data.APIKeys[i].DisabledAt = now
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package mj3gc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMigratesAndBacksUp(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mj3gc.json")
+	v1 := `{"version":1,"users":[{"id":"usr-1","username":"alice"}],"api_keys":[{"id":"key-1","key":"sk-1","enabled":false}]}`
+	if err := os.WriteFile(path, []byte(v1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore()
+	s.SetPath(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := s.Snapshot().Version; got != CurrentDataVersion {
+		t.Fatalf("version = %d, want %d", got, CurrentDataVersion)
+	}
+	if user, _ := s.FindUserByID("usr-1"); user.ReferralCode == "" {
+		t.Fatal("referral code not backfilled")
+	}
+	if key, _ := s.FindAPIKeyByID("key-1"); key.DisabledAt.IsZero() {
+		t.Fatal("disabled_at not backfilled")
+	}
+	backups, _ := filepath.Glob(filepath.Join(dir, "mj3gc.v1-*.bak.json"))
+	if len(backups) != 1 {
+		t.Fatalf("backups = %v, want one v1 backup", backups)
+	}
+	if raw, _ := os.ReadFile(backups[0]); len(raw) == 0 {
+		t.Fatal("empty backup")
+	}
+
+	future := `{"version":99,"users":[],"api_keys":[]}`
+	if err := os.WriteFile(path, []byte(future), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	newer := NewStore()
+	newer.SetPath(path)
+	if err := newer.Load(); err == nil {
+		t.Fatal("loading a newer data version should fail")
+	}
+}
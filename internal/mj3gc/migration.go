@@ -0,0 +1,233 @@
+package mj3gc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const migrationBundleVersion = 1
+
+// migrationInfo binds derived keys and ciphertexts to this bundle format.
+var migrationInfo = []byte("mj3gc-migration-v1")
+
+// ErrInvalidMigration is returned for bundles that cannot be decrypted or decoded.
+var ErrInvalidMigration = errors.New("invalid migration bundle")
+
+// MigrationBundle carries users and keys, secrets included, encrypted to the public
+// key of the receiving deployment: an ephemeral X25519 key agreement feeds HKDF-SHA256
+// which keys AES-256-GCM. Byte fields are base64 encoded in JSON.
+type MigrationBundle struct {
+	Version      int    `json:"version"`
+	EphemeralKey []byte `json:"ephemeral_key"`
+	Nonce        []byte `json:"nonce"`
+	Ciphertext   []byte `json:"ciphertext"`
+}
+
+// MigrationKeyPath is where the deployment's migration private key is kept. Defaults to
+// "mj3gc-migration.key" next to the data file.
+func (s *Store) MigrationKeyPath() string {
+	if path := strings.TrimSpace(s.Settings().MigrationKeyFile); path != "" {
+		return path
+	}
+	path := s.Path()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "mj3gc-migration.key")
+}
+
+// migrationPrivateKey loads the migration private key, creating it on first use.
+func (s *Store) migrationPrivateKey() (*ecdh.PrivateKey, error) {
+	path := s.MigrationKeyPath()
+	if path == "" {
+		return nil, ErrInvalidConfiguration
+	}
+	raw, err := os.ReadFile(path)
+	if err == nil {
+		seed, errDecode := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+		if errDecode != nil {
+			return nil, fmt.Errorf("mj3gc: decode migration key: %w", errDecode)
+		}
+		return ecdh.X25519().NewPrivateKey(seed)
+	}
+	if !isNotExist(err) {
+		return nil, err
+	}
+	key, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(key.Bytes()) + "\n"
+	// O_EXCL keeps a concurrent first use from replacing a key already handed out.
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return s.migrationPrivateKey()
+		}
+		return nil, err
+	}
+	if _, err := file.WriteString(encoded); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return key, file.Close()
+}
+
+// MigrationPublicKey returns the public key other deployments encrypt bundles to.
+func (s *Store) MigrationPublicKey() ([]byte, error) {
+	if s == nil {
+		return nil, ErrInvalidConfiguration
+	}
+	key, err := s.migrationPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return key.PublicKey().Bytes(), nil
+}
+
+// ExportMigration encrypts the selected keys, or every active key when keyIDs is empty,
+// together with their owners to recipient, the target deployment's MigrationPublicKey.
+func (s *Store) ExportMigration(recipient []byte, keyIDs []string) (MigrationBundle, error) {
+	if s == nil {
+		return MigrationBundle{}, ErrInvalidConfiguration
+	}
+	peer, err := ecdh.X25519().NewPublicKey(recipient)
+	if err != nil {
+		return MigrationBundle{}, fmt.Errorf("mj3gc: invalid recipient key: %w", err)
+	}
+	data := s.Snapshot()
+	payload := Data{Version: data.Version}
+	wanted := make(map[string]bool, len(keyIDs))
+	for _, id := range keyIDs {
+		wanted[strings.TrimSpace(id)] = true
+	}
+	owners := make(map[string]bool)
+	for _, key := range data.APIKeys {
+		if len(wanted) > 0 && !wanted[key.ID] {
+			continue
+		}
+		payload.APIKeys = append(payload.APIKeys, key)
+		if key.UserID != "" {
+			owners[key.UserID] = true
+		}
+	}
+	if len(wanted) > len(payload.APIKeys) {
+		for _, key := range payload.APIKeys {
+			delete(wanted, key.ID)
+		}
+		for id := range wanted {
+			return MigrationBundle{}, fmt.Errorf("%w: %s", ErrKeyNotFound, id)
+		}
+	}
+	for _, user := range data.Users {
+		if owners[user.ID] {
+			payload.Users = append(payload.Users, user)
+		}
+	}
+	plaintext, err := json.Marshal(payload)
+	if err != nil {
+		return MigrationBundle{}, err
+	}
+	return sealMigration(peer, plaintext)
+}
+
+// ImportMigration decrypts a bundle addressed to this deployment and merges its users
+// and keys, preserving their secrets.
+func (s *Store) ImportMigration(bundle MigrationBundle) (ImportResult, error) {
+	if s == nil {
+		return ImportResult{}, ErrInvalidConfiguration
+	}
+	key, err := s.migrationPrivateKey()
+	if err != nil {
+		return ImportResult{}, err
+	}
+	plaintext, err := openMigration(key, bundle)
+	if err != nil {
+		return ImportResult{}, err
+	}
+	var data Data
+	if err := json.Unmarshal(plaintext, &data); err != nil {
+		return ImportResult{}, fmt.Errorf("%w: %v", ErrInvalidMigration, err)
+	}
+	return s.Import(data, false)
+}
+
+func migrationAEAD(shared, salt []byte) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, migrationInfo), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealMigration(peer *ecdh.PublicKey, plaintext []byte) (MigrationBundle, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return MigrationBundle{}, err
+	}
+	shared, err := ephemeral.ECDH(peer)
+	if err != nil {
+		return MigrationBundle{}, err
+	}
+	ephemeralKey := ephemeral.PublicKey().Bytes()
+	aead, err := migrationAEAD(shared, ephemeralKey)
+	if err != nil {
+		return MigrationBundle{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return MigrationBundle{}, err
+	}
+	return MigrationBundle{
+		Version:      migrationBundleVersion,
+		EphemeralKey: ephemeralKey,
+		Nonce:        nonce,
+		Ciphertext:   aead.Seal(nil, nonce, plaintext, migrationInfo),
+	}, nil
+}
+
+func openMigration(key *ecdh.PrivateKey, bundle MigrationBundle) ([]byte, error) {
+	if bundle.Version != migrationBundleVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidMigration, bundle.Version)
+	}
+	peer, err := ecdh.X25519().NewPublicKey(bundle.EphemeralKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMigration, err)
+	}
+	shared, err := key.ECDH(peer)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidMigration, err)
+	}
+	aead, err := migrationAEAD(shared, bundle.EphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(bundle.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: bad nonce", ErrInvalidMigration)
+	}
+	plaintext, err := aead.Open(nil, bundle.Nonce, bundle.Ciphertext, migrationInfo)
+	if err != nil {
+		return nil, fmt.Errorf("%w: not addressed to this deployment or corrupted", ErrInvalidMigration)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,56 @@
+package mj3gc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestMigrationBundleRoundTrip(t *testing.T) {
+	source := NewStore()
+	source.data.Users = []User{{ID: "u1", Username: "alice", PasswordHash: "hash"}, {ID: "u2", Username: "bob"}}
+	source.data.APIKeys = []APIKey{
+		{ID: "k1", Key: "secret-1", UserID: "u1", Enabled: true},
+		{ID: "k2", Key: "secret-2", UserID: "u2", Enabled: true},
+	}
+
+	target := NewStore()
+	target.path = filepath.Join(t.TempDir(), "mj3gc.json")
+	public, err := target.MigrationPublicKey()
+	if err != nil {
+		t.Fatalf("MigrationPublicKey: %v", err)
+	}
+	again, err := target.MigrationPublicKey()
+	if err != nil || string(again) != string(public) {
+		t.Fatal("migration key not persisted")
+	}
+
+	bundle, err := source.ExportMigration(public, []string{"k1"})
+	if err != nil {
+		t.Fatalf("ExportMigration: %v", err)
+	}
+	if _, err := NewStore().ImportMigration(bundle); err == nil {
+		t.Fatal("bundle opened by a deployment without the private key")
+	}
+	result, err := target.ImportMigration(bundle)
+	if err != nil {
+		t.Fatalf("ImportMigration: %v", err)
+	}
+	if result.Users != 1 || result.APIKeys != 1 {
+		t.Fatalf("result = %+v", result)
+	}
+	if key, ok := target.FindAPIKey("secret-1"); !ok || key.UserID != "u1" {
+		t.Fatal("migrated key does not keep its secret")
+	}
+	if user, ok := target.FindUserByID("u1"); !ok || user.PasswordHash != "hash" {
+		t.Fatalf("migrated user = %+v", user)
+	}
+
+	bundle.Ciphertext[0] ^= 0xff
+	if _, err := target.ImportMigration(bundle); !errors.Is(err, ErrInvalidMigration) {
+		t.Fatalf("err = %v, want ErrInvalidMigration", err)
+	}
+	if _, err := source.ExportMigration(public, []string{"missing"}); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("err = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,124 @@
+package mj3gc
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// ModelAccessMiddleware enforces the allowed_models and denied_models lists of the
+// authenticated key. It rejects requests for other models with 403 and removes them
+// from the model listings of /v1/models and /v1beta/models. Requests by keys without
+// lists, and by other access providers, pass through unchanged.
+func ModelAccessMiddleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if principal == "" || principal == AnonymousPrincipal {
+			c.Next()
+			return
+		}
+		key, ok := store.FindAPIKeyByPrincipal(principal)
+		if !ok || !key.restrictsModels() {
+			c.Next()
+			return
+		}
+		if model := requestedModel(c); model != "" {
+			if key.DeniesModel(model) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": fmt.Sprintf("model %q is blocked for this API key", model),
+					"code":  "model_blocked",
+					"model": model,
+				})
+				return
+			}
+			if !key.AllowsModel(model) {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"error": fmt.Sprintf("model %q is not allowed for this API key", model),
+					"code":  "model_not_allowed",
+					"model": model,
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+		if c.Request.Method != http.MethodGet || !strings.HasSuffix(c.Request.URL.Path, "/models") {
+			c.Next()
+			return
+		}
+		writer := &modelListWriter{ResponseWriter: c.Writer, body: &bytes.Buffer{}}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if _, err := writer.ResponseWriter.Write(filterModelList(writer.body.Bytes(), key)); err != nil {
+			log.Warnf("mj3gc: failed to write filtered model list: %v", err)
+		}
+	}
+}
+
+// requestedModel returns the model a request addresses: the path of Gemini style
+// /models/{model}:{method} routes, or the "model" field of a JSON body. The body is
+// restored for the handlers that follow.
+func requestedModel(c *gin.Context) string {
+	if action := strings.TrimPrefix(c.Param("action"), "/"); action != "" {
+		model, _, _ := strings.Cut(action, ":")
+		return model
+	}
+	if c.Request.Method == http.MethodGet {
+		return ""
+	}
+	raw, err := peekBody(c)
+	if err != nil {
+		return ""
+	}
+	return gjson.GetBytes(raw, "model").String()
+}
+
+// filterModelList drops the models key may not use from an OpenAI or Claude style
+// listing ("data" entries with an "id") or a Gemini one ("models" entries with a
+// "name" such as "models/gemini-2.5-pro"). Other bodies are returned unchanged.
+func filterModelList(body []byte, key APIKey) []byte {
+	for _, list := range []struct{ path, field string }{{"data", "id"}, {"models", "name"}} {
+		entries := gjson.GetBytes(body, list.path)
+		if !entries.IsArray() {
+			continue
+		}
+		kept := make([]string, 0, len(entries.Array()))
+		for _, entry := range entries.Array() {
+			name := strings.TrimPrefix(entry.Get(list.field).String(), "models/")
+			if key.AllowsModel(name) {
+				kept = append(kept, entry.Raw)
+			}
+		}
+		filtered, err := sjson.SetRawBytes(body, list.path, []byte("["+strings.Join(kept, ",")+"]"))
+		if err != nil {
+			return body
+		}
+		body = filtered
+	}
+	return body
+}
+
+// modelListWriter buffers a model listing so it can be filtered before it is sent.
+type modelListWriter struct {
+	gin.ResponseWriter
+	body *bytes.Buffer
+}
+
+func (w *modelListWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+func (w *modelListWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
@@ -0,0 +1,91 @@
+package mj3gc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestModelAccessMiddleware(t *testing.T) {
+	store := NewStore()
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-limited", Enabled: true, AllowedModels: []string{"gpt-4*"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-open", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-student", Enabled: true, DeniedModels: []string{"claude-*"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	withKey := func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	group := router.Group("", withKey, ModelAccessMiddleware(store))
+	group.GET("/v1/models", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"object": "list", "data": []gin.H{{"id": "gpt-4o"}, {"id": "claude-sonnet-4"}}})
+	})
+	group.GET("/v1beta/models", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"models": []gin.H{{"name": "models/gemini-2.5-pro"}, {"name": "models/gpt-4.1"}}})
+	})
+	group.POST("/v1/chat/completions", func(c *gin.Context) {
+		var body struct {
+			Model string `json:"model"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"model": body.Model})
+	})
+	group.POST("/v1beta/models/*action", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	do := func(method, path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodPost, "/v1/chat/completions", "sk-limited", `{"model":"gpt-4o"}`); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "gpt-4o") {
+		t.Fatalf("allowed model: %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(http.MethodPost, "/v1/chat/completions", "sk-limited", `{"model":"claude-sonnet-4"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("disallowed model status = %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", "sk-limited", `{}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("disallowed gemini model status = %d", rec.Code)
+	}
+	if rec := do(http.MethodPost, "/v1/chat/completions", "sk-open", `{"model":"claude-sonnet-4"}`); rec.Code != http.StatusOK {
+		t.Fatalf("key without allowlist status = %d", rec.Code)
+	}
+
+	rec := do(http.MethodPost, "/v1/chat/completions", "sk-student", `{"model":"claude-sonnet-4"}`)
+	if rec.Code != http.StatusForbidden || gjson.Get(rec.Body.String(), "code").String() != "model_blocked" ||
+		gjson.Get(rec.Body.String(), "model").String() != "claude-sonnet-4" {
+		t.Fatalf("blocked model: %d %s", rec.Code, rec.Body.String())
+	}
+	rec = do(http.MethodGet, "/v1/models", "sk-student", "")
+	if ids := gjson.Get(rec.Body.String(), "data.#.id").String(); ids != `["gpt-4o"]` {
+		t.Fatalf("/v1/models for a deny list = %s", ids)
+	}
+
+	rec = do(http.MethodGet, "/v1/models", "sk-limited", "")
+	if ids := gjson.Get(rec.Body.String(), "data.#.id").String(); ids != `["gpt-4o"]` {
+		t.Fatalf("filtered /v1/models = %s", ids)
+	}
+	rec = do(http.MethodGet, "/v1beta/models", "sk-limited", "")
+	if names := gjson.Get(rec.Body.String(), "models.#.name").String(); names != `["models/gpt-4.1"]` {
+		t.Fatalf("filtered /v1beta/models = %s", names)
+	}
+	rec = do(http.MethodGet, "/v1/models", "sk-open", "")
+	if n := gjson.Get(rec.Body.String(), "data.#").Int(); n != 2 {
+		t.Fatalf("unrestricted /v1/models lists %d models", n)
+	}
+}
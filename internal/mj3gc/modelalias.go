@@ -0,0 +1,128 @@
+package mj3gc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/sjson"
+)
+
+// ModelAliasHeader tells clients which model an aliased request was served by.
+const ModelAliasHeader = "X-MJ3GC-Model"
+
+// NormalizeModelAliases trims the model aliases of a key and checks that every alias
+// maps to a concrete model. Aliases may use '*' wildcards such as "gpt-4*"; targets
+// may not. An empty map clears the aliases.
+func NormalizeModelAliases(aliases map[string]string) (map[string]string, error) {
+	if len(aliases) == 0 {
+		return nil, nil
+	}
+	out := make(map[string]string, len(aliases))
+	for from, to := range aliases {
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if from == "" || to == "" {
+			return nil, fmt.Errorf("model aliases need a model and a target")
+		}
+		if strings.Contains(to, "*") {
+			return nil, fmt.Errorf("model alias target %q may not contain wildcards", to)
+		}
+		if strings.EqualFold(from, to) {
+			continue
+		}
+		out[from] = to
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// ResolveModel returns the model the key's aliases map model to, and whether one did.
+// An exact alias wins over patterns; among patterns the longest wins.
+func (k APIKey) ResolveModel(model string) (string, bool) {
+	if len(k.ModelAliases) == 0 || model == "" {
+		return model, false
+	}
+	for from, to := range k.ModelAliases {
+		if strings.EqualFold(from, model) {
+			return to, true
+		}
+	}
+	patterns := make([]string, 0, len(k.ModelAliases))
+	for from := range k.ModelAliases {
+		if strings.Contains(from, "*") {
+			patterns = append(patterns, from)
+		}
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		if len(patterns[i]) != len(patterns[j]) {
+			return len(patterns[i]) > len(patterns[j])
+		}
+		return patterns[i] < patterns[j]
+	})
+	for _, pattern := range patterns {
+		if matchPattern(pattern, model) {
+			return k.ModelAliases[pattern], true
+		}
+	}
+	return model, false
+}
+
+// ModelAliasMiddleware replaces the model of requests by keys with model_aliases before
+// model checks and routing see it, in the "model" field of JSON bodies or the path of
+// Gemini style /models/{model}:{method} routes. The served model is reported in the
+// X-MJ3GC-Model header. Other requests pass through unchanged.
+func ModelAliasMiddleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if principal == "" || principal == AnonymousPrincipal {
+			c.Next()
+			return
+		}
+		key, ok := store.FindAPIKeyByPrincipal(principal)
+		if !ok || len(key.ModelAliases) == 0 {
+			c.Next()
+			return
+		}
+		model := requestedModel(c)
+		target, aliased := key.ResolveModel(model)
+		if !aliased {
+			c.Next()
+			return
+		}
+		if action := strings.TrimPrefix(c.Param("action"), "/"); action != "" {
+			rewritten := "/" + target
+			if _, method, found := strings.Cut(action, ":"); found {
+				rewritten += ":" + method
+			}
+			for i := range c.Params {
+				if c.Params[i].Key == "action" {
+					c.Params[i].Value = rewritten
+				}
+			}
+		} else {
+			raw, err := peekBody(c)
+			if err != nil {
+				c.Next()
+				return
+			}
+			updated, err := sjson.SetBytes(raw, "model", target)
+			if err != nil {
+				c.Next()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(updated))
+			c.Request.ContentLength = int64(len(updated))
+		}
+		c.Header(ModelAliasHeader, target)
+		c.Next()
+	}
+}
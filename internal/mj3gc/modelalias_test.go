@@ -0,0 +1,89 @@
+package mj3gc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestResolveModel(t *testing.T) {
+	key := APIKey{ModelAliases: map[string]string{
+		"gpt-4o":  "gemini-2.0-flash",
+		"gpt-4*":  "gemini-2.5-flash",
+		"gpt-*":   "gemini-2.5-flash-lite",
+		"claude*": "gemini-2.5-pro",
+	}}
+	cases := map[string]string{
+		"GPT-4o":          "gemini-2.0-flash",
+		"gpt-4o-mini":     "gemini-2.5-flash",
+		"gpt-3.5-turbo":   "gemini-2.5-flash-lite",
+		"claude-sonnet-4": "gemini-2.5-pro",
+	}
+	for model, want := range cases {
+		if got, ok := key.ResolveModel(model); !ok || got != want {
+			t.Fatalf("ResolveModel(%q) = %q, %v, want %q", model, got, ok, want)
+		}
+	}
+	if got, ok := key.ResolveModel("o3"); ok || got != "o3" {
+		t.Fatalf("unaliased model resolved to %q", got)
+	}
+
+	if _, err := NormalizeModelAliases(map[string]string{"gpt-4o": "gemini-*"}); err == nil {
+		t.Fatal("wildcard target accepted")
+	}
+	if _, err := NormalizeModelAliases(map[string]string{" ": "gemini-2.0-flash"}); err == nil {
+		t.Fatal("blank alias accepted")
+	}
+	if got, err := NormalizeModelAliases(map[string]string{" gpt-4o ": " gemini-2.0-flash ", "same": "SAME"}); err != nil || len(got) != 1 || got["gpt-4o"] != "gemini-2.0-flash" {
+		t.Fatalf("NormalizeModelAliases = %v, %v", got, err)
+	}
+}
+
+func TestModelAliasMiddleware(t *testing.T) {
+	store := NewStore()
+	if _, err := store.UpsertAPIKey(APIKey{
+		Key:           "sk-cheap",
+		Enabled:       true,
+		ModelAliases:  map[string]string{"gpt-4o": "gemini-2.0-flash", "gemini-2.5-pro": "gemini-2.0-flash"},
+		AllowedModels: []string{"gemini-2.0-flash"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	withKey := func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	group := router.Group("", withKey, ModelAliasMiddleware(store), ModelAccessMiddleware(store))
+	group.POST("/v1/chat/completions", func(c *gin.Context) {
+		raw, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", raw)
+	})
+	group.POST("/v1beta/models/*action", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("action"))
+	})
+	send := func(path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer sk-cheap")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("/v1/chat/completions", `{"model":"gpt-4o","messages":[]}`)
+	if rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "model").String() != "gemini-2.0-flash" || rec.Header().Get(ModelAliasHeader) != "gemini-2.0-flash" {
+		t.Fatalf("aliased body = %d %s %v", rec.Code, rec.Body.String(), rec.Header())
+	}
+	rec = send("/v1beta/models/gemini-2.5-pro:generateContent", `{}`)
+	if rec.Code != http.StatusOK || rec.Body.String() != "/gemini-2.0-flash:generateContent" {
+		t.Fatalf("aliased gemini route = %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := send("/v1/chat/completions", `{"model":"o3"}`); rec.Code != http.StatusForbidden || rec.Header().Get(ModelAliasHeader) != "" {
+		t.Fatalf("unaliased disallowed model = %d", rec.Code)
+	}
+}
@@ -0,0 +1,175 @@
+package mj3gc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// AccessTokenPrefix marks bearer tokens issued by the OAuth2 token endpoint.
+const AccessTokenPrefix = "mj3gc_at."
+
+const defaultAccessTokenTTL = time.Hour
+
+// ErrInvalidAccessToken is returned for malformed, forged or expired access tokens and
+// for tokens whose key was disabled, deleted or rotated after issuance.
+var ErrInvalidAccessToken = errors.New("invalid access token")
+
+// accessTokenClaims is the signed payload of an access token. Fingerprint binds the
+// token to the key secret so rotating the key revokes its tokens.
+type accessTokenClaims struct {
+	KeyID       string `json:"kid"`
+	Fingerprint string `json:"fp"`
+	IssuedAt    int64  `json:"iat"`
+	ExpiresAt   int64  `json:"exp"`
+}
+
+type oauthState struct {
+	mu  sync.Mutex
+	key []byte
+}
+
+// signingKey returns the HMAC key for access tokens. Without mj3gc.oauth-token-secret a
+// random key is used, so tokens are only honoured by this process until it restarts.
+func (s *Store) signingKey() []byte {
+	if secret := strings.TrimSpace(s.Settings().OAuthTokenSecret); secret != "" {
+		sum := sha256.Sum256([]byte("mj3gc-oauth:" + secret))
+		return sum[:]
+	}
+	s.oauth.mu.Lock()
+	defer s.oauth.mu.Unlock()
+	if s.oauth.key == nil {
+		s.oauth.key = make([]byte, 32)
+		if _, err := rand.Read(s.oauth.key); err != nil {
+			log.Warnf("mj3gc: failed to generate oauth signing key: %v", err)
+		}
+		log.Info("mj3gc: oauth-token-secret not set, issued access tokens are only valid on this instance")
+	}
+	return s.oauth.key
+}
+
+func (s *Store) accessTokenTTL() time.Duration {
+	if seconds := s.Settings().OAuthTokenTTLSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultAccessTokenTTL
+}
+
+func keyFingerprint(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(sum[:8])
+}
+
+// IssueAccessToken authenticates a client_credentials grant where clientID is a key ID
+// and clientSecret its secret, and returns a signed bearer token with its lifetime.
+func (s *Store) IssueAccessToken(clientID, clientSecret string) (string, time.Duration, error) {
+	if s == nil {
+		return "", 0, ErrInvalidConfiguration
+	}
+	key, ok := s.FindAPIKeyByID(strings.TrimSpace(clientID))
+	if !ok || !key.Enabled || !key.MatchesSecret(clientSecret) {
+		return "", 0, ErrInvalidCredentials
+	}
+	ttl := s.accessTokenTTL()
+	now := time.Now()
+	payload, err := json.Marshal(accessTokenClaims{
+		KeyID:       key.ID,
+		Fingerprint: keyFingerprint(secretDigest(key.Key)),
+		IssuedAt:    now.Unix(),
+		ExpiresAt:   now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return AccessTokenPrefix + body + "." + s.signAccessToken(body), ttl, nil
+}
+
+func (s *Store) signAccessToken(body string) string {
+	mac := hmac.New(sha256.New, s.signingKey())
+	mac.Write([]byte(body))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateAccessToken returns the enabled key an access token was issued for.
+func (s *Store) ValidateAccessToken(token string) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidAccessToken
+	}
+	body, signature, ok := strings.Cut(strings.TrimPrefix(token, AccessTokenPrefix), ".")
+	if !ok || !strings.HasPrefix(token, AccessTokenPrefix) {
+		return APIKey{}, ErrInvalidAccessToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.signAccessToken(body))) {
+		return APIKey{}, ErrInvalidAccessToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return APIKey{}, ErrInvalidAccessToken
+	}
+	var claims accessTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return APIKey{}, ErrInvalidAccessToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return APIKey{}, ErrInvalidAccessToken
+	}
+	key, ok := s.FindAPIKeyByID(claims.KeyID)
+	if !ok || !key.Enabled || keyFingerprint(secretDigest(key.Key)) != claims.Fingerprint {
+		return APIKey{}, ErrInvalidAccessToken
+	}
+	return key, nil
+}
+
+// OAuthTokenHandler serves the OAuth2 token endpoint for the client_credentials grant
+// (RFC 6749 section 4.4). Client credentials are accepted through HTTP Basic or the
+// form body. The endpoint answers 404 unless mj3gc.oauth-enabled is set.
+func OAuthTokenHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil || !store.Settings().OAuthEnabled {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Header("Pragma", "no-cache")
+		if grant := c.PostForm("grant_type"); grant != "client_credentials" {
+			code := "unsupported_grant_type"
+			if grant == "" {
+				code = "invalid_request"
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": code})
+			return
+		}
+		clientID, clientSecret, basic := c.Request.BasicAuth()
+		if !basic {
+			clientID, clientSecret = c.PostForm("client_id"), c.PostForm("client_secret")
+		}
+		if clientID == "" || clientSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_request", "error_description": "client credentials required"})
+			return
+		}
+		token, ttl, err := store.IssueAccessToken(clientID, clientSecret)
+		if err != nil {
+			if basic {
+				c.Header("WWW-Authenticate", `Basic realm="mj3gc"`)
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid_client"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"access_token": token,
+			"token_type":   "Bearer",
+			"expires_in":   int64(ttl / time.Second),
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package mj3gc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	s := NewStore()
+	s.data.APIKeys = []APIKey{{ID: "k1", Key: "secret", Enabled: true}}
+
+	if _, _, err := s.IssueAccessToken("k1", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("err = %v, want ErrInvalidCredentials", err)
+	}
+	token, ttl, err := s.IssueAccessToken("k1", "secret")
+	if err != nil {
+		t.Fatalf("IssueAccessToken: %v", err)
+	}
+	if !strings.HasPrefix(token, AccessTokenPrefix) || ttl != defaultAccessTokenTTL {
+		t.Fatalf("token = %q, ttl = %v", token, ttl)
+	}
+	key, err := s.ValidateAccessToken(token)
+	if err != nil || key.ID != "k1" {
+		t.Fatalf("ValidateAccessToken = %+v, %v", key, err)
+	}
+	if _, err := s.ValidateAccessToken(token + "x"); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Fatalf("tampered token: err = %v", err)
+	}
+
+	s.data.APIKeys[0].Key = "rotated"
+	if _, err := s.ValidateAccessToken(token); !errors.Is(err, ErrInvalidAccessToken) {
+		t.Fatalf("token survived key rotation: err = %v", err)
+	}
+}
+
+func TestOAuthTokenHandler(t *testing.T) {
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{OAuthEnabled: true, OAuthTokenTTLSeconds: 60}})
+	s.data.APIKeys = []APIKey{{ID: "k1", Key: "secret", Enabled: true}}
+	router := gin.New()
+	router.POST("/oauth/token", OAuthTokenHandler(s))
+
+	post := func(form url.Values, basicID, basicSecret string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if basicID != "" {
+			req.SetBasicAuth(basicID, basicSecret)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post(url.Values{"grant_type": {"client_credentials"}}, "k1", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+	var resp struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.TokenType != "Bearer" || resp.ExpiresIn != 60 {
+		t.Fatalf("resp = %+v", resp)
+	}
+
+	rec = post(url.Values{"grant_type": {"client_credentials"}, "client_id": {"k1"}, "client_secret": {"nope"}}, "", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("bad secret status = %d", rec.Code)
+	}
+	rec = post(url.Values{"grant_type": {"password"}}, "k1", "secret")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unsupported_grant_type") {
+		t.Fatalf("password grant: %d %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,271 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultOTLPInterval = time.Minute
+	// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+	otlpCumulative = 2
+	// otlpSpanInternal is SPAN_KIND_INTERNAL; otlpStatusOK and otlpStatusError are
+	// STATUS_CODE_OK and STATUS_CODE_ERROR.
+	otlpSpanInternal = 1
+	otlpStatusOK     = 1
+	otlpStatusError  = 2
+)
+
+// StartOTLPExporter pushes per-key and persistence telemetry to mj3gc.otlp-endpoint, and
+// persistence spans to mj3gc.otlp-traces-endpoint, using OTLP/HTTP with JSON encoding
+// until ctx is cancelled. It does nothing when neither endpoint is configured.
+func (s *Store) StartOTLPExporter(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	settings := s.Settings()
+	endpoint := strings.TrimSpace(settings.OTLPEndpoint)
+	tracesEndpoint := strings.TrimSpace(settings.OTLPTracesEndpoint)
+	if endpoint == "" && tracesEndpoint == "" {
+		return
+	}
+	interval := time.Duration(settings.OTLPIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultOTLPInterval
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if endpoint != "" {
+					if err := s.pushOTLP(ctx, client, endpoint, settings.OTLPHeaders); err != nil {
+						log.Warnf("mj3gc: otlp export failed: %v", err)
+					}
+				}
+				if tracesEndpoint != "" {
+					if err := s.pushOTLPTraces(ctx, client, tracesEndpoint, settings.OTLPHeaders); err != nil {
+						log.Warnf("mj3gc: otlp trace export failed: %v", err)
+					}
+				}
+			}
+		}
+	}()
+}
+
+func (s *Store) pushOTLP(ctx context.Context, client *http.Client, endpoint string, headers map[string]string) error {
+	metrics, start := s.KeyMetrics()
+	persistence := s.PersistenceStats()
+	if len(metrics) == 0 && len(persistence.Operations) == 0 {
+		return nil
+	}
+	return postOTLP(ctx, client, endpoint, headers, buildOTLPPayload(metrics, persistence, start, time.Now()))
+}
+
+// pushOTLPTraces sends the persistence spans finished since the previous push.
+func (s *Store) pushOTLPTraces(ctx context.Context, client *http.Client, endpoint string, headers map[string]string) error {
+	spans := s.saveTrace.unexported()
+	if len(spans) == 0 {
+		return nil
+	}
+	return postOTLP(ctx, client, endpoint, headers, buildOTLPTracePayload(spans))
+}
+
+// postOTLP posts an OTLP JSON payload to endpoint.
+func postOTLP(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, body map[string]any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+type otlpAttribute struct {
+	Key   string            `json:"key"`
+	Value map[string]string `json:"value"`
+}
+
+func otlpString(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: map[string]string{"stringValue": value}}
+}
+
+// buildOTLPPayload renders metrics and persistence as an ExportMetricsServiceRequest in the
+// OTLP JSON mapping. 64-bit integers are encoded as strings as required by the protobuf
+// JSON mapping.
+func buildOTLPPayload(metrics []KeyMetrics, persistence PersistenceStats, start, now time.Time) map[string]any {
+	startNano := strconv.FormatInt(start.UnixNano(), 10)
+	nowNano := strconv.FormatInt(now.UnixNano(), 10)
+
+	requestPoints := make([]map[string]any, 0, len(metrics)*2)
+	latencyPoints := make([]map[string]any, 0, len(metrics))
+	for _, m := range metrics {
+		for outcome, n := range m.Requests {
+			requestPoints = append(requestPoints, map[string]any{
+				"attributes":        []otlpAttribute{otlpString("key_id", m.KeyID), otlpString("outcome", outcome)},
+				"startTimeUnixNano": startNano,
+				"timeUnixNano":      nowNano,
+				"asInt":             strconv.FormatInt(n, 10),
+			})
+		}
+		if m.LatencyCount == 0 {
+			continue
+		}
+		buckets := make([]string, len(m.BucketCounts))
+		for i, n := range m.BucketCounts {
+			buckets[i] = strconv.FormatInt(n, 10)
+		}
+		latencyPoints = append(latencyPoints, map[string]any{
+			"attributes":        []otlpAttribute{otlpString("key_id", m.KeyID)},
+			"startTimeUnixNano": startNano,
+			"timeUnixNano":      nowNano,
+			"count":             strconv.FormatInt(m.LatencyCount, 10),
+			"sum":               m.LatencySumMs,
+			"bucketCounts":      buckets,
+			"explicitBounds":    m.BoundsMs,
+		})
+	}
+
+	operationPoints := make([]map[string]any, 0, len(persistence.Operations)*2)
+	durationPoints := make([]map[string]any, 0, len(persistence.Operations))
+	for name, op := range persistence.Operations {
+		for outcome, n := range map[string]int64{"success": op.Count - op.Failures, "error": op.Failures} {
+			operationPoints = append(operationPoints, map[string]any{
+				"attributes":        []otlpAttribute{otlpString("operation", name), otlpString("outcome", outcome)},
+				"startTimeUnixNano": startNano,
+				"timeUnixNano":      nowNano,
+				"asInt":             strconv.FormatInt(n, 10),
+			})
+		}
+		durationPoints = append(durationPoints, map[string]any{
+			"attributes":   []otlpAttribute{otlpString("operation", name)},
+			"timeUnixNano": nowNano,
+			"asDouble":     op.LastMs,
+		})
+	}
+
+	return map[string]any{
+		"resourceMetrics": []map[string]any{{
+			"resource": map[string]any{
+				"attributes": []otlpAttribute{otlpString("service.name", "cli-proxy-api")},
+			},
+			"scopeMetrics": []map[string]any{{
+				"scope": map[string]string{"name": "mj3gc"},
+				"metrics": []map[string]any{
+					{
+						"name": "mj3gc.requests",
+						"unit": "{request}",
+						"sum": map[string]any{
+							"aggregationTemporality": otlpCumulative,
+							"isMonotonic":            true,
+							"dataPoints":             requestPoints,
+						},
+					},
+					{
+						"name": "mj3gc.request.duration",
+						"unit": "ms",
+						"histogram": map[string]any{
+							"aggregationTemporality": otlpCumulative,
+							"dataPoints":             latencyPoints,
+						},
+					},
+					{
+						"name": "mj3gc.persist.operations",
+						"unit": "{operation}",
+						"sum": map[string]any{
+							"aggregationTemporality": otlpCumulative,
+							"isMonotonic":            true,
+							"dataPoints":             operationPoints,
+						},
+					},
+					{
+						"name":  "mj3gc.persist.duration",
+						"unit":  "ms",
+						"gauge": map[string]any{"dataPoints": durationPoints},
+					},
+					{
+						"name": "mj3gc.data.size",
+						"unit": "By",
+						"gauge": map[string]any{"dataPoints": []map[string]any{{
+							"timeUnixNano": nowNano,
+							"asInt":        strconv.FormatInt(persistence.DataFileBytes, 10),
+						}}},
+					},
+				},
+			}},
+		}},
+	}
+}
+
+// buildOTLPTracePayload renders persistence spans as an ExportTraceServiceRequest in the
+// OTLP JSON mapping. Trace and span ids are hex encoded as the JSON mapping requires.
+func buildOTLPTracePayload(spans []PersistSpan) map[string]any {
+	out := make([]map[string]any, 0, len(spans))
+	for _, span := range spans {
+		attributes := []otlpAttribute{otlpString("mj3gc.operation", span.Name)}
+		if span.Bytes > 0 {
+			attributes = append(attributes, otlpAttribute{Key: "mj3gc.bytes", Value: map[string]string{"intValue": strconv.FormatInt(span.Bytes, 10)}})
+		}
+		if span.Slow {
+			attributes = append(attributes, otlpString("mj3gc.slow", "true"))
+		}
+		status := map[string]any{"code": otlpStatusOK}
+		if span.Error != "" {
+			status = map[string]any{"code": otlpStatusError, "message": span.Error}
+		}
+		entry := map[string]any{
+			"traceId":           span.TraceID,
+			"spanId":            span.SpanID,
+			"name":              "mj3gc." + span.Name,
+			"kind":              otlpSpanInternal,
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        attributes,
+			"status":            status,
+		}
+		if span.ParentID != "" {
+			entry["parentSpanId"] = span.ParentID
+		}
+		out = append(out, entry)
+	}
+	return map[string]any{
+		"resourceSpans": []map[string]any{{
+			"resource": map[string]any{
+				"attributes": []otlpAttribute{otlpString("service.name", "cli-proxy-api")},
+			},
+			"scopeSpans": []map[string]any{{
+				"scope": map[string]string{"name": "mj3gc"},
+				"spans": out,
+			}},
+		}},
+	}
+}
@@ -0,0 +1,117 @@
+package mj3gc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// outputTokenField names a request field limiting the completion length. Required
+// fields are set to the cap when the client leaves them out.
+type outputTokenField struct {
+	path     string
+	required bool
+}
+
+// OutputTokenCapMiddleware enforces the max_output_tokens cap of the authenticated key:
+// completion limits above the cap are lowered to it and missing ones are set, whatever
+// the client asked for. Other requests, and keys without a cap, pass through unchanged.
+func OutputTokenCapMiddleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if principal == "" || principal == AnonymousPrincipal {
+			c.Next()
+			return
+		}
+		key, ok := store.FindAPIKeyByPrincipal(principal)
+		if !ok || key.MaxOutputTokens <= 0 {
+			c.Next()
+			return
+		}
+		fields := outputTokenFields(c)
+		if len(fields) == 0 {
+			c.Next()
+			return
+		}
+		body, err := peekBody(c)
+		if err != nil || !gjson.ValidBytes(body) {
+			c.Next()
+			return
+		}
+		capped := capOutputTokens(body, fields, key.MaxOutputTokens)
+		c.Request.Body = io.NopCloser(bytes.NewReader(capped))
+		c.Request.ContentLength = int64(len(capped))
+		c.Next()
+	}
+}
+
+// outputTokenFields returns the completion length fields of the route c addresses.
+func outputTokenFields(c *gin.Context) []outputTokenField {
+	if action := c.Param("action"); action != "" {
+		if strings.HasSuffix(action, ":generateContent") || strings.HasSuffix(action, ":streamGenerateContent") {
+			return []outputTokenField{{path: "generationConfig.maxOutputTokens", required: true}}
+		}
+		return nil
+	}
+	switch path := c.Request.URL.Path; {
+	case strings.HasSuffix(path, "/chat/completions"):
+		// Newer clients send max_completion_tokens, older ones max_tokens.
+		return []outputTokenField{{path: "max_completion_tokens"}, {path: "max_tokens", required: true}}
+	case strings.HasSuffix(path, "/completions"), strings.HasSuffix(path, "/messages"):
+		return []outputTokenField{{path: "max_tokens", required: true}}
+	case strings.HasSuffix(path, "/responses"):
+		return []outputTokenField{{path: "max_output_tokens", required: true}}
+	}
+	return nil
+}
+
+// capOutputTokens lowers the present fields above limit to it. When none of the fields
+// is present, the required ones are set to limit.
+func capOutputTokens(body []byte, fields []outputTokenField, limit int) []byte {
+	present := false
+	for _, field := range fields {
+		value := gjson.GetBytes(body, field.path)
+		if !value.Exists() || value.Type == gjson.Null {
+			continue
+		}
+		present = true
+		if value.Type == gjson.Number && value.Int() > 0 && value.Int() <= int64(limit) {
+			continue
+		}
+		if updated, err := sjson.SetBytes(body, field.path, limit); err == nil {
+			body = updated
+		}
+	}
+	if present {
+		return body
+	}
+	for _, field := range fields {
+		if !field.required {
+			continue
+		}
+		if updated, err := sjson.SetBytes(body, field.path, limit); err == nil {
+			body = updated
+		}
+	}
+	return body
+}
+
+// peekBody reads the request body and puts it back for the handlers that follow.
+func peekBody(c *gin.Context) ([]byte, error) {
+	if c.Request.Body == nil {
+		return nil, nil
+	}
+	raw, err := io.ReadAll(c.Request.Body)
+	_ = c.Request.Body.Close()
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	return raw, err
+}
@@ -0,0 +1,64 @@
+package mj3gc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestOutputTokenCapMiddleware(t *testing.T) {
+	store := NewStore()
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-capped", Enabled: true, MaxOutputTokens: 1024}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-open", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	withKey := func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	echo := func(c *gin.Context) {
+		raw, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", raw)
+	}
+	group := router.Group("", withKey, OutputTokenCapMiddleware(store))
+	group.POST("/v1/chat/completions", echo)
+	group.POST("/v1/messages", echo)
+	group.POST("/v1/responses", echo)
+	group.POST("/v1/messages/count_tokens", echo)
+	group.POST("/v1beta/models/*action", echo)
+
+	cases := []struct {
+		name, key, path, body, field string
+		want                         int64
+	}{
+		{"clamps_max_tokens", "sk-capped", "/v1/chat/completions", `{"model":"m","max_tokens":50000}`, "max_tokens", 1024},
+		{"keeps_lower_value", "sk-capped", "/v1/chat/completions", `{"model":"m","max_tokens":200}`, "max_tokens", 200},
+		{"clamps_max_completion_tokens", "sk-capped", "/v1/chat/completions", `{"max_completion_tokens":9999}`, "max_completion_tokens", 1024},
+		{"leaves_max_tokens_unset_when_completion_given", "sk-capped", "/v1/chat/completions", `{"max_completion_tokens":10}`, "max_tokens", 0},
+		{"injects_missing_limit", "sk-capped", "/v1/chat/completions", `{"model":"m"}`, "max_tokens", 1024},
+		{"claude_messages", "sk-capped", "/v1/messages", `{"max_tokens":64000}`, "max_tokens", 1024},
+		{"responses", "sk-capped", "/v1/responses", `{"input":"hi"}`, "max_output_tokens", 1024},
+		{"gemini", "sk-capped", "/v1beta/models/gemini-2.5-pro:streamGenerateContent", `{"generationConfig":{"maxOutputTokens":65536}}`, "generationConfig.maxOutputTokens", 1024},
+		{"count_tokens_untouched", "sk-capped", "/v1/messages/count_tokens", `{"model":"m"}`, "max_tokens", 0},
+		{"uncapped_key", "sk-open", "/v1/chat/completions", `{"max_tokens":50000}`, "max_tokens", 50000},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Authorization", "Bearer "+tc.key)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if got := gjson.Get(rec.Body.String(), tc.field).Int(); got != tc.want {
+				t.Fatalf("%s = %d, want %d (body %s)", tc.field, got, tc.want, rec.Body.String())
+			}
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 )
 
 // ResolveDataPath returns the persistent data path for mj3gc user/key storage.
+// MJ3GC_DATA_PATH may name a .yaml, .yml or .toml file to keep the data in that format.
 func ResolveDataPath(cfg *config.Config, configFilePath string) string {
 	if override := strings.TrimSpace(os.Getenv("MJ3GC_DATA_PATH")); override != "" {
 		return filepath.Clean(override)
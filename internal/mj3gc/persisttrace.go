@@ -0,0 +1,257 @@
+package mj3gc
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	defaultSlowPersist = time.Second
+	// persistSpanBuffer is how many finished spans are kept for the overview and the
+	// OTLP trace exporter.
+	persistSpanBuffer = 256
+	// slowPersistLogEvery throttles the slow-persistence warning per operation.
+	slowPersistLogEvery = time.Minute
+)
+
+// Persistence operations traced by the store.
+const (
+	PersistOpSave      = "save"
+	PersistOpBackup    = "backup"
+	PersistOpMigration = "migration"
+)
+
+// PersistSpan is one timed persistence operation. Migration steps are children of the
+// migration span that ran them.
+type PersistSpan struct {
+	TraceID    string    `json:"trace_id"`
+	SpanID     string    `json:"span_id"`
+	ParentID   string    `json:"parent_span_id,omitempty"`
+	Name       string    `json:"name"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	DurationMs float64   `json:"duration_ms"`
+	Bytes      int64     `json:"bytes,omitempty"`
+	Slow       bool      `json:"slow,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// PersistOpStats aggregates the spans of one operation since startup.
+type PersistOpStats struct {
+	Count     int64     `json:"count"`
+	Failures  int64     `json:"failures"`
+	Slow      int64     `json:"slow"`
+	LastMs    float64   `json:"last_ms"`
+	MaxMs     float64   `json:"max_ms"`
+	TotalMs   float64   `json:"total_ms"`
+	LastBytes int64     `json:"last_bytes,omitempty"`
+	LastSlow  bool      `json:"last_slow,omitempty"`
+	LastAt    time.Time `json:"last_at"`
+}
+
+// PersistenceStats reports how long saves, backups and migrations take and how large
+// the data file has grown. Warnings name slow operations, the sign that the data file
+// needs a database backend.
+type PersistenceStats struct {
+	SlowThresholdMs int64                     `json:"slow_threshold_ms"`
+	DataFileBytes   int64                     `json:"data_file_bytes,omitempty"`
+	Operations      map[string]PersistOpStats `json:"operations"`
+	Recent          []PersistSpan             `json:"recent,omitempty"`
+	Warnings        []string                  `json:"warnings,omitempty"`
+}
+
+// saveTracer keeps the spans and per-operation totals of store persistence.
+type saveTracer struct {
+	mu       sync.Mutex
+	ops      map[string]*PersistOpStats
+	spans    []PersistSpan
+	next     int
+	finished uint64
+	exported uint64
+	warned   map[string]time.Time
+}
+
+// persistSpanRun is a span being timed.
+type persistSpanRun struct {
+	store *Store
+	span  PersistSpan
+}
+
+func newTraceID(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// slowPersistThreshold is mj3gc.slow-persist-ms or its default.
+func (s *Store) slowPersistThreshold() time.Duration {
+	if ms := s.Settings().SlowPersistMs; ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	return defaultSlowPersist
+}
+
+// startPersistSpan starts timing the named operation, as a child of parent when given.
+func (s *Store) startPersistSpan(name string, parent *persistSpanRun) *persistSpanRun {
+	run := &persistSpanRun{store: s, span: PersistSpan{SpanID: newTraceID(8), Name: name, Start: time.Now()}}
+	if parent != nil {
+		run.span.TraceID, run.span.ParentID = parent.span.TraceID, parent.span.SpanID
+	} else {
+		run.span.TraceID = newTraceID(16)
+	}
+	return run
+}
+
+// end records the span with the bytes written and its error.
+func (r *persistSpanRun) end(bytes int64, err error) {
+	if r == nil || r.store == nil {
+		return
+	}
+	end := time.Now()
+	span := r.span
+	span.End = end
+	span.DurationMs = durationMs(end.Sub(span.Start))
+	span.Bytes = bytes
+	if err != nil {
+		span.Error = err.Error()
+	}
+	threshold := r.store.slowPersistThreshold()
+	// Only top-level operations are judged slow; a slow migration step already makes
+	// its migration slow.
+	span.Slow = span.ParentID == "" && end.Sub(span.Start) > threshold
+	if r.store.saveTrace.record(span) {
+		log.Warnf("mj3gc: slow %s took %s (%s); the data file may need a database backend (storage: postgres or sqlite)",
+			span.Name, end.Sub(span.Start).Round(time.Millisecond), formatBytes(bytes))
+	}
+}
+
+// record stores a finished span and reports whether a slow-persistence warning is due.
+func (t *saveTracer) record(span PersistSpan) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.ops == nil {
+		t.ops = make(map[string]*PersistOpStats)
+		t.warned = make(map[string]time.Time)
+	}
+	op := t.ops[span.Name]
+	if op == nil {
+		op = &PersistOpStats{}
+		t.ops[span.Name] = op
+	}
+	op.Count++
+	if span.Error != "" {
+		op.Failures++
+	}
+	op.LastMs, op.TotalMs, op.LastAt = span.DurationMs, op.TotalMs+span.DurationMs, span.End
+	op.LastSlow = span.Slow
+	op.MaxMs = max(op.MaxMs, span.DurationMs)
+	if span.Bytes > 0 {
+		op.LastBytes = span.Bytes
+	}
+	if len(t.spans) < persistSpanBuffer {
+		t.spans = append(t.spans, span)
+	} else {
+		t.spans[t.next] = span
+	}
+	t.next = (t.next + 1) % persistSpanBuffer
+	t.finished++
+	if !span.Slow {
+		return false
+	}
+	op.Slow++
+	if time.Since(t.warned[span.Name]) < slowPersistLogEvery {
+		return false
+	}
+	t.warned[span.Name] = time.Now()
+	return true
+}
+
+// recentLocked returns the last n spans, oldest first.
+func (t *saveTracer) recentLocked(n int) []PersistSpan {
+	n = min(n, len(t.spans))
+	out := make([]PersistSpan, 0, n)
+	for i := len(t.spans) - n; i < len(t.spans); i++ {
+		idx := i
+		if len(t.spans) == persistSpanBuffer {
+			idx = (t.next + i) % persistSpanBuffer
+		}
+		out = append(out, t.spans[idx])
+	}
+	return out
+}
+
+// unexported returns the spans finished since the last call, oldest first. Spans that
+// were overwritten before being exported are skipped.
+func (t *saveTracer) unexported() []PersistSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	pending := t.recentLocked(int(min(t.finished-t.exported, uint64(persistSpanBuffer))))
+	t.exported = t.finished
+	return pending
+}
+
+// dataFileSize returns the size of the data file of the file backend, or 0.
+func (s *Store) dataFileSize() int64 {
+	fb, ok := s.currentBackend().(*fileBackend)
+	if !ok {
+		return 0
+	}
+	info, err := os.Stat(fb.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// PersistenceStats returns the persistence totals since startup with the most recent
+// spans and a warning for each operation whose last run was slower than
+// mj3gc.slow-persist-ms.
+func (s *Store) PersistenceStats() PersistenceStats {
+	out := PersistenceStats{Operations: map[string]PersistOpStats{}}
+	if s == nil {
+		return out
+	}
+	threshold := s.slowPersistThreshold()
+	out.SlowThresholdMs = threshold.Milliseconds()
+	out.DataFileBytes = s.dataFileSize()
+	t := &s.saveTrace
+	t.mu.Lock()
+	for name, op := range t.ops {
+		out.Operations[name] = *op
+	}
+	out.Recent = t.recentLocked(20)
+	t.mu.Unlock()
+
+	names := make([]string, 0, len(out.Operations))
+	for name := range out.Operations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		op := out.Operations[name]
+		if op.LastSlow {
+			out.Warnings = append(out.Warnings, fmt.Sprintf("last %s took %.0fms (threshold %dms); consider a database backend", name, op.LastMs, threshold.Milliseconds()))
+		}
+	}
+	return out
+}
+
+// formatBytes renders n for log messages.
+func formatBytes(n int64) string {
+	switch {
+	case n <= 0:
+		return "size unknown"
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d bytes", n)
+}
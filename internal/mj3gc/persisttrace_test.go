@@ -0,0 +1,69 @@
+package mj3gc
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPersistenceSpansForMigrationAndSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	v1 := `{"version":1,"users":[{"id":"usr-1","username":"alice"}],"api_keys":[]}`
+	if err := os.WriteFile(path, []byte(v1), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore()
+	s.SetPath(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	stats := s.PersistenceStats()
+	for _, op := range []string{PersistOpMigration, PersistOpMigration + ".v1-v2", PersistOpSave} {
+		if stats.Operations[op].Count != 1 {
+			t.Fatalf("operations = %+v, want one %s", stats.Operations, op)
+		}
+	}
+	if stats.Operations[PersistOpSave].LastBytes <= 0 || stats.DataFileBytes <= 0 {
+		t.Fatalf("save bytes %d, data file bytes %d, want the file size", stats.Operations[PersistOpSave].LastBytes, stats.DataFileBytes)
+	}
+
+	spans := s.saveTrace.unexported()
+	var migration, step PersistSpan
+	for _, span := range spans {
+		switch span.Name {
+		case PersistOpMigration:
+			migration = span
+		case PersistOpMigration + ".v1-v2":
+			step = span
+		}
+	}
+	if step.ParentID == "" || step.ParentID != migration.SpanID || step.TraceID != migration.TraceID {
+		t.Fatalf("step span %+v is not a child of migration span %+v", step, migration)
+	}
+	if again := s.saveTrace.unexported(); len(again) != 0 {
+		t.Fatalf("spans exported twice: %+v", again)
+	}
+}
+
+func TestSlowPersistenceIsReported(t *testing.T) {
+	s := NewStore()
+	span := s.startPersistSpan(PersistOpSave, nil)
+	span.span.Start = time.Now().Add(-2 * defaultSlowPersist)
+	span.end(1<<20, nil)
+
+	stats := s.PersistenceStats()
+	if op := stats.Operations[PersistOpSave]; op.Slow != 1 || !op.LastSlow {
+		t.Fatalf("save stats = %+v, want one slow save", op)
+	}
+	if len(stats.Warnings) != 1 || !strings.Contains(stats.Warnings[0], "save") {
+		t.Fatalf("warnings = %v, want a slow save warning", stats.Warnings)
+	}
+
+	s.startPersistSpan(PersistOpSave, nil).end(1<<20, nil)
+	if stats := s.PersistenceStats(); len(stats.Warnings) != 0 {
+		t.Fatalf("warnings = %v after a fast save", stats.Warnings)
+	}
+}
@@ -3,6 +3,7 @@ package mj3gc
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -41,7 +42,7 @@ func authenticateAPIKey(store *Store, r *http.Request) (APIKey, bool) {
 		return APIKey{}, false
 	}
 	key, ok := store.FindAPIKey(value)
-	if !ok || !key.Enabled {
+	if !ok || !key.Enabled || key.Expired(time.Now()) {
 		return APIKey{}, false
 	}
 	return key, true
@@ -0,0 +1,86 @@
+package mj3gc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+	log "github.com/sirupsen/logrus"
+)
+
+// postgresBackend shares users, keys and usage counters between proxy instances.
+type postgresBackend struct {
+	*sqlBackend
+}
+
+// OpenPostgresBackend connects to PostgreSQL using dsn and prepares the mj3gc tables.
+func OpenPostgresBackend(dsn string) (Backend, error) {
+	if dsn == "" {
+		return nil, fmt.Errorf("mj3gc: postgres DSN is required")
+	}
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("mj3gc: open postgres connection: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("mj3gc: ping postgres: %w", err)
+	}
+	backend, err := newSQLBackend(db, rebindDollar)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	backend.sharedCounters = true
+	return &postgresBackend{sqlBackend: backend}, nil
+}
+
+func (b *postgresBackend) IncrementUsage(keyID string, delta int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	var total int64
+	err := b.db.QueryRowContext(ctx,
+		`UPDATE mj3gc_api_keys SET used_count = used_count + $1 WHERE id = $2 RETURNING used_count`,
+		delta, keyID).Scan(&total)
+	if err == sql.ErrNoRows {
+		return 0, ErrKeyNotFound
+	}
+	return total, err
+}
+
+func (b *postgresBackend) ResetUsage(keyID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	_, err := b.db.ExecContext(ctx, `UPDATE mj3gc_api_keys SET used_count = 0 WHERE id = $1`, keyID)
+	return err
+}
+
+// StartSync periodically refreshes the store from its backend until ctx is cancelled.
+// It does nothing unless mj3gc.sync-interval-seconds is positive.
+func (s *Store) StartSync(ctx context.Context) {
+	if s == nil {
+		return
+	}
+	interval := time.Duration(s.Settings().SyncIntervalSeconds) * time.Second
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.Refresh(); err != nil {
+					log.Warnf("mj3gc: store sync failed: %v", err)
+				}
+			}
+		}
+	}()
+}
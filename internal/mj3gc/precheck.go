@@ -0,0 +1,144 @@
+package mj3gc
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/quota"
+)
+
+// QuotaCheck is the result of a pre-flight admission check. Remaining is -1 when the
+// key has no total limit; InFlight is -1 when concurrency is tracked by shared counters.
+type QuotaCheck struct {
+	Allowed           bool    `json:"allowed"`
+	Reason            string  `json:"reason,omitempty"`
+	KeyID             string  `json:"key_id"`
+	Requests          int64   `json:"requests"`
+	Used              int64   `json:"used"`
+	TotalLimit        int64   `json:"total_limit,omitempty"`
+	Remaining         int64   `json:"remaining"`
+	UsedTokens        int64   `json:"used_tokens,omitempty"`
+	TokenLimit        int64   `json:"token_limit,omitempty"`
+	SpentUSD          float64 `json:"spent_usd,omitempty"`
+	BudgetUSD         float64 `json:"budget_usd,omitempty"`
+	InFlight          int     `json:"in_flight"`
+	ConcurrencyLimit  int     `json:"concurrency_limit,omitempty"`
+	RPMLimit          int     `json:"rpm_limit,omitempty"`
+	RetryAfterSeconds int     `json:"retry_after_seconds,omitempty"`
+}
+
+// CheckRequest reports whether `requests` more requests for the key an access provider
+// authenticated as principal would be admitted right now by request and token quota,
+// budget, schedule, parent quota, concurrency, rpm_limit and load shedding. Nothing is
+// consumed.
+func (s *Store) CheckRequest(principal string, requests int64) (QuotaCheck, error) {
+	if s == nil {
+		return QuotaCheck{}, ErrInvalidConfiguration
+	}
+	if requests < 1 {
+		requests = 1
+	}
+	key, ok := s.FindAPIKeyByPrincipal(strings.TrimSpace(principal))
+	if !ok {
+		return QuotaCheck{}, ErrKeyNotFound
+	}
+	check := QuotaCheck{
+		KeyID:            key.ID,
+		Requests:         requests,
+		Used:             key.UsedCount,
+		TotalLimit:       key.TotalLimit,
+		Remaining:        -1,
+		UsedTokens:       key.UsedTokens,
+		TokenLimit:       key.TokenLimit,
+		SpentUSD:         key.SpentUSD,
+		BudgetUSD:        key.BudgetUSD,
+		ConcurrencyLimit: key.ConcurrencyLimit,
+		RPMLimit:         key.RPMLimit,
+	}
+	if counters := s.counterBackend(); counters != nil {
+		check.InFlight = -1
+		if used, err := counters.Usage(key.ID); err == nil {
+			check.Used = used
+		}
+	} else {
+		unlock := s.rlock("CheckRequest")
+		check.InFlight = s.inflight[key.ID]
+		unlock()
+	}
+	if key.TotalLimit > 0 {
+		check.Remaining = max(key.TotalLimit-check.Used, 0)
+	}
+
+	err := quota.Check(key.Limits(), check.Used)
+	if err == nil && key.TotalLimit > 0 && check.Used+requests > key.TotalLimit {
+		err = ErrQuotaExceeded
+	}
+	if err == nil {
+		err = key.checkSchedule(time.Now())
+	}
+	if err == nil {
+		err = key.checkTokenBudget()
+	}
+	if err == nil {
+		if counters := s.counterBackend(); counters != nil {
+			err = s.checkSharedParent(counters, key)
+		} else {
+			err = s.ParentQuotaError(key)
+		}
+	}
+	if err == nil && key.ConcurrencyLimit > 0 && check.InFlight >= key.ConcurrencyLimit {
+		err = ErrConcurrencyExceeded
+	}
+	if err == nil {
+		err = s.rates.peek(key.ID, key.RPMLimit, requests, time.Now())
+	}
+	if err != nil {
+		check.Reason = err.Error()
+		check.RetryAfterSeconds = RetryAfterSeconds(err)
+		return check, nil
+	}
+	if shed, retry := s.ShouldShed(key.Key); shed {
+		check.Reason = "server overloaded"
+		check.RetryAfterSeconds = retry
+		return check, nil
+	}
+	check.Allowed = true
+	return check, nil
+}
+
+// QuotaCheckHandler serves POST /v1/quota/check. The optional body {"requests": n}
+// is the number of requests the caller intends to submit and defaults to 1.
+func QuotaCheckHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value := c.GetString("apiKey")
+		if value == "" || value == AnonymousPrincipal {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "api key required"})
+			return
+		}
+		var body struct {
+			Requests int64 `json:"requests"`
+		}
+		if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body"})
+			return
+		}
+		if body.Requests < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "requests must be positive"})
+			return
+		}
+		check, err := store.CheckRequest(value, body.Requests)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if check.RetryAfterSeconds > 0 {
+			c.Header("Retry-After", strconv.Itoa(check.RetryAfterSeconds))
+		}
+		c.JSON(http.StatusOK, check)
+	}
+}
@@ -0,0 +1,112 @@
+package mj3gc
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestCheckRequestDoesNotConsume(t *testing.T) {
+	s := NewStore()
+	s.data.APIKeys = []APIKey{{ID: "k1", Key: "secret", Enabled: true, TotalLimit: 5, UsedCount: 3, ConcurrencyLimit: 1}}
+
+	check, err := s.CheckRequest("secret", 2)
+	if err != nil {
+		t.Fatalf("CheckRequest: %v", err)
+	}
+	if !check.Allowed || check.Remaining != 2 {
+		t.Fatalf("check = %+v, want allowed with 2 remaining", check)
+	}
+	if check, _ = s.CheckRequest("secret", 3); check.Allowed || check.Reason != ErrQuotaExceeded.Error() {
+		t.Fatalf("batch over quota: %+v", check)
+	}
+
+	if _, err := s.BeginRequest("secret"); err != nil {
+		t.Fatalf("BeginRequest: %v", err)
+	}
+	if check, _ = s.CheckRequest("secret", 1); check.Allowed || check.InFlight != 1 {
+		t.Fatalf("at concurrency limit: %+v", check)
+	}
+	s.EndRequest("secret", false, "")
+	if check, _ = s.CheckRequest("secret", 1); !check.Allowed || check.Used != 3 {
+		t.Fatalf("after release: %+v", check)
+	}
+	if _, err := s.CheckRequest("unknown", 1); err != ErrKeyNotFound {
+		t.Fatalf("err = %v, want ErrKeyNotFound", err)
+	}
+}
+
+func TestCheckRequestPeeksAtRPMLimit(t *testing.T) {
+	s := NewStore()
+	s.data.APIKeys = []APIKey{{ID: "k1", Key: "secret", Enabled: true, RPMLimit: 2}}
+
+	if check, _ := s.CheckRequest("secret", 2); !check.Allowed || check.RPMLimit != 2 {
+		t.Fatalf("check = %+v, want two requests allowed", check)
+	}
+	if check, _ := s.CheckRequest("secret", 3); check.Allowed || !strings.HasPrefix(check.Reason, ErrRateLimited.Error()) {
+		t.Fatalf("batch over rpm_limit: %+v", check)
+	}
+	if _, err := s.BeginRequest("secret"); err != nil {
+		t.Fatalf("BeginRequest: %v", err)
+	}
+	s.EndRequest("secret", true, "")
+	check, _ := s.CheckRequest("secret", 2)
+	if check.Allowed || check.RetryAfterSeconds < 1 {
+		t.Fatalf("one slot left in the window: %+v", check)
+	}
+	for i := 0; i < 5; i++ {
+		s.CheckRequest("secret", 1)
+	}
+	if _, err := s.BeginRequest("secret"); err != nil {
+		t.Fatalf("checks used the rate window: %v", err)
+	}
+}
+
+func TestCheckRequestWithHashedPrincipal(t *testing.T) {
+	s := NewStore()
+	s.SetPath(filepath.Join(t.TempDir(), "mj3gc.json"))
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{HashKeySecrets: true}})
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-precheck-secret", Enabled: true, TotalLimit: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := s.FindAPIKeyByID(key.ID)
+	if stored.Key != HashSecret("sk-precheck-secret") {
+		t.Fatalf("key stored as %q, want its digest", stored.Key)
+	}
+
+	check, err := s.CheckRequest(stored.Key, 1)
+	if err != nil {
+		t.Fatalf("CheckRequest(digest principal): %v", err)
+	}
+	if !check.Allowed || check.KeyID != key.ID || check.Remaining != 3 {
+		t.Fatalf("check = %+v, want the hashed key allowed", check)
+	}
+}
+
+func TestCheckRequestHonoursParentQuota(t *testing.T) {
+	s := NewStore()
+	s.data.APIKeys = []APIKey{
+		{ID: "parent", Key: "sk-parent", Enabled: true, TotalLimit: 2, UsedCount: 2},
+		{ID: "child", Key: "sk-child", Enabled: true, ParentID: "parent"},
+	}
+
+	check, err := s.CheckRequest("sk-child", 1)
+	if err != nil {
+		t.Fatalf("CheckRequest: %v", err)
+	}
+	if check.Allowed || check.Reason != ErrQuotaExceeded.Error() {
+		t.Fatalf("check = %+v, want the child refused by the exhausted parent", check)
+	}
+	if _, err := s.BeginRequest("sk-child"); err == nil {
+		t.Fatal("BeginRequest admitted a request the check refused")
+	}
+}
@@ -0,0 +1,359 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned by ParseQuery for malformed expressions.
+var ErrInvalidQuery = errors.New("invalid query")
+
+// Fields is the flattened, queryable view of a record. Keys are lower-case field names.
+type Fields map[string]string
+
+// Query is a parsed filter expression accepted by the audit and log endpoints.
+// The grammar is:
+//
+//	expr  := or
+//	or    := and ("OR" and)*
+//	and   := unary (["AND"] unary)*
+//	unary := ("NOT" | "-") unary | "(" expr ")" | term
+//	term  := field ":" value | field ":" (">"|">="|"<"|"<=") value
+//	       | field ":" "[" lo " TO " hi "]" | field ":" lo ".." hi | word
+//
+// Values may be double-quoted and may contain '*' wildcards. Comparisons are numeric
+// when both sides are numbers or dates (RFC 3339 or YYYY-MM-DD, compared as unix
+// seconds) and lexical otherwise. A bare word matches any field containing it.
+// The zero Query matches everything.
+type Query struct {
+	root queryNode
+}
+
+// IsZero reports whether the query is empty.
+func (q Query) IsZero() bool { return q.root == nil }
+
+// Match reports whether fields satisfy the query.
+func (q Query) Match(fields Fields) bool {
+	if q.root == nil {
+		return true
+	}
+	return q.root.match(fields)
+}
+
+// ParseQuery parses input into a Query. Blank input yields the zero Query.
+func ParseQuery(input string) (Query, error) {
+	tokens, err := lexQuery(input)
+	if err != nil {
+		return Query{}, err
+	}
+	if len(tokens) == 0 {
+		return Query{}, nil
+	}
+	p := &queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return Query{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return Query{}, fmt.Errorf("%w: unexpected %q", ErrInvalidQuery, p.tokens[p.pos])
+	}
+	return Query{root: root}, nil
+}
+
+type queryNode interface {
+	match(Fields) bool
+}
+
+type andNode struct{ left, right queryNode }
+type orNode struct{ left, right queryNode }
+type notNode struct{ inner queryNode }
+
+func (n andNode) match(f Fields) bool { return n.left.match(f) && n.right.match(f) }
+func (n orNode) match(f Fields) bool  { return n.left.match(f) || n.right.match(f) }
+func (n notNode) match(f Fields) bool { return !n.inner.match(f) }
+
+// textNode matches when any field contains the word.
+type textNode struct{ word string }
+
+func (n textNode) match(f Fields) bool {
+	pattern := "*" + strings.Trim(n.word, "*") + "*"
+	for _, value := range f {
+		if matchPattern(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	opEq = iota
+	opGt
+	opGe
+	opLt
+	opLe
+	opRange
+)
+
+type termNode struct {
+	field    string
+	op       int
+	value    string
+	hi       string
+	openLow  bool
+	openHigh bool
+}
+
+func (n termNode) match(f Fields) bool {
+	value, ok := f[n.field]
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case opEq:
+		if strings.Contains(n.value, "*") {
+			return matchPattern(n.value, value)
+		}
+		return strings.EqualFold(value, n.value)
+	case opGt:
+		return compareQueryValues(value, n.value) > 0
+	case opGe:
+		return compareQueryValues(value, n.value) >= 0
+	case opLt:
+		return compareQueryValues(value, n.value) < 0
+	case opLe:
+		return compareQueryValues(value, n.value) <= 0
+	case opRange:
+		if !n.openLow && compareQueryValues(value, n.value) < 0 {
+			return false
+		}
+		if !n.openHigh && compareQueryValues(value, n.hi) > 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// compareQueryValues compares numerically when both values are numbers or dates.
+func compareQueryValues(a, b string) int {
+	if x, ok := queryNumber(a); ok {
+		if y, ok := queryNumber(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			default:
+				return 0
+			}
+		}
+	}
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func queryNumber(raw string) (float64, bool) {
+	if f, err := strconv.ParseFloat(raw, 64); err == nil {
+		return f, true
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02"} {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return float64(t.Unix()), true
+		}
+	}
+	return 0, false
+}
+
+// lexQuery splits input into parentheses and words. Quoted strings and bracketed
+// ranges are kept inside a single word.
+func lexQuery(input string) ([]string, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuote, inRange := false, false
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	for _, r := range input {
+		switch {
+		case inQuote:
+			current.WriteRune(r)
+			if r == '"' {
+				inQuote = false
+			}
+		case r == '"':
+			inQuote = true
+			current.WriteRune(r)
+		case inRange:
+			current.WriteRune(r)
+			if r == ']' {
+				inRange = false
+			}
+		case r == '[':
+			inRange = true
+			current.WriteRune(r)
+		case r == '(' || r == ')':
+			flush()
+			tokens = append(tokens, string(r))
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("%w: unterminated quote", ErrInvalidQuery)
+	}
+	if inRange {
+		return nil, fmt.Errorf("%w: unterminated range", ErrInvalidQuery)
+	}
+	flush()
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *queryParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "OR" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		next := p.peek()
+		if next == "" || next == ")" || next == "OR" {
+			return left, nil
+		}
+		if next == "AND" {
+			p.pos++
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	token := p.peek()
+	switch {
+	case token == "":
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrInvalidQuery)
+	case token == "NOT":
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	case token == "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("%w: missing closing parenthesis", ErrInvalidQuery)
+		}
+		p.pos++
+		return inner, nil
+	case token == ")" || token == "AND" || token == "OR":
+		return nil, fmt.Errorf("%w: unexpected %q", ErrInvalidQuery, token)
+	case len(token) > 1 && token[0] == '-':
+		p.pos++
+		term, err := parseQueryTerm(token[1:])
+		if err != nil {
+			return nil, err
+		}
+		return notNode{term}, nil
+	default:
+		p.pos++
+		return parseQueryTerm(token)
+	}
+}
+
+func parseQueryTerm(token string) (queryNode, error) {
+	field, value, ok := strings.Cut(token, ":")
+	if !ok || strings.HasPrefix(token, "\"") {
+		word := unquoteQueryValue(token)
+		if word == "" {
+			return nil, fmt.Errorf("%w: empty term", ErrInvalidQuery)
+		}
+		return textNode{word: word}, nil
+	}
+	field = strings.ToLower(strings.TrimSpace(field))
+	if field == "" {
+		return nil, fmt.Errorf("%w: missing field in %q", ErrInvalidQuery, token)
+	}
+	term := termNode{field: field}
+	switch {
+	case strings.HasPrefix(value, "[") && strings.HasSuffix(value, "]"):
+		inner := strings.TrimSpace(value[1 : len(value)-1])
+		idx := strings.Index(strings.ToUpper(inner), " TO ")
+		if idx < 0 {
+			return nil, fmt.Errorf("%w: range %q needs TO", ErrInvalidQuery, value)
+		}
+		term.op = opRange
+		term.value = unquoteQueryValue(strings.TrimSpace(inner[:idx]))
+		term.hi = unquoteQueryValue(strings.TrimSpace(inner[idx+4:]))
+	case strings.HasPrefix(value, ">="):
+		term.op, term.value = opGe, unquoteQueryValue(value[2:])
+	case strings.HasPrefix(value, "<="):
+		term.op, term.value = opLe, unquoteQueryValue(value[2:])
+	case strings.HasPrefix(value, ">"):
+		term.op, term.value = opGt, unquoteQueryValue(value[1:])
+	case strings.HasPrefix(value, "<"):
+		term.op, term.value = opLt, unquoteQueryValue(value[1:])
+	case !strings.HasPrefix(value, "\"") && strings.Contains(value, ".."):
+		lo, hi, _ := strings.Cut(value, "..")
+		term.op, term.value, term.hi = opRange, lo, hi
+	default:
+		term.op, term.value = opEq, unquoteQueryValue(value)
+	}
+	if term.op == opRange {
+		term.openLow = term.value == "" || term.value == "*"
+		term.openHigh = term.hi == "" || term.hi == "*"
+		return term, nil
+	}
+	if term.value == "" {
+		return nil, fmt.Errorf("%w: missing value for %q", ErrInvalidQuery, field)
+	}
+	return term, nil
+}
+
+func unquoteQueryValue(raw string) string {
+	if len(raw) >= 2 && strings.HasPrefix(raw, "\"") && strings.HasSuffix(raw, "\"") {
+		return raw[1 : len(raw)-1]
+	}
+	return raw
+}
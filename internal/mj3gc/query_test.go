@@ -0,0 +1,54 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestQueryMatch(t *testing.T) {
+	fields := Fields{
+		"action":          "key.update",
+		"target":          "key-123",
+		"actor":           "admin",
+		"timestamp":       "1767225600", // 2026-01-01T00:00:00Z
+		"details.rotated": "true",
+	}
+	cases := []struct {
+		query string
+		want  bool
+	}{
+		{"", true},
+		{"action:key.update", true},
+		{"action:KEY.*", true},
+		{"action:user.*", false},
+		{"action:key.* AND actor:admin", true},
+		{"action:user.* OR actor:admin", true},
+		{"action:key.* -actor:admin", false},
+		{"NOT (action:user.* OR target:other)", true},
+		{"timestamp:>=2026-01-01", true},
+		{"timestamp:<2026-01-01", false},
+		{"timestamp:[2025-12-31 TO 2026-01-02]", true},
+		{"timestamp:1767225601..*", false},
+		{"details.rotated:true", true},
+		{"missing:value", false},
+		{"admin", true},
+		{`"key-1"`, true},
+	}
+	for _, tc := range cases {
+		q, err := ParseQuery(tc.query)
+		if err != nil {
+			t.Fatalf("ParseQuery(%q): %v", tc.query, err)
+		}
+		if got := q.Match(fields); got != tc.want {
+			t.Errorf("Match(%q) = %v, want %v", tc.query, got, tc.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, query := range []string{"(action:x", "action:", `"open`, "a OR", "timestamp:[1 2]", "AND x"} {
+		if _, err := ParseQuery(query); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ParseQuery(%q) err = %v, want ErrInvalidQuery", query, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQuotaTransfer reports a quota transfer between keys that cannot be made.
+var ErrQuotaTransfer = errors.New("quota transfer not possible")
+
+// QuotaTransfer is the outcome of TransferQuota.
+type QuotaTransfer struct {
+	From     APIKey `json:"from"`
+	To       APIKey `json:"to"`
+	Requests int64  `json:"requests"`
+}
+
+// TransferQuota moves requests of the remaining request quota of the key fromID to the
+// key toID in one step, lowering the total limit of one and raising the other. Both keys
+// must belong to the same user and have a request limit. requests <= 0 moves everything
+// that remains.
+func (s *Store) TransferQuota(fromID, toID string, requests int64) (QuotaTransfer, error) {
+	if s == nil {
+		return QuotaTransfer{}, ErrInvalidConfiguration
+	}
+	if fromID == toID {
+		return QuotaTransfer{}, fmt.Errorf("%w: source and destination are the same key", ErrQuotaTransfer)
+	}
+	defer s.lock("TransferQuota")()
+	fi, ok := s.keyIDIndexLocked(fromID)
+	if !ok {
+		return QuotaTransfer{}, ErrKeyNotFound
+	}
+	ti, ok := s.keyIDIndexLocked(toID)
+	if !ok {
+		return QuotaTransfer{}, ErrKeyNotFound
+	}
+	from, to := &s.data.APIKeys[fi], &s.data.APIKeys[ti]
+	if from.UserID == "" || from.UserID != to.UserID {
+		return QuotaTransfer{}, fmt.Errorf("%w: keys must belong to the same user", ErrQuotaTransfer)
+	}
+	if from.TotalLimit <= 0 || to.TotalLimit <= 0 {
+		return QuotaTransfer{}, fmt.Errorf("%w: both keys need a request limit", ErrQuotaTransfer)
+	}
+	remaining := from.TotalLimit - from.UsedCount
+	if remaining < 0 {
+		remaining = 0
+	}
+	if requests <= 0 {
+		requests = remaining
+	}
+	if requests == 0 || requests > remaining {
+		return QuotaTransfer{}, fmt.Errorf("%w: source key has %d requests remaining", ErrQuotaTransfer, remaining)
+	}
+	if from.TotalLimit == requests {
+		// A total limit of zero means unlimited.
+		return QuotaTransfer{}, fmt.Errorf("%w: a key cannot give away its whole limit, disable it instead", ErrQuotaTransfer)
+	}
+	now := time.Now()
+	from.closeLimitPeriod(now)
+	to.closeLimitPeriod(now)
+	from.TotalLimit -= requests
+	to.TotalLimit += requests
+	return QuotaTransfer{From: *from, To: *to, Requests: requests}, nil
+}
@@ -0,0 +1,49 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTransferQuota(t *testing.T) {
+	s := NewStore()
+	from, _ := s.UpsertAPIKey(APIKey{Key: "sk-from", UserID: "u1", Enabled: true, TotalLimit: 100, UsedCount: 30})
+	to, _ := s.UpsertAPIKey(APIKey{Key: "sk-to", UserID: "u1", Enabled: true, TotalLimit: 10})
+	other, _ := s.UpsertAPIKey(APIKey{Key: "sk-other", UserID: "u2", Enabled: true, TotalLimit: 10})
+	otherSpare, _ := s.UpsertAPIKey(APIKey{Key: "sk-other-spare", UserID: "u2", Enabled: true, TotalLimit: 5})
+	unlimited, _ := s.UpsertAPIKey(APIKey{Key: "sk-unlimited", UserID: "u1", Enabled: true})
+
+	got, err := s.TransferQuota(from.ID, to.ID, 20)
+	if err != nil {
+		t.Fatalf("TransferQuota: %v", err)
+	}
+	if got.Requests != 20 || got.From.TotalLimit != 80 || got.To.TotalLimit != 30 {
+		t.Fatalf("transfer = %+v", got)
+	}
+
+	got, err = s.TransferQuota(from.ID, to.ID, 0)
+	if err != nil {
+		t.Fatalf("TransferQuota(all): %v", err)
+	}
+	if got.Requests != 50 || got.From.TotalLimit != 30 || got.To.TotalLimit != 80 {
+		t.Fatalf("transfer of the remainder = %+v", got)
+	}
+
+	for name, tc := range map[string]struct {
+		from, to string
+		requests int64
+	}{
+		"nothing_left":   {from.ID, to.ID, 1},
+		"other_user":     {to.ID, other.ID, 1},
+		"unlimited_dest": {to.ID, unlimited.ID, 1},
+		"same_key":       {to.ID, to.ID, 1},
+		"whole_limit":    {other.ID, otherSpare.ID, 10},
+	} {
+		if _, err := s.TransferQuota(tc.from, tc.to, tc.requests); !errors.Is(err, ErrQuotaTransfer) {
+			t.Errorf("%s: err = %v, want ErrQuotaTransfer", name, err)
+		}
+	}
+	if _, err := s.TransferQuota(from.ID, "missing", 1); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("missing destination: %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// rateWindow is the span rpm_limit counts requests over.
+const rateWindow = time.Minute
+
+// RateLimitError reports a request rejected by the rpm_limit of its key. It wraps
+// ErrRateLimited and says when the oldest request in the window leaves it.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%v, retry in %s", ErrRateLimited, e.RetryAfter.Round(time.Second))
+}
+
+func (e *RateLimitError) Unwrap() error { return ErrRateLimited }
+
+// RetryAfterSeconds returns the whole seconds a client rejected with err should wait,
+// rounded up, or 0 when err is not a rate limit rejection.
+func RetryAfterSeconds(err error) int {
+	var rl *RateLimitError
+	if !errors.As(err, &rl) {
+		return 0
+	}
+	seconds := int((rl.RetryAfter + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// rateLimiter keeps a sliding log of the admitted request times of each key with an
+// rpm_limit. The log lives in memory only and is per instance.
+type rateLimiter struct {
+	mu   sync.Mutex
+	logs map[string][]time.Time
+}
+
+// admit records a request by keyID at now unless the key already made limit requests
+// in the preceding minute. limit <= 0 means unlimited.
+func (r *rateLimiter) admit(keyID string, limit int, now time.Time) error {
+	if limit <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.logs == nil {
+		r.logs = make(map[string][]time.Time)
+	}
+	log := recentRequests(r.logs[keyID], now)
+	if err := rateLimitError(log, limit, 1, now); err != nil {
+		r.logs[keyID] = log
+		return err
+	}
+	r.logs[keyID] = append(log, now)
+	return nil
+}
+
+// peek reports whether n more requests by keyID would be admitted at now without
+// recording them.
+func (r *rateLimiter) peek(keyID string, limit int, n int64, now time.Time) error {
+	if limit <= 0 {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return rateLimitError(recentRequests(r.logs[keyID], now), limit, n, now)
+}
+
+// recentRequests drops the times of log that left the window at now.
+func recentRequests(log []time.Time, now time.Time) []time.Time {
+	cutoff := now.Add(-rateWindow)
+	expired := 0
+	for expired < len(log) && !log[expired].After(cutoff) {
+		expired++
+	}
+	return log[expired:]
+}
+
+// rateLimitError returns the error for n more requests against the recent log, with
+// the delay until enough of them leave the window. More than limit requests never fit
+// and are told to wait a full window.
+func rateLimitError(log []time.Time, limit int, n int64, now time.Time) error {
+	over := int64(len(log)) + n - int64(limit)
+	if over <= 0 {
+		return nil
+	}
+	if over > int64(len(log)) {
+		return &RateLimitError{RetryAfter: rateWindow}
+	}
+	return &RateLimitError{RetryAfter: log[over-1].Add(rateWindow).Sub(now)}
+}
+
+// forget drops the log of a deleted key.
+func (r *rateLimiter) forget(keyID string) {
+	r.mu.Lock()
+	delete(r.logs, keyID)
+	r.mu.Unlock()
+}
+
+// prune drops the logs of keys without a request in the last minute.
+func (r *rateLimiter) prune(now time.Time) {
+	cutoff := now.Add(-rateWindow)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for keyID, log := range r.logs {
+		if len(log) == 0 || !log[len(log)-1].After(cutoff) {
+			delete(r.logs, keyID)
+		}
+	}
+}
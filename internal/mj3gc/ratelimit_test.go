@@ -0,0 +1,77 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterSlidingWindow(t *testing.T) {
+	var r rateLimiter
+	start := time.Unix(1_700_000_000, 0)
+	for i := 0; i < 3; i++ {
+		if err := r.admit("k", 3, start.Add(time.Duration(i)*10*time.Second)); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	err := r.admit("k", 3, start.Add(30*time.Second))
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("fourth request = %v, want ErrRateLimited", err)
+	}
+	if got := RetryAfterSeconds(err); got != 30 {
+		t.Fatalf("RetryAfterSeconds = %d, want 30", got)
+	}
+	// The first request leaves the window one minute after it was made.
+	if err := r.admit("k", 3, start.Add(time.Minute+time.Second)); err != nil {
+		t.Fatalf("request after the window slid: %v", err)
+	}
+	if err := r.admit("other", 3, start.Add(30*time.Second)); err != nil {
+		t.Fatalf("other key: %v", err)
+	}
+	if err := r.admit("k", 0, start.Add(30*time.Second)); err != nil {
+		t.Fatalf("unlimited: %v", err)
+	}
+
+	r.prune(start.Add(3 * time.Minute))
+	if len(r.logs) != 0 {
+		t.Fatalf("prune kept %d idle logs", len(r.logs))
+	}
+}
+
+func TestBeginRequestRPMLimit(t *testing.T) {
+	s := NewStore()
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-rpm-limited", Enabled: true, RPMLimit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := s.BeginRequest(key.Key); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		s.EndRequest(key.Key, true, "")
+	}
+	_, err = s.BeginRequest(key.Key)
+	if !errors.Is(err, ErrRateLimited) || RetryAfterSeconds(err) < 1 {
+		t.Fatalf("third request = %v, want ErrRateLimited with a retry delay", err)
+	}
+}
+
+func TestRejectedRequestsKeepTheRateWindow(t *testing.T) {
+	s := NewStore()
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-rpm-busy", Enabled: true, RPMLimit: 2, ConcurrencyLimit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.BeginRequest(key.Key); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := s.BeginRequest(key.Key); !errors.Is(err, ErrConcurrencyExceeded) {
+			t.Fatalf("busy request %d = %v, want ErrConcurrencyExceeded", i, err)
+		}
+	}
+	s.EndRequest(key.Key, true, "")
+	if _, err := s.BeginRequest(key.Key); err != nil {
+		t.Fatalf("request after the slot freed = %v; rejected requests used the rate window", err)
+	}
+}
@@ -0,0 +1,183 @@
+package mj3gc
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redisPoolSize = 8
+
+var errRedisNil = errors.New("redis: nil")
+
+// redisClient is a minimal RESP2 client covering the handful of commands used for
+// shared counters. Connections are pooled and re-dialled after any I/O error.
+type redisClient struct {
+	addr     string
+	password string
+	db       int
+	timeout  time.Duration
+	pool     chan *redisConn
+}
+
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+func newRedisClient(addr, password string, db int) *redisClient {
+	return &redisClient{
+		addr:     addr,
+		password: password,
+		db:       db,
+		timeout:  5 * time.Second,
+		pool:     make(chan *redisConn, redisPoolSize),
+	}
+}
+
+func (c *redisClient) dial() (*redisConn, error) {
+	conn, err := net.DialTimeout("tcp", c.addr, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	rc := &redisConn{conn: conn, rd: bufio.NewReader(conn)}
+	if c.password != "" {
+		if _, err := rc.do(c.timeout, "AUTH", c.password); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if c.db != 0 {
+		if _, err := rc.do(c.timeout, "SELECT", strconv.Itoa(c.db)); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	return rc, nil
+}
+
+// do runs a single command and returns its decoded reply.
+func (c *redisClient) do(args ...string) (any, error) {
+	var rc *redisConn
+	select {
+	case rc = <-c.pool:
+	default:
+		var err error
+		if rc, err = c.dial(); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := rc.do(c.timeout, args...)
+	var replyErr redisError
+	if err != nil && !errors.As(err, &replyErr) && !errors.Is(err, errRedisNil) {
+		_ = rc.conn.Close()
+		return nil, err
+	}
+	select {
+	case c.pool <- rc:
+	default:
+		_ = rc.conn.Close()
+	}
+	return reply, err
+}
+
+func (c *redisClient) int(args ...string) (int64, error) {
+	reply, err := c.do(args...)
+	if err != nil {
+		return 0, err
+	}
+	switch v := reply.(type) {
+	case int64:
+		return v, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("redis: unexpected reply %T", reply)
+	}
+}
+
+func (c *redisClient) Close() error {
+	for {
+		select {
+		case rc := <-c.pool:
+			_ = rc.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+func (rc *redisConn) do(timeout time.Duration, args ...string) (any, error) {
+	if err := rc.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	sb.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		sb.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n" + arg + "\r\n")
+	}
+	if _, err := io.WriteString(rc.conn, sb.String()); err != nil {
+		return nil, err
+	}
+	return rc.readReply()
+}
+
+func (rc *redisConn) readReply() (any, error) {
+	line, err := rc.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rc.rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, errRedisNil
+		}
+		items := make([]any, 0, n)
+		for i := 0; i < n; i++ {
+			item, err := rc.readReply()
+			if err != nil && !errors.Is(err, errRedisNil) {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply prefix %q", line[0])
+	}
+}
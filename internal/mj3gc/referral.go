@@ -0,0 +1,118 @@
+package mj3gc
+
+import (
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReferralSummary reports the sign-ups attributed to a user's referral code.
+type ReferralSummary struct {
+	Code            string   `json:"code"`
+	UserID          string   `json:"user_id"`
+	Username        string   `json:"username"`
+	SignUps         int      `json:"sign_ups"`
+	ReferredUserIDs []string `json:"referred_user_ids"`
+	UsedCount       int64    `json:"used_count"`
+}
+
+// FindUserByReferralCode returns the user owning the given referral code.
+func (s *Store) FindUserByReferralCode(code string) (User, bool) {
+	if s == nil {
+		return User{}, false
+	}
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return User{}, false
+	}
+	defer s.rlock("FindUserByReferralCode")()
+	for _, u := range s.data.Users {
+		if strings.EqualFold(u.ReferralCode, code) {
+			return u, true
+		}
+	}
+	return User{}, false
+}
+
+// GrantReferralBonus adds amount to the total limit of the referrer's oldest enabled, limited key.
+// Keys without a limit are skipped because they cannot benefit from extra quota.
+func (s *Store) GrantReferralBonus(referrerID string, amount int64) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidConfiguration
+	}
+	if amount <= 0 {
+		return APIKey{}, fmt.Errorf("invalid bonus amount")
+	}
+	defer s.lock("GrantReferralBonus")()
+	target := -1
+	for i, k := range s.data.APIKeys {
+		if k.UserID != referrerID || !k.Enabled || k.TotalLimit <= 0 {
+			continue
+		}
+		if target < 0 || k.CreatedAt.Before(s.data.APIKeys[target].CreatedAt) {
+			target = i
+		}
+	}
+	if target < 0 {
+		return APIKey{}, ErrKeyNotFound
+	}
+	s.data.APIKeys[target].closeLimitPeriod(time.Now())
+	s.data.APIKeys[target].TotalLimit += amount
+	return s.data.APIKeys[target], nil
+}
+
+// ReferralSummaries aggregates sign-ups and request usage per referral code.
+// Only users that referred at least one sign-up are included.
+func (s *Store) ReferralSummaries() []ReferralSummary {
+	if s == nil {
+		return nil
+	}
+	defer s.rlock("ReferralSummaries")()
+
+	byReferrer := make(map[string]*ReferralSummary)
+	referrerOf := make(map[string]string)
+	for _, u := range s.data.Users {
+		if u.ReferredBy == "" {
+			continue
+		}
+		summary, ok := byReferrer[u.ReferredBy]
+		if !ok {
+			summary = &ReferralSummary{UserID: u.ReferredBy}
+			byReferrer[u.ReferredBy] = summary
+		}
+		summary.SignUps++
+		summary.ReferredUserIDs = append(summary.ReferredUserIDs, u.ID)
+		referrerOf[u.ID] = u.ReferredBy
+	}
+	for _, u := range s.data.Users {
+		if summary, ok := byReferrer[u.ID]; ok {
+			summary.Code = u.ReferralCode
+			summary.Username = u.Username
+		}
+	}
+	for _, k := range s.data.APIKeys {
+		if referrer, ok := referrerOf[k.UserID]; ok {
+			byReferrer[referrer].UsedCount += k.UsedCount
+		}
+	}
+
+	out := make([]ReferralSummary, 0, len(byReferrer))
+	for _, summary := range byReferrer {
+		out = append(out, *summary)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].SignUps != out[j].SignUps {
+			return out[i].SignUps > out[j].SignUps
+		}
+		return out[i].UserID < out[j].UserID
+	})
+	return out
+}
+
+func newReferralCode() string {
+	buf := make([]byte, 5)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%X", buf)
+}
@@ -0,0 +1,136 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const storageControlPlane = "control-plane"
+
+// OpenControlPlaneBackend reads users and keys from a control plane instance at
+// settings.ControlPlaneURL, the server that runs management and the portal and owns
+// the data. Usage is reported back as increments; configuration changes must be made
+// on the control plane, so stores using this backend are read-only.
+func OpenControlPlaneBackend(settings config.MJ3GCConfig) (Backend, error) {
+	endpoint := strings.TrimRight(strings.TrimSpace(settings.ControlPlaneURL), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("mj3gc: control-plane-url is required for control-plane storage")
+	}
+	if settings.ControlPlaneToken == "" {
+		return nil, fmt.Errorf("mj3gc: control-plane-token is required for control-plane storage")
+	}
+	return &controlPlaneBackend{endpoint: endpoint, token: settings.ControlPlaneToken, client: &http.Client{}}, nil
+}
+
+// controlPlaneBackend is the client side of the /internal/mj3gc API.
+type controlPlaneBackend struct {
+	endpoint string
+	token    string
+	client   *http.Client
+
+	mu       sync.Mutex
+	revision int64
+}
+
+// do sends a request to the control plane and decodes a 200 response into out. It
+// returns the response status and headers.
+func (b *controlPlaneBackend) do(ctx context.Context, method, path string, body any, out any) (int, http.Header, error) {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.endpoint+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+b.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= http.StatusBadRequest {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, resp.Header, fmt.Errorf("control plane %s %s returned %d: %s", method, path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	if out != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, resp.Header, err
+		}
+	}
+	return resp.StatusCode, resp.Header, nil
+}
+
+func (b *controlPlaneBackend) Load() (Data, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvTimeout)
+	defer cancel()
+	var data Data
+	_, header, err := b.do(ctx, http.MethodGet, "/internal/mj3gc/data", nil, &data)
+	if err != nil {
+		return Data{}, err
+	}
+	revision, _ := strconv.ParseInt(header.Get(controlPlaneRevisionHeader), 10, 64)
+	b.mu.Lock()
+	b.revision = revision
+	b.mu.Unlock()
+	return data, nil
+}
+
+// Save always fails: the control plane owns the data.
+func (b *controlPlaneBackend) Save(Data) error {
+	return ErrReadOnly
+}
+
+func (b *controlPlaneBackend) IncrementUsage(keyID string, delta int64) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kvTimeout)
+	defer cancel()
+	var out struct {
+		UsedCount int64 `json:"used_count"`
+	}
+	if _, _, err := b.do(ctx, http.MethodPost, "/internal/mj3gc/usage", usageDelta{KeyID: keyID, Delta: delta}, &out); err != nil {
+		return 0, err
+	}
+	return out.UsedCount, nil
+}
+
+func (b *controlPlaneBackend) ResetUsage(keyID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), kvTimeout)
+	defer cancel()
+	_, _, err := b.do(ctx, http.MethodPost, "/internal/mj3gc/usage/reset", usageDelta{KeyID: keyID}, nil)
+	return err
+}
+
+// WaitForChange long-polls the control plane until its revision moves past the one
+// seen by the last Load.
+func (b *controlPlaneBackend) WaitForChange(ctx context.Context) error {
+	b.mu.Lock()
+	revision := b.revision
+	b.mu.Unlock()
+	path := "/internal/mj3gc/revision?after=" + url.QueryEscape(strconv.FormatInt(revision, 10))
+	for {
+		status, _, err := b.do(ctx, http.MethodGet, path, nil, nil)
+		if err != nil {
+			return err
+		}
+		if status == http.StatusOK {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package mj3gc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Delivery channels of a ReportSubscription.
+const (
+	ReportEmail   = "email"
+	ReportWebhook = "webhook"
+)
+
+const (
+	// maxReportSubscriptions bounds the subscriptions of one user.
+	maxReportSubscriptions = 10
+	// reportTopModels is how many models a report lists when top models are requested.
+	reportTopModels = 5
+	defaultSMTPPort = 587
+)
+
+var (
+	ErrReportNotFound = errors.New("report subscription not found")
+	ErrInvalidReport  = errors.New("invalid report subscription")
+)
+
+// ReportContent selects what a usage report includes besides the request count.
+type ReportContent struct {
+	Tokens    bool `json:"tokens"`
+	Cost      bool `json:"cost"`
+	TopModels bool `json:"top_models"`
+}
+
+// ReportSubscription sends a portal user a summary of the usage of their keys after
+// every completed week (from Monday) or calendar month, in UTC. Target is an email
+// address or a webhook URL depending on Channel.
+type ReportSubscription struct {
+	ID        string        `json:"id"`
+	UserID    string        `json:"user_id"`
+	Frequency string        `json:"frequency"`
+	Channel   string        `json:"channel"`
+	Target    string        `json:"target"`
+	Content   ReportContent `json:"content"`
+	CreatedAt time.Time     `json:"created_at"`
+	// SentUntil is the end of the last period reported. Failed deliveries leave it
+	// unchanged so the next maintenance run tries again.
+	SentUntil  time.Time `json:"sent_until,omitempty"`
+	LastSentAt time.Time `json:"last_sent_at,omitempty"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// UsageReport is the usage of one user's keys over a report period. Tokens, Cost and
+// TopModels are only set when the subscription asks for them.
+type UsageReport struct {
+	SubscriptionID string             `json:"subscription_id"`
+	UserID         string             `json:"user_id"`
+	Username       string             `json:"username"`
+	Frequency      string             `json:"frequency"`
+	From           time.Time          `json:"from"`
+	To             time.Time          `json:"to"`
+	Keys           int                `json:"keys"`
+	Requests       int64              `json:"requests"`
+	Failed         int64              `json:"failed"`
+	Tokens         *ReportTokens      `json:"tokens,omitempty"`
+	Cost           *float64           `json:"cost,omitempty"`
+	Currency       string             `json:"currency,omitempty"`
+	TopModels      []ReportModelUsage `json:"top_models,omitempty"`
+}
+
+// ReportTokens totals the tokens of a report period.
+type ReportTokens struct {
+	Input  int64 `json:"input"`
+	Output int64 `json:"output"`
+	Total  int64 `json:"total"`
+}
+
+// ReportModelUsage is the usage of one model in a report period.
+type ReportModelUsage struct {
+	Model    string `json:"model"`
+	Requests int64  `json:"requests"`
+	Tokens   int64  `json:"tokens"`
+}
+
+// ReportPeriod returns the last complete week (Monday to Monday) or calendar month
+// before now, in UTC.
+func ReportPeriod(frequency string, now time.Time) (from, to time.Time) {
+	now = now.UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if frequency == ResetMonthly {
+		to = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return to.AddDate(0, -1, 0), to
+	}
+	to = day.AddDate(0, 0, -((int(day.Weekday()) - int(time.Monday) + 7) % 7))
+	return to.AddDate(0, 0, -7), to
+}
+
+// normalizeReportSubscription validates sub against the current settings.
+func normalizeReportSubscription(settings config.MJ3GCConfig, sub ReportSubscription) (ReportSubscription, error) {
+	sub.Frequency = strings.ToLower(strings.TrimSpace(sub.Frequency))
+	sub.Channel = strings.ToLower(strings.TrimSpace(sub.Channel))
+	sub.Target = strings.TrimSpace(sub.Target)
+	if sub.Frequency != ResetWeekly && sub.Frequency != ResetMonthly {
+		return sub, fmt.Errorf("%w: frequency must be weekly or monthly", ErrInvalidReport)
+	}
+	switch sub.Channel {
+	case ReportEmail:
+		if strings.TrimSpace(settings.SMTP.Host) == "" {
+			return sub, fmt.Errorf("%w: email delivery is not configured", ErrInvalidReport)
+		}
+		if addr, err := mail.ParseAddress(sub.Target); err != nil || addr.Address != sub.Target {
+			return sub, fmt.Errorf("%w: invalid email address", ErrInvalidReport)
+		}
+	case ReportWebhook:
+		if err := ValidateShadowURL(sub.Target); err != nil {
+			return sub, fmt.Errorf("%w: %v", ErrInvalidReport, err)
+		}
+	default:
+		return sub, fmt.Errorf("%w: channel must be email or webhook", ErrInvalidReport)
+	}
+	return sub, nil
+}
+
+// CreateReportSubscription subscribes the user sub.UserID to usage reports. The first
+// report covers the first period that ends after the subscription is made.
+func (s *Store) CreateReportSubscription(sub ReportSubscription) (ReportSubscription, error) {
+	if s == nil {
+		return ReportSubscription{}, ErrInvalidConfiguration
+	}
+	sub, err := normalizeReportSubscription(s.Settings(), sub)
+	if err != nil {
+		return ReportSubscription{}, err
+	}
+	now := time.Now().UTC()
+	sub.ID = newID("rpt")
+	sub.CreatedAt = now
+	_, sub.SentUntil = ReportPeriod(sub.Frequency, now)
+	sub.LastSentAt = time.Time{}
+	sub.LastError = ""
+	defer s.lock("CreateReportSubscription")()
+	if _, ok := s.userIDIndexLocked(sub.UserID); !ok {
+		return ReportSubscription{}, ErrUserNotFound
+	}
+	count := 0
+	for _, existing := range s.data.ReportSubscriptions {
+		if existing.UserID == sub.UserID {
+			count++
+		}
+	}
+	if count >= maxReportSubscriptions {
+		return ReportSubscription{}, fmt.Errorf("%w: at most %d subscriptions per user", ErrInvalidReport, maxReportSubscriptions)
+	}
+	s.data.ReportSubscriptions = append(s.data.ReportSubscriptions, sub)
+	return sub, nil
+}
+
+// ListReportSubscriptions returns the report subscriptions of userID, oldest first.
+func (s *Store) ListReportSubscriptions(userID string) []ReportSubscription {
+	if s == nil {
+		return nil
+	}
+	defer s.rlock("ListReportSubscriptions")()
+	out := make([]ReportSubscription, 0)
+	for _, sub := range s.data.ReportSubscriptions {
+		if sub.UserID == userID {
+			out = append(out, sub)
+		}
+	}
+	return out
+}
+
+// UpdateReportSubscription replaces the frequency, channel, target and content of the
+// subscription sub.ID owned by sub.UserID. Changing the frequency restarts the schedule.
+func (s *Store) UpdateReportSubscription(sub ReportSubscription) (ReportSubscription, error) {
+	if s == nil {
+		return ReportSubscription{}, ErrInvalidConfiguration
+	}
+	sub, err := normalizeReportSubscription(s.Settings(), sub)
+	if err != nil {
+		return ReportSubscription{}, err
+	}
+	defer s.lock("UpdateReportSubscription")()
+	for i, existing := range s.data.ReportSubscriptions {
+		if existing.ID != sub.ID || existing.UserID != sub.UserID {
+			continue
+		}
+		sub.CreatedAt = existing.CreatedAt
+		sub.SentUntil = existing.SentUntil
+		sub.LastSentAt = existing.LastSentAt
+		sub.LastError = existing.LastError
+		if sub.Frequency != existing.Frequency {
+			_, sub.SentUntil = ReportPeriod(sub.Frequency, time.Now())
+		}
+		s.data.ReportSubscriptions[i] = sub
+		return sub, nil
+	}
+	return ReportSubscription{}, ErrReportNotFound
+}
+
+// DeleteReportSubscription unsubscribes userID from the subscription id.
+func (s *Store) DeleteReportSubscription(userID, id string) error {
+	if s == nil {
+		return ErrInvalidConfiguration
+	}
+	defer s.lock("DeleteReportSubscription")()
+	for i, sub := range s.data.ReportSubscriptions {
+		if sub.ID == id && sub.UserID == userID {
+			s.data.ReportSubscriptions = append(s.data.ReportSubscriptions[:i:i], s.data.ReportSubscriptions[i+1:]...)
+			return nil
+		}
+	}
+	return ErrReportNotFound
+}
+
+// BuildUsageReport summarises the requests of keys recorded in snapshot between from
+// and to for sub.
+func BuildUsageReport(settings config.MJ3GCConfig, sub ReportSubscription, user User, keys []APIKey, snapshot usage.StatisticsSnapshot, from, to time.Time) UsageReport {
+	report := UsageReport{
+		SubscriptionID: sub.ID,
+		UserID:         user.ID,
+		Username:       user.Username,
+		Frequency:      sub.Frequency,
+		From:           from,
+		To:             to,
+		Keys:           len(keys),
+	}
+	tokens := ReportTokens{}
+	cost := 0.0
+	models := make(map[string]*ReportModelUsage)
+	for _, key := range keys {
+		for model, stats := range snapshot.APIs[key.Key].Models {
+			for _, detail := range stats.Details {
+				if detail.Timestamp.Before(from) || !detail.Timestamp.Before(to) {
+					continue
+				}
+				report.Requests++
+				if detail.Failed {
+					report.Failed++
+				}
+				tokens.Input += detail.Tokens.InputTokens
+				tokens.Output += detail.Tokens.OutputTokens
+				tokens.Total += detail.Tokens.TotalTokens
+				cost += EstimateCost(settings.ModelPrices, RequestSample{
+					Timestamp:       detail.Timestamp,
+					Model:           model,
+					InputTokens:     detail.Tokens.InputTokens,
+					OutputTokens:    detail.Tokens.OutputTokens,
+					ReasoningTokens: detail.Tokens.ReasoningTokens,
+					CachedTokens:    detail.Tokens.CachedTokens,
+					TotalTokens:     detail.Tokens.TotalTokens,
+				})
+				entry := models[model]
+				if entry == nil {
+					entry = &ReportModelUsage{Model: model}
+					models[model] = entry
+				}
+				entry.Requests++
+				entry.Tokens += detail.Tokens.TotalTokens
+			}
+		}
+	}
+	if sub.Content.Tokens {
+		report.Tokens = &tokens
+	}
+	if sub.Content.Cost {
+		report.Cost = &cost
+		report.Currency = Currency(settings)
+	}
+	if sub.Content.TopModels {
+		report.TopModels = make([]ReportModelUsage, 0, len(models))
+		for _, entry := range models {
+			report.TopModels = append(report.TopModels, *entry)
+		}
+		sort.Slice(report.TopModels, func(i, j int) bool {
+			a, b := report.TopModels[i], report.TopModels[j]
+			if a.Tokens != b.Tokens {
+				return a.Tokens > b.Tokens
+			}
+			if a.Requests != b.Requests {
+				return a.Requests > b.Requests
+			}
+			return a.Model < b.Model
+		})
+		if len(report.TopModels) > reportTopModels {
+			report.TopModels = report.TopModels[:reportTopModels]
+		}
+	}
+	return report
+}
+
+// SendDueReports delivers the reports of every subscription whose period has ended
+// since its last report and returns how many were sent. Subscriptions of disabled or
+// deleted users are skipped.
+func (s *Store) SendDueReports(now time.Time) int {
+	if s == nil || s.ReadOnly() {
+		return 0
+	}
+	type dueReport struct {
+		sub  ReportSubscription
+		user User
+		keys []APIKey
+	}
+	var due []dueReport
+	func() {
+		defer s.rlock("SendDueReports")()
+		for _, sub := range s.data.ReportSubscriptions {
+			if _, to := ReportPeriod(sub.Frequency, now); !sub.SentUntil.Before(to) {
+				continue
+			}
+			i, ok := s.userIDIndexLocked(sub.UserID)
+			if !ok || s.data.Users[i].Disabled {
+				continue
+			}
+			item := dueReport{sub: sub, user: s.data.Users[i]}
+			for _, key := range s.data.APIKeys {
+				if key.UserID == sub.UserID {
+					item.keys = append(item.keys, key)
+				}
+			}
+			due = append(due, item)
+		}
+	}()
+	if len(due) == 0 {
+		return 0
+	}
+	settings := s.Settings()
+	snapshot := usage.GetRequestStatistics().Snapshot()
+	sent := 0
+	for _, item := range due {
+		from, to := ReportPeriod(item.sub.Frequency, now)
+		report := BuildUsageReport(settings, item.sub, item.user, item.keys, snapshot, from, to)
+		err := deliverReport(settings, item.sub, report)
+		if err != nil {
+			log.Warnf("mj3gc: usage report %s not delivered to %s: %v", item.sub.ID, item.sub.Target, err)
+		} else {
+			sent++
+		}
+		s.recordReportDelivery(item.sub.ID, to, now, err)
+	}
+	return sent
+}
+
+func (s *Store) recordReportDelivery(id string, until, at time.Time, err error) {
+	defer s.lock("recordReportDelivery")()
+	for i := range s.data.ReportSubscriptions {
+		sub := &s.data.ReportSubscriptions[i]
+		if sub.ID != id {
+			continue
+		}
+		if err != nil {
+			sub.LastError = err.Error()
+			return
+		}
+		sub.SentUntil = until
+		sub.LastSentAt = at.UTC()
+		sub.LastError = ""
+		return
+	}
+}
+
+func deliverReport(settings config.MJ3GCConfig, sub ReportSubscription, report UsageReport) error {
+	if sub.Channel == ReportEmail {
+		return mailReport(settings.SMTP, sub.Target, report)
+	}
+	payload, err := json.Marshal(map[string]any{"type": "usage_report", "report": report})
+	if err != nil {
+		return err
+	}
+	return postWebhook(sub.Target, payload)
+}
+
+// mailReport sends report as a plain-text email through the configured mail server.
+func mailReport(server config.MJ3GCSMTP, to string, report UsageReport) error {
+	subject := fmt.Sprintf("Your %s usage report, %s to %s", report.Frequency,
+		report.From.Format("2006-01-02"), report.To.AddDate(0, 0, -1).Format("2006-01-02"))
+	return sendMail(server, to, subject, FormatUsageReport(report))
+}
+
+// sendMail sends a plain-text email through the configured mail server.
+func sendMail(server config.MJ3GCSMTP, to, subject, body string) error {
+	host := strings.TrimSpace(server.Host)
+	if host == "" {
+		return fmt.Errorf("email delivery is not configured")
+	}
+	port := server.Port
+	if port <= 0 {
+		port = defaultSMTPPort
+	}
+	from := strings.TrimSpace(server.From)
+	if from == "" {
+		from = server.Username
+	}
+	var auth smtp.Auth
+	if server.Username != "" {
+		auth = smtp.PlainAuth("", server.Username, server.Password, host)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\n", from, to, subject, time.Now().UTC().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return smtp.SendMail(net.JoinHostPort(host, strconv.Itoa(port)), auth, from, []string{to}, []byte(msg.String()))
+}
+
+// FormatUsageReport renders report as plain text.
+func FormatUsageReport(report UsageReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Usage of %s from %s to %s (UTC)\n\n", report.Username,
+		report.From.Format("2006-01-02"), report.To.AddDate(0, 0, -1).Format("2006-01-02"))
+	fmt.Fprintf(&b, "Keys: %d\nRequests: %d (%d failed)\n", report.Keys, report.Requests, report.Failed)
+	if report.Tokens != nil {
+		fmt.Fprintf(&b, "Tokens: %d (%d input, %d output)\n", report.Tokens.Total, report.Tokens.Input, report.Tokens.Output)
+	}
+	if report.Cost != nil {
+		fmt.Fprintf(&b, "Estimated cost: %.2f %s\n", *report.Cost, report.Currency)
+	}
+	if len(report.TopModels) > 0 {
+		b.WriteString("\nTop models:\n")
+		for _, model := range report.TopModels {
+			fmt.Fprintf(&b, "  %s: %d requests, %d tokens\n", model.Model, model.Requests, model.Tokens)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,115 @@
+package mj3gc
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/usage"
+)
+
+func TestReportPeriod(t *testing.T) {
+	now := time.Date(2026, 3, 12, 15, 0, 0, 0, time.UTC) // a Thursday
+	from, to := ReportPeriod(ResetWeekly, now)
+	if want := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC); !from.Equal(want) || !to.Equal(want.AddDate(0, 0, 7)) {
+		t.Fatalf("weekly period = %v - %v", from, to)
+	}
+	from, to = ReportPeriod(ResetMonthly, now)
+	if !from.Equal(time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("monthly period = %v - %v", from, to)
+	}
+}
+
+func TestBuildUsageReport(t *testing.T) {
+	from := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	detail := func(at time.Time, tokens int64, failed bool) usage.RequestDetail {
+		return usage.RequestDetail{Timestamp: at, Failed: failed, Tokens: usage.TokenStats{InputTokens: tokens / 2, OutputTokens: tokens / 2, TotalTokens: tokens}}
+	}
+	snapshot := usage.StatisticsSnapshot{APIs: map[string]usage.APISnapshot{
+		"sk-a": {Models: map[string]usage.ModelSnapshot{
+			"gpt-4o":   {Details: []usage.RequestDetail{detail(from.Add(time.Hour), 1000, false), detail(to, 5000, false)}},
+			"claude-3": {Details: []usage.RequestDetail{detail(from.Add(2*time.Hour), 3000, true)}},
+		}},
+		"sk-other": {Models: map[string]usage.ModelSnapshot{
+			"gpt-4o": {Details: []usage.RequestDetail{detail(from.Add(time.Hour), 9000, false)}},
+		}},
+	}}
+	settings := config.MJ3GCConfig{ModelPrices: []config.MJ3GCModelPrice{{Model: "gpt-4o", InputPerMillion: 1000, OutputPerMillion: 1000}}}
+	keys := []APIKey{{ID: "k1", Key: "sk-a"}}
+	user := User{ID: "u1", Username: "alice"}
+
+	report := BuildUsageReport(settings, ReportSubscription{Frequency: ResetWeekly}, user, keys, snapshot, from, to)
+	if report.Requests != 2 || report.Failed != 1 || report.Tokens != nil || report.Cost != nil || report.TopModels != nil {
+		t.Fatalf("plain report = %+v", report)
+	}
+
+	sub := ReportSubscription{Frequency: ResetWeekly, Content: ReportContent{Tokens: true, Cost: true, TopModels: true}}
+	report = BuildUsageReport(settings, sub, user, keys, snapshot, from, to)
+	if report.Tokens == nil || report.Tokens.Total != 4000 {
+		t.Fatalf("tokens = %+v", report.Tokens)
+	}
+	if report.Cost == nil || *report.Cost != 1 || report.Currency == "" {
+		t.Fatalf("cost = %v %s", report.Cost, report.Currency)
+	}
+	if len(report.TopModels) != 2 || report.TopModels[0].Model != "claude-3" {
+		t.Fatalf("top models = %+v", report.TopModels)
+	}
+}
+
+func TestReportSubscriptions(t *testing.T) {
+	var received []map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&payload)
+		received = append(received, payload)
+	}))
+	defer server.Close()
+
+	s := NewStore()
+	user, err := s.UpsertUser(User{Username: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateReportSubscription(ReportSubscription{UserID: user.ID, Frequency: ResetWeekly, Channel: ReportEmail, Target: "alice@example.com"}); !errors.Is(err, ErrInvalidReport) {
+		t.Fatalf("email without smtp = %v", err)
+	}
+	if _, err := s.CreateReportSubscription(ReportSubscription{UserID: user.ID, Frequency: "daily", Channel: ReportWebhook, Target: server.URL}); !errors.Is(err, ErrInvalidReport) {
+		t.Fatalf("daily frequency = %v", err)
+	}
+	sub, err := s.CreateReportSubscription(ReportSubscription{UserID: user.ID, Frequency: ResetWeekly, Channel: ReportWebhook, Target: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if sent := s.SendDueReports(now); sent != 0 {
+		t.Fatalf("sent %d reports before the first period ended", sent)
+	}
+	nextWeek := now.AddDate(0, 0, 7)
+	if sent := s.SendDueReports(nextWeek); sent != 1 || len(received) != 1 || received[0]["type"] != "usage_report" {
+		t.Fatalf("sent %d, received %v", sent, received)
+	}
+	if sent := s.SendDueReports(nextWeek); sent != 0 {
+		t.Fatalf("report sent twice for one period")
+	}
+	if got := s.ListReportSubscriptions(user.ID); len(got) != 1 || got[0].LastSentAt.IsZero() {
+		t.Fatalf("subscriptions = %+v", got)
+	}
+
+	sub.Frequency = ResetMonthly
+	sub.UserID = "someone-else"
+	if _, err := s.UpdateReportSubscription(sub); !errors.Is(err, ErrReportNotFound) {
+		t.Fatalf("update of another user's subscription = %v", err)
+	}
+	if err := s.DeleteReportSubscription(user.ID, sub.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.ListReportSubscriptions(user.ID); len(got) != 0 {
+		t.Fatalf("subscriptions after delete = %+v", got)
+	}
+}
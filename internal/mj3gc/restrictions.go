@@ -0,0 +1,79 @@
+package mj3gc
+
+import (
+	"net/url"
+	"strings"
+)
+
+// AllowsUserAgent reports whether the key may be used by a client sending userAgent.
+// Keys without a User-Agent allowlist accept any client.
+func (k APIKey) AllowsUserAgent(userAgent string) bool {
+	if len(k.AllowedUserAgents) == 0 {
+		return true
+	}
+	return matchAny(k.AllowedUserAgents, userAgent)
+}
+
+// AllowsOrigin reports whether a browser request with the given Origin and Referer
+// headers may use the key. Patterns match the origin ("https://app.example.com",
+// "https://*.example.com") or, without a scheme, just its host ("*.example.com"). The
+// origin is taken from Origin, falling back to the scheme and host of Referer; paths
+// are ignored so a crafted Referer cannot satisfy a host pattern. Keys without
+// allowed_origins accept any request, keys with them reject requests carrying neither
+// header.
+func (k APIKey) AllowsOrigin(origin, referer string) bool {
+	if len(k.AllowedOrigins) == 0 {
+		return true
+	}
+	scheme, host := parseOrigin(origin)
+	if host == "" {
+		scheme, host = parseOrigin(referer)
+	}
+	if host == "" {
+		return false
+	}
+	for _, pattern := range k.AllowedOrigins {
+		pattern = strings.TrimSuffix(pattern, "/")
+		if strings.Contains(pattern, "://") {
+			if matchPattern(pattern, scheme+"://"+host) {
+				return true
+			}
+		} else if matchPattern(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseOrigin returns the scheme and host (with port) of an Origin or Referer value.
+func parseOrigin(value string) (string, string) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "null" {
+		return "", ""
+	}
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil {
+		return "", ""
+	}
+	return strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+}
+
+// AllowsModel reports whether the key may request model: it must match the allowlist,
+// when the key has one, and must not match the deny list, which is evaluated after it.
+// Patterns may use '*' wildcards such as "gpt-4*".
+func (k APIKey) AllowsModel(model string) bool {
+	if len(k.AllowedModels) > 0 && !matchAny(k.AllowedModels, model) {
+		return false
+	}
+	return !k.DeniesModel(model)
+}
+
+// DeniesModel reports whether model matches the key's deny list.
+func (k APIKey) DeniesModel(model string) bool {
+	return len(k.DeniedModels) > 0 && matchAny(k.DeniedModels, model)
+}
+
+// restrictsModels reports whether the key has an allowlist or a deny list of models.
+func (k APIKey) restrictsModels() bool {
+	return len(k.AllowedModels) > 0 || len(k.DeniedModels) > 0
+}
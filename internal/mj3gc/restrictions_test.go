@@ -0,0 +1,94 @@
+package mj3gc
+
+import "testing"
+
+func TestAPIKeyAllowsUserAgent(t *testing.T) {
+	cases := []struct {
+		name     string
+		patterns []string
+		ua       string
+		want     bool
+	}{
+		{"no_allowlist", nil, "curl/8.0", true},
+		{"exact_match", []string{"MyApp/1.2"}, "MyApp/1.2", true},
+		{"case_insensitive", []string{"myapp/*"}, "MyApp/1.2 (Linux)", true},
+		{"prefix_wildcard", []string{"MyApp/*"}, "curl/8.0", false},
+		{"middle_wildcard", []string{"MyApp/* (Windows*)"}, "MyApp/2.0 (Windows NT 10.0)", true},
+		{"any_pattern", []string{"Other/*", "MyApp/*"}, "MyApp/3", true},
+		{"empty_user_agent", []string{"MyApp/*"}, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			key := APIKey{AllowedUserAgents: tc.patterns}
+			if got := key.AllowsUserAgent(tc.ua); got != tc.want {
+				t.Fatalf("AllowsUserAgent(%q) = %v, want %v", tc.ua, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAllowsModel(t *testing.T) {
+	key := APIKey{AllowedModels: []string{"gpt-4*", "claude-sonnet-4"}}
+	for model, want := range map[string]bool{
+		"gpt-4o":          true,
+		"GPT-4.1-mini":    true,
+		"claude-sonnet-4": true,
+		"claude-opus-4":   false,
+		"gpt-3.5-turbo":   false,
+		"":                false,
+	} {
+		if got := key.AllowsModel(model); got != want {
+			t.Errorf("AllowsModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+	if !(APIKey{}).AllowsModel("anything") {
+		t.Error("key without an allowlist rejected a model")
+	}
+}
+
+func TestAPIKeyDeniedModels(t *testing.T) {
+	key := APIKey{AllowedModels: []string{"gpt-*"}, DeniedModels: []string{"*-reasoning*", "gpt-4.5"}}
+	for model, want := range map[string]bool{
+		"gpt-4o":             true,
+		"gpt-4.5":            false,
+		"gpt-5-reasoning":    false,
+		"claude-sonnet-4":    false,
+		"o3-reasoning-large": false,
+	} {
+		if got := key.AllowsModel(model); got != want {
+			t.Errorf("AllowsModel(%q) = %v, want %v", model, got, want)
+		}
+	}
+	denyOnly := APIKey{DeniedModels: []string{"o1*"}}
+	if !denyOnly.AllowsModel("gpt-4o") || denyOnly.AllowsModel("o1-pro") {
+		t.Error("deny list without an allowlist misapplied")
+	}
+}
+
+func TestAPIKeyAllowsOrigin(t *testing.T) {
+	key := APIKey{AllowedOrigins: []string{"https://app.example.com/", "https://*.example.org", "localhost:*"}}
+	cases := []struct {
+		name, origin, referer string
+		want                  bool
+	}{
+		{"exact_origin", "https://app.example.com", "", true},
+		{"wrong_scheme", "http://app.example.com", "", false},
+		{"wildcard_subdomain", "https://docs.example.org", "", true},
+		{"host_pattern", "http://localhost:5173", "", true},
+		{"referer_fallback", "", "https://app.example.com/settings?tab=keys", true},
+		{"referer_path_ignored", "", "https://evil.test/https://app.example.com", false},
+		{"origin_wins_over_referer", "https://evil.test", "https://app.example.com/", false},
+		{"no_headers", "", "", false},
+		{"opaque_origin", "null", "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := key.AllowsOrigin(tc.origin, tc.referer); got != tc.want {
+				t.Fatalf("AllowsOrigin(%q, %q) = %v, want %v", tc.origin, tc.referer, got, tc.want)
+			}
+		})
+	}
+	if !(APIKey{}).AllowsOrigin("", "") {
+		t.Fatal("key without allowed_origins rejected a request")
+	}
+}
@@ -0,0 +1,188 @@
+package mj3gc
+
+import (
+	"sort"
+	"time"
+)
+
+// RevisionSummary counts the changes recorded at one revision.
+type RevisionSummary struct {
+	Revision int64          `json:"revision"`
+	At       time.Time      `json:"at"`
+	Changes  map[string]int `json:"changes"`
+}
+
+// RollbackReport summarises a rollback.
+type RollbackReport struct {
+	From     int64 `json:"from"`
+	To       int64 `json:"to"`
+	Users    int   `json:"users"`
+	APIKeys  int   `json:"api_keys"`
+	Restored int   `json:"restored"`
+	Trashed  int   `json:"trashed"`
+}
+
+// ListRevisions returns the revisions that can still be rolled back to, newest first.
+// Change counts are keyed by "<kind>.<op>", for example "api_key.removed".
+func (s *Store) ListRevisions() []RevisionSummary {
+	if s == nil {
+		return nil
+	}
+	defer s.lockUsage("ListRevisions")()
+	s.recordChangesLocked()
+	var out []RevisionSummary
+	for _, change := range s.data.Changes {
+		if len(out) == 0 || out[len(out)-1].Revision != change.Revision {
+			out = append(out, RevisionSummary{Revision: change.Revision, At: change.At, Changes: map[string]int{}})
+		}
+		out[len(out)-1].Changes[change.Kind+"."+change.Op]++
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Revision > out[j].Revision })
+	return out
+}
+
+// Rollback restores users and keys to their state at revision target by undoing the
+// recorded changes after it, newest first. Records added since then move to the trash;
+// removed ones come back from the trash or archive. Keys keep their current usage.
+// The rollback is itself recorded as a new revision, so it can be rolled back too.
+func (s *Store) Rollback(target int64) (RollbackReport, error) {
+	if s == nil {
+		return RollbackReport{}, ErrInvalidConfiguration
+	}
+	defer s.lock("Rollback")()
+	s.recordChangesLocked()
+	current := s.data.Revision
+	if target < 0 || target >= current {
+		return RollbackReport{}, ErrInvalidRevision
+	}
+	if len(s.data.Changes) == 0 || s.data.Changes[0].Revision > target+1 {
+		return RollbackReport{}, ErrRevisionExpired
+	}
+
+	now := time.Now()
+	users := newRecordList(s.data.Users, func(u User) string { return u.ID })
+	keys := newRecordList(s.data.APIKeys, func(k APIKey) string { return k.ID })
+	deletedUsers := newRecordList(s.data.DeletedUsers, func(u User) string { return u.ID })
+	deletedKeys := newRecordList(s.data.DeletedKeys, func(k APIKey) string { return k.ID })
+	archivedKeys := newRecordList(s.data.ArchivedKeys, func(k APIKey) string { return k.ID })
+	report := RollbackReport{From: current, To: target}
+	touchedUsers, touchedKeys := map[string]bool{}, map[string]bool{}
+
+	for i := len(s.data.Changes) - 1; i >= 0; i-- {
+		change := s.data.Changes[i]
+		if change.Revision <= target {
+			break
+		}
+		switch change.Kind {
+		case ChangeKindUser:
+			touchedUsers[change.ID] = true
+			switch {
+			case change.Op == ChangeAdded:
+				if u, ok := users.remove(change.ID); ok {
+					u.DeletedAt = now
+					deletedUsers.put(u)
+					report.Trashed++
+				}
+			case change.PreviousUser != nil:
+				if change.Op == ChangeRemoved {
+					deletedUsers.remove(change.ID)
+					report.Restored++
+				}
+				previous := *change.PreviousUser
+				previous.DeletedAt = time.Time{}
+				users.put(previous)
+			}
+		case ChangeKindAPIKey:
+			touchedKeys[change.ID] = true
+			switch {
+			case change.Op == ChangeAdded:
+				if k, ok := keys.remove(change.ID); ok {
+					k.DeletedAt = now
+					deletedKeys.put(k)
+					report.Trashed++
+				}
+			case change.PreviousKey != nil:
+				previous := *change.PreviousKey
+				live, ok := keys.get(change.ID)
+				if change.Op == ChangeRemoved {
+					if k, found := deletedKeys.remove(change.ID); found {
+						live, ok = k, true
+					} else if k, found := archivedKeys.remove(change.ID); found {
+						live, ok = k, true
+					}
+					report.Restored++
+				}
+				if ok {
+					previous.UsedCount, previous.LastUsedAt = live.UsedCount, live.LastUsedAt
+					previous.LastUsedIP = live.LastUsedIP
+					previous.UsedTokens, previous.SpentUSD = live.UsedTokens, live.SpentUSD
+					if previous.ResetInterval == live.ResetInterval {
+						previous.LastResetAt = live.LastResetAt
+					}
+				}
+				previous.DeletedAt = time.Time{}
+				keys.put(previous)
+			}
+		}
+	}
+
+	s.data.Users = users.slice()
+	s.data.APIKeys = keys.slice()
+	s.data.DeletedUsers = deletedUsers.slice()
+	s.data.DeletedKeys = deletedKeys.slice()
+	s.data.ArchivedKeys = archivedKeys.slice()
+	for id := range touchedKeys {
+		if _, ok := keys.get(id); !ok {
+			delete(s.inflight, id)
+		}
+	}
+	report.Users, report.APIKeys = len(touchedUsers), len(touchedKeys)
+	return report, nil
+}
+
+// recordList is a slice of records addressable by ID that keeps the original order and
+// appends new records at the end.
+type recordList[T any] struct {
+	id    func(T) string
+	items map[string]T
+	order []string
+}
+
+func newRecordList[T any](items []T, id func(T) string) *recordList[T] {
+	list := &recordList[T]{id: id, items: make(map[string]T, len(items))}
+	for _, item := range items {
+		list.put(item)
+	}
+	return list
+}
+
+func (l *recordList[T]) get(id string) (T, bool) {
+	item, ok := l.items[id]
+	return item, ok
+}
+
+func (l *recordList[T]) put(item T) {
+	id := l.id(item)
+	if _, ok := l.items[id]; !ok {
+		l.order = append(l.order, id)
+	}
+	l.items[id] = item
+}
+
+func (l *recordList[T]) remove(id string) (T, bool) {
+	item, ok := l.items[id]
+	delete(l.items, id)
+	return item, ok
+}
+
+func (l *recordList[T]) slice() []T {
+	out := make([]T, 0, len(l.items))
+	seen := make(map[string]bool, len(l.items))
+	for _, id := range l.order {
+		if item, ok := l.items[id]; ok && !seen[id] {
+			seen[id] = true
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRollbackUndoesBulkDelete(t *testing.T) {
+	s := NewStore()
+	s.resetChangesLocked()
+	user, _ := s.UpsertUser(User{Username: "alice"})
+	k1, _ := s.UpsertAPIKey(APIKey{Key: "k1", UserID: user.ID, Enabled: true, Label: "one"})
+	k2, _ := s.UpsertAPIKey(APIKey{Key: "k2", UserID: user.ID, Enabled: true})
+	base := s.Revision()
+
+	s.EndRequest("k1", true, "")
+	k1, _ = s.FindAPIKeyByID(k1.ID)
+	k1.Label = "renamed"
+	_, _ = s.UpsertAPIKey(k1)
+	_ = s.DeleteAPIKey(k2.ID)
+	_ = s.DeleteUser(user.ID)
+	extra, _ := s.UpsertAPIKey(APIKey{Key: "k3", Enabled: true})
+	if s.Revision() != base+1 {
+		t.Fatalf("revision = %d, want %d", s.Revision(), base+1)
+	}
+	if revisions := s.ListRevisions(); len(revisions) != 2 || revisions[0].Changes["api_key.removed"] != 1 {
+		t.Fatalf("revisions = %+v", revisions)
+	}
+
+	report, err := s.Rollback(base)
+	if err != nil {
+		t.Fatalf("Rollback: %v", err)
+	}
+	if report.Restored != 2 || report.Trashed != 1 {
+		t.Fatalf("report = %+v", report)
+	}
+	got, ok := s.FindAPIKeyByID(k1.ID)
+	if !ok || got.Label != "one" || got.UsedCount != 1 {
+		t.Fatalf("k1 = %+v, %v", got, ok)
+	}
+	if _, ok := s.FindAPIKey("k2"); !ok {
+		t.Fatal("deleted key not restored")
+	}
+	if _, ok := s.FindUserByID(user.ID); !ok {
+		t.Fatal("deleted user not restored")
+	}
+	if _, ok := s.FindAPIKeyByID(extra.ID); ok {
+		t.Fatal("key added after the target revision survived")
+	}
+	if len(s.ListDeletedUsers()) != 0 || len(s.ListDeletedAPIKeys()) != 1 {
+		t.Fatalf("trash = %+v / %+v", s.ListDeletedUsers(), s.ListDeletedAPIKeys())
+	}
+	if s.Revision() != base+2 {
+		t.Fatalf("rollback not recorded as a revision")
+	}
+	if _, err := s.Rollback(base + 2); !errors.Is(err, ErrInvalidRevision) {
+		t.Fatalf("err = %v, want ErrInvalidRevision", err)
+	}
+}
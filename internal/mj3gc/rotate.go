@@ -0,0 +1,69 @@
+package mj3gc
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaxRotationGrace bounds how long a rotated secret may keep authenticating.
+const MaxRotationGrace = 30 * 24 * time.Hour
+
+// RotateAPIKey replaces the secret of the key with the given id by a generated one,
+// keeping its settings and usage. When grace is positive the old secret keeps
+// authenticating until it elapses; otherwise it stops immediately. It returns the
+// updated key and the old secret.
+func (s *Store) RotateAPIKey(id string, grace time.Duration) (APIKey, string, error) {
+	if s == nil {
+		return APIKey{}, "", ErrInvalidConfiguration
+	}
+	if grace < 0 || grace > MaxRotationGrace {
+		return APIKey{}, "", fmt.Errorf("grace period must be between 0 and %s", MaxRotationGrace)
+	}
+	value, err := NewAPIKey()
+	if err != nil {
+		return APIKey{}, "", err
+	}
+	defer s.lock("RotateAPIKey")()
+	i, ok := s.keyIDIndexLocked(id)
+	if !ok {
+		return APIKey{}, "", ErrKeyNotFound
+	}
+	key := &s.data.APIKeys[i]
+	old := key.Key
+	if key.PreviousKey != "" {
+		s.revokeSecretLocked(key.PreviousKey)
+	}
+	key.Key = value
+	key.PreviousKey = ""
+	key.PreviousKeyExpiresAt = time.Time{}
+	if grace > 0 {
+		key.PreviousKey = old
+		key.PreviousKeyExpiresAt = time.Now().Add(grace)
+	} else {
+		s.revokeSecretLocked(old)
+	}
+	return *key, old, nil
+}
+
+// ExpireRotatedKeys forgets previous secrets whose grace period has ended and
+// returns how many were dropped. Lookups already ignore them; this keeps them out
+// of the stored data.
+func (s *Store) ExpireRotatedKeys() int {
+	if s == nil {
+		return 0
+	}
+	now := time.Now()
+	defer s.lock("ExpireRotatedKeys")()
+	expired := 0
+	for i := range s.data.APIKeys {
+		key := &s.data.APIKeys[i]
+		if key.PreviousKey == "" || now.Before(key.PreviousKeyExpiresAt) {
+			continue
+		}
+		s.revokeSecretLocked(key.PreviousKey)
+		key.PreviousKey = ""
+		key.PreviousKeyExpiresAt = time.Time{}
+		expired++
+	}
+	return expired
+}
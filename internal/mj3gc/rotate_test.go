@@ -0,0 +1,71 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRotateAPIKey(t *testing.T) {
+	store := NewStore()
+	key, err := store.UpsertAPIKey(APIKey{Key: "sk-original", Label: "ci", Enabled: true, TotalLimit: 10, UsedCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, previous, err := store.RotateAPIKey(key.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("RotateAPIKey: %v", err)
+	}
+	if previous != "sk-original" || rotated.Key == "sk-original" || rotated.Key == "" {
+		t.Fatalf("rotated key = %q, previous = %q", rotated.Key, previous)
+	}
+	if rotated.ID != key.ID || rotated.Label != "ci" || rotated.TotalLimit != 10 || rotated.UsedCount != 3 {
+		t.Fatalf("rotation lost settings: %+v", rotated)
+	}
+	for _, value := range []string{rotated.Key, "sk-original"} {
+		if got, ok := store.FindAPIKey(value); !ok || got.ID != key.ID {
+			t.Fatalf("FindAPIKey(%q) = %v, %v during grace period", value, got.ID, ok)
+		}
+	}
+	if _, err := store.BeginRequest("sk-original"); err != nil {
+		t.Fatalf("BeginRequest with previous secret: %v", err)
+	}
+	store.EndRequest("sk-original", true, "")
+	if got, _ := store.FindAPIKeyByID(key.ID); got.UsedCount != 4 {
+		t.Fatalf("used count = %d, want 4", got.UsedCount)
+	}
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-original", Enabled: true}); !errors.Is(err, ErrDuplicateAPIKey) {
+		t.Fatalf("reusing a secret in its grace period = %v, want ErrDuplicateAPIKey", err)
+	}
+
+	store.mu.Lock()
+	store.data.APIKeys[0].PreviousKeyExpiresAt = time.Now().Add(-time.Second)
+	store.mu.Unlock()
+	if _, ok := store.FindAPIKey("sk-original"); ok {
+		t.Fatal("previous secret still authenticates after the grace period")
+	}
+	if n := store.ExpireRotatedKeys(); n != 1 {
+		t.Fatalf("ExpireRotatedKeys = %d, want 1", n)
+	}
+	if got, _ := store.FindAPIKeyByID(key.ID); got.PreviousKey != "" {
+		t.Fatalf("previous key = %q after expiry", got.PreviousKey)
+	}
+
+	again, previous, err := store.RotateAPIKey(key.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if previous != rotated.Key || again.PreviousKey != "" {
+		t.Fatalf("rotation without grace kept %q", again.PreviousKey)
+	}
+	if _, ok := store.FindAPIKey(rotated.Key); ok {
+		t.Fatal("secret rotated without grace still authenticates")
+	}
+	if _, _, err := store.RotateAPIKey("missing", 0); !errors.Is(err, ErrKeyNotFound) {
+		t.Fatalf("RotateAPIKey(missing) = %v", err)
+	}
+	if _, _, err := store.RotateAPIKey(key.ID, -time.Second); err == nil {
+		t.Fatal("negative grace accepted")
+	}
+}
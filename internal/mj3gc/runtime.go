@@ -0,0 +1,200 @@
+package mj3gc
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// runtimeState tracks the background jobs started by Start. Jobs that read their
+// settings once run under jobs and are restarted by Reconfigure; the usage flusher and
+// the maintenance loop run under the lifetime of the store.
+type runtimeState struct {
+	mu       sync.Mutex
+	lifetime context.Context
+	stop     context.CancelFunc
+	jobs     context.CancelFunc
+	// storage holds the storage settings and data path the open backend was built from.
+	storage config.MJ3GCConfig
+	path    string
+}
+
+// Start applies cfg to the store, opens the configured backend and counters, loads the
+// data and starts the background jobs: maintenance, the usage flusher, backups,
+// heartbeat, backend syncing and watching, file watching, OTLP export and change data
+// capture. They run until Stop. The jobs are started even when loading fails, so the
+// store keeps serving the keys it has.
+func (s *Store) Start(cfg *config.Config, configFilePath string) error {
+	if s == nil || cfg == nil {
+		return ErrInvalidConfiguration
+	}
+	s.ApplyConfig(cfg)
+	err := s.openStorage(cfg, configFilePath)
+
+	ctx, stop := context.WithCancel(context.Background())
+	s.runtime.mu.Lock()
+	if s.runtime.stop != nil {
+		s.runtime.mu.Unlock()
+		stop()
+		return err
+	}
+	s.runtime.lifetime, s.runtime.stop = ctx, stop
+	s.runtime.mu.Unlock()
+	s.StartFlusher(ctx)
+	s.StartMaintenance(ctx)
+	s.restartJobs()
+	return err
+}
+
+// Reconfigure applies a reloaded cfg. The backend and counters are reopened and the data
+// reloaded only when their settings or the data path changed, after pending usage was
+// flushed. The background jobs that read their settings on start are restarted and the
+// usage flusher picks up changed flush settings.
+func (s *Store) Reconfigure(cfg *config.Config, configFilePath string) error {
+	if s == nil || cfg == nil {
+		return ErrInvalidConfiguration
+	}
+	s.ApplyConfig(cfg)
+	s.runtime.mu.Lock()
+	changed := s.runtime.path != ResolveDataPath(cfg, configFilePath) ||
+		!reflect.DeepEqual(s.runtime.storage, storageSettings(cfg.MJ3GC))
+	s.runtime.mu.Unlock()
+	var err error
+	if changed {
+		if errFlush := s.Flush(); errFlush != nil {
+			log.Warnf("mj3gc: failed to flush usage before reopening storage: %v", errFlush)
+		}
+		err = s.openStorage(cfg, configFilePath)
+	}
+	s.RetuneFlusher()
+	s.restartJobs()
+	return err
+}
+
+// Stop stops the background jobs started by Start. The flusher persists pending usage
+// before it exits.
+func (s *Store) Stop() {
+	if s == nil {
+		return
+	}
+	s.runtime.mu.Lock()
+	defer s.runtime.mu.Unlock()
+	if s.runtime.jobs != nil {
+		s.runtime.jobs()
+		s.runtime.jobs = nil
+	}
+	if s.runtime.stop != nil {
+		s.runtime.stop()
+		s.runtime.stop, s.runtime.lifetime = nil, nil
+	}
+}
+
+// restartJobs cancels the jobs started by the previous call and starts them again with
+// the current settings. It does nothing before Start.
+func (s *Store) restartJobs() {
+	s.runtime.mu.Lock()
+	if s.runtime.lifetime == nil {
+		s.runtime.mu.Unlock()
+		return
+	}
+	if s.runtime.jobs != nil {
+		s.runtime.jobs()
+	}
+	ctx, cancel := context.WithCancel(s.runtime.lifetime)
+	s.runtime.jobs = cancel
+	s.runtime.mu.Unlock()
+
+	s.StartBackups(ctx)
+	s.StartHeartbeat(ctx)
+	s.StartSync(ctx)
+	s.StartBackendWatch(ctx)
+	s.StartFileWatcher(ctx)
+	s.StartOTLPExporter(ctx)
+	s.StartChangeCapture(ctx)
+}
+
+// openStorage opens the backend and counters selected by cfg, loads the data from them
+// and closes the ones they replace. The JSON file storage uses the store path directly
+// so key changes apply without reopening it.
+func (s *Store) openStorage(cfg *config.Config, configFilePath string) error {
+	settings := cfg.MJ3GC
+	path := ResolveDataPath(cfg, configFilePath)
+	var backend Backend
+	storage := strings.ToLower(strings.TrimSpace(settings.Storage))
+	if (storage != "" && storage != storageFile) || strings.TrimSpace(settings.S3Mirror.Bucket) != "" {
+		opened, err := OpenBackend(settings, path)
+		if err != nil {
+			return err
+		}
+		backend = opened
+	}
+	counters, err := OpenCounterBackend(settings)
+	if err != nil {
+		closeQuietly(backend)
+		return err
+	}
+
+	unlock := s.rlock("openStorage")
+	previous, previousCounters := s.backend, s.counters
+	unlock()
+	s.SetPath(path)
+	s.SetBackend(backend)
+	s.SetCounterBackend(nil)
+	err = s.Load()
+	// Seed the shared counters with the loaded usage.
+	s.SetCounterBackend(counters)
+	if previous != backend {
+		closeQuietly(previous)
+	}
+	if previousCounters != nil && previousCounters != counters {
+		closeQuietly(previousCounters)
+	}
+
+	s.runtime.mu.Lock()
+	s.runtime.storage, s.runtime.path = storageSettings(settings), path
+	s.runtime.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	log.Infof("mj3gc: loaded %d users and %d keys from %s", len(s.ListUsers()), len(s.ListAPIKeys()), path)
+	return nil
+}
+
+// storageSettings keeps the settings that select and open the backend and counters.
+func storageSettings(settings config.MJ3GCConfig) config.MJ3GCConfig {
+	return config.MJ3GCConfig{
+		Storage:                 settings.Storage,
+		SQLitePath:              settings.SQLitePath,
+		SQLiteDriver:            settings.SQLiteDriver,
+		ShardDir:                settings.ShardDir,
+		ShardCount:              settings.ShardCount,
+		PostgresDSN:             settings.PostgresDSN,
+		Counters:                settings.Counters,
+		RedisAddr:               settings.RedisAddr,
+		RedisPassword:           settings.RedisPassword,
+		RedisDB:                 settings.RedisDB,
+		RedisInflightTTLSeconds: settings.RedisInflightTTLSeconds,
+		EncryptionKeyFile:       settings.EncryptionKeyFile,
+		SigningKeyFile:          settings.SigningKeyFile,
+		AcceptUnsignedData:      settings.AcceptUnsignedData,
+		S3Mirror:                settings.S3Mirror,
+		KVEndpoint:              settings.KVEndpoint,
+		KVPrefix:                settings.KVPrefix,
+		KVUsername:              settings.KVUsername,
+		KVPassword:              settings.KVPassword,
+		KVToken:                 settings.KVToken,
+		ControlPlaneURL:         settings.ControlPlaneURL,
+		ControlPlaneToken:       settings.ControlPlaneToken,
+	}
+}
+
+func closeQuietly(v any) {
+	if closer, ok := v.(io.Closer); ok {
+		_ = closer.Close()
+	}
+}
@@ -0,0 +1,125 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	defaultMirrorObject = "mj3gc/mj3gc-data.json"
+	mirrorTimeout       = 30 * time.Second
+)
+
+// ErrMirrorConflict is returned by Save when the mirrored object changed since it was
+// last read, typically because another instance saved in between. The local file is
+// still written; reload the store to pick up the remote copy.
+var ErrMirrorConflict = errors.New("mj3gc: remote data changed since last load")
+
+// s3MirrorBackend keeps the JSON data file on local disk and mirrors it to an
+// S3-compatible bucket. Load pulls the remote copy first; Save pushes with an ETag
+// precondition so concurrent writers are detected instead of silently overwritten.
+type s3MirrorBackend struct {
+	local  *fileBackend
+	client *minio.Client
+	bucket string
+	object string
+
+	mu   sync.Mutex
+	etag string
+}
+
+func openS3Mirror(mirror config.MJ3GCS3Mirror, local *fileBackend) (*s3MirrorBackend, error) {
+	endpoint := strings.TrimSpace(mirror.Endpoint)
+	bucket := strings.TrimSpace(mirror.Bucket)
+	if endpoint == "" || bucket == "" {
+		return nil, fmt.Errorf("mj3gc: s3-mirror requires endpoint and bucket")
+	}
+	options := &minio.Options{
+		Creds:  credentials.NewStaticV4(strings.TrimSpace(mirror.AccessKey), strings.TrimSpace(mirror.SecretKey), ""),
+		Secure: mirror.UseSSL,
+		Region: mirror.Region,
+	}
+	if mirror.PathStyle {
+		options.BucketLookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(endpoint, options)
+	if err != nil {
+		return nil, fmt.Errorf("mj3gc: create s3 client: %w", err)
+	}
+	object := strings.Trim(strings.TrimSpace(mirror.Object), "/")
+	if object == "" {
+		object = defaultMirrorObject
+	}
+	return &s3MirrorBackend{local: local, client: client, bucket: bucket, object: object}, nil
+}
+
+func (b *s3MirrorBackend) Load() (Data, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	obj, err := b.client.GetObject(ctx, b.bucket, b.object, minio.GetObjectOptions{})
+	if err != nil {
+		return Data{}, fmt.Errorf("mj3gc: fetch s3 mirror: %w", err)
+	}
+	defer func() { _ = obj.Close() }()
+	payload, err := io.ReadAll(obj)
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+			b.etag = ""
+			return b.local.Load()
+		}
+		return Data{}, fmt.Errorf("mj3gc: fetch s3 mirror: %w", err)
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		return Data{}, fmt.Errorf("mj3gc: stat s3 mirror: %w", err)
+	}
+	// The object holds the file exactly as written, so encrypted data stays encrypted.
+	if err := writeFileAtomic(b.local.path, payload); err != nil {
+		return Data{}, err
+	}
+	b.etag = info.ETag
+	return b.local.Load()
+}
+
+func (b *s3MirrorBackend) Save(data Data) error {
+	if err := b.local.Save(data); err != nil {
+		return err
+	}
+	payload, err := os.ReadFile(b.local.path)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+	defer cancel()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	opts := minio.PutObjectOptions{ContentType: "application/json"}
+	if b.etag != "" {
+		opts.SetMatchETag(b.etag)
+	} else {
+		opts.SetMatchETagExcept("*")
+	}
+	info, err := b.client.PutObject(ctx, b.bucket, b.object, bytes.NewReader(payload), int64(len(payload)), opts)
+	if err != nil {
+		if minio.ToErrorResponse(err).StatusCode == http.StatusPreconditionFailed {
+			return ErrMirrorConflict
+		}
+		return fmt.Errorf("mj3gc: upload s3 mirror: %w", err)
+	}
+	b.etag = info.ETag
+	return nil
+}
@@ -0,0 +1,151 @@
+package mj3gc
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySchedule limits when a key may be used: on the listed days between Start and End
+// in Timezone. End before Start spans midnight and belongs to the day it starts on.
+type KeySchedule struct {
+	// Timezone is an IANA zone such as "Europe/Berlin". Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Days are "mon" to "sun". Empty means every day.
+	Days []string `json:"days,omitempty"`
+	// Start and End are "HH:MM". Both empty means the whole day.
+	Start string `json:"start,omitempty"`
+	End   string `json:"end,omitempty"`
+}
+
+var scheduleDays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// scheduleZones caches loaded time zones by name.
+var scheduleZones sync.Map
+
+func scheduleLocation(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if loc, ok := scheduleZones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	scheduleZones.Store(name, loc)
+	return loc, nil
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, want HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Normalize trims and lower-cases the schedule and checks every field.
+func (s KeySchedule) Normalize() (KeySchedule, error) {
+	s.Timezone = strings.TrimSpace(s.Timezone)
+	if _, err := scheduleLocation(s.Timezone); err != nil {
+		return KeySchedule{}, fmt.Errorf("invalid timezone %q", s.Timezone)
+	}
+	days := make([]string, 0, len(s.Days))
+	seen := make(map[string]struct{}, len(s.Days))
+	for _, day := range s.Days {
+		day = strings.ToLower(strings.TrimSpace(day))
+		if len(day) > 3 {
+			day = day[:3]
+		}
+		if _, ok := scheduleDays[day]; !ok {
+			return KeySchedule{}, fmt.Errorf("invalid day %q", day)
+		}
+		if _, dup := seen[day]; !dup {
+			seen[day] = struct{}{}
+			days = append(days, day)
+		}
+	}
+	s.Days = nil
+	if len(days) > 0 {
+		s.Days = days
+	}
+	s.Start, s.End = strings.TrimSpace(s.Start), strings.TrimSpace(s.End)
+	if (s.Start == "") != (s.End == "") {
+		return KeySchedule{}, fmt.Errorf("start and end must be set together")
+	}
+	if s.Start != "" {
+		start, err := parseClock(s.Start)
+		if err != nil {
+			return KeySchedule{}, err
+		}
+		end, err := parseClock(s.End)
+		if err != nil {
+			return KeySchedule{}, err
+		}
+		if start == end {
+			return KeySchedule{}, fmt.Errorf("start and end must differ")
+		}
+	}
+	return s, nil
+}
+
+// Allows reports whether the schedule admits a request at t. Schedules that fail to
+// parse admit nothing, so a broken edit of the data file fails closed.
+func (s KeySchedule) Allows(t time.Time) bool {
+	loc, err := scheduleLocation(s.Timezone)
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+	if s.Start == "" {
+		return s.allowsDay(t.Weekday())
+	}
+	start, errStart := parseClock(s.Start)
+	end, errEnd := parseClock(s.End)
+	if errStart != nil || errEnd != nil {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return s.allowsDay(t.Weekday()) && minute >= start && minute < end
+	}
+	// Overnight window: the evening part on a listed day or the morning after it.
+	if minute >= start {
+		return s.allowsDay(t.Weekday())
+	}
+	return minute < end && s.allowsDay((t.Weekday()+6)%7)
+}
+
+func (s KeySchedule) allowsDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, name := range s.Days {
+		if scheduleDays[name] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSchedule rejects requests after the key expired or reached its sunset date, or
+// outside its schedule.
+func (k APIKey) checkSchedule(now time.Time) error {
+	if k.Expired(now) {
+		return ErrKeyExpired
+	}
+	if k.SunsetPassed(now) {
+		return ErrKeySuspended
+	}
+	if k.Schedule != nil && !k.Schedule.Allows(now) {
+		return ErrOutsideSchedule
+	}
+	return nil
+}
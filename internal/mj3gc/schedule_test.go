@@ -0,0 +1,68 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyScheduleAllows(t *testing.T) {
+	class, err := KeySchedule{Timezone: "Europe/Berlin", Days: []string{"Mon", "tuesday", "wed", "thu", "fri"}, Start: "08:00", End: "20:00"}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	night, err := KeySchedule{Days: []string{"fri"}, Start: "22:00", End: "02:00"}.Normalize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	berlin, _ := time.LoadLocation("Europe/Berlin")
+	cases := []struct {
+		name     string
+		schedule KeySchedule
+		at       time.Time
+		want     bool
+	}{
+		// 2026-03-02 is a Monday.
+		{"weekday_in_class", class, time.Date(2026, 3, 2, 9, 30, 0, 0, berlin), true},
+		{"weekday_before_class", class, time.Date(2026, 3, 2, 7, 59, 0, 0, berlin), false},
+		{"end_is_exclusive", class, time.Date(2026, 3, 2, 20, 0, 0, 0, berlin), false},
+		{"saturday", class, time.Date(2026, 3, 7, 10, 0, 0, 0, berlin), false},
+		{"other_zone", class, time.Date(2026, 3, 2, 7, 30, 0, 0, time.UTC), true},
+		{"overnight_evening", night, time.Date(2026, 3, 6, 23, 0, 0, 0, time.UTC), true},
+		{"overnight_morning_after", night, time.Date(2026, 3, 7, 1, 59, 0, 0, time.UTC), true},
+		{"overnight_wrong_day", night, time.Date(2026, 3, 6, 1, 0, 0, 0, time.UTC), false},
+		{"days_only", KeySchedule{Days: []string{"sat", "sun"}}, time.Date(2026, 3, 8, 3, 0, 0, 0, time.UTC), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.schedule.Allows(tc.at); got != tc.want {
+				t.Fatalf("Allows(%s) = %v, want %v", tc.at, got, tc.want)
+			}
+		})
+	}
+
+	for _, bad := range []KeySchedule{
+		{Timezone: "Mars/Olympus"},
+		{Days: []string{"someday"}},
+		{Start: "08:00"},
+		{Start: "8am", End: "20:00"},
+		{Start: "08:00", End: "08:00"},
+	} {
+		if _, err := bad.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) accepted", bad)
+		}
+	}
+}
+
+func TestBeginRequestOutsideSchedule(t *testing.T) {
+	s := NewStore()
+	now := time.Now().UTC()
+	closed := KeySchedule{Start: now.Add(time.Hour).Format("15:04"), End: now.Add(2 * time.Hour).Format("15:04")}
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-class", Enabled: true, Schedule: &closed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.BeginRequest(key.Key); !errors.Is(err, ErrOutsideSchedule) {
+		t.Fatalf("BeginRequest = %v, want ErrOutsideSchedule", err)
+	}
+}
@@ -0,0 +1,378 @@
+package mj3gc
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	scimUserSchema  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	scimListSchema  = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	scimErrorSchema = "urn:ietf:params:scim:api:messages:2.0:Error"
+	scimContentType = "application/scim+json"
+	scimActor       = "scim"
+	scimUsersPath   = "/scim/v2/Users"
+)
+
+// scimFilter matches the equality filters identity providers use to look up a user
+// before provisioning it, for example `userName eq "alice"`.
+var scimFilter = regexp.MustCompile(`(?i)^\s*(userName|externalId|id)\s+eq\s+"((?:[^"\\]|\\.)*)"\s*$`)
+
+// SCIMUser is the SCIM 2.0 core User resource restricted to the attributes mj3gc keeps.
+type SCIMUser struct {
+	Schemas    []string  `json:"schemas"`
+	ID         string    `json:"id,omitempty"`
+	ExternalID string    `json:"externalId,omitempty"`
+	UserName   string    `json:"userName"`
+	Active     *bool     `json:"active,omitempty"`
+	Password   string    `json:"password,omitempty"`
+	Meta       *SCIMMeta `json:"meta,omitempty"`
+}
+
+// SCIMMeta is the meta attribute of a SCIM resource.
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	Location     string    `json:"location"`
+}
+
+type scimPatch struct {
+	Operations []struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		Value json.RawMessage `json:"value"`
+	} `json:"Operations"`
+}
+
+func toSCIMUser(u User) SCIMUser {
+	active := !u.Disabled
+	return SCIMUser{
+		Schemas:    []string{scimUserSchema},
+		ID:         u.ID,
+		ExternalID: u.ExternalID,
+		UserName:   u.Username,
+		Active:     &active,
+		Meta:       &SCIMMeta{ResourceType: "User", Created: u.CreatedAt, Location: scimUsersPath + "/" + u.ID},
+	}
+}
+
+func scimError(c *gin.Context, status int, scimType, detail string) {
+	body := gin.H{"schemas": []string{scimErrorSchema}, "status": strconv.Itoa(status), "detail": detail}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	c.Header("Content-Type", scimContentType)
+	c.AbortWithStatusJSON(status, body)
+}
+
+func scimJSON(c *gin.Context, status int, body any) {
+	c.Header("Content-Type", scimContentType)
+	c.JSON(status, body)
+}
+
+// scimStoreError answers a failed store write with the matching SCIM error.
+func scimStoreError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrDuplicateUsername):
+		scimError(c, http.StatusConflict, "uniqueness", "userName is already taken")
+	case errors.Is(err, ErrUserNotFound):
+		scimError(c, http.StatusNotFound, "", "user not found")
+	case errors.Is(err, ErrReadOnly):
+		scimError(c, http.StatusForbidden, "", err.Error())
+	default:
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+	}
+}
+
+// SCIMAuth guards the SCIM endpoints. It answers 404 unless mj3gc.scim-token is set
+// and requires that token as a bearer credential.
+func SCIMAuth(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := ""
+		if store != nil {
+			token = store.Settings().SCIMToken
+		}
+		if token == "" {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+		got := strings.TrimSpace(strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+		if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			scimError(c, http.StatusUnauthorized, "", "invalid token")
+			return
+		}
+		c.Next()
+	}
+}
+
+// SCIMListUsersHandler serves GET /scim/v2/Users with optional equality filter and
+// 1-based startIndex/count pagination.
+func SCIMListUsersHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		users := store.ListUsers()
+		sort.SliceStable(users, func(i, j int) bool { return users[i].CreatedAt.Before(users[j].CreatedAt) })
+		if filter := c.Query("filter"); filter != "" {
+			match := scimFilter.FindStringSubmatch(filter)
+			if match == nil {
+				scimError(c, http.StatusBadRequest, "invalidFilter", "only eq filters on userName, externalId and id are supported")
+				return
+			}
+			value := strings.ReplaceAll(strings.ReplaceAll(match[2], `\"`, `"`), `\\`, `\`)
+			filtered := users[:0]
+			for _, u := range users {
+				switch strings.ToLower(match[1]) {
+				case "username":
+					if strings.EqualFold(u.Username, value) {
+						filtered = append(filtered, u)
+					}
+				case "externalid":
+					if u.ExternalID == value {
+						filtered = append(filtered, u)
+					}
+				default:
+					if u.ID == value {
+						filtered = append(filtered, u)
+					}
+				}
+			}
+			users = filtered
+		}
+		start, _ := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+		if start < 1 {
+			start = 1
+		}
+		count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(len(users))))
+		if err != nil || count < 0 {
+			count = len(users)
+		}
+		page := []SCIMUser{}
+		for i := start - 1; i < len(users) && len(page) < count; i++ {
+			page = append(page, toSCIMUser(users[i]))
+		}
+		scimJSON(c, http.StatusOK, gin.H{
+			"schemas":      []string{scimListSchema},
+			"totalResults": len(users),
+			"startIndex":   start,
+			"itemsPerPage": len(page),
+			"Resources":    page,
+		})
+	}
+}
+
+// SCIMGetUserHandler serves GET /scim/v2/Users/:id.
+func SCIMGetUserHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := store.FindUserByID(c.Param("id"))
+		if !ok {
+			scimError(c, http.StatusNotFound, "", "user not found")
+			return
+		}
+		scimJSON(c, http.StatusOK, toSCIMUser(user))
+	}
+}
+
+// SCIMCreateUserHandler serves POST /scim/v2/Users. Provisioned users get the user
+// role; without a password they can only sign in once one is set.
+func SCIMCreateUserHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body SCIMUser
+		if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.UserName) == "" {
+			scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+			return
+		}
+		if store.ReadOnly() {
+			scimStoreError(c, ErrReadOnly)
+			return
+		}
+		if _, exists := store.FindUserByUsername(body.UserName); exists {
+			scimStoreError(c, ErrDuplicateUsername)
+			return
+		}
+		user := User{Username: strings.TrimSpace(body.UserName), ExternalID: body.ExternalID, Role: roleUser}
+		if body.Active != nil {
+			user.Disabled = !*body.Active
+		}
+		if err := applySCIMPassword(&user, body.Password); err != nil {
+			scimStoreError(c, err)
+			return
+		}
+		created, err := store.UpsertUser(user)
+		if err == nil {
+			err = store.Save()
+		}
+		if err != nil {
+			scimStoreError(c, err)
+			return
+		}
+		store.RecordAudit(AuditEntry{Actor: scimActor, Action: "user.provision", Target: created.ID, Details: map[string]any{"username": created.Username}})
+		c.Header("Location", scimUsersPath+"/"+created.ID)
+		scimJSON(c, http.StatusCreated, toSCIMUser(created))
+	}
+}
+
+// SCIMReplaceUserHandler serves PUT /scim/v2/Users/:id.
+func SCIMReplaceUserHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body SCIMUser
+		if err := c.ShouldBindJSON(&body); err != nil || strings.TrimSpace(body.UserName) == "" {
+			scimError(c, http.StatusBadRequest, "invalidValue", "userName is required")
+			return
+		}
+		updateSCIMUser(c, store, func(user *User) error {
+			user.Username = strings.TrimSpace(body.UserName)
+			user.ExternalID = body.ExternalID
+			user.Disabled = body.Active != nil && !*body.Active
+			return applySCIMPassword(user, body.Password)
+		})
+	}
+}
+
+// SCIMPatchUserHandler serves PATCH /scim/v2/Users/:id for the add and replace
+// operations on active, userName, externalId and password, which is how identity
+// providers deactivate users.
+func SCIMPatchUserHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body scimPatch
+		if err := c.ShouldBindJSON(&body); err != nil || len(body.Operations) == 0 {
+			scimError(c, http.StatusBadRequest, "invalidSyntax", "Operations are required")
+			return
+		}
+		updateSCIMUser(c, store, func(user *User) error {
+			for _, op := range body.Operations {
+				switch strings.ToLower(op.Op) {
+				case "add", "replace":
+				default:
+					return fmt.Errorf("unsupported op %q", op.Op)
+				}
+				values := map[string]json.RawMessage{}
+				if op.Path == "" {
+					if err := json.Unmarshal(op.Value, &values); err != nil {
+						return fmt.Errorf("value must be an object without a path")
+					}
+				} else {
+					values[op.Path] = op.Value
+				}
+				for path, value := range values {
+					if err := applySCIMAttribute(user, path, value); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	}
+}
+
+// SCIMDeleteUserHandler serves DELETE /scim/v2/Users/:id, moving the user to the trash.
+func SCIMDeleteUserHandler(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if store.ReadOnly() {
+			scimStoreError(c, ErrReadOnly)
+			return
+		}
+		err := store.DeleteUser(id)
+		if err == nil {
+			err = store.Save()
+		}
+		if err != nil {
+			scimStoreError(c, err)
+			return
+		}
+		store.RecordAudit(AuditEntry{Actor: scimActor, Action: "user.deprovision", Target: id})
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func updateSCIMUser(c *gin.Context, store *Store, apply func(*User) error) {
+	if store.ReadOnly() {
+		scimStoreError(c, ErrReadOnly)
+		return
+	}
+	user, ok := store.FindUserByID(c.Param("id"))
+	if !ok {
+		scimError(c, http.StatusNotFound, "", "user not found")
+		return
+	}
+	wasDisabled := user.Disabled
+	if err := apply(&user); err != nil {
+		scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+	updated, err := store.UpsertUser(user)
+	if err == nil {
+		err = store.Save()
+	}
+	if err != nil {
+		scimStoreError(c, err)
+		return
+	}
+	action := "user.update"
+	if updated.Disabled != wasDisabled {
+		action = "user.deactivate"
+		if !updated.Disabled {
+			action = "user.activate"
+		}
+	}
+	store.RecordAudit(AuditEntry{Actor: scimActor, Action: action, Target: updated.ID, Details: map[string]any{"username": updated.Username}})
+	scimJSON(c, http.StatusOK, toSCIMUser(updated))
+}
+
+func applySCIMAttribute(user *User, path string, value json.RawMessage) error {
+	var text string
+	switch strings.ToLower(path) {
+	case "active":
+		var active bool
+		if err := json.Unmarshal(value, &active); err != nil {
+			// Some providers send booleans as strings.
+			if err := json.Unmarshal(value, &text); err != nil {
+				return fmt.Errorf("active must be a boolean")
+			}
+			if active, err = strconv.ParseBool(text); err != nil {
+				return fmt.Errorf("active must be a boolean")
+			}
+		}
+		user.Disabled = !active
+	case "username":
+		if err := json.Unmarshal(value, &text); err != nil || strings.TrimSpace(text) == "" {
+			return fmt.Errorf("userName must be a non-empty string")
+		}
+		user.Username = strings.TrimSpace(text)
+	case "externalid":
+		if err := json.Unmarshal(value, &text); err != nil {
+			return fmt.Errorf("externalId must be a string")
+		}
+		user.ExternalID = text
+	case "password":
+		if err := json.Unmarshal(value, &text); err != nil {
+			return fmt.Errorf("password must be a string")
+		}
+		return applySCIMPassword(user, text)
+	case "schemas", "meta", "id":
+	default:
+		return fmt.Errorf("unsupported attribute %q", path)
+	}
+	return nil
+}
+
+func applySCIMPassword(user *User, password string) error {
+	if password == "" {
+		return nil
+	}
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	user.PasswordHash = hash
+	return nil
+}
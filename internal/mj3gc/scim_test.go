@@ -0,0 +1,87 @@
+package mj3gc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestSCIMUserLifecycle(t *testing.T) {
+	s := NewStore()
+	s.SetPath(filepath.Join(t.TempDir(), "mj3gc.json"))
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{SCIMToken: "scim-secret"}})
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	group := router.Group("/scim/v2", SCIMAuth(s))
+	group.GET("/Users", SCIMListUsersHandler(s))
+	group.POST("/Users", SCIMCreateUserHandler(s))
+	group.GET("/Users/:id", SCIMGetUserHandler(s))
+	group.PATCH("/Users/:id", SCIMPatchUserHandler(s))
+	group.DELETE("/Users/:id", SCIMDeleteUserHandler(s))
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Content-Type", scimContentType)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "/scim/v2/Users", "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token status = %d", rec.Code)
+	}
+	rec := do(http.MethodPost, "/scim/v2/Users", "scim-secret",
+		`{"schemas":["`+scimUserSchema+`"],"userName":"alice@example.com","externalId":"ext-1","active":true,"password":"correct horse battery"}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("create status = %d: %s", rec.Code, rec.Body)
+	}
+	var created SCIMUser
+	if err := json.Unmarshal(rec.Body.Bytes(), &created); err != nil || created.ID == "" {
+		t.Fatalf("created = %+v, %v", created, err)
+	}
+	if rec := do(http.MethodPost, "/scim/v2/Users", "scim-secret", `{"userName":"ALICE@example.com"}`); rec.Code != http.StatusConflict {
+		t.Fatalf("duplicate status = %d", rec.Code)
+	}
+	if _, err := s.AuthenticateUser("alice@example.com", "correct horse battery"); err != nil {
+		t.Fatalf("provisioned user cannot sign in: %v", err)
+	}
+
+	filter := url.QueryEscape(`externalId eq "ext-1"`)
+	rec = do(http.MethodGet, "/scim/v2/Users?filter="+filter, "scim-secret", "")
+	var list struct {
+		TotalResults int        `json:"totalResults"`
+		Resources    []SCIMUser `json:"Resources"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || list.TotalResults != 1 || list.Resources[0].ID != created.ID {
+		t.Fatalf("filtered list = %s", rec.Body)
+	}
+
+	rec = do(http.MethodPatch, "/scim/v2/Users/"+created.ID, "scim-secret",
+		`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"Replace","path":"active","value":"False"}]}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch status = %d: %s", rec.Code, rec.Body)
+	}
+	if user, _ := s.FindUserByID(created.ID); !user.Disabled {
+		t.Fatal("user was not deactivated")
+	}
+	if _, err := s.AuthenticateUser("alice@example.com", "correct horse battery"); err == nil {
+		t.Fatal("deactivated user can still sign in")
+	}
+
+	if rec := do(http.MethodDelete, "/scim/v2/Users/"+created.ID, "scim-secret", ""); rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d", rec.Code)
+	}
+	if rec := do(http.MethodGet, "/scim/v2/Users/"+created.ID, "scim-secret", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("get after delete status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,127 @@
+package mj3gc
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Key scopes. A key with scopes may only call the proxy routes that require one of
+// them; keys without scopes may call every route.
+const (
+	ScopeChat       = "chat"
+	ScopeEmbeddings = "embeddings"
+	ScopeImages     = "images"
+)
+
+var knownScopes = map[string]struct{}{ScopeChat: {}, ScopeEmbeddings: {}, ScopeImages: {}}
+
+// NormalizeScopes lower-cases, de-duplicates and sorts scopes and rejects unknown ones.
+func NormalizeScopes(scopes []string) ([]string, error) {
+	seen := make(map[string]struct{}, len(scopes))
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" {
+			continue
+		}
+		if _, ok := knownScopes[scope]; !ok {
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		}
+		if _, ok := seen[scope]; ok {
+			continue
+		}
+		seen[scope] = struct{}{}
+		out = append(out, scope)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// HasScope reports whether the key may call routes requiring scope.
+func (k APIKey) HasScope(scope string) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiredScope returns the scope a proxy route needs. ok is false for routes that
+// need none, such as model listings; unknown routes return "" with ok true, which no
+// scoped key satisfies.
+func RequiredScope(method, path, action string) (scope string, ok bool) {
+	if method == http.MethodGet && (strings.HasSuffix(path, "/models") || strings.Contains(path, "/models/")) {
+		return "", false
+	}
+	if action != "" {
+		_, verb, _ := strings.Cut(strings.TrimPrefix(action, "/"), ":")
+		switch verb {
+		case "generateContent", "streamGenerateContent", "countTokens":
+			return ScopeChat, true
+		case "embedContent", "batchEmbedContents":
+			return ScopeEmbeddings, true
+		case "predict":
+			return ScopeImages, true
+		}
+		return "", true
+	}
+	switch {
+	case strings.HasSuffix(path, "/quota/check"), strings.HasSuffix(path, "/key/info"):
+		return "", false
+	case strings.HasSuffix(path, "/chat/completions"), strings.HasSuffix(path, "/completions"),
+		strings.HasSuffix(path, "/messages"), strings.HasSuffix(path, "/messages/count_tokens"),
+		strings.HasSuffix(path, "/responses"):
+		return ScopeChat, true
+	case strings.HasSuffix(path, "/embeddings"):
+		return ScopeEmbeddings, true
+	case strings.Contains(path, "/images/"):
+		return ScopeImages, true
+	}
+	return "", true
+}
+
+// ScopeMiddleware rejects requests by keys lacking the scope their route requires
+// with 403.
+func ScopeMiddleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if principal == "" || principal == AnonymousPrincipal {
+			c.Next()
+			return
+		}
+		key, ok := store.FindAPIKeyByPrincipal(principal)
+		if !ok || len(key.Scopes) == 0 {
+			c.Next()
+			return
+		}
+		scope, needed := RequiredScope(c.Request.Method, c.Request.URL.Path, c.Param("action"))
+		if !needed || (scope != "" && key.HasScope(scope)) {
+			c.Next()
+			return
+		}
+		message := "this API key may not call this endpoint"
+		if scope != "" {
+			message = fmt.Sprintf("this API key lacks the %q scope", scope)
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": message,
+			"code":  "scope_required",
+			"scope": scope,
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package mj3gc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNormalizeScopes(t *testing.T) {
+	got, err := NormalizeScopes([]string{" Embeddings", "chat", "embeddings", ""})
+	if err != nil || strings.Join(got, ",") != "chat,embeddings" {
+		t.Fatalf("NormalizeScopes = %v, %v", got, err)
+	}
+	if _, err := NormalizeScopes([]string{"billing"}); err == nil {
+		t.Fatal("unknown scope accepted")
+	}
+	if got, _ := NormalizeScopes([]string{" "}); got != nil {
+		t.Fatalf("blank scopes = %v, want nil", got)
+	}
+}
+
+func TestScopeMiddleware(t *testing.T) {
+	store := NewStore()
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-embed", Enabled: true, Scopes: []string{ScopeEmbeddings}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UpsertAPIKey(APIKey{Key: "sk-open", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	withKey := func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	group := router.Group("", withKey, ScopeMiddleware(store))
+	group.GET("/v1/models", ok)
+	group.POST("/v1/chat/completions", ok)
+	group.POST("/v1/embeddings", ok)
+	group.POST("/v1/quota/check", ok)
+	group.POST("/v1/unmapped", ok)
+	group.POST("/v1beta/models/*action", ok)
+
+	cases := []struct {
+		key, method, path string
+		want              int
+	}{
+		{"sk-embed", http.MethodPost, "/v1/embeddings", http.StatusOK},
+		{"sk-embed", http.MethodPost, "/v1beta/models/text-embedding-004:embedContent", http.StatusOK},
+		{"sk-embed", http.MethodPost, "/v1/chat/completions", http.StatusForbidden},
+		{"sk-embed", http.MethodPost, "/v1beta/models/gemini-2.5-pro:generateContent", http.StatusForbidden},
+		{"sk-embed", http.MethodPost, "/v1/unmapped", http.StatusForbidden},
+		{"sk-embed", http.MethodGet, "/v1/models", http.StatusOK},
+		{"sk-embed", http.MethodPost, "/v1/quota/check", http.StatusOK},
+		{"sk-open", http.MethodPost, "/v1/chat/completions", http.StatusOK},
+		{"sk-open", http.MethodPost, "/v1/unmapped", http.StatusOK},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer "+tc.key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s %s = %d, want %d (%s)", tc.key, tc.method, tc.path, rec.Code, tc.want, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package mj3gc
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	defaultMinKeyLength = 20
+	// minKeyEntropyBits is the least estimated entropy of a manually entered secret.
+	minKeyEntropyBits = 64
+
+	secretFilterBits   = 1 << 17
+	secretFilterHashes = 7
+)
+
+var (
+	ErrWeakKey    = errors.New("weak api key")
+	ErrRevokedKey = errors.New("api key was previously revoked")
+)
+
+// weakKeyFragments are substrings that make a secret guessable regardless of its length.
+var weakKeyFragments = []string{
+	"password", "passw0rd", "changeme", "letmein", "secret", "qwerty", "asdfgh", "zxcvbn",
+	"123456", "234567", "345678", "456789", "654321", "abcdef", "000000", "111111",
+}
+
+// SecretFilter is a Bloom filter over SHA-256 hashes of revoked key secrets. It never
+// stores the secrets themselves, may report false positives and never reports false
+// negatives. It is copied on write so snapshots can be marshalled without the lock.
+type SecretFilter struct {
+	Bits  []byte `json:"bits"`
+	Count int    `json:"count"`
+}
+
+func secretFilterPositions(value string) [secretFilterHashes]uint32 {
+	sum := sha256.Sum256([]byte(value))
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	var out [secretFilterHashes]uint32
+	for i := range out {
+		out[i] = uint32((h1 + uint64(i)*h2) % secretFilterBits)
+	}
+	return out
+}
+
+// Contains reports whether value may have been added to the filter.
+func (f *SecretFilter) Contains(value string) bool {
+	if f == nil || len(f.Bits) != secretFilterBits/8 {
+		return false
+	}
+	for _, pos := range secretFilterPositions(value) {
+		if f.Bits[pos/8]&(1<<(pos%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// with returns a copy of the filter that also contains value.
+func (f *SecretFilter) with(value string) *SecretFilter {
+	next := &SecretFilter{Bits: make([]byte, secretFilterBits/8)}
+	if f != nil && len(f.Bits) == len(next.Bits) {
+		copy(next.Bits, f.Bits)
+		next.Count = f.Count
+	}
+	for _, pos := range secretFilterPositions(value) {
+		next.Bits[pos/8] |= 1 << (pos % 8)
+	}
+	next.Count++
+	return next
+}
+
+// revokeSecretLocked remembers a secret that stopped authenticating so it cannot be
+// entered again. It does nothing unless mj3gc.revoked-secret-filter is enabled.
+func (s *Store) revokeSecretLocked(value string) {
+	if value == "" || !s.settings.RevokedSecretFilter {
+		return
+	}
+	s.data.RevokedSecrets = s.data.RevokedSecrets.with(value)
+}
+
+// revokeReplacedSecretsLocked revokes the secrets in before that are no longer used by
+// a key with the same ID in after, because the key was rotated or deleted.
+func (s *Store) revokeReplacedSecretsLocked(before, after []APIKey) {
+	if !s.settings.RevokedSecretFilter {
+		return
+	}
+	current := make(map[string]string, len(after))
+	for _, k := range after {
+		current[k.ID] = k.Key
+	}
+	for _, k := range before {
+		if value, ok := current[k.ID]; !ok || value != k.Key {
+			s.revokeSecretLocked(k.Key)
+		}
+	}
+}
+
+// CheckKeySecret rejects a manually entered key secret that is short, low in entropy,
+// built from common patterns, derived from a username or, when the revoked secret
+// filter is enabled, previously revoked. Generated keys always pass.
+func (s *Store) CheckKeySecret(value string) error {
+	if s == nil {
+		return ErrInvalidConfiguration
+	}
+	settings := s.Settings()
+	minLength := settings.MinKeyLength
+	if minLength <= 0 {
+		minLength = defaultMinKeyLength
+	}
+	if len(value) < minLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakKey, minLength)
+	}
+	if bits := secretEntropyBits(value); bits < minKeyEntropyBits {
+		return fmt.Errorf("%w: estimated entropy %.0f bits, need %d", ErrWeakKey, bits, minKeyEntropyBits)
+	}
+	if IsHashedSecret(value) {
+		return fmt.Errorf("%w: must not look like a stored digest", ErrWeakKey)
+	}
+	lower := strings.ToLower(value)
+	for _, fragment := range weakKeyFragments {
+		if strings.Contains(lower, fragment) {
+			return fmt.Errorf("%w: contains the common pattern %q", ErrWeakKey, fragment)
+		}
+	}
+	if repeatsUnit(lower) {
+		return fmt.Errorf("%w: repeats a short pattern", ErrWeakKey)
+	}
+
+	defer s.rlock("CheckKeySecret")()
+	for _, u := range s.data.Users {
+		name := strings.ToLower(u.Username)
+		if len(name) >= 4 && strings.Contains(lower, name) {
+			return fmt.Errorf("%w: contains a username", ErrWeakKey)
+		}
+	}
+	if settings.RevokedSecretFilter && (s.data.RevokedSecrets.Contains(value) || s.data.RevokedSecrets.Contains(HashSecret(value))) {
+		return ErrRevokedKey
+	}
+	return nil
+}
+
+// secretEntropyBits estimates the entropy of value from its character distribution.
+func secretEntropyBits(value string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range value {
+		counts[r]++
+		total++
+	}
+	perChar := 0.0
+	for _, n := range counts {
+		p := float64(n) / float64(total)
+		perChar -= p * math.Log2(p)
+	}
+	return perChar * float64(total)
+}
+
+// repeatsUnit reports whether value is a short unit of up to four characters repeated.
+func repeatsUnit(value string) bool {
+	for size := 1; size <= 4 && size*2 <= len(value); size++ {
+		if strings.Repeat(value[:size], len(value)/size+1)[:len(value)] == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestCheckKeySecret(t *testing.T) {
+	s := NewStore()
+	s.data.Users = []User{{ID: "u1", Username: "alice"}}
+
+	generated, err := NewAPIKey()
+	if err != nil {
+		t.Fatalf("NewAPIKey: %v", err)
+	}
+	if err := s.CheckKeySecret(generated); err != nil {
+		t.Fatalf("generated key rejected: %v", err)
+	}
+	for _, weak := range []string{
+		"short-Key-1",
+		"aaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+		"abababababababababababababab",
+		"Tq8-password-Zr4wXk9-Lp2mVn",
+		"Tq8-123456-Zr4wXk9-Lp2mVnQs",
+		"Tq8-Alice-Zr4wXk9-Lp2mVnQsY",
+	} {
+		if err := s.CheckKeySecret(weak); !errors.Is(err, ErrWeakKey) {
+			t.Errorf("CheckKeySecret(%q) = %v, want ErrWeakKey", weak, err)
+		}
+	}
+}
+
+func TestRevokedSecretFilter(t *testing.T) {
+	s := NewStore()
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{RevokedSecretFilter: true}})
+	first, _ := NewAPIKey()
+	second, _ := NewAPIKey()
+	key, err := s.UpsertAPIKey(APIKey{Key: first, Enabled: true})
+	if err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := s.CheckKeySecret(first); err != nil {
+		t.Fatalf("active secret flagged: %v", err)
+	}
+
+	key.Key = second
+	if _, err := s.UpsertAPIKey(key); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := s.CheckKeySecret(first); !errors.Is(err, ErrRevokedKey) {
+		t.Fatalf("rotated secret: err = %v, want ErrRevokedKey", err)
+	}
+	if err := s.DeleteAPIKey(key.ID); err != nil {
+		t.Fatalf("DeleteAPIKey: %v", err)
+	}
+	if err := s.CheckKeySecret(second); !errors.Is(err, ErrRevokedKey) {
+		t.Fatalf("deleted secret: err = %v, want ErrRevokedKey", err)
+	}
+	if got := s.Snapshot().RevokedSecrets.Count; got != 2 {
+		t.Fatalf("revoked count = %d, want 2", got)
+	}
+}
+
+func TestSanitizeKeyMasksSecret(t *testing.T) {
+	key := SanitizeKey(APIKey{ID: "k1", Key: "mj3gc-abcdefghijkl"})
+	if key.Key != "****ijkl" || key.ID != "k1" {
+		t.Fatalf("SanitizeKey = %+v", key)
+	}
+	if got := MaskSecret("short"); got != "****" {
+		t.Fatalf("MaskSecret(short) = %q", got)
+	}
+}
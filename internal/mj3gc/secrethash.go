@@ -0,0 +1,110 @@
+package mj3gc
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	// hashedSecretPrefix marks a stored key secret that is a SHA-256 digest.
+	hashedSecretPrefix = "sha256:"
+	// maxSecretDisplayPrefix bounds how much of a hashed secret is kept for display.
+	maxSecretDisplayPrefix = 10
+)
+
+// HashSecret returns the digest stored for a key secret when mj3gc.hash-key-secrets
+// is enabled. Generated and accepted secrets carry enough entropy that an unsalted
+// hash is not practical to reverse.
+func HashSecret(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hashedSecretPrefix + hex.EncodeToString(sum[:])
+}
+
+// IsHashedSecret reports whether a stored key secret is a digest.
+func IsHashedSecret(value string) bool {
+	return strings.HasPrefix(value, hashedSecretPrefix)
+}
+
+// secretDigest returns the digest of a stored secret, hashing it if still plain.
+func secretDigest(value string) string {
+	if value == "" || IsHashedSecret(value) {
+		return value
+	}
+	return HashSecret(value)
+}
+
+// secretDisplayPrefix returns the leading characters of a secret kept next to its
+// digest so admins and users can tell keys apart: at most a quarter of the secret.
+func secretDisplayPrefix(value string) string {
+	n := len(value) / 4
+	if n > maxSecretDisplayPrefix {
+		n = maxSecretDisplayPrefix
+	}
+	return value[:n]
+}
+
+// MatchesSecret reports in constant time whether value is the current secret of the
+// key, whether it is stored plain or hashed.
+func (k APIKey) MatchesSecret(value string) bool {
+	if value == "" || k.Key == "" {
+		return false
+	}
+	stored, given := k.Key, value
+	if IsHashedSecret(stored) {
+		given = HashSecret(value)
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(given)) == 1
+}
+
+// hashSecrets replaces the plain secrets of all keys, including archived and trashed
+// ones and secrets in a rotation grace period, by their digests and returns how many
+// were replaced.
+func (d *Data) hashSecrets() int {
+	hashed := 0
+	for _, list := range [][]APIKey{d.APIKeys, d.ArchivedKeys, d.DeletedKeys} {
+		for i := range list {
+			key := &list[i]
+			if key.Key != "" && !IsHashedSecret(key.Key) {
+				key.KeyPrefix = secretDisplayPrefix(key.Key)
+				key.Key = HashSecret(key.Key)
+				hashed++
+			}
+			if key.PreviousKey != "" && !IsHashedSecret(key.PreviousKey) {
+				key.PreviousKey = HashSecret(key.PreviousKey)
+				hashed++
+			}
+		}
+	}
+	return hashed
+}
+
+// hasPlainSecrets reports whether any key secret in d is stored in plain text.
+func (d *Data) hasPlainSecrets() bool {
+	for _, list := range [][]APIKey{d.APIKeys, d.ArchivedKeys, d.DeletedKeys} {
+		for _, key := range list {
+			if (key.Key != "" && !IsHashedSecret(key.Key)) || (key.PreviousKey != "" && !IsHashedSecret(key.PreviousKey)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hashSecretsBeforeSave hashes secrets set since the last save when
+// mj3gc.hash-key-secrets is enabled. Secrets stay plain in memory between being set
+// and the next save so the request that set them can return them once.
+func (s *Store) hashSecretsBeforeSave() {
+	if !s.Settings().HashKeySecrets {
+		return
+	}
+	unlock := s.rlock("Save")
+	plain := s.data.hasPlainSecrets()
+	unlock()
+	if !plain {
+		return
+	}
+	defer s.lock("HashSecrets")()
+	s.data.hashSecrets()
+}
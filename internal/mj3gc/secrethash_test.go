@@ -0,0 +1,98 @@
+package mj3gc
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestHashKeySecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	legacy := NewStore()
+	legacy.SetPath(path)
+	if err := legacy.Load(); err != nil {
+		t.Fatal(err)
+	}
+	old, err := legacy.UpsertAPIKey(APIKey{Key: "sk-legacy-plain-secret", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewStore()
+	s.SetPath(path)
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{HashKeySecrets: true}})
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	stored, _ := s.FindAPIKeyByID(old.ID)
+	if stored.Key != HashSecret("sk-legacy-plain-secret") || stored.KeyPrefix != "sk-le" {
+		t.Fatalf("legacy key stored as %q with prefix %q", stored.Key, stored.KeyPrefix)
+	}
+	if _, ok := s.FindAPIKey("sk-legacy-plain-secret"); !ok {
+		t.Fatal("hashed key does not authenticate with its secret")
+	}
+	if _, ok := s.FindAPIKey(stored.Key); ok {
+		t.Fatal("digest authenticates as a secret")
+	}
+	if _, ok := s.FindAPIKeyByPrincipal(stored.Key); !ok {
+		t.Fatal("digest principal not found")
+	}
+	if _, err := s.BeginRequest(stored.Key); err != nil {
+		t.Fatalf("BeginRequest(principal): %v", err)
+	}
+	s.EndRequest(stored.Key, true, "")
+
+	created, err := s.UpsertAPIKey(APIKey{Key: "sk-created-secret-value", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.Key != "sk-created-secret-value" {
+		t.Fatalf("upsert returned %q, want the secret until the next save", created.Key)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"sk-legacy-plain-secret", "sk-created-secret-value"} {
+		if strings.Contains(string(raw), secret) {
+			t.Fatalf("data file still contains %q", secret)
+		}
+	}
+	if _, err := s.UpsertAPIKey(APIKey{Key: "sk-created-secret-value", Enabled: true}); !errors.Is(err, ErrDuplicateAPIKey) {
+		t.Fatalf("duplicate of a hashed secret = %v, want ErrDuplicateAPIKey", err)
+	}
+	if got := SanitizeKey(stored).Key; got != "sk-le****" {
+		t.Fatalf("sanitized hashed key = %q", got)
+	}
+
+	if _, _, err := s.IssueAccessToken(old.ID, "sk-legacy-plain-secret"); err != nil {
+		t.Fatalf("IssueAccessToken with hashed secret: %v", err)
+	}
+	if _, _, err := s.IssueAccessToken(old.ID, stored.Key); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("IssueAccessToken with digest = %v", err)
+	}
+
+	rotated, _, err := s.RotateAPIKey(old.ID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{rotated.Key, "sk-legacy-plain-secret"} {
+		if got, ok := s.FindAPIKey(secret); !ok || got.ID != old.ID {
+			t.Fatalf("FindAPIKey(%q) after rotation = %v", secret, ok)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package mj3gc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	shadowMaxBodyBytes = 10 << 20
+	shadowTimeout      = 30 * time.Second
+	// shadowMaxInFlight bounds the shadow requests running at once. Mirrors beyond it
+	// are dropped rather than queued, so a slow shadow target cannot pile up goroutines.
+	shadowMaxInFlight = 64
+)
+
+var shadowClient = &http.Client{Timeout: shadowTimeout}
+
+var (
+	shadowSlots   = make(chan struct{}, shadowMaxInFlight)
+	shadowDropped atomic.Int64
+)
+
+// ShadowStats reports the shadow requests in flight and those dropped since start
+// because shadowMaxInFlight were already running.
+type ShadowStats struct {
+	InFlight int   `json:"in_flight"`
+	Dropped  int64 `json:"dropped"`
+}
+
+// CurrentShadowStats returns the current ShadowStats.
+func CurrentShadowStats() ShadowStats {
+	return ShadowStats{InFlight: len(shadowSlots), Dropped: shadowDropped.Load()}
+}
+
+// shadowForwardHeaders lists the request headers copied to shadow targets. Credentials are never forwarded.
+var shadowForwardHeaders = []string{"Content-Type", "Accept", "User-Agent", "Anthropic-Version"}
+
+// ValidateShadowURL checks that raw is an absolute http(s) URL usable as a shadow target.
+func ValidateShadowURL(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid shadow url: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("invalid shadow url: unsupported scheme %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("invalid shadow url: missing host")
+	}
+	return nil
+}
+
+// captureShadowBody buffers the request body so it can be replayed to a shadow target while
+// leaving the original request readable. Bodies larger than shadowMaxBodyBytes are not shadowed.
+func captureShadowBody(r *http.Request) ([]byte, bool) {
+	if r == nil || r.Body == nil {
+		return nil, true
+	}
+	buf, err := io.ReadAll(io.LimitReader(r.Body, shadowMaxBodyBytes+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(buf), r.Body), r.Body}
+	if err != nil || len(buf) > shadowMaxBodyBytes {
+		return nil, false
+	}
+	return buf, true
+}
+
+// dispatchShadow replays the request to the key's shadow target in the background.
+// The result is only logged; it never affects the primary response or quota accounting.
+// The request is dropped and counted when shadowMaxInFlight mirrors are running.
+func dispatchShadow(key APIKey, r *http.Request, body []byte) {
+	target := strings.TrimRight(strings.TrimSpace(key.ShadowURL), "/")
+	if target == "" || r == nil || r.URL == nil {
+		return
+	}
+	endpoint := target + r.URL.Path
+	if query := stripKeyQuery(r.URL.Query()); query != "" {
+		endpoint += "?" + query
+	}
+	header := make(http.Header)
+	for _, name := range shadowForwardHeaders {
+		if v := r.Header.Get(name); v != "" {
+			header.Set(name, v)
+		}
+	}
+	header.Set("X-MJ3GC-Shadow", "1")
+	header.Set("X-MJ3GC-Key-ID", key.ID)
+	method := r.Method
+
+	select {
+	case shadowSlots <- struct{}{}:
+	default:
+		shadowDropped.Add(1)
+		log.Debugf("mj3gc: shadow request for key %s dropped, %d already in flight", key.ID, shadowMaxInFlight)
+		return
+	}
+	go func() {
+		defer func() { <-shadowSlots }()
+		ctx, cancel := context.WithTimeout(context.Background(), shadowTimeout)
+		defer cancel()
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+		if err != nil {
+			log.Debugf("mj3gc: shadow request for key %s not built: %v", key.ID, err)
+			return
+		}
+		req.Header = header
+		resp, err := shadowClient.Do(req)
+		if err != nil {
+			log.Debugf("mj3gc: shadow request for key %s failed: %v", key.ID, err)
+			return
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+		log.Debugf("mj3gc: shadow request for key %s returned %d", key.ID, resp.StatusCode)
+	}()
+}
+
+func stripKeyQuery(values url.Values) string {
+	values.Del("key")
+	values.Del("auth_token")
+	return values.Encode()
+}
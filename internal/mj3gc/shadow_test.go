@@ -0,0 +1,45 @@
+package mj3gc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDispatchShadowDropsMirrorsOverTheLimit(t *testing.T) {
+	release := make(chan struct{})
+	arrived := make(chan struct{}, shadowMaxInFlight)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer target.Close()
+
+	key := APIKey{ID: "k1", ShadowURL: target.URL}
+	req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	dropped := CurrentShadowStats().Dropped
+	for i := 0; i < shadowMaxInFlight; i++ {
+		dispatchShadow(key, req, []byte("{}"))
+	}
+	for i := 0; i < shadowMaxInFlight; i++ {
+		select {
+		case <-arrived:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d shadow requests reached the target", i)
+		}
+	}
+	dispatchShadow(key, req, []byte("{}"))
+	if stats := CurrentShadowStats(); stats.InFlight != shadowMaxInFlight || stats.Dropped != dropped+1 {
+		t.Fatalf("stats = %+v, want %d in flight and one more dropped", stats, shadowMaxInFlight)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for CurrentShadowStats().InFlight > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("shadow slots not released: %+v", CurrentShadowStats())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
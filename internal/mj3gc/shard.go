@@ -0,0 +1,202 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+const (
+	storageSharded      = "sharded"
+	defaultShardCount   = 64
+	maxShardCount       = 4096
+	shardDocumentName   = "document.json"
+	shardKeysFilePrefix = "keys-"
+	shardKeysFileSuffix = ".json"
+)
+
+// OpenShardedBackend stores API keys in settings.ShardCount files bucketed by a hash
+// of the key ID, next to a document file holding everything else, under
+// settings.ShardDir. Files use the same encryption and signing as the single JSON file.
+func OpenShardedBackend(settings config.MJ3GCConfig, dataPath string) (Backend, error) {
+	dir := strings.TrimSpace(settings.ShardDir)
+	if dir == "" {
+		if dataPath == "" {
+			return nil, fmt.Errorf("mj3gc: shard-dir is required for sharded storage")
+		}
+		dir = strings.TrimSuffix(dataPath, filepath.Ext(dataPath)) + "-shards"
+	}
+	count := settings.ShardCount
+	if count <= 0 {
+		count = defaultShardCount
+	}
+	if count > maxShardCount {
+		return nil, fmt.Errorf("mj3gc: shard-count must not exceed %d", maxShardCount)
+	}
+	key, err := LoadEncryptionKey(settings)
+	if err != nil {
+		return nil, err
+	}
+	signKey, err := LoadSigningKey(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &shardedBackend{
+		dir:      dir,
+		count:    count,
+		template: fileBackend{key: key, signKey: signKey, acceptUnsigned: settings.AcceptUnsignedData},
+		known:    make(map[string]uint64),
+	}, nil
+}
+
+// shardedBackend splits the dataset so that a save only marshals and writes the shards
+// whose keys changed, and a usage update rewrites the single shard holding the key.
+type shardedBackend struct {
+	dir      string
+	count    int
+	template fileBackend
+
+	mu sync.Mutex
+	// known maps shard file names to the fingerprint of their keys as last read or
+	// written, so unchanged shards are skipped.
+	known map[string]uint64
+}
+
+func (b *shardedBackend) file(name string) *fileBackend {
+	f := b.template
+	f.path = filepath.Join(b.dir, name)
+	return &f
+}
+
+// shardName returns the file holding the key with the given ID.
+func (b *shardedBackend) shardName(id string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(id))
+	return fmt.Sprintf("%s%04d%s", shardKeysFilePrefix, h.Sum32()%uint32(b.count), shardKeysFileSuffix)
+}
+
+// shardFiles lists the key shard files on disk, including those of a previous shard count.
+func (b *shardedBackend) shardFiles() ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(b.dir, shardKeysFilePrefix+"*"+shardKeysFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		names = append(names, filepath.Base(match))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Load reads the document and every shard in parallel. Keys are ordered by shard and
+// then by their position within it. Like fileBackend.Load it returns the data together
+// with ErrSignatureMismatch when any file fails verification.
+func (b *shardedBackend) Load() (Data, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	data, mismatch := b.file(shardDocumentName).Load()
+	if mismatch != nil && !errors.Is(mismatch, ErrSignatureMismatch) {
+		return Data{}, mismatch
+	}
+	names, err := b.shardFiles()
+	if err != nil {
+		return Data{}, err
+	}
+	shards := make([]Data, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			shards[i], errs[i] = b.file(name).Load()
+		}(i, name)
+	}
+	wg.Wait()
+
+	known := make(map[string]uint64, len(names))
+	data.APIKeys = nil
+	for i, name := range names {
+		if err := errs[i]; err != nil {
+			if !errors.Is(err, ErrSignatureMismatch) {
+				return Data{}, fmt.Errorf("mj3gc: load shard %s: %w", name, err)
+			}
+			if mismatch == nil {
+				mismatch = fmt.Errorf("shard %s: %w", name, err)
+			}
+		}
+		data.APIKeys = append(data.APIKeys, shards[i].APIKeys...)
+		known[name] = fingerprint(shards[i].APIKeys)
+	}
+	b.known = known
+	return data, mismatch
+}
+
+// Save writes the shards whose keys changed, removes shards that became empty or
+// belong to a previous shard count, and then writes the document.
+func (b *shardedBackend) Save(data Data) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	shards := make(map[string][]APIKey)
+	for _, k := range data.APIKeys {
+		name := b.shardName(k.ID)
+		shards[name] = append(shards[name], k)
+	}
+	for name, keys := range shards {
+		sum := fingerprint(keys)
+		if known, ok := b.known[name]; ok && known == sum {
+			continue
+		}
+		if err := b.file(name).Save(Data{Version: data.Version, APIKeys: keys}); err != nil {
+			return fmt.Errorf("mj3gc: save shard %s: %w", name, err)
+		}
+		b.known[name] = sum
+	}
+	existing, err := b.shardFiles()
+	if err != nil {
+		return err
+	}
+	for _, name := range existing {
+		if _, ok := shards[name]; ok {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.dir, name)); err != nil && !isNotExist(err) {
+			return err
+		}
+		delete(b.known, name)
+	}
+	document := data
+	document.APIKeys = nil
+	return b.file(shardDocumentName).Save(document)
+}
+
+// SaveUsage rewrites only the shard holding the key.
+func (b *shardedBackend) SaveUsage(keyID string, usedCount int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	name := b.shardName(keyID)
+	shard := b.file(name)
+	data, err := shard.Load()
+	if err != nil {
+		return err
+	}
+	for i := range data.APIKeys {
+		if data.APIKeys[i].ID == keyID {
+			data.APIKeys[i].UsedCount = usedCount
+			if err := shard.Save(data); err != nil {
+				return err
+			}
+			b.known[name] = fingerprint(data.APIKeys)
+			return nil
+		}
+	}
+	return ErrKeyNotFound
+}
@@ -0,0 +1,105 @@
+package mj3gc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestShardedBackendRoundTrip(t *testing.T) {
+	t.Setenv("MJ3GC_ENCRYPTION_KEY", "")
+	t.Setenv("MJ3GC_SIGNING_KEY", "")
+	dir := t.TempDir()
+	settings := config.MJ3GCConfig{Storage: storageSharded, ShardCount: 4}
+	backend, err := OpenBackend(settings, filepath.Join(dir, "mj3gc.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewStore()
+	s.SetBackend(backend)
+	if err := s.Load(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := s.UpsertAPIKey(APIKey{ID: fmt.Sprintf("k%02d", i), Key: fmt.Sprintf("sk-%02d", i), Enabled: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.UpsertUser(User{Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	shardDir := filepath.Join(dir, "mj3gc-shards")
+	sharded := backend.(*shardedBackend)
+	files, err := sharded.shardFiles()
+	if err != nil || len(files) == 0 || len(files) > 4 {
+		t.Fatalf("shard files = %v, %v", files, err)
+	}
+
+	// Changing one key rewrites only its shard.
+	target := sharded.shardName("k03")
+	past := time.Now().Add(-time.Hour)
+	for _, name := range files {
+		if name != target {
+			if err := os.Chtimes(filepath.Join(shardDir, name), past, past); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	key, _ := s.FindAPIKeyByID("k03")
+	key.Label = "changed"
+	if _, err := s.UpsertAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range files {
+		info, err := os.Stat(filepath.Join(shardDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rewritten := !info.ModTime().Equal(past); rewritten != (name == target) {
+			t.Fatalf("shard %s rewritten = %v", name, rewritten)
+		}
+	}
+	if err := backend.(UsageSaver).SaveUsage("k07", 9); err != nil {
+		t.Fatal(err)
+	}
+
+	// A different shard count reads the old layout and redistributes it on save.
+	settings.ShardCount = 2
+	resharded, err := OpenBackend(settings, filepath.Join(dir, "mj3gc.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reloaded := NewStore()
+	reloaded.SetBackend(resharded)
+	if err := reloaded.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if keys := reloaded.Snapshot().APIKeys; len(keys) != 20 {
+		t.Fatalf("reloaded %d keys, want 20", len(keys))
+	}
+	if got, _ := reloaded.FindAPIKeyByID("k03"); got.Label != "changed" {
+		t.Fatalf("k03 label = %q", got.Label)
+	}
+	if got, _ := reloaded.FindAPIKeyByID("k07"); got.UsedCount != 9 {
+		t.Fatalf("k07 used count = %d, want 9", got.UsedCount)
+	}
+	if _, ok := reloaded.FindUserByUsername("alice"); !ok {
+		t.Fatal("user missing from the document")
+	}
+	if err := reloaded.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := resharded.(*shardedBackend).shardFiles(); len(files) > 2 {
+		t.Fatalf("old shards left behind: %v", files)
+	}
+}
@@ -0,0 +1,114 @@
+package mj3gc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// signedHeader prefixes signed data files. The hex HMAC-SHA256 of the payload follows
+// on the same line and the payload, encrypted or not, follows the line break.
+var signedHeader = []byte("MJ3GC-HMAC-SHA256-1 ")
+
+const (
+	minSigningKeyLength = 16
+
+	// SignatureRefuse fails to load a data file whose signature does not match.
+	SignatureRefuse = "refuse"
+	// SignatureReadOnly loads a data file whose signature does not match but refuses
+	// to save until a correctly signed file is loaded.
+	SignatureReadOnly = "read-only"
+)
+
+var (
+	// ErrSignatureMismatch means the data file was changed outside the server or
+	// signed with a different key.
+	ErrSignatureMismatch = errors.New("mj3gc: data file signature does not match")
+	// ErrReadOnly is returned by saves while the store is read-only, either by
+	// configuration or after a signature mismatch.
+	ErrReadOnly = errors.New("mj3gc: store is read-only")
+)
+
+// LoadSigningKey resolves the data signing key from MJ3GC_SIGNING_KEY or
+// mj3gc.signing-key-file. A nil key means signing is disabled.
+func LoadSigningKey(settings config.MJ3GCConfig) ([]byte, error) {
+	raw := strings.TrimSpace(os.Getenv("MJ3GC_SIGNING_KEY"))
+	if raw == "" {
+		path := strings.TrimSpace(settings.SigningKeyFile)
+		if path == "" {
+			return nil, nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("mj3gc: read signing key file: %w", err)
+		}
+		raw = strings.TrimSpace(string(content))
+	}
+	if len(raw) < minSigningKeyLength {
+		return nil, fmt.Errorf("mj3gc: signing key must be at least %d bytes", minSigningKeyLength)
+	}
+	return []byte(raw), nil
+}
+
+func signPayload(key, payload []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(payload)
+	sum := hex.EncodeToString(mac.Sum(nil))
+	out := make([]byte, 0, len(signedHeader)+len(sum)+1+len(payload))
+	out = append(out, signedHeader...)
+	out = append(out, sum...)
+	out = append(out, '\n')
+	return append(out, payload...)
+}
+
+// verifyPayload strips the signature line from raw and checks it against key. An
+// unsigned payload is returned as is with signed false. On a mismatch the payload is
+// still returned alongside ErrSignatureMismatch.
+func verifyPayload(key, raw []byte) (payload []byte, signed bool, err error) {
+	if !bytes.HasPrefix(raw, signedHeader) {
+		return raw, false, nil
+	}
+	rest := raw[len(signedHeader):]
+	end := bytes.IndexByte(rest, '\n')
+	if end < 0 {
+		return nil, true, fmt.Errorf("%w: signature line is truncated", ErrSignatureMismatch)
+	}
+	payload = rest[end+1:]
+	if key == nil {
+		return payload, true, nil
+	}
+	want, err := hex.DecodeString(string(rest[:end]))
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write(payload)
+	if err != nil || !hmac.Equal(want, mac.Sum(nil)) {
+		return payload, true, ErrSignatureMismatch
+	}
+	return payload, true, nil
+}
+
+// signatureMismatch returns mj3gc.signature-mismatch, defaulting to SignatureRefuse.
+func (s *Store) signatureMismatch() string {
+	if mode := strings.ToLower(strings.TrimSpace(s.Settings().SignatureMismatch)); mode == SignatureReadOnly {
+		return mode
+	}
+	return SignatureRefuse
+}
+
+// ReadOnly reports whether the store refuses to save, because mj3gc.read-only is set,
+// because the data file it loaded failed signature verification or because the data
+// is owned by a control plane.
+func (s *Store) ReadOnly() bool {
+	if s == nil {
+		return false
+	}
+	defer s.rlock("ReadOnly")()
+	_, remote := s.backend.(*controlPlaneBackend)
+	return s.settings.ReadOnly || s.tampered || remote
+}
@@ -0,0 +1,150 @@
+package mj3gc
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func signedStore(t *testing.T, path string, settings config.MJ3GCConfig) *Store {
+	t.Helper()
+	s := NewStore()
+	s.SetPath(path)
+	s.ApplyConfig(&config.Config{MJ3GC: settings})
+	return s
+}
+
+func TestSignedDataFileDetectsTampering(t *testing.T) {
+	t.Setenv("MJ3GC_SIGNING_KEY", "")
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "signing.key")
+	if err := os.WriteFile(keyFile, []byte("0123456789abcdef0123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "mj3gc.json")
+	settings := config.MJ3GCConfig{SigningKeyFile: keyFile}
+
+	s := signedStore(t, path, settings)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := s.UpsertAPIKey(APIKey{Key: "sk-signed", Enabled: true, TotalLimit: 10}); err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(raw, signedHeader) {
+		t.Fatal("data file is not signed")
+	}
+	if err := signedStore(t, path, settings).Load(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+
+	tampered := bytes.Replace(raw, []byte(`"total_limit": 10`), []byte(`"total_limit": 0`), 1)
+	if bytes.Equal(tampered, raw) {
+		t.Fatal("test did not modify the file")
+	}
+	if err := os.WriteFile(path, tampered, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := signedStore(t, path, settings).Load(); !errors.Is(err, ErrSignatureMismatch) {
+		t.Fatalf("Load of tampered file = %v, want ErrSignatureMismatch", err)
+	}
+
+	settings.SignatureMismatch = SignatureReadOnly
+	readOnly := signedStore(t, path, settings)
+	if err := readOnly.Load(); err != nil {
+		t.Fatalf("read-only Load: %v", err)
+	}
+	if !readOnly.ReadOnly() {
+		t.Fatal("store should be read-only")
+	}
+	if _, ok := readOnly.FindAPIKey("sk-signed"); !ok {
+		t.Fatal("key missing in read-only store")
+	}
+	if err := readOnly.Save(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Save = %v, want ErrReadOnly", err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, tampered) {
+		t.Fatal("read-only store rewrote the data file")
+	}
+}
+
+func TestUnsignedDataFileNeedsAcceptUnsigned(t *testing.T) {
+	t.Setenv("MJ3GC_SIGNING_KEY", "")
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	plain := signedStore(t, path, config.MJ3GCConfig{})
+	if err := plain.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := plain.UpsertAPIKey(APIKey{Key: "sk-plain", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("MJ3GC_SIGNING_KEY", "an-environment-signing-key")
+	if err := signedStore(t, path, config.MJ3GCConfig{}).Load(); !errors.Is(err, ErrSignatureMismatch) {
+		t.Fatalf("Load of unsigned file = %v, want ErrSignatureMismatch", err)
+	}
+	adopt := signedStore(t, path, config.MJ3GCConfig{AcceptUnsignedData: true})
+	if err := adopt.Load(); err != nil {
+		t.Fatalf("Load with accept-unsigned-data: %v", err)
+	}
+	if err := adopt.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := signedStore(t, path, config.MJ3GCConfig{}).Load(); err != nil {
+		t.Fatalf("Load after signing: %v", err)
+	}
+}
+
+func TestReadOnlyStoreDoesNotSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	primary := signedStore(t, path, config.MJ3GCConfig{})
+	if err := primary.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := primary.UpsertAPIKey(APIKey{Key: "sk-primary", Enabled: true, TotalLimit: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if err := primary.Save(); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replica := signedStore(t, path, config.MJ3GCConfig{ReadOnly: true})
+	if err := replica.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	key, err := replica.BeginRequest("sk-primary")
+	if err != nil {
+		t.Fatalf("BeginRequest: %v", err)
+	}
+	replica.EndRequest("sk-primary", true, "")
+	if err := replica.SaveUsage(key.ID, 1); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SaveUsage = %v, want ErrReadOnly", err)
+	}
+	if err := replica.Save(); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Save = %v, want ErrReadOnly", err)
+	}
+	if _, err := replica.Import(Data{}, true); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Import = %v, want ErrReadOnly", err)
+	}
+	if after, _ := os.ReadFile(path); !bytes.Equal(after, before) {
+		t.Fatal("read-only store rewrote the data file")
+	}
+}
@@ -0,0 +1,266 @@
+package mj3gc
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const sqlTimeout = 30 * time.Second
+
+// sqlBackend persists users and API keys as JSON documents in relational tables.
+// Hot columns (key value, owner, usage counter) are stored separately so lookups and
+// usage updates do not need to touch the document.
+type sqlBackend struct {
+	db *sql.DB
+	// rebind converts '?' placeholders into the driver's native syntax.
+	rebind func(query string) string
+	// sharedCounters leaves used_count untouched on Save because other instances
+	// increment it concurrently.
+	sharedCounters bool
+}
+
+var sqlSchema = []string{
+	`CREATE TABLE IF NOT EXISTS mj3gc_meta (
+		name TEXT PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS mj3gc_users (
+		id TEXT PRIMARY KEY,
+		username TEXT NOT NULL,
+		data TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS mj3gc_api_keys (
+		id TEXT PRIMARY KEY,
+		api_key TEXT NOT NULL UNIQUE,
+		user_id TEXT NOT NULL,
+		used_count BIGINT NOT NULL DEFAULT 0,
+		data TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS mj3gc_api_keys_user_id ON mj3gc_api_keys (user_id)`,
+}
+
+func newSQLBackend(db *sql.DB, rebind func(string) string) (*sqlBackend, error) {
+	if rebind == nil {
+		rebind = func(query string) string { return query }
+	}
+	b := &sqlBackend{db: db, rebind: rebind}
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	for _, stmt := range sqlSchema {
+		if _, err := db.ExecContext(ctx, stmt); err != nil {
+			return nil, fmt.Errorf("mj3gc: create schema: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// Close releases the database handle.
+func (b *sqlBackend) Close() error {
+	return b.db.Close()
+}
+
+func (b *sqlBackend) Load() (Data, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+
+	// Everything except users and API keys lives in a single JSON document.
+	var data Data
+	found := false
+	var document string
+	err := b.db.QueryRowContext(ctx, b.rebind(`SELECT value FROM mj3gc_meta WHERE name = ?`), "document").Scan(&document)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return Data{}, err
+	default:
+		found = true
+		if err := json.Unmarshal([]byte(document), &data); err != nil {
+			return Data{}, fmt.Errorf("mj3gc: decode document: %w", err)
+		}
+		data.Users, data.APIKeys = nil, nil
+	}
+
+	rows, err := b.db.QueryContext(ctx, b.rebind(`SELECT data FROM mj3gc_users`))
+	if err != nil {
+		return Data{}, err
+	}
+	for rows.Next() {
+		var raw string
+		var user User
+		if err := rows.Scan(&raw); err != nil {
+			_ = rows.Close()
+			return Data{}, err
+		}
+		if err := json.Unmarshal([]byte(raw), &user); err != nil {
+			_ = rows.Close()
+			return Data{}, fmt.Errorf("mj3gc: decode user: %w", err)
+		}
+		data.Users = append(data.Users, user)
+	}
+	if err := closeRows(rows); err != nil {
+		return Data{}, err
+	}
+
+	rows, err = b.db.QueryContext(ctx, b.rebind(`SELECT data, used_count FROM mj3gc_api_keys`))
+	if err != nil {
+		return Data{}, err
+	}
+	for rows.Next() {
+		var raw string
+		var used int64
+		var key APIKey
+		if err := rows.Scan(&raw, &used); err != nil {
+			_ = rows.Close()
+			return Data{}, err
+		}
+		if err := json.Unmarshal([]byte(raw), &key); err != nil {
+			_ = rows.Close()
+			return Data{}, fmt.Errorf("mj3gc: decode api key: %w", err)
+		}
+		key.UsedCount = used
+		data.APIKeys = append(data.APIKeys, key)
+	}
+	if err := closeRows(rows); err != nil {
+		return Data{}, err
+	}
+
+	if !found && len(data.Users) == 0 && len(data.APIKeys) == 0 {
+		return Data{}, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func (b *sqlBackend) Save(data Data) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	userIDs := make([]string, 0, len(data.Users))
+	for _, u := range data.Users {
+		userIDs = append(userIDs, u.ID)
+	}
+	if err := b.deleteMissing(ctx, tx, "mj3gc_users", userIDs); err != nil {
+		return err
+	}
+	keyIDs := make([]string, 0, len(data.APIKeys))
+	for _, k := range data.APIKeys {
+		keyIDs = append(keyIDs, k.ID)
+	}
+	if err := b.deleteMissing(ctx, tx, "mj3gc_api_keys", keyIDs); err != nil {
+		return err
+	}
+
+	upsertUser := b.rebind(`INSERT INTO mj3gc_users (id, username, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET username = excluded.username, data = excluded.data`)
+	for _, u := range data.Users {
+		raw, err := json.Marshal(u)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, upsertUser, u.ID, u.Username, string(raw)); err != nil {
+			return fmt.Errorf("mj3gc: save user %s: %w", u.ID, err)
+		}
+	}
+	usedCountUpdate := "excluded.used_count"
+	if b.sharedCounters {
+		usedCountUpdate = "mj3gc_api_keys.used_count"
+	}
+	upsertKey := b.rebind(`INSERT INTO mj3gc_api_keys (id, api_key, user_id, used_count, data) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET api_key = excluded.api_key, user_id = excluded.user_id,
+		used_count = ` + usedCountUpdate + `, data = excluded.data`)
+	for _, k := range data.APIKeys {
+		raw, err := json.Marshal(k)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, upsertKey, k.ID, k.Key, k.UserID, k.UsedCount, string(raw)); err != nil {
+			return fmt.Errorf("mj3gc: save api key %s: %w", k.ID, err)
+		}
+	}
+
+	rest := data
+	rest.Users, rest.APIKeys = nil, nil
+	document, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+	upsertMeta := b.rebind(`INSERT INTO mj3gc_meta (name, value) VALUES (?, ?)
+		ON CONFLICT (name) DO UPDATE SET value = excluded.value`)
+	if _, err := tx.ExecContext(ctx, upsertMeta, "document", string(document)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (b *sqlBackend) SaveUsage(keyID string, usedCount int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	_, err := b.db.ExecContext(ctx, b.rebind(`UPDATE mj3gc_api_keys SET used_count = ? WHERE id = ?`), usedCount, keyID)
+	return err
+}
+
+// deleteMissing removes rows from table whose id is not part of keep.
+func (b *sqlBackend) deleteMissing(ctx context.Context, tx *sql.Tx, table string, keep []string) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id FROM "+table)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]struct{}, len(keep))
+	for _, id := range keep {
+		wanted[id] = struct{}{}
+	}
+	var stale []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		if _, ok := wanted[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+	if err := closeRows(rows); err != nil {
+		return err
+	}
+	stmt := b.rebind("DELETE FROM " + table + " WHERE id = ?")
+	for _, id := range stale {
+		if _, err := tx.ExecContext(ctx, stmt, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func closeRows(rows *sql.Rows) error {
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return err
+	}
+	return rows.Close()
+}
+
+// rebindDollar rewrites '?' placeholders as $1, $2, ... for PostgreSQL.
+func rebindDollar(query string) string {
+	var sb strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			sb.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
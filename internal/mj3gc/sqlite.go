@@ -0,0 +1,61 @@
+package mj3gc
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Registers the "sqlite3" driver. It needs cgo; builds with CGO_ENABLED=0
+	// link a stub that fails to open databases.
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const defaultSQLiteDriver = "sqlite3"
+
+// OpenSQLiteBackend opens (or creates) a SQLite database at path. driverName selects
+// the database/sql driver; it defaults to "sqlite3" (github.com/mattn/go-sqlite3),
+// which is linked into cgo builds.
+func OpenSQLiteBackend(driverName, path string) (Backend, error) {
+	driverName = strings.TrimSpace(driverName)
+	if driverName == "" {
+		driverName = defaultSQLiteDriver
+	}
+	if !driverRegistered(driverName) {
+		return nil, fmt.Errorf("mj3gc: sql driver %q is not registered in this build", driverName)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := sql.Open(driverName, path)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer; serialising connections avoids SQLITE_BUSY under load.
+	db.SetMaxOpenConns(1)
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTimeout)
+	defer cancel()
+	for _, pragma := range []string{"PRAGMA journal_mode=WAL", "PRAGMA synchronous=NORMAL", "PRAGMA busy_timeout=5000"} {
+		if _, err := db.ExecContext(ctx, pragma); err != nil {
+			_ = db.Close()
+			return nil, fmt.Errorf("mj3gc: %s: %w", pragma, err)
+		}
+	}
+	backend, err := newSQLBackend(db, nil)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return backend, nil
+}
+
+func driverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+//go:build cgo
+
+package mj3gc
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestSQLiteBackendRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	backend, err := OpenBackend(config.MJ3GCConfig{Storage: storageSQLite}, filepath.Join(dir, "mj3gc.json"))
+	if err != nil {
+		t.Fatalf("OpenBackend: %v", err)
+	}
+	s := NewStore()
+	s.SetBackend(backend)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	user, err := s.UpsertUser(User{Username: "alice"})
+	if err != nil {
+		t.Fatalf("UpsertUser: %v", err)
+	}
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-sqlite-round-trip", UserID: user.ID, Enabled: true, TotalLimit: 10})
+	if err != nil {
+		t.Fatalf("UpsertAPIKey: %v", err)
+	}
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	saver, ok := backend.(UsageSaver)
+	if !ok {
+		t.Fatal("sqlite backend does not save usage in place")
+	}
+	if err := saver.SaveUsage(key.ID, 3); err != nil {
+		t.Fatalf("SaveUsage: %v", err)
+	}
+	if err := backend.(*sqlBackend).Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// The database defaults to the data path with a ".db" extension.
+	reopened, err := OpenSQLiteBackend("", filepath.Join(dir, "mj3gc.db"))
+	if err != nil {
+		t.Fatalf("OpenSQLiteBackend: %v", err)
+	}
+	defer func() { _ = reopened.(*sqlBackend).Close() }()
+	data, err := reopened.Load()
+	if err != nil {
+		t.Fatalf("Load after reopen: %v", err)
+	}
+	if len(data.Users) != 1 || data.Users[0].Username != "alice" {
+		t.Fatalf("users = %+v", data.Users)
+	}
+	if len(data.APIKeys) != 1 {
+		t.Fatalf("keys = %+v", data.APIKeys)
+	}
+	got := data.APIKeys[0]
+	if got.ID != key.ID || got.Key != key.Key || got.UserID != user.ID || got.TotalLimit != 10 || got.UsedCount != 3 {
+		t.Fatalf("key = %+v", got)
+	}
+}
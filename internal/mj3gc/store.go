@@ -3,15 +3,17 @@ package mj3gc
 import (
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/quota"
+	log "github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -21,51 +23,176 @@ const (
 )
 
 var (
-	ErrKeyNotFound          = errors.New("api key not found")
-	ErrKeyDisabled          = errors.New("api key disabled")
-	ErrQuotaExceeded        = errors.New("quota exceeded")
-	ErrConcurrencyExceeded  = errors.New("concurrency exceeded")
+	ErrKeyNotFound          = quota.ErrKeyNotFound
+	ErrKeyDisabled          = quota.ErrKeyDisabled
+	ErrKeyExpired           = quota.ErrKeyExpired
+	ErrKeySuspended         = quota.ErrKeySuspended
+	ErrQuotaExceeded        = quota.ErrQuotaExceeded
+	ErrConcurrencyExceeded  = quota.ErrConcurrencyExceeded
+	ErrRateLimited          = quota.ErrRateLimited
+	ErrTokenQuotaExceeded   = quota.ErrTokenQuotaExceeded
+	ErrBudgetExceeded       = quota.ErrBudgetExceeded
+	ErrOutsideSchedule      = quota.ErrOutsideSchedule
 	ErrUserNotFound         = errors.New("user not found")
 	ErrInvalidCredentials   = errors.New("invalid credentials")
 	ErrDuplicateUsername    = errors.New("duplicate username")
 	ErrDuplicateAPIKey      = errors.New("duplicate api key")
 	ErrInvalidConfiguration = errors.New("invalid configuration")
+	ErrKeyArchived          = errors.New("api key archived")
 )
 
 type Data struct {
-	Version   int       `json:"version"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Users     []User    `json:"users"`
-	APIKeys   []APIKey  `json:"api_keys"`
+	// Version is the data layout; see CurrentDataVersion. Revision counts changes to
+	// users and keys, which Changes records for incremental sync.
+	Version      int       `json:"version"`
+	Revision     int64     `json:"revision,omitempty"`
+	Changes      []Change  `json:"changes,omitempty"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Users        []User    `json:"users"`
+	APIKeys      []APIKey  `json:"api_keys"`
+	ArchivedKeys []APIKey  `json:"archived_keys,omitempty"`
+	DeletedUsers []User    `json:"deleted_users,omitempty"`
+	DeletedKeys  []APIKey  `json:"deleted_keys,omitempty"`
+
+	Quarantine    []QuarantinedRecord `json:"quarantine,omitempty"`
+	Announcements []Announcement      `json:"announcements,omitempty"`
+	// ReportSubscriptions schedule usage reports for portal users; see SendDueReports.
+	ReportSubscriptions []ReportSubscription `json:"report_subscriptions,omitempty"`
+	// RevokedSecrets remembers rotated and deleted key secrets; see CheckKeySecret.
+	RevokedSecrets *SecretFilter `json:"revoked_secrets,omitempty"`
 }
 
 type User struct {
-	ID           string    `json:"id"`
-	Username     string    `json:"username"`
-	PasswordHash string    `json:"password_hash"`
-	Role         string    `json:"role"`
-	Disabled     bool      `json:"disabled"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           string       `json:"id"`
+	Username     string       `json:"username"`
+	ExternalID   string       `json:"external_id,omitempty"`
+	PasswordHash string       `json:"password_hash"`
+	Role         string       `json:"role"`
+	Disabled     bool         `json:"disabled"`
+	ReferralCode string       `json:"referral_code,omitempty"`
+	ReferredBy   string       `json:"referred_by,omitempty"`
+	Billing      *BillingInfo `json:"billing,omitempty"`
+	// MaxKeys overrides mj3gc.max-keys-per-user for the user when positive; a negative
+	// value lets the user hold any number of keys.
+	MaxKeys int `json:"max_keys,omitempty"`
+	// Pool binds the keys of the user to an upstream account pool; see UpstreamPool.
+	Pool      string    `json:"pool,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	DeletedAt time.Time `json:"deleted_at,omitempty"`
 }
 
 type APIKey struct {
-	ID                string    `json:"id"`
-	Key               string    `json:"key"`
-	Label             string    `json:"label"`
-	UserID            string    `json:"user_id"`
-	Enabled           bool      `json:"enabled"`
-	TotalLimit        int64     `json:"total_limit"`
-	UsedCount         int64     `json:"used_count"`
-	ConcurrencyLimit  int       `json:"concurrency_limit"`
-	CompatibilityMode bool      `json:"compatibility_mode"`
-	CreatedAt         time.Time `json:"created_at"`
+	ID string `json:"id"`
+	// Key is the secret, or its digest when mj3gc.hash-key-secrets is enabled; see
+	// HashSecret. KeyPrefix then keeps its first characters for display.
+	Key       string `json:"key"`
+	KeyPrefix string `json:"key_prefix,omitempty"`
+	// PreviousKey is the secret replaced by the last rotation. It keeps
+	// authenticating until PreviousKeyExpiresAt; see RotateAPIKey.
+	PreviousKey          string    `json:"previous_key,omitempty"`
+	PreviousKeyExpiresAt time.Time `json:"previous_key_expires_at,omitempty"`
+	Label                string    `json:"label"`
+	UserID               string    `json:"user_id"`
+	ParentID             string    `json:"parent_id,omitempty"`
+	Enabled              bool      `json:"enabled"`
+	TotalLimit           int64     `json:"total_limit"`
+	UsedCount            int64     `json:"used_count"`
+	TokenLimit           int64     `json:"token_limit,omitempty"`
+	UsedTokens           int64     `json:"used_tokens,omitempty"`
+	// BudgetUSD caps SpentUSD, the cost estimated from mj3gc.model-prices, in the
+	// currency of the price table.
+	BudgetUSD float64 `json:"budget_usd,omitempty"`
+	SpentUSD  float64 `json:"spent_usd,omitempty"`
+	// ResetInterval resets the usage counters on a schedule; see ResetsAt.
+	ResetInterval string    `json:"reset_interval,omitempty"`
+	LastResetAt   time.Time `json:"last_reset_at,omitempty"`
+	// LimitHistory keeps the earlier limits of the key; see LimitsAt.
+	LimitHistory      []LimitPeriod `json:"limit_history,omitempty"`
+	ConcurrencyLimit  int           `json:"concurrency_limit"`
+	RPMLimit          int           `json:"rpm_limit,omitempty"`
+	MaxOutputTokens   int           `json:"max_output_tokens,omitempty"`
+	MaxBodyBytes      int64         `json:"max_body_bytes,omitempty"`
+	Streaming         string        `json:"streaming,omitempty"`
+	CompatibilityMode bool          `json:"compatibility_mode"`
+	ShadowURL         string        `json:"shadow_url,omitempty"`
+	Priority          string        `json:"priority,omitempty"`
+	ExpiresAt         time.Time     `json:"expires_at,omitempty"`
+	DeprecatedAt      time.Time     `json:"deprecated_at,omitempty"`
+	SunsetAt          time.Time     `json:"sunset_at,omitempty"`
+	DisabledAt        time.Time     `json:"disabled_at,omitempty"`
+	// DisabledReason says why the store disabled the key, e.g. DisabledReasonIdle.
+	DisabledReason string    `json:"disabled_reason,omitempty"`
+	ReenabledAt    time.Time `json:"reenabled_at,omitempty"`
+	LastUsedAt     time.Time `json:"last_used_at,omitempty"`
+	// LastUsedIP is the client address of the request that set LastUsedAt.
+	LastUsedIP        string            `json:"last_used_ip,omitempty"`
+	AllowedUserAgents []string          `json:"allowed_user_agents,omitempty"`
+	AllowedIPs        []string          `json:"allowed_ips,omitempty"`
+	AllowedOrigins    []string          `json:"allowed_origins,omitempty"`
+	AllowedModels     []string          `json:"allowed_models,omitempty"`
+	DeniedModels      []string          `json:"denied_models,omitempty"`
+	ModelAliases      map[string]string `json:"model_aliases,omitempty"`
+	Features          []string          `json:"features,omitempty"`
+	Scopes            []string          `json:"scopes,omitempty"`
+	Tags              map[string]string `json:"tags,omitempty"`
+	Schedule          *KeySchedule      `json:"schedule,omitempty"`
+	CountPolicy       string            `json:"count_policy,omitempty"`
+	Residency         string            `json:"residency,omitempty"`
+	Pool              string            `json:"pool,omitempty"`
+	// AlertThresholds are the percentages of the key's quota that raise a usage alert,
+	// mj3gc.usage-alerts.thresholds when empty; AlertsSent are those raised this period.
+	AlertThresholds []int `json:"alert_thresholds,omitempty"`
+	AlertsSent      []int `json:"alerts_sent,omitempty"`
+	// PinnedAuths and PinnedProviders route the key's requests only through these
+	// upstream credentials (auth IDs) or providers, e.g. dedicated premium accounts.
+	PinnedAuths     []string  `json:"pinned_auths,omitempty"`
+	PinnedProviders []string  `json:"pinned_providers,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+	DeletedAt       time.Time `json:"deleted_at,omitempty"`
+}
+
+// Limits returns the enforcement settings of the key.
+func (k APIKey) Limits() quota.Limits {
+	return quota.Limits{Enabled: k.Enabled, TotalLimit: k.TotalLimit, ConcurrencyLimit: k.ConcurrencyLimit}
 }
 
 type Store struct {
-	mu       sync.RWMutex
-	path     string
-	data     Data
-	inflight map[string]int
+	mu        sync.RWMutex
+	path      string
+	data      Data
+	inflight  map[string]int
+	settings  config.MJ3GCConfig
+	runtime   runtimeState
+	audit     auditLog
+	locks     lockStats
+	backend   Backend
+	counters  CounterBackend
+	telemetry telemetry
+	index     storeIndex
+	changes   changeTracker
+	flush     flusher
+	journal   usageJournal
+	anonymous anonymousBudget
+	load      loadMonitor
+	admission admissionGate
+	fileWatch fileWatchState
+	backups   backupState
+	oauth     oauthState
+	events    requestEventFeed
+	rates     rateLimiter
+	persisted persistHealth
+	saveTrace saveTracer
+	cdc       changeCapture
+	alerts    alertDispatcher
+
+	// dataKey encrypts the JSON data file; dataKeyErr holds a key that failed to load so
+	// the store refuses to fall back to plaintext.
+	dataKey    []byte
+	dataKeyErr error
+	// signKey signs the JSON data file. tampered is set when the loaded file failed
+	// signature verification under mj3gc.signature-mismatch "read-only".
+	signKey  []byte
+	tampered bool
 }
 
 var defaultStore = NewStore()
@@ -80,17 +207,16 @@ func (s *Store) SetPath(path string) {
 	if s == nil {
 		return
 	}
-	s.mu.Lock()
+	unlock := s.lock("SetPath")
 	s.path = strings.TrimSpace(path)
-	s.mu.Unlock()
+	unlock()
 }
 
 func (s *Store) Path() string {
 	if s == nil {
 		return ""
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	defer s.rlock("Path")()
 	return s.path
 }
 
@@ -98,30 +224,82 @@ func (s *Store) Load() error {
 	if s == nil {
 		return nil
 	}
-	path := s.Path()
-	if path == "" {
+	backend := s.currentBackend()
+	if backend == nil {
 		return ErrInvalidConfiguration
 	}
-	raw, err := os.ReadFile(path)
+	data, err := backend.Load()
+	tampered := false
 	if err != nil {
-		if os.IsNotExist(err) {
-			s.mu.Lock()
-			s.data = Data{Version: 1, UpdatedAt: time.Now()}
-			s.mu.Unlock()
+		if isNotExist(err) {
+			unlock := s.lock("Load")
+			s.data = Data{Version: CurrentDataVersion, UpdatedAt: time.Now()}
+			s.tampered = false
+			s.resetChangesLocked()
+			unlock()
+			s.loadAudit()
 			return nil
 		}
-		return err
+		if !errors.Is(err, ErrSignatureMismatch) || s.signatureMismatch() != SignatureReadOnly {
+			return err
+		}
+		log.Errorf("%v; loaded read-only, changes will not be saved", err)
+		tampered = true
 	}
-	var data Data
-	if err := json.Unmarshal(raw, &data); err != nil {
+	original := data
+	original.Users = append([]User(nil), data.Users...)
+	original.APIKeys = append([]APIKey(nil), data.APIKeys...)
+	migrated, err := s.migrateDataTraced(&data)
+	if err != nil {
 		return err
 	}
-	if data.Version == 0 {
-		data.Version = 1
+	mode := s.loadValidation()
+	issues := validateData(&data, mode == ValidationRepair)
+	for _, issue := range issues {
+		log.Warnf("mj3gc: %s %s: %s (%s)", issue.Kind, issue.ID, issue.Problem, issue.Action)
 	}
-	s.mu.Lock()
+	if len(issues) > 0 && mode == ValidationStrict {
+		return fmt.Errorf("mj3gc: %w: %d problems found, see log", ErrInvalidData, len(issues))
+	}
+	repaired := len(issues) > 0 && mode == ValidationRepair
+	// Hash before the data becomes the change baseline so no plain secret is recorded
+	// in the change history.
+	hashed := 0
+	if s.Settings().HashKeySecrets && !tampered {
+		hashed = data.hashSecrets()
+	}
+	unlock := s.lock("Load")
 	s.data = data
-	s.mu.Unlock()
+	s.tampered = tampered
+	s.resetChangesLocked()
+	unlock()
+	s.noteFileState(original)
+	if (migrated || repaired) && !s.ReadOnly() {
+		backup, err := s.backupData(original)
+		if err != nil {
+			return fmt.Errorf("mj3gc: back up data before rewriting it: %w", err)
+		}
+		if err := s.Save(); err != nil {
+			return err
+		}
+		if migrated {
+			log.Infof("mj3gc: migrated data from v%d to v%d (backup: %s)", original.Version, data.Version, backup)
+		}
+		if repaired {
+			log.Infof("mj3gc: repaired %d problems in the data file (backup: %s)", len(issues), backup)
+		}
+	} else if hashed > 0 && !s.ReadOnly() {
+		if err := s.Save(); err != nil {
+			return err
+		}
+	}
+	if hashed > 0 {
+		log.Infof("mj3gc: replaced %d key secrets by their digests", hashed)
+	}
+	if s.journalEnabled() {
+		s.replayUsageJournal()
+	}
+	s.loadAudit()
 	return nil
 }
 
@@ -129,53 +307,228 @@ func (s *Store) Save() error {
 	if s == nil {
 		return nil
 	}
-	path := s.Path()
-	if path == "" {
+	backend := s.currentBackend()
+	if backend == nil {
 		return ErrInvalidConfiguration
 	}
-	s.mu.RLock()
+	if s.ReadOnly() {
+		return ErrReadOnly
+	}
+	s.hashSecretsBeforeSave()
+	journaled := s.journalEnabled()
+	if journaled {
+		// Hold the journal while saving so no record lands between the snapshot and
+		// the truncation that follows it.
+		s.journal.mu.Lock()
+		defer s.journal.mu.Unlock()
+	}
+	unlock := s.lockUsage("Save")
+	s.recordChangesLocked()
 	data := s.snapshotLocked()
-	s.mu.RUnlock()
+	unlock()
 	data.UpdatedAt = time.Now()
-	payload, err := json.MarshalIndent(data, "", "  ")
+	span := s.startPersistSpan(PersistOpSave, nil)
+	err := backend.Save(data)
+	span.end(s.dataFileSize(), err)
+	s.notePersist(err)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
-		return err
+	s.noteFileState(data)
+	if journaled {
+		return s.truncateJournalLocked()
 	}
-	tmp, err := os.CreateTemp(filepath.Dir(path), "mj3gc-*.json")
-	if err != nil {
-		return err
+	return nil
+}
+
+// SaveUsage persists a usage change of delta that EndRequest already applied to the key
+// with the given id. Shared backends apply the increment atomically and return the global
+// total unless a CounterBackend already owns the counters; backends that cannot update
+// counters in place fall back to a full Save. With mj3gc.usage-journal the file backend
+// appends to the usage journal instead; otherwise local persistence is deferred to the
+// background flusher while it runs.
+func (s *Store) SaveUsage(keyID string, delta int64) error {
+	if s == nil {
+		return nil
+	}
+	if s.chaosPersistFailure(keyID) {
+		return ErrChaosPersistence
+	}
+	backend := s.currentBackend()
+	if backend == nil {
+		return ErrInvalidConfiguration
+	}
+	if counter, ok := backend.(UsageCounter); ok && s.counterBackend() == nil {
+		total, err := counter.IncrementUsage(keyID, delta)
+		if err != nil {
+			return err
+		}
+		s.setUsedCount(keyID, total)
+		return nil
+	}
+	if s.ReadOnly() {
+		return ErrReadOnly
+	}
+	if s.journalEnabled() {
+		return s.appendUsage(keyID)
+	}
+	if s.deferUsage(keyID) {
+		return nil
+	}
+	saver, ok := backend.(UsageSaver)
+	if !ok {
+		return s.Save()
+	}
+	key, ok := s.FindAPIKeyByID(keyID)
+	if !ok {
+		return ErrKeyNotFound
 	}
-	defer func() {
-		_ = tmp.Close()
-		_ = os.Remove(tmp.Name())
-	}()
-	if _, err := tmp.Write(payload); err != nil {
+	return saver.SaveUsage(key.ID, key.UsedCount)
+}
+
+// ResetUsage clears the usage counter of the key with the given id. Callers still
+// persist the store afterwards; shared backends are reset immediately.
+func (s *Store) ResetUsage(id string) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidConfiguration
+	}
+	if counter, ok := s.currentBackend().(UsageCounter); ok {
+		if err := counter.ResetUsage(id); err != nil {
+			return APIKey{}, err
+		}
+	}
+	if counters := s.counterBackend(); counters != nil {
+		if err := counters.ResetUsage(id); err != nil {
+			return APIKey{}, err
+		}
+	}
+	defer s.lockUsage("ResetUsage")()
+	if i, ok := s.keyIDIndexLocked(id); ok {
+		s.data.APIKeys[i].UsedCount = 0
+		s.data.APIKeys[i].UsedTokens = 0
+		s.data.APIKeys[i].SpentUSD = 0
+		return s.data.APIKeys[i], nil
+	}
+	return APIKey{}, ErrKeyNotFound
+}
+
+// setUsedCount stores a usage total observed after a counted request.
+func (s *Store) setUsedCount(id string, used int64) {
+	s.setLastUse(id, used, "")
+}
+
+// setLastUse stores a usage total observed after a counted request made from
+// clientIP, which may be empty when unknown.
+func (s *Store) setLastUse(id string, used int64, clientIP string) {
+	defer s.lockUsage("setLastUse")()
+	if i, ok := s.keyIDIndexLocked(id); ok {
+		s.data.APIKeys[i].UsedCount = used
+		s.data.APIKeys[i].LastUsedAt = time.Now()
+		if clientIP != "" {
+			s.data.APIKeys[i].LastUsedIP = clientIP
+		}
+	}
+}
+
+// Refresh replaces the in-memory users and keys with the backend contents while
+// keeping in-flight counters. It lets instances sharing a backend observe each
+// other's changes.
+func (s *Store) Refresh() error {
+	if s == nil {
+		return nil
+	}
+	backend := s.currentBackend()
+	if backend == nil {
+		return ErrInvalidConfiguration
+	}
+	data, err := backend.Load()
+	if err != nil {
+		if isNotExist(err) {
+			return nil
+		}
 		return err
 	}
-	if err := tmp.Close(); err != nil {
+	// Another instance owns the upgrade of shared data; only migrate the in-memory copy.
+	if _, err := migrateData(&data); err != nil {
 		return err
 	}
-	return os.Rename(tmp.Name(), path)
+	defer s.lock("Refresh")()
+	s.data = data
+	s.tampered = false
+	s.resetChangesLocked()
+	return nil
 }
 
 func (s *Store) snapshotLocked() Data {
 	data := Data{
-		Version: s.data.Version,
-		Users:   append([]User(nil), s.data.Users...),
-		APIKeys: append([]APIKey(nil), s.data.APIKeys...),
+		Version:      s.data.Version,
+		Revision:     s.data.Revision,
+		Changes:      s.data.Changes,
+		Users:        cloneRecords(s.data.Users, User.clone),
+		APIKeys:      cloneRecords(s.data.APIKeys, APIKey.clone),
+		ArchivedKeys: cloneRecords(s.data.ArchivedKeys, APIKey.clone),
+		DeletedUsers: cloneRecords(s.data.DeletedUsers, User.clone),
+		DeletedKeys:  cloneRecords(s.data.DeletedKeys, APIKey.clone),
+		Quarantine:   append([]QuarantinedRecord(nil), s.data.Quarantine...),
+
+		Announcements:       append([]Announcement(nil), s.data.Announcements...),
+		ReportSubscriptions: append([]ReportSubscription(nil), s.data.ReportSubscriptions...),
+		RevokedSecrets:      s.data.RevokedSecrets,
 	}
 	return data
 }
 
+// clone returns a copy of the user that shares no memory with u.
+func (u User) clone() User {
+	if u.Billing != nil {
+		billing := *u.Billing
+		u.Billing = &billing
+	}
+	return u
+}
+
+// clone returns a copy of the key that shares no slices or maps with k.
+func (k APIKey) clone() APIKey {
+	k.LimitHistory = slices.Clone(k.LimitHistory)
+	k.AllowedUserAgents = slices.Clone(k.AllowedUserAgents)
+	k.AllowedIPs = slices.Clone(k.AllowedIPs)
+	k.AllowedOrigins = slices.Clone(k.AllowedOrigins)
+	k.AllowedModels = slices.Clone(k.AllowedModels)
+	k.DeniedModels = slices.Clone(k.DeniedModels)
+	k.ModelAliases = maps.Clone(k.ModelAliases)
+	k.Features = slices.Clone(k.Features)
+	k.Scopes = slices.Clone(k.Scopes)
+	k.Tags = maps.Clone(k.Tags)
+	if k.Schedule != nil {
+		schedule := *k.Schedule
+		schedule.Days = slices.Clone(schedule.Days)
+		k.Schedule = &schedule
+	}
+	k.AlertThresholds = slices.Clone(k.AlertThresholds)
+	k.AlertsSent = slices.Clone(k.AlertsSent)
+	k.PinnedAuths = slices.Clone(k.PinnedAuths)
+	k.PinnedProviders = slices.Clone(k.PinnedProviders)
+	return k
+}
+
+// cloneRecords returns a copy of records with every record copied by clone, so
+// snapshots can be changed without touching the store.
+func cloneRecords[T any](records []T, clone func(T) T) []T {
+	if len(records) == 0 {
+		return nil
+	}
+	out := make([]T, len(records))
+	for i, r := range records {
+		out[i] = clone(r)
+	}
+	return out
+}
+
 func (s *Store) Snapshot() Data {
 	if s == nil {
 		return Data{}
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	defer s.rlock("Snapshot")()
 	return s.snapshotLocked()
 }
 
@@ -183,6 +536,11 @@ func (s *Store) UpsertUser(user User) (User, error) {
 	if s == nil {
 		return User{}, ErrInvalidConfiguration
 	}
+	defer s.lock("UpsertUser")()
+	return s.data.upsertUser(user)
+}
+
+func (d *Data) upsertUser(user User) (User, error) {
 	if strings.TrimSpace(user.Username) == "" {
 		return User{}, fmt.Errorf("username required")
 	}
@@ -195,11 +553,11 @@ func (s *Store) UpsertUser(user User) (User, error) {
 	if user.CreatedAt.IsZero() {
 		user.CreatedAt = time.Now()
 	}
+	if user.ReferralCode == "" {
+		user.ReferralCode = newReferralCode()
+	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for _, existing := range s.data.Users {
+	for _, existing := range d.Users {
 		if strings.EqualFold(existing.Username, user.Username) && existing.ID != user.ID {
 			return User{}, ErrDuplicateUsername
 		}
@@ -207,39 +565,45 @@ func (s *Store) UpsertUser(user User) (User, error) {
 
 	if user.ID == "" {
 		user.ID = newID("usr")
-		s.data.Users = append(s.data.Users, user)
+		d.Users = append(d.Users, user)
 	} else {
 		updated := false
-		for i := range s.data.Users {
-			if s.data.Users[i].ID == user.ID {
-				s.data.Users[i] = user
+		for i := range d.Users {
+			if d.Users[i].ID == user.ID {
+				d.Users[i] = user
 				updated = true
 				break
 			}
 		}
 		if !updated {
-			s.data.Users = append(s.data.Users, user)
+			d.Users = append(d.Users, user)
 		}
 	}
 
 	return user, nil
 }
 
+// DeleteUser moves a user to the trash, from where it can be restored until the
+// retention period purges it.
 func (s *Store) DeleteUser(id string) error {
 	if s == nil {
 		return ErrInvalidConfiguration
 	}
+	defer s.lock("DeleteUser")()
+	return s.data.deleteUser(id)
+}
+
+func (d *Data) deleteUser(id string) error {
 	if strings.TrimSpace(id) == "" {
 		return ErrUserNotFound
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	out := make([]User, 0, len(s.data.Users))
+	out := make([]User, 0, len(d.Users))
 	found := false
-	for _, u := range s.data.Users {
+	for _, u := range d.Users {
 		if u.ID == id {
 			found = true
+			u.DeletedAt = time.Now()
+			d.DeletedUsers = append(d.DeletedUsers, u)
 			continue
 		}
 		out = append(out, u)
@@ -247,7 +611,7 @@ func (s *Store) DeleteUser(id string) error {
 	if !found {
 		return ErrUserNotFound
 	}
-	s.data.Users = out
+	d.Users = out
 	return nil
 }
 
@@ -259,12 +623,9 @@ func (s *Store) FindUserByUsername(username string) (User, bool) {
 	if username == "" {
 		return User{}, false
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, u := range s.data.Users {
-		if strings.EqualFold(u.Username, username) {
-			return u, true
-		}
+	defer s.rlock("FindUserByUsername")()
+	if i, ok := s.usernameIndexLocked(username); ok {
+		return s.data.Users[i], true
 	}
 	return User{}, false
 }
@@ -273,12 +634,9 @@ func (s *Store) FindUserByID(id string) (User, bool) {
 	if s == nil {
 		return User{}, false
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, u := range s.data.Users {
-		if u.ID == id {
-			return u, true
-		}
+	defer s.rlock("FindUserByID")()
+	if i, ok := s.userIDIndexLocked(id); ok {
+		return s.data.Users[i], true
 	}
 	return User{}, false
 }
@@ -298,6 +656,22 @@ func (s *Store) UpsertAPIKey(key APIKey) (APIKey, error) {
 	if s == nil {
 		return APIKey{}, ErrInvalidConfiguration
 	}
+	defer s.lock("UpsertAPIKey")()
+	var previous string
+	if i, ok := s.keyIDIndexLocked(key.ID); ok && key.ID != "" {
+		previous = s.data.APIKeys[i].Key
+	}
+	if err := s.data.checkKeyLimit(key, s.settings.MaxKeysPerUser); err != nil {
+		return APIKey{}, err
+	}
+	updated, err := s.data.upsertAPIKey(key)
+	if err == nil && previous != updated.Key {
+		s.revokeSecretLocked(previous)
+	}
+	return updated, err
+}
+
+func (d *Data) upsertAPIKey(key APIKey) (APIKey, error) {
 	if strings.TrimSpace(key.Key) == "" {
 		return APIKey{}, fmt.Errorf("api key required")
 	}
@@ -305,49 +679,90 @@ func (s *Store) UpsertAPIKey(key APIKey) (APIKey, error) {
 		key.CreatedAt = time.Now()
 	}
 
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	for _, existing := range s.data.APIKeys {
-		if existing.Key == key.Key && existing.ID != key.ID {
+	// Stored secrets may be digests, so compare against both forms.
+	digest := secretDigest(key.Key)
+	sameSecret := func(stored string) bool { return stored != "" && (stored == key.Key || stored == digest) }
+	for _, existing := range d.APIKeys {
+		if (sameSecret(existing.Key) || sameSecret(existing.PreviousKey)) && existing.ID != key.ID {
+			return APIKey{}, ErrDuplicateAPIKey
+		}
+	}
+	for _, archived := range d.ArchivedKeys {
+		if archived.ID == key.ID && key.ID != "" {
+			return APIKey{}, ErrKeyArchived
+		}
+		if sameSecret(archived.Key) {
 			return APIKey{}, ErrDuplicateAPIKey
 		}
 	}
+	key.ParentID = strings.TrimSpace(key.ParentID)
+	if err := d.checkParent(key); err != nil {
+		return APIKey{}, err
+	}
+	if key.Enabled {
+		key.DisabledAt = time.Time{}
+		if key.DisabledReason != "" {
+			key.DisabledReason = ""
+			key.ReenabledAt = time.Now()
+		}
+	} else if key.DisabledAt.IsZero() {
+		key.DisabledAt = time.Now()
+	}
 
 	if key.ID == "" {
 		key.ID = newID("key")
-		s.data.APIKeys = append(s.data.APIKeys, key)
+		trackResetInterval(APIKey{}, &key, time.Now())
+		d.APIKeys = append(d.APIKeys, key)
 	} else {
 		updated := false
-		for i := range s.data.APIKeys {
-			if s.data.APIKeys[i].ID == key.ID {
-				s.data.APIKeys[i] = key
+		for i := range d.APIKeys {
+			if d.APIKeys[i].ID == key.ID {
+				trackLimitChange(d.APIKeys[i], &key, time.Now())
+				trackResetInterval(d.APIKeys[i], &key, time.Now())
+				d.APIKeys[i] = key
 				updated = true
 				break
 			}
 		}
 		if !updated {
-			s.data.APIKeys = append(s.data.APIKeys, key)
+			trackResetInterval(APIKey{}, &key, time.Now())
+			d.APIKeys = append(d.APIKeys, key)
 		}
 	}
 
 	return key, nil
 }
 
+// DeleteAPIKey moves a key to the trash. Trashed keys no longer authenticate.
 func (s *Store) DeleteAPIKey(id string) error {
 	if s == nil {
 		return ErrInvalidConfiguration
 	}
+	defer s.lock("DeleteAPIKey")()
+	var value string
+	if i, ok := s.keyIDIndexLocked(id); ok {
+		value = s.data.APIKeys[i].Key
+	}
+	if err := s.data.deleteAPIKey(id); err != nil {
+		return err
+	}
+	s.revokeSecretLocked(value)
+	delete(s.inflight, id)
+	s.rates.forget(id)
+	return nil
+}
+
+func (d *Data) deleteAPIKey(id string) error {
 	if strings.TrimSpace(id) == "" {
 		return ErrKeyNotFound
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	out := make([]APIKey, 0, len(s.data.APIKeys))
+	out := make([]APIKey, 0, len(d.APIKeys))
 	found := false
-	for _, k := range s.data.APIKeys {
+	for _, k := range d.APIKeys {
 		if k.ID == id {
 			found = true
+			k.DeletedAt = time.Now()
+			d.DeletedKeys = append(d.DeletedKeys, k)
 			continue
 		}
 		out = append(out, k)
@@ -355,7 +770,7 @@ func (s *Store) DeleteAPIKey(id string) error {
 	if !found {
 		return ErrKeyNotFound
 	}
-	s.data.APIKeys = out
+	d.APIKeys = out
 	return nil
 }
 
@@ -367,12 +782,23 @@ func (s *Store) FindAPIKey(value string) (APIKey, bool) {
 	if value == "" {
 		return APIKey{}, false
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, k := range s.data.APIKeys {
-		if k.Key == value {
-			return k, true
-		}
+	defer s.rlock("FindAPIKey")()
+	if i, ok := s.secretIndexLocked(value); ok {
+		return s.data.APIKeys[i], true
+	}
+	return APIKey{}, false
+}
+
+// FindAPIKeyByPrincipal returns the key an access provider result names, which is the
+// stored Key and may be a digest. Unlike FindAPIKey it must not be used with values
+// supplied by clients.
+func (s *Store) FindAPIKeyByPrincipal(principal string) (APIKey, bool) {
+	if s == nil || principal == "" {
+		return APIKey{}, false
+	}
+	defer s.rlock("FindAPIKeyByPrincipal")()
+	if i, ok := s.principalIndexLocked(principal); ok {
+		return s.data.APIKeys[i], true
 	}
 	return APIKey{}, false
 }
@@ -385,12 +811,9 @@ func (s *Store) FindAPIKeyByID(id string) (APIKey, bool) {
 	if id == "" {
 		return APIKey{}, false
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	for _, k := range s.data.APIKeys {
-		if k.ID == id {
-			return k, true
-		}
+	defer s.rlock("FindAPIKeyByID")()
+	if i, ok := s.keyIDIndexLocked(id); ok {
+		return s.data.APIKeys[i], true
 	}
 	return APIKey{}, false
 }
@@ -399,8 +822,7 @@ func (s *Store) ListAPIKeys() []APIKey {
 	if s == nil {
 		return nil
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	defer s.rlock("ListAPIKeys")()
 	out := make([]APIKey, len(s.data.APIKeys))
 	copy(out, s.data.APIKeys)
 	return out
@@ -414,8 +836,7 @@ func (s *Store) ListAPIKeysByUser(userID string) []APIKey {
 	if userID == "" {
 		return nil
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	defer s.rlock("ListAPIKeysByUser")()
 	out := make([]APIKey, 0, len(s.data.APIKeys))
 	for _, k := range s.data.APIKeys {
 		if k.UserID == userID {
@@ -429,8 +850,7 @@ func (s *Store) ListUsers() []User {
 	if s == nil {
 		return nil
 	}
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	defer s.rlock("ListUsers")()
 	out := make([]User, len(s.data.Users))
 	copy(out, s.data.Users)
 	return out
@@ -444,32 +864,45 @@ func (s *Store) BeginRequest(value string) (APIKey, error) {
 	if value == "" {
 		return APIKey{}, ErrKeyNotFound
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for i := range s.data.APIKeys {
-		if s.data.APIKeys[i].Key != value {
-			continue
-		}
-		key := s.data.APIKeys[i]
-		if !key.Enabled {
-			return APIKey{}, ErrKeyDisabled
-		}
-		if key.TotalLimit > 0 && key.UsedCount >= key.TotalLimit {
-			return APIKey{}, ErrQuotaExceeded
-		}
-		if key.ConcurrencyLimit > 0 {
-			current := s.inflight[key.ID]
-			if current >= key.ConcurrencyLimit {
-				return APIKey{}, ErrConcurrencyExceeded
-			}
-			s.inflight[key.ID] = current + 1
-		}
-		return key, nil
+	s.resetDueUsageFor(value, time.Now())
+	if counters := s.counterBackend(); counters != nil {
+		return s.beginShared(counters, value)
 	}
-	return APIKey{}, ErrKeyNotFound
+	defer s.lockUsage("BeginRequest")()
+	i, ok := s.principalIndexLocked(value)
+	if !ok {
+		return APIKey{}, ErrKeyNotFound
+	}
+	key := s.data.APIKeys[i]
+	if err := quota.Check(key.Limits(), key.UsedCount); err != nil {
+		return APIKey{}, err
+	}
+	now := time.Now()
+	if err := key.checkSchedule(now); err != nil {
+		return APIKey{}, err
+	}
+	if err := key.checkTokenBudget(); err != nil {
+		return APIKey{}, err
+	}
+	if err := s.parentQuotaErrorLocked(key); err != nil {
+		return APIKey{}, err
+	}
+	if key.ConcurrencyLimit > 0 && s.inflight[key.ID] >= key.ConcurrencyLimit {
+		return APIKey{}, ErrConcurrencyExceeded
+	}
+	// The rate window is charged last so requests rejected by another check do not use it.
+	if err := s.rates.admit(key.ID, key.RPMLimit, now); err != nil {
+		return APIKey{}, err
+	}
+	if key.ConcurrencyLimit > 0 {
+		s.inflight[key.ID]++
+	}
+	return key, nil
 }
 
-func (s *Store) EndRequest(value string, count bool) {
+// EndRequest releases the concurrency slot taken by BeginRequest and, when count is
+// set, charges the request to the key and records clientIP as its last-used address.
+func (s *Store) EndRequest(value string, count bool, clientIP string) {
 	if s == nil {
 		return
 	}
@@ -477,23 +910,29 @@ func (s *Store) EndRequest(value string, count bool) {
 	if value == "" {
 		return
 	}
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	for i := range s.data.APIKeys {
-		if s.data.APIKeys[i].Key != value {
-			continue
-		}
-		key := &s.data.APIKeys[i]
-		if key.ConcurrencyLimit > 0 {
-			current := s.inflight[key.ID]
-			if current > 0 {
-				s.inflight[key.ID] = current - 1
-			}
+	if counters := s.counterBackend(); counters != nil {
+		s.endShared(counters, value, count, clientIP)
+		return
+	}
+	defer s.lockUsage("EndRequest")()
+	i, ok := s.principalIndexLocked(value)
+	if !ok {
+		return
+	}
+	key := &s.data.APIKeys[i]
+	if key.ConcurrencyLimit > 0 {
+		current := s.inflight[key.ID]
+		if current > 0 {
+			s.inflight[key.ID] = current - 1
 		}
-		if count {
-			key.UsedCount++
+	}
+	if count {
+		key.UsedCount++
+		key.LastUsedAt = time.Now()
+		if clientIP != "" {
+			key.LastUsedIP = clientIP
 		}
-		return
+		s.chargeParentLocked(*key, 1, 0, 0)
 	}
 }
 
@@ -528,6 +967,26 @@ func SanitizeUser(user User) User {
 	return user
 }
 
+// SanitizeKey masks the key secrets, leaving their last four characters for recognition.
+// A hashed secret is shown as its display prefix instead.
 func SanitizeKey(key APIKey) APIKey {
+	if IsHashedSecret(key.Key) {
+		key.Key = key.KeyPrefix + "****"
+	} else {
+		key.Key = MaskSecret(key.Key)
+	}
+	key.PreviousKey = MaskSecret(key.PreviousKey)
 	return key
 }
+
+// MaskSecret replaces all but the last four characters of value. Digests are masked
+// entirely.
+func MaskSecret(value string) string {
+	if len(value) <= 8 || IsHashedSecret(value) {
+		if value == "" {
+			return ""
+		}
+		return "****"
+	}
+	return "****" + value[len(value)-4:]
+}
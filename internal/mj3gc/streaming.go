@@ -0,0 +1,104 @@
+package mj3gc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+	"github.com/tidwall/sjson"
+)
+
+// Streaming policies of APIKey.Streaming.
+const (
+	// StreamingAllow lets clients choose. It is the default.
+	StreamingAllow = "allow"
+	// StreamingReject refuses streaming requests with 403.
+	StreamingReject = "reject"
+	// StreamingOff serves streaming requests as regular ones, so no SSE is sent.
+	StreamingOff = "off"
+)
+
+const geminiStreamMethod = ":streamGenerateContent"
+
+// NormalizeStreamingPolicy validates a streaming policy, mapping blank to the default.
+func NormalizeStreamingPolicy(policy string) (string, error) {
+	policy = strings.ToLower(strings.TrimSpace(policy))
+	switch policy {
+	case "", StreamingAllow:
+		return "", nil
+	case StreamingReject, StreamingOff:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unsupported streaming policy %q", policy)
+	}
+}
+
+// StreamingMiddleware enforces the streaming policy of the authenticated key on
+// "stream": true bodies and Gemini :streamGenerateContent routes. StreamingReject
+// aborts them with 403; StreamingOff clears the stream flag, or routes Gemini requests
+// to :generateContent, before the handler sees them. Keys allowing streaming, and
+// other access providers, pass through unchanged.
+func StreamingMiddleware(store *Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if store == nil || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+		principal := c.GetString("apiKey")
+		if principal == "" || principal == AnonymousPrincipal {
+			c.Next()
+			return
+		}
+		key, ok := store.FindAPIKeyByPrincipal(principal)
+		if !ok || key.Streaming == "" || key.Streaming == StreamingAllow {
+			c.Next()
+			return
+		}
+		action := c.Param("action")
+		geminiStream := strings.HasSuffix(action, geminiStreamMethod)
+		var body []byte
+		if !geminiStream {
+			raw, err := peekBody(c)
+			if err != nil || !gjson.GetBytes(raw, "stream").Bool() {
+				c.Next()
+				return
+			}
+			body = raw
+		}
+		if key.Streaming == StreamingReject {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "streaming is disabled for this API key",
+				"code":  "streaming_not_allowed",
+			})
+			return
+		}
+		if geminiStream {
+			for i := range c.Params {
+				if c.Params[i].Key == "action" {
+					c.Params[i].Value = strings.TrimSuffix(action, geminiStreamMethod) + ":generateContent"
+				}
+			}
+			query := c.Request.URL.Query()
+			query.Del("alt")
+			c.Request.URL.RawQuery = query.Encode()
+			c.Next()
+			return
+		}
+		updated, err := sjson.SetBytes(body, "stream", false)
+		if err == nil {
+			// OpenAI rejects stream_options on non-streaming requests.
+			updated, err = sjson.DeleteBytes(updated, "stream_options")
+		}
+		if err != nil {
+			c.Next()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(updated))
+		c.Request.ContentLength = int64(len(updated))
+		c.Next()
+	}
+}
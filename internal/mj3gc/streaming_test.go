@@ -0,0 +1,72 @@
+package mj3gc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tidwall/gjson"
+)
+
+func TestStreamingMiddleware(t *testing.T) {
+	store := NewStore()
+	for _, key := range []APIKey{
+		{Key: "sk-reject", Enabled: true, Streaming: StreamingReject},
+		{Key: "sk-off", Enabled: true, Streaming: StreamingOff},
+		{Key: "sk-open", Enabled: true},
+	} {
+		if _, err := store.UpsertAPIKey(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := gin.New()
+	withKey := func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}
+	echo := func(c *gin.Context) {
+		raw, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", raw)
+	}
+	group := router.Group("", withKey, StreamingMiddleware(store))
+	group.POST("/v1/chat/completions", echo)
+	group.POST("/v1beta/models/*action", func(c *gin.Context) {
+		c.String(http.StatusOK, c.Param("action")+"?"+c.Request.URL.RawQuery)
+	})
+
+	send := func(key, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	streamBody := `{"model":"m","stream":true,"stream_options":{"include_usage":true}}`
+	if rec := send("sk-reject", "/v1/chat/completions", streamBody); rec.Code != http.StatusForbidden || gjson.Get(rec.Body.String(), "code").String() != "streaming_not_allowed" {
+		t.Fatalf("reject = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := send("sk-reject", "/v1/chat/completions", `{"model":"m"}`); rec.Code != http.StatusOK {
+		t.Fatalf("reject without stream = %d", rec.Code)
+	}
+	if rec := send("sk-reject", "/v1beta/models/gemini-2.5-pro:streamGenerateContent", `{}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("reject gemini stream = %d", rec.Code)
+	}
+
+	rec := send("sk-off", "/v1/chat/completions", streamBody)
+	if rec.Code != http.StatusOK || gjson.Get(rec.Body.String(), "stream").Bool() || gjson.Get(rec.Body.String(), "stream_options").Exists() {
+		t.Fatalf("off = %d %s", rec.Code, rec.Body.String())
+	}
+	rec = send("sk-off", "/v1beta/models/gemini-2.5-pro:streamGenerateContent?alt=sse", `{}`)
+	if got := rec.Body.String(); got != "/gemini-2.5-pro:generateContent?" {
+		t.Fatalf("off gemini routed to %q", got)
+	}
+
+	rec = send("sk-open", "/v1/chat/completions", streamBody)
+	if !gjson.Get(rec.Body.String(), "stream").Bool() {
+		t.Fatalf("open key body changed: %s", rec.Body.String())
+	}
+}
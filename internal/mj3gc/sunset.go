@@ -0,0 +1,95 @@
+package mj3gc
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DisabledReasonSunset marks keys suspended by SuspendSunsetKeys at their sunset date.
+const DisabledReasonSunset = "sunset"
+
+// Sunset returns a copy of the key scheduled to be suspended at, announcing the
+// deprecation from now when it was not announced before. A zero at cancels the sunset.
+func (k APIKey) Sunset(at, now time.Time) APIKey {
+	if at.IsZero() {
+		k.SunsetAt, k.DeprecatedAt = time.Time{}, time.Time{}
+		return k
+	}
+	k.SunsetAt = at.UTC()
+	if k.DeprecatedAt.IsZero() {
+		k.DeprecatedAt = now.UTC()
+	}
+	return k
+}
+
+// SunsetPassed reports whether the key has reached its sunset date at now.
+func (k APIKey) SunsetPassed(now time.Time) bool {
+	return !k.SunsetAt.IsZero() && !now.Before(k.SunsetAt)
+}
+
+// DisabledError returns the error requests of the disabled key are refused with:
+// ErrKeySuspended once it was suspended at its sunset date, ErrKeyDisabled otherwise.
+func (k APIKey) DisabledError() error {
+	if k.DisabledReason == DisabledReasonSunset {
+		return ErrKeySuspended
+	}
+	return ErrKeyDisabled
+}
+
+// SunsetRemaining returns the time left before the key's sunset date, zero once it
+// passed, and whether the key has one.
+func (k APIKey) SunsetRemaining(now time.Time) (time.Duration, bool) {
+	if k.SunsetAt.IsZero() {
+		return 0, false
+	}
+	return max(k.SunsetAt.Sub(now), 0), true
+}
+
+// setSunsetHeaders announces a planned sunset to clients with the Deprecation (RFC
+// 9745) and Sunset (RFC 8594) response headers.
+func setSunsetHeaders(c *gin.Context, key APIKey) {
+	if key.SunsetAt.IsZero() {
+		return
+	}
+	deprecated := key.DeprecatedAt
+	if deprecated.IsZero() {
+		deprecated = key.SunsetAt
+	}
+	c.Header("Deprecation", "@"+strconv.FormatInt(deprecated.Unix(), 10))
+	c.Header("Sunset", key.SunsetAt.UTC().Format(http.TimeFormat))
+}
+
+// SuspendSunsetKeys disables the enabled keys whose sunset date passed before now,
+// stamped with DisabledReasonSunset, and writes an audit entry for each.
+func (s *Store) SuspendSunsetKeys(now time.Time) []APIKey {
+	if s == nil {
+		return nil
+	}
+	var suspended []APIKey
+	unlock := s.lock("SuspendSunsetKeys")
+	for i := range s.data.APIKeys {
+		k := &s.data.APIKeys[i]
+		if !k.Enabled || !k.SunsetPassed(now) {
+			continue
+		}
+		k.Enabled = false
+		k.DisabledAt = now
+		k.DisabledReason = DisabledReasonSunset
+		suspended = append(suspended, *k)
+	}
+	unlock()
+	for _, k := range suspended {
+		s.RecordAudit(AuditEntry{
+			Actor:   "maintenance",
+			Action:  "key.disable",
+			Target:  k.ID,
+			Reason:  fmt.Sprintf("sunset at %s", FormatTimestamp(k.SunsetAt)),
+			Details: map[string]any{"disabled_reason": DisabledReasonSunset, "sunset_at": k.SunsetAt},
+		})
+	}
+	return suspended
+}
@@ -0,0 +1,71 @@
+package mj3gc
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSunsetHeadersAndSuspension(t *testing.T) {
+	store := NewStore()
+	now := time.Now()
+	sunset := now.Add(48 * time.Hour).Truncate(time.Second)
+	key, err := store.UpsertAPIKey(APIKey{Key: "sk-old", Enabled: true}.Sunset(sunset, now))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("apiKey", strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer "))
+	}, QuotaMiddleware(store))
+	router.POST("/v1/chat/completions", func(c *gin.Context) { c.Status(http.StatusOK) })
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/chat/completions", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer sk-old")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("before sunset: status %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Sunset"), sunset.UTC().Format(http.TimeFormat); got != want {
+		t.Fatalf("Sunset = %q, want %q", got, want)
+	}
+	if got := rec.Header().Get("Deprecation"); !strings.HasPrefix(got, "@") {
+		t.Fatalf("Deprecation = %q, want a @timestamp", got)
+	}
+	if remaining, ok := key.SunsetRemaining(now); !ok || remaining <= 47*time.Hour {
+		t.Fatalf("remaining = %v %v, want about two days", remaining, ok)
+	}
+
+	if suspended := store.SuspendSunsetKeys(now); len(suspended) != 0 {
+		t.Fatalf("suspended %d keys before their sunset", len(suspended))
+	}
+	if err := key.checkSchedule(sunset); !errors.Is(err, ErrKeySuspended) {
+		t.Fatalf("request at sunset: err=%v, want ErrKeySuspended", err)
+	}
+	suspended := store.SuspendSunsetKeys(sunset.Add(time.Minute))
+	if len(suspended) != 1 || suspended[0].Enabled || suspended[0].DisabledReason != DisabledReasonSunset {
+		t.Fatalf("suspended = %+v, want the key disabled for its sunset", suspended)
+	}
+	if err := suspended[0].DisabledError(); !errors.Is(err, ErrKeySuspended) {
+		t.Fatalf("suspended key: err=%v, want ErrKeySuspended", err)
+	}
+	if err := (APIKey{}).DisabledError(); !errors.Is(err, ErrKeyDisabled) {
+		t.Fatalf("disabled key: err=%v, want ErrKeyDisabled", err)
+	}
+
+	cancelled := key.Sunset(time.Time{}, now)
+	if !cancelled.SunsetAt.IsZero() || !cancelled.DeprecatedAt.IsZero() {
+		t.Fatalf("cancelled sunset = %+v", cancelled)
+	}
+}
@@ -0,0 +1,90 @@
+package mj3gc
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	maxTags           = 64
+	maxTagNameLength  = 64
+	maxTagValueLength = 256
+)
+
+// NormalizeTags trims tag names and values and validates them. Names may not be empty
+// or contain ':', which separates name and value in tag filters. An empty map yields
+// nil.
+func NormalizeTags(tags map[string]string) (map[string]string, error) {
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("at most %d tags are allowed", maxTags)
+	}
+	out := make(map[string]string, len(tags))
+	for name, value := range tags {
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		switch {
+		case name == "":
+			return nil, fmt.Errorf("tag names must not be empty")
+		case strings.Contains(name, ":"):
+			return nil, fmt.Errorf("tag %q must not contain ':'", name)
+		case len(name) > maxTagNameLength:
+			return nil, fmt.Errorf("tag %q is longer than %d characters", name, maxTagNameLength)
+		case len(value) > maxTagValueLength:
+			return nil, fmt.Errorf("value of tag %q is longer than %d characters", name, maxTagValueLength)
+		}
+		out[name] = value
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return out, nil
+}
+
+// TagFilter selects keys by tag. A filter without a value matches keys having the tag
+// at all; values compare case-insensitively and may use '*' wildcards.
+type TagFilter struct {
+	Name     string
+	Value    string
+	HasValue bool
+}
+
+// ParseTagFilter parses "name:value" or "name".
+func ParseTagFilter(raw string) TagFilter {
+	name, value, ok := strings.Cut(strings.TrimSpace(raw), ":")
+	return TagFilter{Name: strings.TrimSpace(name), Value: strings.TrimSpace(value), HasValue: ok}
+}
+
+// Match reports whether the key carries the tag.
+func (f TagFilter) Match(key APIKey) bool {
+	value, ok := key.Tags[f.Name]
+	if !ok {
+		return false
+	}
+	switch {
+	case !f.HasValue:
+		return true
+	case f.Value == "":
+		return value == ""
+	}
+	return matchPattern(f.Value, value)
+}
+
+// FilterKeysByTags returns the keys matching every filter.
+func FilterKeysByTags(keys []APIKey, filters []TagFilter) []APIKey {
+	if len(filters) == 0 {
+		return keys
+	}
+	out := make([]APIKey, 0, len(keys))
+	for _, key := range keys {
+		matched := true
+		for _, f := range filters {
+			if !f.Match(key) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			out = append(out, key)
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package mj3gc
+
+import "testing"
+
+func TestNormalizeTags(t *testing.T) {
+	got, err := NormalizeTags(map[string]string{" team ": " research ", "env": ""})
+	if err != nil || got["team"] != "research" || len(got) != 2 {
+		t.Fatalf("NormalizeTags = %v, %v", got, err)
+	}
+	for _, bad := range []map[string]string{{"": "x"}, {"team:a": "x"}} {
+		if _, err := NormalizeTags(bad); err == nil {
+			t.Fatalf("NormalizeTags(%v) accepted", bad)
+		}
+	}
+	if got, _ := NormalizeTags(map[string]string{}); got != nil {
+		t.Fatalf("empty tags = %v, want nil", got)
+	}
+}
+
+func TestFilterKeysByTags(t *testing.T) {
+	keys := []APIKey{
+		{ID: "a", Tags: map[string]string{"team": "research", "env": "prod"}},
+		{ID: "b", Tags: map[string]string{"team": "Research-EU"}},
+		{ID: "c", Tags: map[string]string{"env": ""}},
+		{ID: "d"},
+	}
+	cases := []struct {
+		filters []string
+		want    string
+	}{
+		{nil, "abcd"},
+		{[]string{"team:research"}, "a"},
+		{[]string{"team:research*"}, "ab"},
+		{[]string{"team"}, "ab"},
+		{[]string{"team:research", "env:prod"}, "a"},
+		{[]string{"env:"}, "c"},
+		{[]string{"owner:x"}, ""},
+	}
+	for _, tc := range cases {
+		var filters []TagFilter
+		for _, raw := range tc.filters {
+			filters = append(filters, ParseTagFilter(raw))
+		}
+		got := ""
+		for _, key := range FilterKeysByTags(keys, filters) {
+			got += key.ID
+		}
+		if got != tc.want {
+			t.Errorf("filters %v = %q, want %q", tc.filters, got, tc.want)
+		}
+	}
+}
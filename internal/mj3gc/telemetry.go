@@ -0,0 +1,144 @@
+package mj3gc
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// latencyBoundsMs are the explicit histogram bucket upper bounds in milliseconds.
+var latencyBoundsMs = []float64{50, 100, 250, 500, 1000, 2500, 5000, 10000, 30000, 60000, 120000}
+
+const (
+	outcomeSuccess  = "success"
+	outcomeFailure  = "failure"
+	outcomeRejected = "rejected"
+	outcomeShed     = "shed"
+	// outcomeAborted is a request the client disconnected from before it finished.
+	outcomeAborted = "aborted"
+	// outcomeTimeout is a request that failed because the upstream timed out.
+	outcomeTimeout = "timeout"
+)
+
+// KeyMetrics is a cumulative snapshot of request telemetry for one key.
+type KeyMetrics struct {
+	KeyID        string           `json:"key_id"`
+	Requests     map[string]int64 `json:"requests"`
+	LatencyCount int64            `json:"latency_count"`
+	LatencySumMs float64          `json:"latency_sum_ms"`
+	BucketCounts []int64          `json:"bucket_counts"`
+	BoundsMs     []float64        `json:"bounds_ms"`
+}
+
+type keyMetrics struct {
+	requests     map[string]int64
+	latencyCount int64
+	latencySumMs float64
+	buckets      []int64
+}
+
+type telemetry struct {
+	mu    sync.Mutex
+	start time.Time
+	keys  map[string]*keyMetrics
+}
+
+func (t *telemetry) entry(keyID string) *keyMetrics {
+	if t.keys == nil {
+		t.keys = make(map[string]*keyMetrics)
+		t.start = time.Now()
+	}
+	m, ok := t.keys[keyID]
+	if !ok {
+		m = &keyMetrics{requests: make(map[string]int64), buckets: make([]int64, len(latencyBoundsMs)+1)}
+		t.keys[keyID] = m
+	}
+	return m
+}
+
+// AbortedRequests returns how many requests of the key the client abandoned since startup.
+func (s *Store) AbortedRequests(keyID string) int64 { return s.RequestOutcome(keyID, outcomeAborted) }
+
+// TimeoutRequests returns how many requests of the key hit an upstream timeout since startup.
+func (s *Store) TimeoutRequests(keyID string) int64 { return s.RequestOutcome(keyID, outcomeTimeout) }
+
+// requestOutcome classifies a finished request. Client disconnects are told apart from
+// upstream timeouts, which surface as 408 or 504 responses or an expired deadline.
+func requestOutcome(status int, ctxErr error) string {
+	switch {
+	case errors.Is(ctxErr, context.Canceled):
+		return outcomeAborted
+	case errors.Is(ctxErr, context.DeadlineExceeded),
+		status == http.StatusRequestTimeout, status == http.StatusGatewayTimeout:
+		return outcomeTimeout
+	case status >= http.StatusBadRequest:
+		return outcomeFailure
+	default:
+		return outcomeSuccess
+	}
+}
+
+// RecordRequest adds a completed or rejected request to the per-key telemetry.
+// Rejected, shed and aborted requests only increment the request counter; completed
+// requests also feed the latency estimate used for load shedding.
+func (s *Store) RecordRequest(keyID, outcome string, latency time.Duration) {
+	if s == nil || keyID == "" {
+		return
+	}
+	s.telemetry.mu.Lock()
+	defer s.telemetry.mu.Unlock()
+	m := s.telemetry.entry(keyID)
+	m.requests[outcome]++
+	if outcome == outcomeRejected || outcome == outcomeShed || outcome == outcomeAborted {
+		return
+	}
+	s.load.observe(latency)
+	ms := durationMs(latency)
+	m.latencyCount++
+	m.latencySumMs += ms
+	idx := sort.SearchFloat64s(latencyBoundsMs, ms)
+	m.buckets[idx]++
+}
+
+// RequestOutcome returns how many requests of the key ended with outcome since startup.
+func (s *Store) RequestOutcome(keyID, outcome string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.telemetry.mu.Lock()
+	defer s.telemetry.mu.Unlock()
+	if m, ok := s.telemetry.keys[keyID]; ok {
+		return m.requests[outcome]
+	}
+	return 0
+}
+
+// KeyMetrics returns cumulative telemetry for every key seen since startup together
+// with the time collection started.
+func (s *Store) KeyMetrics() ([]KeyMetrics, time.Time) {
+	if s == nil {
+		return nil, time.Time{}
+	}
+	s.telemetry.mu.Lock()
+	defer s.telemetry.mu.Unlock()
+	out := make([]KeyMetrics, 0, len(s.telemetry.keys))
+	for id, m := range s.telemetry.keys {
+		requests := make(map[string]int64, len(m.requests))
+		for outcome, n := range m.requests {
+			requests[outcome] = n
+		}
+		out = append(out, KeyMetrics{
+			KeyID:        id,
+			Requests:     requests,
+			LatencyCount: m.latencyCount,
+			LatencySumMs: m.latencySumMs,
+			BucketCounts: append([]int64(nil), m.buckets...),
+			BoundsMs:     latencyBoundsMs,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].KeyID < out[j].KeyID })
+	return out, s.telemetry.start
+}
@@ -0,0 +1,45 @@
+package mj3gc
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequestOutcome(t *testing.T) {
+	cases := []struct {
+		status int
+		err    error
+		want   string
+	}{
+		{http.StatusOK, nil, outcomeSuccess},
+		{http.StatusBadGateway, nil, outcomeFailure},
+		{http.StatusOK, context.Canceled, outcomeAborted},
+		{http.StatusGatewayTimeout, nil, outcomeTimeout},
+		{http.StatusRequestTimeout, nil, outcomeTimeout},
+		{http.StatusOK, context.DeadlineExceeded, outcomeTimeout},
+	}
+	for _, tc := range cases {
+		if got := requestOutcome(tc.status, tc.err); got != tc.want {
+			t.Errorf("requestOutcome(%d, %v) = %q, want %q", tc.status, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestAbortedAndTimeoutCounters(t *testing.T) {
+	s := NewStore()
+	s.RecordRequest("k1", outcomeAborted, time.Second)
+	s.RecordRequest("k1", outcomeAborted, time.Second)
+	s.RecordRequest("k1", outcomeTimeout, time.Minute)
+	if got := s.AbortedRequests("k1"); got != 2 {
+		t.Fatalf("AbortedRequests = %d, want 2", got)
+	}
+	if got := s.TimeoutRequests("k1"); got != 1 {
+		t.Fatalf("TimeoutRequests = %d, want 1", got)
+	}
+	metrics, _ := s.KeyMetrics()
+	if len(metrics) != 1 || metrics[0].LatencyCount != 1 {
+		t.Fatalf("metrics = %+v, want only the timeout in the latency histogram", metrics)
+	}
+}
@@ -0,0 +1,100 @@
+package mj3gc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// MaxTemporaryKeyTTL bounds the lifetime of keys minted by CreateTemporaryKey.
+	MaxTemporaryKeyTTL = 7 * 24 * time.Hour
+	// DefaultTemporaryKeyQuota is the request quota of temporary keys minted without one.
+	DefaultTemporaryKeyQuota = 100
+)
+
+// Expired reports whether the key has an expiry time that has passed at now.
+func (k APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// TemporaryKeyRequest describes a short-lived key minted for a user, for example for a
+// demo or a CI job.
+type TemporaryKeyRequest struct {
+	UserID        string
+	Label         string
+	TTL           time.Duration
+	TotalLimit    int64
+	AllowedModels []string
+}
+
+// CreateTemporaryKey mints a key for the user that stops authenticating after the TTL
+// and carries a request quota, DefaultTemporaryKeyQuota when none is given. Expired
+// keys are removed by PurgeExpiredKeys. The returned key holds the generated secret.
+func (s *Store) CreateTemporaryKey(req TemporaryKeyRequest) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidConfiguration
+	}
+	userID := strings.TrimSpace(req.UserID)
+	if _, ok := s.FindUserByID(userID); !ok {
+		return APIKey{}, ErrUserNotFound
+	}
+	if req.TTL <= 0 || req.TTL > MaxTemporaryKeyTTL {
+		return APIKey{}, fmt.Errorf("ttl must be positive and at most %s", MaxTemporaryKeyTTL)
+	}
+	limit := req.TotalLimit
+	if limit == 0 {
+		limit = DefaultTemporaryKeyQuota
+	}
+	if limit < 0 {
+		return APIKey{}, fmt.Errorf("total_limit must be positive")
+	}
+	label := strings.TrimSpace(req.Label)
+	if label == "" {
+		label = "temporary"
+	}
+	secret, err := NewAPIKey()
+	if err != nil {
+		return APIKey{}, err
+	}
+	now := time.Now()
+	return s.UpsertAPIKey(APIKey{
+		Key:           secret,
+		Label:         label,
+		UserID:        userID,
+		Enabled:       true,
+		TotalLimit:    limit,
+		AllowedModels: NormalizePatterns(req.AllowedModels),
+		ExpiresAt:     now.Add(req.TTL),
+		CreatedAt:     now,
+	})
+}
+
+// PurgeExpiredKeys deletes, without keeping them in the trash, the keys whose expiry
+// passed before now, and returns their ids. Keys that still have child keys are kept.
+func (s *Store) PurgeExpiredKeys(now time.Time) []string {
+	if s == nil {
+		return nil
+	}
+	defer s.lock("PurgeExpiredKeys")()
+	parents := make(map[string]bool)
+	for _, k := range s.data.APIKeys {
+		if k.ParentID != "" {
+			parents[k.ParentID] = true
+		}
+	}
+	var purged []string
+	kept := s.data.APIKeys[:0]
+	for _, k := range s.data.APIKeys {
+		if !k.Expired(now) || parents[k.ID] {
+			kept = append(kept, k)
+			continue
+		}
+		purged = append(purged, k.ID)
+		s.revokeSecretLocked(k.Key)
+		delete(s.inflight, k.ID)
+		s.rates.forget(k.ID)
+	}
+	s.data.APIKeys = kept
+	return purged
+}
@@ -0,0 +1,45 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestTemporaryKeyExpiresAndIsPurged(t *testing.T) {
+	s := NewStore()
+	s.data.Users = []User{{ID: "usr-ci", Username: "ci"}}
+
+	if _, err := s.CreateTemporaryKey(TemporaryKeyRequest{UserID: "usr-missing", TTL: time.Hour}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("unknown user: err=%v, want ErrUserNotFound", err)
+	}
+	if _, err := s.CreateTemporaryKey(TemporaryKeyRequest{UserID: "usr-ci", TTL: MaxTemporaryKeyTTL + time.Minute}); err == nil {
+		t.Fatal("TTL above the maximum was accepted")
+	}
+
+	key, err := s.CreateTemporaryKey(TemporaryKeyRequest{UserID: "usr-ci", TTL: 30 * time.Minute})
+	if err != nil {
+		t.Fatalf("CreateTemporaryKey: %v", err)
+	}
+	if key.TotalLimit != DefaultTemporaryKeyQuota || key.Label != "temporary" || key.ExpiresAt.IsZero() {
+		t.Fatalf("key = %+v, want the default quota, label and an expiry", key)
+	}
+	if _, err := s.BeginRequest(key.Key); err != nil {
+		t.Fatalf("request before expiry: %v", err)
+	}
+	s.EndRequest(key.Key, true, "")
+
+	if purged := s.PurgeExpiredKeys(time.Now()); len(purged) != 0 {
+		t.Fatalf("purged %v before expiry", purged)
+	}
+	s.data.APIKeys[0].ExpiresAt = time.Now().Add(-time.Minute)
+	if _, err := s.BeginRequest(key.Key); !errors.Is(err, ErrKeyExpired) {
+		t.Fatalf("request after expiry: err=%v, want ErrKeyExpired", err)
+	}
+	if purged := s.PurgeExpiredKeys(time.Now()); len(purged) != 1 || purged[0] != key.ID {
+		t.Fatalf("purged %v, want %s", purged, key.ID)
+	}
+	if _, ok := s.FindAPIKeyByID(key.ID); ok {
+		t.Fatal("expired key still present after purge")
+	}
+}
@@ -0,0 +1,208 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidImport is returned by Import for malformed or inconsistent data.
+var ErrInvalidImport = errors.New("invalid import")
+
+// ExportOptions selects which secrets an export carries.
+type ExportOptions struct {
+	PasswordHashes bool
+	KeyValues      bool
+}
+
+// ImportResult summarises an Import.
+type ImportResult struct {
+	Users        int `json:"users"`
+	APIKeys      int `json:"api_keys"`
+	ArchivedKeys int `json:"archived_keys"`
+	// GeneratedKeys lists the IDs of new keys imported without a value, which were
+	// given a freshly generated one.
+	GeneratedKeys []string `json:"generated_keys,omitempty"`
+}
+
+// Export returns the full store state. Password hashes and raw key values are blanked
+// unless opts asks for them so exports can move between environments without secrets.
+// The trash, records quarantined by load repair and the change history stay local.
+func (s *Store) Export(opts ExportOptions) Data {
+	if s == nil {
+		return Data{}
+	}
+	data := s.Snapshot()
+	data.DeletedUsers, data.DeletedKeys, data.Quarantine = nil, nil, nil
+	data.Changes = nil
+	if !opts.PasswordHashes {
+		for i := range data.Users {
+			data.Users[i].PasswordHash = ""
+		}
+	}
+	if !opts.KeyValues {
+		for i := range data.APIKeys {
+			data.APIKeys[i].Key = ""
+		}
+		for i := range data.ArchivedKeys {
+			data.ArchivedKeys[i].Key = ""
+		}
+	}
+	return data
+}
+
+// Import loads an export into the store, matching users and keys by ID. With replace
+// the store contents are replaced; otherwise imported entries are added or overwrite
+// their existing counterpart. Users imported without a password hash and keys without
+// a value keep the secret of the existing entry, and new keys without a value get a
+// generated one. The result is persisted before returning.
+func (s *Store) Import(data Data, replace bool) (ImportResult, error) {
+	if s == nil {
+		return ImportResult{}, ErrInvalidConfiguration
+	}
+	if s.ReadOnly() {
+		return ImportResult{}, ErrReadOnly
+	}
+	if _, err := migrateData(&data); err != nil {
+		return ImportResult{}, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if err := checkImportIDs(data); err != nil {
+		return ImportResult{}, err
+	}
+
+	unlock := s.lock("Import")
+	users := make(map[string]User, len(s.data.Users))
+	for _, u := range s.data.Users {
+		users[u.ID] = u
+	}
+	keys := make(map[string]APIKey, len(s.data.APIKeys)+len(s.data.ArchivedKeys))
+	for _, k := range append(append([]APIKey(nil), s.data.APIKeys...), s.data.ArchivedKeys...) {
+		keys[k.ID] = k
+	}
+
+	result := ImportResult{Users: len(data.Users), APIKeys: len(data.APIKeys), ArchivedKeys: len(data.ArchivedKeys)}
+	for i := range data.Users {
+		if data.Users[i].PasswordHash == "" {
+			data.Users[i].PasswordHash = users[data.Users[i].ID].PasswordHash
+		}
+	}
+	fill := func(list []APIKey) error {
+		for i := range list {
+			if strings.TrimSpace(list[i].Key) != "" {
+				continue
+			}
+			if existing, ok := keys[list[i].ID]; ok {
+				list[i].Key = existing.Key
+				continue
+			}
+			value, err := NewAPIKey()
+			if err != nil {
+				return err
+			}
+			list[i].Key = value
+			result.GeneratedKeys = append(result.GeneratedKeys, list[i].ID)
+		}
+		return nil
+	}
+	if err := fill(data.APIKeys); err != nil {
+		unlock()
+		return ImportResult{}, err
+	}
+	if err := fill(data.ArchivedKeys); err != nil {
+		unlock()
+		return ImportResult{}, err
+	}
+
+	next := data
+	if !replace {
+		next = s.snapshotLocked()
+		next.Users = mergeByID(next.Users, data.Users, func(u User) string { return u.ID })
+		next.APIKeys = mergeByID(next.APIKeys, data.APIKeys, func(k APIKey) string { return k.ID })
+		next.ArchivedKeys = mergeByID(next.ArchivedKeys, data.ArchivedKeys, func(k APIKey) string { return k.ID })
+		// A key moves between the active and archived sections when the import says so.
+		next.APIKeys = withoutIDs(next.APIKeys, data.ArchivedKeys)
+		next.ArchivedKeys = withoutIDs(next.ArchivedKeys, data.APIKeys)
+	}
+	next.Version = CurrentDataVersion
+	// The revision history describes this store, not the imported one.
+	next.Revision, next.Changes = s.data.Revision, s.data.Changes
+	if err := checkImportKeyValues(next); err != nil {
+		unlock()
+		return ImportResult{}, err
+	}
+	s.data = next
+	unlock()
+
+	s.resetSharedUsage(next.APIKeys)
+	return result, s.Save()
+}
+
+// checkImportIDs rejects entries without an ID and IDs used twice.
+func checkImportIDs(data Data) error {
+	users := make(map[string]bool, len(data.Users))
+	for _, u := range data.Users {
+		if strings.TrimSpace(u.ID) == "" {
+			return fmt.Errorf("%w: user %q has no id", ErrInvalidImport, u.Username)
+		}
+		if users[u.ID] {
+			return fmt.Errorf("%w: duplicate user id %s", ErrInvalidImport, u.ID)
+		}
+		users[u.ID] = true
+	}
+	keys := make(map[string]bool, len(data.APIKeys)+len(data.ArchivedKeys))
+	for _, k := range append(append([]APIKey(nil), data.APIKeys...), data.ArchivedKeys...) {
+		if strings.TrimSpace(k.ID) == "" {
+			return fmt.Errorf("%w: api key without id", ErrInvalidImport)
+		}
+		if keys[k.ID] {
+			return fmt.Errorf("%w: duplicate api key id %s", ErrInvalidImport, k.ID)
+		}
+		keys[k.ID] = true
+	}
+	return nil
+}
+
+func checkImportKeyValues(data Data) error {
+	seen := make(map[string]string)
+	for _, k := range append(append([]APIKey(nil), data.APIKeys...), data.ArchivedKeys...) {
+		if other, ok := seen[k.Key]; ok && other != k.ID {
+			return fmt.Errorf("%w: %s and %s", ErrDuplicateAPIKey, other, k.ID)
+		}
+		seen[k.Key] = k.ID
+	}
+	return nil
+}
+
+func mergeByID[T any](existing, incoming []T, id func(T) string) []T {
+	index := make(map[string]int, len(existing))
+	out := append([]T(nil), existing...)
+	for i, item := range out {
+		index[id(item)] = i
+	}
+	for _, item := range incoming {
+		if i, ok := index[id(item)]; ok {
+			out[i] = item
+			continue
+		}
+		index[id(item)] = len(out)
+		out = append(out, item)
+	}
+	return out
+}
+
+func withoutIDs(keys, drop []APIKey) []APIKey {
+	if len(drop) == 0 {
+		return keys
+	}
+	ids := make(map[string]bool, len(drop))
+	for _, k := range drop {
+		ids[k.ID] = true
+	}
+	out := keys[:0]
+	for _, k := range keys {
+		if !ids[k.ID] {
+			out = append(out, k)
+		}
+	}
+	return out
+}
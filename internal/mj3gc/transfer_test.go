@@ -0,0 +1,59 @@
+package mj3gc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := NewStore()
+	src.data.Users = []User{{ID: "u1", Username: "alice", PasswordHash: "hash"}}
+	src.data.APIKeys = []APIKey{{ID: "k1", Key: "secret-1", UserID: "u1", Enabled: true, UsedCount: 7}}
+	src.data.DeletedKeys = []APIKey{{ID: "k0", Key: "trashed"}}
+
+	exported := src.Export(ExportOptions{})
+	if exported.Users[0].PasswordHash != "" || exported.APIKeys[0].Key != "" || len(exported.DeletedKeys) != 0 {
+		t.Fatalf("secrets leaked into export: %+v", exported)
+	}
+	if src.Snapshot().APIKeys[0].Key != "secret-1" {
+		t.Fatal("export modified the store")
+	}
+
+	dst := NewStore()
+	dst.path = filepath.Join(t.TempDir(), "mj3gc.json")
+	dst.data.Users = []User{{ID: "u1", Username: "alice-old", PasswordHash: "kept"}}
+	dst.data.APIKeys = []APIKey{{ID: "k1", Key: "existing", Enabled: true}, {ID: "k9", Key: "other", Enabled: true}}
+
+	result, err := dst.Import(exported, false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if len(result.GeneratedKeys) != 0 {
+		t.Fatalf("generated = %v, want none for a known key", result.GeneratedKeys)
+	}
+	data := dst.Snapshot()
+	if data.Users[0].Username != "alice" || data.Users[0].PasswordHash != "kept" {
+		t.Fatalf("user = %+v", data.Users[0])
+	}
+	if len(data.APIKeys) != 2 || data.APIKeys[0].Key != "existing" || data.APIKeys[0].UsedCount != 7 {
+		t.Fatalf("keys = %+v", data.APIKeys)
+	}
+
+	exported.APIKeys = append(exported.APIKeys, APIKey{ID: "k2", Enabled: true})
+	result, err = dst.Import(exported, true)
+	if err != nil {
+		t.Fatalf("Import replace: %v", err)
+	}
+	if len(result.GeneratedKeys) != 1 || result.GeneratedKeys[0] != "k2" {
+		t.Fatalf("generated = %v, want [k2]", result.GeneratedKeys)
+	}
+	if _, ok := dst.FindAPIKey("other"); ok {
+		t.Fatal("replace kept a key missing from the import")
+	}
+
+	exported.APIKeys = append(exported.APIKeys, APIKey{ID: "k1"})
+	if _, err := dst.Import(exported, false); !errors.Is(err, ErrInvalidImport) {
+		t.Fatalf("err = %v, want ErrInvalidImport for duplicate ids", err)
+	}
+}
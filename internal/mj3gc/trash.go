@@ -0,0 +1,124 @@
+package mj3gc
+
+import (
+	"strings"
+	"time"
+)
+
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// ListDeletedUsers returns the users in the trash.
+func (s *Store) ListDeletedUsers() []User {
+	if s == nil {
+		return nil
+	}
+	defer s.rlock("ListDeletedUsers")()
+	out := make([]User, len(s.data.DeletedUsers))
+	copy(out, s.data.DeletedUsers)
+	return out
+}
+
+// ListDeletedAPIKeys returns the keys in the trash.
+func (s *Store) ListDeletedAPIKeys() []APIKey {
+	if s == nil {
+		return nil
+	}
+	defer s.rlock("ListDeletedAPIKeys")()
+	out := make([]APIKey, len(s.data.DeletedKeys))
+	copy(out, s.data.DeletedKeys)
+	return out
+}
+
+// RestoreDeletedUser moves a user out of the trash. It fails with ErrDuplicateUsername
+// when another user has taken the username in the meantime.
+func (s *Store) RestoreDeletedUser(id string) (User, error) {
+	if s == nil {
+		return User{}, ErrInvalidConfiguration
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return User{}, ErrUserNotFound
+	}
+	defer s.lock("RestoreDeletedUser")()
+	for i, u := range s.data.DeletedUsers {
+		if u.ID != id {
+			continue
+		}
+		for _, existing := range s.data.Users {
+			if existing.ID == u.ID || strings.EqualFold(existing.Username, u.Username) {
+				return User{}, ErrDuplicateUsername
+			}
+		}
+		s.data.DeletedUsers = append(s.data.DeletedUsers[:i:i], s.data.DeletedUsers[i+1:]...)
+		u.DeletedAt = time.Time{}
+		s.data.Users = append(s.data.Users, u)
+		return u, nil
+	}
+	return User{}, ErrUserNotFound
+}
+
+// RestoreDeletedKey moves a key out of the trash with its previous secret and usage.
+// It fails with ErrDuplicateAPIKey when the ID or secret has been reused since.
+func (s *Store) RestoreDeletedKey(id string) (APIKey, error) {
+	if s == nil {
+		return APIKey{}, ErrInvalidConfiguration
+	}
+	id = strings.TrimSpace(id)
+	if id == "" {
+		return APIKey{}, ErrKeyNotFound
+	}
+	defer s.lock("RestoreDeletedKey")()
+	for i, k := range s.data.DeletedKeys {
+		if k.ID != id {
+			continue
+		}
+		for _, existing := range append(append([]APIKey(nil), s.data.APIKeys...), s.data.ArchivedKeys...) {
+			if existing.ID == k.ID || existing.Key == k.Key {
+				return APIKey{}, ErrDuplicateAPIKey
+			}
+		}
+		s.data.DeletedKeys = append(s.data.DeletedKeys[:i:i], s.data.DeletedKeys[i+1:]...)
+		k.DeletedAt = time.Time{}
+		s.data.APIKeys = append(s.data.APIKeys, k)
+		return k, nil
+	}
+	return APIKey{}, ErrKeyNotFound
+}
+
+// PurgeTrash permanently removes users and keys deleted more than olderThan ago and
+// returns how many entries were removed.
+func (s *Store) PurgeTrash(olderThan time.Duration) int {
+	if s == nil || olderThan <= 0 {
+		return 0
+	}
+	cutoff := time.Now().Add(-olderThan)
+	defer s.lock("PurgeTrash")()
+	purged := 0
+	users := s.data.DeletedUsers[:0]
+	for _, u := range s.data.DeletedUsers {
+		if u.DeletedAt.Before(cutoff) {
+			purged++
+			continue
+		}
+		users = append(users, u)
+	}
+	s.data.DeletedUsers = users
+	keys := s.data.DeletedKeys[:0]
+	for _, k := range s.data.DeletedKeys {
+		if k.DeletedAt.Before(cutoff) {
+			purged++
+			continue
+		}
+		keys = append(keys, k)
+	}
+	s.data.DeletedKeys = keys
+	return purged
+}
+
+// trashRetention returns mj3gc.trash-retention-days as a duration.
+func (s *Store) trashRetention() time.Duration {
+	if days := s.Settings().TrashRetentionDays; days > 0 {
+		return time.Duration(days) * 24 * time.Hour
+	}
+	return defaultTrashRetention
+}
@@ -0,0 +1,58 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeleteMovesToTrashAndRestores(t *testing.T) {
+	s := NewStore()
+	s.data.Users = []User{{ID: "u1", Username: "alice"}}
+	s.data.APIKeys = []APIKey{{ID: "k1", Key: "secret", UserID: "u1", Enabled: true, UsedCount: 4}}
+
+	if err := s.DeleteAPIKey("k1"); err != nil {
+		t.Fatalf("DeleteAPIKey: %v", err)
+	}
+	if err := s.DeleteUser("u1"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, ok := s.FindAPIKey("secret"); ok {
+		t.Fatal("trashed key still authenticates")
+	}
+	trashed := s.ListDeletedAPIKeys()
+	if len(trashed) != 1 || trashed[0].DeletedAt.IsZero() {
+		t.Fatalf("trash = %+v", trashed)
+	}
+
+	key, err := s.RestoreDeletedKey("k1")
+	if err != nil {
+		t.Fatalf("RestoreDeletedKey: %v", err)
+	}
+	if !key.DeletedAt.IsZero() || key.UsedCount != 4 {
+		t.Fatalf("restored key = %+v", key)
+	}
+	if _, ok := s.FindAPIKey("secret"); !ok {
+		t.Fatal("restored key does not authenticate")
+	}
+
+	if _, err := s.UpsertUser(User{Username: "alice"}); err != nil {
+		t.Fatalf("UpsertUser: %v", err)
+	}
+	if _, err := s.RestoreDeletedUser("u1"); !errors.Is(err, ErrDuplicateUsername) {
+		t.Fatalf("err = %v, want ErrDuplicateUsername", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	s := NewStore()
+	s.data.DeletedUsers = []User{{ID: "old", DeletedAt: time.Now().Add(-40 * 24 * time.Hour)}, {ID: "new", DeletedAt: time.Now()}}
+	s.data.DeletedKeys = []APIKey{{ID: "k", DeletedAt: time.Now().Add(-31 * 24 * time.Hour)}}
+
+	if purged := s.PurgeTrash(s.trashRetention()); purged != 2 {
+		t.Fatalf("purged = %d, want 2", purged)
+	}
+	if users := s.ListDeletedUsers(); len(users) != 1 || users[0].ID != "new" {
+		t.Fatalf("remaining = %+v", users)
+	}
+}
@@ -0,0 +1,52 @@
+package mj3gc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// defaultCurrency is the currency of mj3gc.model-prices when mj3gc.currency is unset.
+const defaultCurrency = "USD"
+
+// PayloadUnits tells portal frontends how to read the figures of usage and billing
+// payloads, so they do not have to guess formats. Timestamps are sent both as Unix
+// seconds and as ISO-8601 strings in UTC.
+type PayloadUnits struct {
+	Timestamp    string `json:"timestamp"`
+	TimestampISO string `json:"timestamp_iso"`
+	Tokens       string `json:"tokens"`
+	Latency      string `json:"latency"`
+	Currency     string `json:"currency"`
+	CostDecimals int    `json:"cost_decimals"`
+}
+
+// Units returns the payload units for settings.
+func Units(settings config.MJ3GCConfig) PayloadUnits {
+	return PayloadUnits{
+		Timestamp:    "unix_seconds",
+		TimestampISO: "rfc3339_utc",
+		Tokens:       "count",
+		Latency:      "milliseconds",
+		Currency:     Currency(settings),
+		CostDecimals: 6,
+	}
+}
+
+// Currency returns the ISO 4217 code model prices and estimated costs are in.
+func Currency(settings config.MJ3GCConfig) string {
+	if currency := strings.ToUpper(strings.TrimSpace(settings.Currency)); currency != "" {
+		return currency
+	}
+	return defaultCurrency
+}
+
+// FormatTimestamp renders t as the ISO-8601 companion of a Unix seconds timestamp.
+// The zero time renders as "".
+func FormatTimestamp(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,24 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestUnits(t *testing.T) {
+	if got := Units(config.MJ3GCConfig{}).Currency; got != "USD" {
+		t.Fatalf("default currency = %q", got)
+	}
+	if got := Units(config.MJ3GCConfig{Currency: " eur "}).Currency; got != "EUR" {
+		t.Fatalf("configured currency = %q", got)
+	}
+	at := time.Date(2026, 3, 1, 14, 30, 5, 0, time.FixedZone("CET", 3600))
+	if got := FormatTimestamp(at); got != "2026-03-01T13:30:05Z" {
+		t.Fatalf("FormatTimestamp = %q", got)
+	}
+	if got := FormatTimestamp(time.Time{}); got != "" {
+		t.Fatalf("FormatTimestamp(zero) = %q", got)
+	}
+}
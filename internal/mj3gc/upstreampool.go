@@ -0,0 +1,52 @@
+package mj3gc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// NormalizePool lower-cases and trims an upstream pool name.
+func NormalizePool(pool string) string {
+	return strings.ToLower(strings.TrimSpace(pool))
+}
+
+// UpstreamPool returns the upstream account pool requests by key are routed to: the
+// pool of the key itself, or else the pool of its user. Empty means any credential.
+func (s *Store) UpstreamPool(key APIKey) string {
+	if key.Pool != "" {
+		return key.Pool
+	}
+	if s == nil || key.UserID == "" {
+		return ""
+	}
+	if user, ok := s.FindUserByID(key.UserID); ok {
+		return user.Pool
+	}
+	return ""
+}
+
+// NormalizePins trims and de-duplicates the pinned credential IDs or provider names of
+// a key, keeping their order. Provider names are lower-cased, auth IDs are kept as
+// they are. Commas are rejected because the pins travel comma separated.
+func NormalizePins(pins []string, providers bool) ([]string, error) {
+	seen := make(map[string]struct{}, len(pins))
+	var out []string
+	for _, pin := range pins {
+		pin = strings.TrimSpace(pin)
+		if providers {
+			pin = strings.ToLower(pin)
+		}
+		if pin == "" {
+			continue
+		}
+		if strings.Contains(pin, ",") {
+			return nil, fmt.Errorf("pin %q must not contain a comma", pin)
+		}
+		if _, ok := seen[pin]; ok {
+			continue
+		}
+		seen[pin] = struct{}{}
+		out = append(out, pin)
+	}
+	return out, nil
+}
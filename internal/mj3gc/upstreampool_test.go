@@ -0,0 +1,37 @@
+package mj3gc
+
+import "testing"
+
+func TestUpstreamPool(t *testing.T) {
+	s := NewStore()
+	user, err := s.UpsertUser(User{Username: "acme", PasswordHash: "x", Pool: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		key  APIKey
+		want string
+	}{
+		{APIKey{UserID: user.ID}, "acme"},
+		{APIKey{UserID: user.ID, Pool: "acme-eu"}, "acme-eu"},
+		{APIKey{UserID: "missing"}, ""},
+		{APIKey{}, ""},
+	} {
+		if got := s.UpstreamPool(tc.key); got != tc.want {
+			t.Errorf("UpstreamPool(%+v) = %q, want %q", tc.key, got, tc.want)
+		}
+	}
+}
+
+func TestNormalizePins(t *testing.T) {
+	got, err := NormalizePins([]string{" Claude ", "claude", "", "GEMINI"}, true)
+	if err != nil || len(got) != 2 || got[0] != "claude" || got[1] != "gemini" {
+		t.Fatalf("NormalizePins(providers) = %v, %v", got, err)
+	}
+	if got, _ := NormalizePins([]string{"Auth-1"}, false); len(got) != 1 || got[0] != "Auth-1" {
+		t.Fatalf("NormalizePins(auths) = %v", got)
+	}
+	if _, err := NormalizePins([]string{"a,b"}, false); err == nil {
+		t.Fatal("NormalizePins accepted a comma")
+	}
+}
@@ -0,0 +1,349 @@
+package mj3gc
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// defaultAlertCooldown is the cool-down of a channel without mj3gc.usage-alerts.cooldown-minutes.
+const defaultAlertCooldown = 15 * time.Minute
+
+// Quotas a UsageAlert refers to.
+const (
+	AlertQuotaRequests = "requests"
+	AlertQuotaTokens   = "tokens"
+	AlertQuotaBudget   = "budget"
+)
+
+// UsageAlert is raised the first time in a reset period that the usage of a key reaches
+// one of its alert thresholds. Quota is the request quota, token limit or budget with
+// the largest share in use.
+type UsageAlert struct {
+	KeyID     string    `json:"key_id"`
+	Label     string    `json:"label"`
+	UserID    string    `json:"user_id,omitempty"`
+	Threshold int       `json:"threshold"`
+	Quota     string    `json:"quota"`
+	Used      float64   `json:"used"`
+	Limit     float64   `json:"limit"`
+	Percent   float64   `json:"percent"`
+	ResetsAt  time.Time `json:"resets_at,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+// NormalizeAlertThresholds sorts and deduplicates thresholds and checks that each is a
+// percentage from 1 to 100. Empty falls back to mj3gc.usage-alerts.thresholds.
+func NormalizeAlertThresholds(thresholds []int) ([]int, error) {
+	if len(thresholds) == 0 {
+		return nil, nil
+	}
+	out := make([]int, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		if threshold < 1 || threshold > 100 {
+			return nil, fmt.Errorf("invalid alert threshold %d, want a percentage from 1 to 100", threshold)
+		}
+		out = append(out, threshold)
+	}
+	sort.Ints(out)
+	return slices.Compact(out), nil
+}
+
+// alertThresholds returns the thresholds of the key, or else the valid configured ones.
+func alertThresholds(settings config.MJ3GCConfig, k APIKey) []int {
+	if len(k.AlertThresholds) > 0 {
+		return k.AlertThresholds
+	}
+	var out []int
+	for _, threshold := range settings.UsageAlerts.Thresholds {
+		if threshold >= 1 && threshold <= 100 {
+			out = append(out, threshold)
+		}
+	}
+	sort.Ints(out)
+	return slices.Compact(out)
+}
+
+// usageAlert describes the quota of the key with the largest share in use, with
+// Threshold left to the caller. ok is false when the key has no limit at all.
+func (k APIKey) usageAlert(now time.Time) (alert UsageAlert, ok bool) {
+	alert = UsageAlert{KeyID: k.ID, Label: k.Label, UserID: k.UserID, ResetsAt: k.ResetsAt(), At: now.UTC()}
+	consider := func(quota string, used, limit float64) {
+		if limit <= 0 {
+			return
+		}
+		if percent := used / limit * 100; !ok || percent > alert.Percent {
+			alert.Quota, alert.Used, alert.Limit, alert.Percent = quota, used, limit, percent
+			ok = true
+		}
+	}
+	consider(AlertQuotaRequests, float64(k.UsedCount), float64(k.TotalLimit))
+	consider(AlertQuotaTokens, float64(k.UsedTokens), float64(k.TokenLimit))
+	consider(AlertQuotaBudget, k.SpentUSD, k.BudgetUSD)
+	return alert, ok
+}
+
+// crossedThresholds returns the thresholds percent reached that were not sent yet, and
+// the thresholds to remember as sent. Sent thresholds percent fell back below, after a
+// usage reset or a raised limit, are forgotten so they fire again.
+func crossedThresholds(thresholds, sent []int, percent float64) (crossed, stillSent []int) {
+	for _, threshold := range sent {
+		if percent >= float64(threshold) {
+			stillSent = append(stillSent, threshold)
+		}
+	}
+	for _, threshold := range thresholds {
+		if percent >= float64(threshold) && !slices.Contains(sent, threshold) {
+			crossed = append(crossed, threshold)
+			stillSent = append(stillSent, threshold)
+		}
+	}
+	sort.Ints(stillSent)
+	return crossed, slices.Compact(stillSent)
+}
+
+// RaiseUsageAlerts checks the key with the given id and the parent whose quota it
+// shares against their alert thresholds, and notifies the usage-alerts channels of
+// each key that reached a threshold for the first time this period. It returns the
+// alerts raised. Read-only instances leave alerts to the writer.
+func (s *Store) RaiseUsageAlerts(id string, now time.Time) []UsageAlert {
+	if s == nil || s.ReadOnly() {
+		return nil
+	}
+	key, ok := s.FindAPIKeyByID(id)
+	if !ok {
+		return nil
+	}
+	settings := s.Settings()
+	var raised []UsageAlert
+	if alert, ok := s.raiseUsageAlert(settings, key, now); ok {
+		raised = append(raised, alert)
+	}
+	if key.ParentID != "" {
+		if parent, found := s.FindAPIKeyByID(key.ParentID); found {
+			if alert, ok := s.raiseUsageAlert(settings, parent, now); ok {
+				raised = append(raised, alert)
+			}
+		}
+	}
+	return raised
+}
+
+// CheckUsageAlerts raises the alerts of every key, including keys whose usage changed
+// outside the request path, and returns how many were raised.
+func (s *Store) CheckUsageAlerts(now time.Time) int {
+	if s == nil || s.ReadOnly() {
+		return 0
+	}
+	settings := s.Settings()
+	raised := 0
+	for _, key := range s.ListAPIKeys() {
+		if _, ok := s.raiseUsageAlert(settings, key, now); ok {
+			raised++
+		}
+	}
+	return raised
+}
+
+// raiseUsageAlert updates the sent thresholds of key and dispatches an alert for the
+// highest threshold it crossed, if any. Crossing several thresholds at once, e.g. with
+// one large request, sends a single alert.
+func (s *Store) raiseUsageAlert(settings config.MJ3GCConfig, key APIKey, now time.Time) (UsageAlert, bool) {
+	thresholds := alertThresholds(settings, key)
+	if len(thresholds) == 0 && len(key.AlertsSent) == 0 {
+		return UsageAlert{}, false
+	}
+	alert, _ := key.usageAlert(now)
+	crossed, sent := crossedThresholds(thresholds, key.AlertsSent, alert.Percent)
+	if slices.Equal(sent, key.AlertsSent) {
+		return UsageAlert{}, false
+	}
+	unlock := s.lock("RaiseUsageAlerts")
+	i, ok := s.keyIDIndexLocked(key.ID)
+	// A concurrent request that saw the same usage may have claimed the thresholds first.
+	if !ok || !slices.Equal(s.data.APIKeys[i].AlertsSent, key.AlertsSent) {
+		unlock()
+		return UsageAlert{}, false
+	}
+	s.data.APIKeys[i].AlertsSent = sent
+	unlock()
+	if len(crossed) == 0 {
+		return UsageAlert{}, false
+	}
+	alert.Threshold = crossed[len(crossed)-1]
+	s.dispatchUsageAlert(settings, alert, now)
+	return alert, true
+}
+
+// alertDispatcher delivers usage alerts with a cool-down per channel: a channel gets at
+// most one message per cool-down, and alerts raised meanwhile are queued and sent
+// together as a digest when it ends. The cool-down runs from the last message sent to
+// the channel, so a changed cool-down applies right away.
+type alertDispatcher struct {
+	mu       sync.Mutex
+	channels map[string]*alertChannel
+	// deliver sends alerts to a channel; it defaults to deliverUsageAlerts.
+	deliver func(settings config.MJ3GCConfig, channel string, alerts []UsageAlert) error
+}
+
+type alertChannel struct {
+	lastSent time.Time
+	pending  []UsageAlert
+	timer    *time.Timer
+}
+
+// usageAlertChannels returns the configured channels as "webhook:<url>" and
+// "email:<address>".
+func usageAlertChannels(settings config.MJ3GCConfig) []string {
+	var channels []string
+	for _, endpoint := range settings.UsageAlerts.Webhooks {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			channels = append(channels, "webhook:"+endpoint)
+		}
+	}
+	for _, address := range settings.UsageAlerts.Emails {
+		if address = strings.TrimSpace(address); address != "" {
+			channels = append(channels, "email:"+address)
+		}
+	}
+	return channels
+}
+
+// usageAlertCooldown is the cool-down of channel: its channel-cooldown-minutes entry,
+// else mj3gc.usage-alerts.cooldown-minutes or its default.
+func usageAlertCooldown(settings config.MJ3GCConfig, channel string) time.Duration {
+	_, target, _ := strings.Cut(channel, ":")
+	if minutes := settings.UsageAlerts.ChannelCooldownMinutes[target]; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	if minutes := settings.UsageAlerts.CooldownMinutes; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultAlertCooldown
+}
+
+// dispatchUsageAlert sends alert to every channel that is not cooling down and queues
+// it for the digest of the others.
+func (s *Store) dispatchUsageAlert(settings config.MJ3GCConfig, alert UsageAlert, now time.Time) {
+	d := &s.alerts
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.channels == nil {
+		d.channels = make(map[string]*alertChannel)
+	}
+	for _, channel := range usageAlertChannels(settings) {
+		ch := d.channels[channel]
+		if ch == nil {
+			ch = &alertChannel{}
+			d.channels[channel] = ch
+		}
+		quietUntil := ch.lastSent.Add(usageAlertCooldown(settings, channel))
+		if len(ch.pending) == 0 && !now.Before(quietUntil) {
+			ch.lastSent = now
+			go s.sendUsageAlerts(settings, channel, []UsageAlert{alert})
+			continue
+		}
+		ch.pending = append(ch.pending, alert)
+		if ch.timer == nil {
+			ch.timer = time.AfterFunc(quietUntil.Sub(now), func() {
+				s.flushUsageAlertDigest(channel, time.Now())
+			})
+		}
+	}
+}
+
+// flushUsageAlertDigest sends the alerts queued for channel during its cool-down as one
+// digest and starts a new cool-down. Channels removed from the config are dropped.
+func (s *Store) flushUsageAlertDigest(channel string, now time.Time) {
+	settings := s.Settings()
+	d := &s.alerts
+	d.mu.Lock()
+	ch := d.channels[channel]
+	if ch == nil {
+		d.mu.Unlock()
+		return
+	}
+	if ch.timer != nil {
+		ch.timer.Stop()
+		ch.timer = nil
+	}
+	digest := ch.pending
+	ch.pending = nil
+	if !slices.Contains(usageAlertChannels(settings), channel) {
+		delete(d.channels, channel)
+		digest = nil
+	} else if len(digest) > 0 {
+		ch.lastSent = now
+	}
+	d.mu.Unlock()
+	if len(digest) > 0 {
+		s.sendUsageAlerts(settings, channel, digest)
+	}
+}
+
+func (s *Store) sendUsageAlerts(settings config.MJ3GCConfig, channel string, alerts []UsageAlert) {
+	s.alerts.mu.Lock()
+	deliver := s.alerts.deliver
+	s.alerts.mu.Unlock()
+	if deliver == nil {
+		deliver = deliverUsageAlerts
+	}
+	if err := deliver(settings, channel, alerts); err != nil {
+		log.Warnf("mj3gc: %d usage alerts not delivered to %s: %v", len(alerts), channel, err)
+	}
+}
+
+// deliverUsageAlerts posts alerts as JSON to a webhook channel or mails them to an
+// email channel.
+func deliverUsageAlerts(settings config.MJ3GCConfig, channel string, alerts []UsageAlert) error {
+	kind, target, _ := strings.Cut(channel, ":")
+	if kind == "email" {
+		subject := fmt.Sprintf("%d usage alerts", len(alerts))
+		if len(alerts) == 1 {
+			alert := alerts[0]
+			subject = fmt.Sprintf("Key %s reached %d%% of its %s quota", alertKeyName(alert), alert.Threshold, alert.Quota)
+		}
+		return sendMail(settings.SMTP, target, subject, FormatUsageAlerts(alerts))
+	}
+	payload, err := json.Marshal(map[string]any{"type": "usage_alert", "digest": len(alerts) > 1, "alerts": alerts})
+	if err != nil {
+		return err
+	}
+	return postWebhook(target, payload)
+}
+
+// FormatUsageAlerts renders alerts as plain text, one line per alert.
+func FormatUsageAlerts(alerts []UsageAlert) string {
+	var b strings.Builder
+	for _, alert := range alerts {
+		fmt.Fprintf(&b, "%s: key %s reached %d%% of its %s quota, %s of %s used",
+			FormatTimestamp(alert.At), alertKeyName(alert), alert.Threshold, alert.Quota,
+			formatAlertAmount(alert.Quota, alert.Used), formatAlertAmount(alert.Quota, alert.Limit))
+		if !alert.ResetsAt.IsZero() {
+			fmt.Fprintf(&b, ", resets %s", FormatTimestamp(alert.ResetsAt))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func alertKeyName(alert UsageAlert) string {
+	if alert.Label == "" {
+		return alert.KeyID
+	}
+	return fmt.Sprintf("%q (%s)", alert.Label, alert.KeyID)
+}
+
+func formatAlertAmount(quota string, v float64) string {
+	if quota == AlertQuotaBudget {
+		return fmt.Sprintf("%.2f", v)
+	}
+	return fmt.Sprintf("%.0f", v)
+}
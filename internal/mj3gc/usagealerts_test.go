@@ -0,0 +1,123 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func TestUsageAlertsFireOncePerPeriodWithDigests(t *testing.T) {
+	s := NewStore()
+	s.settings.UsageAlerts = config.MJ3GCUsageAlerts{
+		Thresholds:      []int{50, 100},
+		Webhooks:        []string{"https://hooks.example.com/alerts"},
+		CooldownMinutes: 30,
+	}
+	delivered := make(chan []UsageAlert, 10)
+	s.alerts.deliver = func(_ config.MJ3GCConfig, channel string, alerts []UsageAlert) error {
+		if channel != "webhook:https://hooks.example.com/alerts" {
+			t.Errorf("delivered to %q", channel)
+		}
+		delivered <- alerts
+		return nil
+	}
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-alerts", Label: "ci", Enabled: true, TotalLimit: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	use := func(now time.Time) []UsageAlert {
+		if _, err := s.BeginRequest(key.Key); err != nil {
+			t.Fatalf("BeginRequest: %v", err)
+		}
+		s.EndRequest(key.Key, true, "")
+		return s.RaiseUsageAlerts(key.ID, now)
+	}
+
+	now := time.Now()
+	if raised := use(now); len(raised) != 0 {
+		t.Fatalf("raised %+v at 25%%", raised)
+	}
+	if raised := use(now); len(raised) != 1 || raised[0].Threshold != 50 || raised[0].Quota != AlertQuotaRequests {
+		t.Fatalf("raised %+v at 50%%, want the 50%% request alert", raised)
+	}
+	if got := <-delivered; len(got) != 1 || got[0].Threshold != 50 {
+		t.Fatalf("delivered %+v, want the 50%% alert right away", got)
+	}
+	if raised := s.RaiseUsageAlerts(key.ID, now); len(raised) != 0 {
+		t.Fatalf("threshold raised twice: %+v", raised)
+	}
+
+	// The 100% alert arrives during the cool-down and waits for the digest.
+	use(now.Add(time.Minute))
+	if raised := use(now.Add(time.Minute)); len(raised) != 1 || raised[0].Threshold != 100 {
+		t.Fatalf("raised %+v at 100%%", raised)
+	}
+	select {
+	case got := <-delivered:
+		t.Fatalf("delivered %+v during the cool-down", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.flushUsageAlertDigest("webhook:https://hooks.example.com/alerts", now.Add(30*time.Minute))
+	if got := <-delivered; len(got) != 1 || got[0].Threshold != 100 {
+		t.Fatalf("digest %+v, want the 100%% alert", got)
+	}
+
+	// A usage reset re-arms the thresholds for the next period.
+	if _, err := s.ResetUsage(key.ID); err != nil {
+		t.Fatal(err)
+	}
+	use(now.Add(time.Hour))
+	if raised := use(now.Add(time.Hour)); len(raised) != 1 || raised[0].Threshold != 50 {
+		t.Fatalf("raised %+v after the reset, want the 50%% alert again", raised)
+	}
+	if got := <-delivered; len(got) != 1 || got[0].Threshold != 50 {
+		t.Fatalf("delivered %+v after the cool-down, want the 50%% alert", got)
+	}
+
+	if _, err := NormalizeAlertThresholds([]int{80, 120}); err == nil {
+		t.Fatal("threshold above 100 accepted")
+	}
+}
+
+func TestUsageAlertCooldownPerChannel(t *testing.T) {
+	s := NewStore()
+	s.settings.UsageAlerts = config.MJ3GCUsageAlerts{
+		Webhooks:               []string{"https://hooks.example.com/ops", "https://hooks.example.com/pager"},
+		CooldownMinutes:        30,
+		ChannelCooldownMinutes: map[string]int{"https://hooks.example.com/pager": 1},
+	}
+	delivered := make(chan string, 10)
+	s.alerts.deliver = func(_ config.MJ3GCConfig, channel string, _ []UsageAlert) error {
+		delivered <- channel
+		return nil
+	}
+	received := func() map[string]bool {
+		got := map[string]bool{}
+		for {
+			select {
+			case channel := <-delivered:
+				got[channel] = true
+			case <-time.After(50 * time.Millisecond):
+				return got
+			}
+		}
+	}
+
+	now := time.Now()
+	s.dispatchUsageAlert(s.settings, UsageAlert{KeyID: "k1", Threshold: 50}, now)
+	if got := received(); len(got) != 2 {
+		t.Fatalf("first alert delivered to %v, want both channels", got)
+	}
+	s.dispatchUsageAlert(s.settings, UsageAlert{KeyID: "k1", Threshold: 100}, now.Add(2*time.Minute))
+	got := received()
+	if !got["webhook:https://hooks.example.com/pager"] || got["webhook:https://hooks.example.com/ops"] {
+		t.Fatalf("second alert delivered to %v, want only the channel with the shorter cool-down", got)
+	}
+	s.alerts.mu.Lock()
+	lastSent := s.alerts.channels["webhook:https://hooks.example.com/pager"].lastSent
+	s.alerts.mu.Unlock()
+	if !lastSent.Equal(now.Add(2 * time.Minute)) {
+		t.Fatalf("pager last sent at %v, want the second alert", lastSent)
+	}
+}
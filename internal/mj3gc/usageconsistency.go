@@ -0,0 +1,58 @@
+package mj3gc
+
+import (
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+// FeatureExactUsage is the feature flag that makes portal usage endpoints report
+// exact usage for a key: read from the shared counters on every call instead of
+// served from a cache. It suits billing integrations; dashboards do without.
+const FeatureExactUsage = "exact-usage"
+
+const defaultPortalUsageCacheTTL = 30 * time.Second
+
+// PortalUsageCacheTTL returns how long portal usage figures may be cached, or zero when
+// caching is disabled.
+func PortalUsageCacheTTL(settings config.MJ3GCConfig) time.Duration {
+	switch seconds := settings.PortalUsageCacheSeconds; {
+	case seconds < 0:
+		return 0
+	case seconds == 0:
+		return defaultPortalUsageCacheTTL
+	default:
+		return time.Duration(seconds) * time.Second
+	}
+}
+
+// FreshUsage returns key with the request count currently held by the shared counters
+// or the shared backend, so it includes requests served by every instance, and stores
+// that count locally. Keys of a store without shared counters are returned unchanged,
+// their in-memory counters being exact already. Token and cost counters are per
+// instance in every setup.
+func (s *Store) FreshUsage(key APIKey) (APIKey, error) {
+	if s == nil {
+		return key, ErrInvalidConfiguration
+	}
+	var (
+		used int64
+		err  error
+	)
+	if counters := s.counterBackend(); counters != nil {
+		used, err = counters.Usage(key.ID)
+	} else if counter, ok := s.currentBackend().(UsageCounter); ok {
+		used, err = counter.IncrementUsage(key.ID, 0)
+	} else {
+		return key, nil
+	}
+	if err != nil {
+		return key, err
+	}
+	key.UsedCount = used
+	defer s.lockUsage("FreshUsage")()
+	if i, ok := s.keyIDIndexLocked(key.ID); ok {
+		s.data.APIKeys[i].UsedCount = used
+	}
+	return key, nil
+}
@@ -0,0 +1,61 @@
+package mj3gc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+	"github.com/router-for-me/CLIProxyAPI/v6/sdk/quota"
+)
+
+// sharedTestCounters is an in-process CounterBackend standing in for Redis.
+type sharedTestCounters struct {
+	*quota.MemoryCounters
+}
+
+func (c sharedTestCounters) ResetUsage(keyID string) error {
+	c.SetUsage(keyID, 0)
+	return nil
+}
+
+func (c sharedTestCounters) Seed(keyID string, used int64) error {
+	c.SetUsage(keyID, used)
+	return nil
+}
+
+func TestFreshUsage(t *testing.T) {
+	s := NewStore()
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-billing-integration", Enabled: true, Features: []string{FeatureExactUsage}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := s.FreshUsage(key); err != nil || got.UsedCount != 0 {
+		t.Fatalf("FreshUsage without shared counters = %d, %v", got.UsedCount, err)
+	}
+
+	counters := sharedTestCounters{quota.NewMemoryCounters()}
+	s.SetCounterBackend(counters)
+	// Another instance serves requests through the shared counters.
+	if _, err := counters.AddUsage(key.ID, 5); err != nil {
+		t.Fatal(err)
+	}
+	if stale, _ := s.FindAPIKeyByID(key.ID); stale.UsedCount != 0 {
+		t.Fatalf("local used_count = %d before the fresh read", stale.UsedCount)
+	}
+	got, err := s.FreshUsage(key)
+	if err != nil || got.UsedCount != 5 {
+		t.Fatalf("FreshUsage = %d, %v, want 5", got.UsedCount, err)
+	}
+	if local, _ := s.FindAPIKeyByID(key.ID); local.UsedCount != 5 {
+		t.Fatalf("local used_count after the fresh read = %d", local.UsedCount)
+	}
+}
+
+func TestPortalUsageCacheTTL(t *testing.T) {
+	cases := map[int]time.Duration{0: 30 * time.Second, 5: 5 * time.Second, -1: 0}
+	for seconds, want := range cases {
+		if got := PortalUsageCacheTTL(config.MJ3GCConfig{PortalUsageCacheSeconds: seconds}); got != want {
+			t.Fatalf("PortalUsageCacheTTL(%d) = %s, want %s", seconds, got, want)
+		}
+	}
+}
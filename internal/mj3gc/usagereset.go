@@ -0,0 +1,148 @@
+package mj3gc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Reset intervals of APIKey.ResetInterval. Periods start at midnight, on Mondays for
+// weekly resets and on the first of the month for monthly ones, in the timezone of the
+// key's schedule or else UTC.
+const (
+	ResetDaily   = "daily"
+	ResetWeekly  = "weekly"
+	ResetMonthly = "monthly"
+)
+
+// NormalizeResetInterval trims and lower-cases interval and checks that it is one of
+// the reset intervals. Empty disables scheduled resets.
+func NormalizeResetInterval(interval string) (string, error) {
+	interval = strings.ToLower(strings.TrimSpace(interval))
+	switch interval {
+	case "", ResetDaily, ResetWeekly, ResetMonthly:
+		return interval, nil
+	}
+	return "", fmt.Errorf("invalid reset_interval %q, want daily, weekly or monthly", interval)
+}
+
+// ResetsAt returns when the usage counters of the key are reset next, or the zero time
+// when the key has no reset interval.
+func (k APIKey) ResetsAt() time.Time {
+	if k.ResetInterval == "" {
+		return time.Time{}
+	}
+	loc := time.UTC
+	if k.Schedule != nil {
+		if zone, err := scheduleLocation(k.Schedule.Timezone); err == nil {
+			loc = zone
+		}
+	}
+	since := k.LastResetAt
+	if since.IsZero() {
+		since = k.CreatedAt
+	}
+	return nextResetBoundary(k.ResetInterval, since.In(loc))
+}
+
+// resetDue reports whether the usage counters of the key should have been reset by now.
+func (k APIKey) resetDue(now time.Time) bool {
+	at := k.ResetsAt()
+	return !at.IsZero() && !now.Before(at)
+}
+
+// nextResetBoundary returns the start of the reset period following the one t is in,
+// in the location of t.
+func nextResetBoundary(interval string, t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	switch interval {
+	case ResetDaily:
+		return day.AddDate(0, 0, 1)
+	case ResetWeekly:
+		days := (int(time.Monday) - int(day.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return day.AddDate(0, 0, days)
+	case ResetMonthly:
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+	}
+	return time.Time{}
+}
+
+// trackResetInterval carries the last reset of previous over to next. Setting or
+// changing the interval starts the schedule at now. LastResetAt is owned by the store,
+// so a value passed in by the caller is ignored.
+func trackResetInterval(previous APIKey, next *APIKey, now time.Time) {
+	switch {
+	case next.ResetInterval == "":
+		next.LastResetAt = time.Time{}
+	case next.ResetInterval != previous.ResetInterval || previous.LastResetAt.IsZero():
+		next.LastResetAt = now
+	default:
+		next.LastResetAt = previous.LastResetAt
+	}
+}
+
+// ResetDueUsage resets the usage counters of the key with the given id when its reset
+// interval has elapsed, records an audit entry and persists the store. It reports
+// whether the key was reset. Read-only instances leave resets to the writer.
+func (s *Store) ResetDueUsage(id string, now time.Time) bool {
+	if s == nil || s.ReadOnly() {
+		return false
+	}
+	unlock := s.lock("ResetDueUsage")
+	i, ok := s.keyIDIndexLocked(id)
+	if !ok || !s.data.APIKeys[i].resetDue(now) {
+		unlock()
+		return false
+	}
+	// Claim the reset before releasing the lock so concurrent requests reset only once.
+	key := s.data.APIKeys[i]
+	s.data.APIKeys[i].LastResetAt = now
+	unlock()
+
+	if _, err := s.ResetUsage(id); err != nil {
+		log.Warnf("mj3gc: failed to reset usage of key %s: %v", id, err)
+		unlock = s.lock("ResetDueUsage")
+		if i, ok := s.keyIDIndexLocked(id); ok {
+			s.data.APIKeys[i].LastResetAt = key.LastResetAt
+		}
+		unlock()
+		return false
+	}
+	s.RecordAudit(AuditEntry{
+		Actor:   "maintenance",
+		Action:  "key.reset_usage",
+		Target:  id,
+		Reason:  key.ResetInterval + " reset",
+		Details: map[string]any{"used_count": key.UsedCount, "used_tokens": key.UsedTokens, "spent_usd": key.SpentUSD},
+	})
+	if err := s.Save(); err != nil {
+		log.Warnf("mj3gc: failed to save after resetting usage of key %s: %v", id, err)
+	}
+	return true
+}
+
+// resetDueUsageFor resets the key authenticated by value when its reset is due. It runs
+// before every request so an exhausted key recovers as soon as its period ends.
+func (s *Store) resetDueUsageFor(value string, now time.Time) {
+	key, ok := s.FindAPIKeyByPrincipal(value)
+	if ok && key.resetDue(now) {
+		s.ResetDueUsage(key.ID, now)
+	}
+}
+
+// ResetDueKeys resets every key whose reset interval has elapsed, including keys that
+// see no requests, and returns how many were reset.
+func (s *Store) ResetDueKeys(now time.Time) int {
+	reset := 0
+	for _, key := range s.ListAPIKeys() {
+		if key.resetDue(now) && s.ResetDueUsage(key.ID, now) {
+			reset++
+		}
+	}
+	return reset
+}
@@ -0,0 +1,77 @@
+package mj3gc
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNextResetBoundary(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone data unavailable")
+	}
+	// Wednesday.
+	at := time.Date(2026, 1, 14, 15, 30, 0, 0, time.UTC)
+	cases := []struct {
+		interval string
+		t        time.Time
+		want     time.Time
+	}{
+		{ResetDaily, at, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{ResetWeekly, at, time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC)},
+		{ResetWeekly, time.Date(2026, 1, 19, 0, 0, 0, 0, time.UTC), time.Date(2026, 1, 26, 0, 0, 0, 0, time.UTC)},
+		{ResetMonthly, time.Date(2026, 12, 31, 23, 0, 0, 0, time.UTC), time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{ResetDaily, at.In(berlin), time.Date(2026, 1, 15, 0, 0, 0, 0, berlin)},
+	}
+	for _, tc := range cases {
+		if got := nextResetBoundary(tc.interval, tc.t); !got.Equal(tc.want) {
+			t.Errorf("nextResetBoundary(%s, %s) = %s, want %s", tc.interval, tc.t, got, tc.want)
+		}
+	}
+	if _, err := NormalizeResetInterval("hourly"); err == nil {
+		t.Fatal("NormalizeResetInterval accepted hourly")
+	}
+}
+
+func TestScheduledUsageReset(t *testing.T) {
+	s := NewStore()
+	key, err := s.UpsertAPIKey(APIKey{Key: "sk-daily-reset", Enabled: true, TotalLimit: 1, ResetInterval: ResetDaily})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.LastResetAt.IsZero() || !key.ResetsAt().After(time.Now()) {
+		t.Fatalf("new key resets at %s after %s", key.ResetsAt(), key.LastResetAt)
+	}
+	if _, err := s.BeginRequest("sk-daily-reset"); err != nil {
+		t.Fatal(err)
+	}
+	s.EndRequest("sk-daily-reset", true, "")
+	if _, err := s.BeginRequest("sk-daily-reset"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("BeginRequest over the limit = %v", err)
+	}
+
+	// Move the last reset into the previous period.
+	unlock := s.lock("test")
+	i, _ := s.keyIDIndexLocked(key.ID)
+	s.data.APIKeys[i].LastResetAt = time.Now().Add(-48 * time.Hour)
+	unlock()
+	if _, err := s.BeginRequest("sk-daily-reset"); err != nil {
+		t.Fatalf("BeginRequest after the reset period = %v", err)
+	}
+	got, _ := s.FindAPIKeyByID(key.ID)
+	if got.UsedCount != 0 || !got.ResetsAt().After(time.Now()) {
+		t.Fatalf("key after reset = used %d, resets at %s", got.UsedCount, got.ResetsAt())
+	}
+	if entries := s.AuditEntries(AuditFilter{Action: "key.reset_usage", Target: key.ID}); len(entries) != 1 {
+		t.Fatalf("audit entries = %+v", entries)
+	}
+	if n := s.ResetDueKeys(time.Now()); n != 0 {
+		t.Fatalf("ResetDueKeys reset %d keys twice", n)
+	}
+
+	cleared, err := s.UpsertAPIKey(APIKey{ID: key.ID, Key: "sk-daily-reset", Enabled: true, TotalLimit: 1})
+	if err != nil || !cleared.LastResetAt.IsZero() || !cleared.ResetsAt().IsZero() {
+		t.Fatalf("key without interval = %+v, %v", cleared, err)
+	}
+}
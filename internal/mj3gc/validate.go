@@ -0,0 +1,161 @@
+package mj3gc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Load validation modes selected by mj3gc.load-validation.
+const (
+	// ValidationWarn logs problems found on load and serves the data unchanged. Default.
+	ValidationWarn = "warn"
+	// ValidationRepair fixes what it can, quarantines the rest and saves the result.
+	ValidationRepair = "repair"
+	// ValidationStrict refuses to load data with problems.
+	ValidationStrict = "strict"
+)
+
+// ErrInvalidData is returned by Load in strict validation mode.
+var ErrInvalidData = errors.New("invalid data")
+
+// Actions recorded on validation issues.
+const (
+	issueFixed       = "fixed"
+	issueQuarantined = "quarantined"
+	issueReported    = "reported"
+)
+
+// ValidationIssue is one problem found in the loaded data and what was done about it.
+type ValidationIssue struct {
+	Kind    string `json:"kind"`
+	ID      string `json:"id,omitempty"`
+	Problem string `json:"problem"`
+	Action  string `json:"action"`
+}
+
+// QuarantinedRecord is a user or key removed by repair because it could not be fixed.
+// It is kept in the data file for an operator to inspect.
+type QuarantinedRecord struct {
+	Reason        string    `json:"reason"`
+	User          *User     `json:"user,omitempty"`
+	APIKey        *APIKey   `json:"api_key,omitempty"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// validateData checks users and keys for empty or duplicate IDs, empty or duplicate
+// usernames and key secrets, invalid roles and negative limits. With repair set, fixable
+// records are corrected in place and the rest move to data.Quarantine.
+func validateData(data *Data, repair bool) []ValidationIssue {
+	var issues []ValidationIssue
+	now := time.Now()
+	report := func(kind, id, problem string, fixable bool) string {
+		action := issueReported
+		if repair {
+			action = issueQuarantined
+			if fixable {
+				action = issueFixed
+			}
+		}
+		issues = append(issues, ValidationIssue{Kind: kind, ID: id, Problem: problem, Action: action})
+		return action
+	}
+
+	userIDs := make(map[string]bool, len(data.Users))
+	usernames := make(map[string]bool, len(data.Users))
+	users := make([]User, 0, len(data.Users))
+	for _, u := range data.Users {
+		problem := ""
+		switch {
+		case strings.TrimSpace(u.Username) == "":
+			problem = "empty username"
+		case usernames[strings.ToLower(u.Username)]:
+			problem = "duplicate username " + u.Username
+		case u.ID != "" && userIDs[u.ID]:
+			problem = "duplicate id"
+		}
+		if problem != "" {
+			if report("user", u.ID, problem, false) == issueQuarantined {
+				quarantined := u
+				data.Quarantine = append(data.Quarantine, QuarantinedRecord{Reason: problem, User: &quarantined, QuarantinedAt: now})
+				continue
+			}
+		}
+		if strings.TrimSpace(u.ID) == "" && report("user", u.Username, "empty id", true) == issueFixed {
+			u.ID = newID("usr")
+		}
+		if u.Role != roleOwner && u.Role != roleUser && report("user", u.ID, fmt.Sprintf("invalid role %q", u.Role), true) == issueFixed {
+			u.Role = roleUser
+		}
+		userIDs[u.ID] = true
+		usernames[strings.ToLower(u.Username)] = true
+		users = append(users, u)
+	}
+
+	keyIDs := make(map[string]bool, len(data.APIKeys))
+	secrets := make(map[string]bool, len(data.APIKeys))
+	keys := make([]APIKey, 0, len(data.APIKeys))
+	for _, k := range data.APIKeys {
+		problem := ""
+		switch {
+		case strings.TrimSpace(k.Key) == "":
+			problem = "empty key"
+		case secrets[k.Key]:
+			problem = "duplicate key value"
+		case k.ID != "" && keyIDs[k.ID]:
+			problem = "duplicate id"
+		}
+		if problem != "" {
+			if report("api_key", k.ID, problem, false) == issueQuarantined {
+				quarantined := k
+				data.Quarantine = append(data.Quarantine, QuarantinedRecord{Reason: problem, APIKey: &quarantined, QuarantinedAt: now})
+				continue
+			}
+		}
+		if strings.TrimSpace(k.ID) == "" && report("api_key", k.Label, "empty id", true) == issueFixed {
+			k.ID = newID("key")
+		}
+		if (k.TotalLimit < 0 || k.ConcurrencyLimit < 0) && report("api_key", k.ID, "negative limit", true) == issueFixed {
+			k.TotalLimit = max(k.TotalLimit, 0)
+			k.ConcurrencyLimit = max(k.ConcurrencyLimit, 0)
+		}
+		keyIDs[k.ID] = true
+		secrets[k.Key] = true
+		keys = append(keys, k)
+	}
+
+	if repair {
+		data.Users = users
+		data.APIKeys = keys
+	}
+	return issues
+}
+
+// Validate reports problems in the data currently served without changing it.
+func (s *Store) Validate() []ValidationIssue {
+	if s == nil {
+		return nil
+	}
+	data := s.Snapshot()
+	return validateData(&data, false)
+}
+
+// ListQuarantined returns the records moved aside by load repair.
+func (s *Store) ListQuarantined() []QuarantinedRecord {
+	if s == nil {
+		return nil
+	}
+	defer s.rlock("ListQuarantined")()
+	return append([]QuarantinedRecord(nil), s.data.Quarantine...)
+}
+
+// loadValidation returns mj3gc.load-validation, defaulting to ValidationWarn.
+func (s *Store) loadValidation() string {
+	switch mode := strings.ToLower(strings.TrimSpace(s.Settings().LoadValidation)); mode {
+	case ValidationRepair, ValidationStrict:
+		return mode
+	default:
+		return ValidationWarn
+	}
+}
@@ -0,0 +1,82 @@
+package mj3gc
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/router-for-me/CLIProxyAPI/v6/internal/config"
+)
+
+func inconsistentData() Data {
+	return Data{
+		Version: CurrentDataVersion,
+		Users: []User{
+			{ID: "u1", Username: "alice", Role: roleUser},
+			{ID: "u2", Username: "ALICE", Role: roleUser},
+			{Username: "bob", Role: "admin"},
+		},
+		APIKeys: []APIKey{
+			{ID: "k1", Key: "secret"},
+			{ID: "k2", Key: "secret"},
+			{Key: "other", TotalLimit: -1},
+			{ID: "k4"},
+		},
+	}
+}
+
+func TestValidateDataReportsWithoutRepair(t *testing.T) {
+	data := inconsistentData()
+	issues := validateData(&data, false)
+	if len(issues) != 7 {
+		t.Fatalf("issues = %+v, want 7", issues)
+	}
+	for _, issue := range issues {
+		if issue.Action != issueReported {
+			t.Fatalf("issue %+v changed data without repair", issue)
+		}
+	}
+	if len(data.Users) != 3 || len(data.APIKeys) != 4 {
+		t.Fatal("data changed without repair")
+	}
+}
+
+func TestLoadRepairsData(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "mj3gc.json")
+	seed := NewStore()
+	seed.SetPath(path)
+	seed.ApplyConfig(&config.Config{})
+	seed.data = inconsistentData()
+	if err := seed.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	strict := NewStore()
+	strict.SetPath(path)
+	strict.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{LoadValidation: ValidationStrict}})
+	if err := strict.Load(); !errors.Is(err, ErrInvalidData) {
+		t.Fatalf("strict Load err = %v, want ErrInvalidData", err)
+	}
+
+	s := NewStore()
+	s.SetPath(path)
+	s.ApplyConfig(&config.Config{MJ3GC: config.MJ3GCConfig{LoadValidation: ValidationRepair}})
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if issues := s.Validate(); len(issues) != 0 {
+		t.Fatalf("issues after repair = %+v", issues)
+	}
+	users := s.ListUsers()
+	if len(users) != 2 || users[1].ID == "" || users[1].Role != roleUser {
+		t.Fatalf("users = %+v", users)
+	}
+	keys := s.ListAPIKeys()
+	if len(keys) != 2 || keys[1].ID == "" || keys[1].TotalLimit != 0 {
+		t.Fatalf("keys = %+v", keys)
+	}
+	if quarantined := s.ListQuarantined(); len(quarantined) != 3 {
+		t.Fatalf("quarantine = %+v, want 3 records", quarantined)
+	}
+}
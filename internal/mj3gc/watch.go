@@ -0,0 +1,194 @@
+package mj3gc
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	watchDebounce     = 250 * time.Millisecond
+	watchPollInterval = 5 * time.Second
+)
+
+// fileWatchState remembers the data file as this process last read or wrote it, so the
+// watcher can tell external edits from its own saves and which counters an operator
+// changed by hand.
+type fileWatchState struct {
+	mu       sync.Mutex
+	modTime  time.Time
+	size     int64
+	counters map[string]int64
+}
+
+// noteFileState records the data file state after a load or save through the file backend.
+func (s *Store) noteFileState(data Data) {
+	fb, ok := s.currentBackend().(*fileBackend)
+	if !ok {
+		return
+	}
+	info, err := os.Stat(fb.path)
+	if err != nil {
+		return
+	}
+	counters := make(map[string]int64, len(data.APIKeys))
+	for _, key := range data.APIKeys {
+		counters[key.ID] = key.UsedCount
+	}
+	s.fileWatch.mu.Lock()
+	s.fileWatch.modTime, s.fileWatch.size, s.fileWatch.counters = info.ModTime(), info.Size(), counters
+	s.fileWatch.mu.Unlock()
+}
+
+// fileChanged reports whether the data file differs from what this process last saw.
+func (s *Store) fileChanged(path string) bool {
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	s.fileWatch.mu.Lock()
+	defer s.fileWatch.mu.Unlock()
+	return !info.ModTime().Equal(s.fileWatch.modTime) || info.Size() != s.fileWatch.size
+}
+
+// StartFileWatcher reloads the store when the JSON data file is changed by something
+// other than this process, such as a hand edit or a GitOps sync. It uses fsnotify and
+// falls back to mtime polling when notifications are unavailable. Only the file
+// backend is watched.
+func (s *Store) StartFileWatcher(ctx context.Context) {
+	if s == nil || !s.Settings().WatchFile {
+		return
+	}
+	fb, ok := s.currentBackend().(*fileBackend)
+	if !ok {
+		return
+	}
+	path := fb.path
+	watcher, err := fsnotify.NewWatcher()
+	if err == nil {
+		// Watch the directory: atomic saves replace the file and drop file-level watches.
+		if err = watcher.Add(filepath.Dir(path)); err != nil {
+			_ = watcher.Close()
+		}
+	}
+	if err != nil {
+		log.Warnf("mj3gc: file notifications unavailable, polling %s: %v", path, err)
+		go s.pollFile(ctx, path)
+		return
+	}
+	go func() {
+		defer func() { _ = watcher.Close() }()
+		var debounce <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == filepath.Clean(path) && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					debounce = time.After(watchDebounce)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warnf("mj3gc: file watcher error: %v", err)
+			case <-debounce:
+				debounce = nil
+				s.reloadIfChanged(path)
+			}
+		}
+	}()
+}
+
+func (s *Store) pollFile(ctx context.Context, path string) {
+	ticker := time.NewTicker(watchPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reloadIfChanged(path)
+		}
+	}
+}
+
+func (s *Store) reloadIfChanged(path string) {
+	if !s.fileChanged(path) {
+		return
+	}
+	if err := s.ReloadMerged(); err != nil {
+		log.Warnf("mj3gc: failed to reload changed data file: %v", err)
+		return
+	}
+	log.Infof("mj3gc: reloaded %s after external change", path)
+	s.RecordAudit(AuditEntry{Actor: "file-watcher", Action: "store.reload", Target: path})
+}
+
+// ReloadMerged re-reads the backend and replaces users and keys while keeping in-memory
+// usage counters. A counter is taken from the backend only when it differs from the
+// value this process last read or wrote, i.e. when it was edited deliberately.
+func (s *Store) ReloadMerged() error {
+	if s == nil {
+		return nil
+	}
+	backend := s.currentBackend()
+	if backend == nil {
+		return ErrInvalidConfiguration
+	}
+	data, err := backend.Load()
+	if err != nil {
+		return err
+	}
+	if _, err := migrateData(&data); err != nil {
+		return err
+	}
+	loaded := data
+	loaded.APIKeys = append([]APIKey(nil), data.APIKeys...)
+
+	s.fileWatch.mu.Lock()
+	known := s.fileWatch.counters
+	s.fileWatch.mu.Unlock()
+
+	unlock := s.lock("ReloadMerged")
+	current := make(map[string]APIKey, len(s.data.APIKeys))
+	for _, key := range s.data.APIKeys {
+		current[key.ID] = key
+	}
+	for i := range data.APIKeys {
+		key := &data.APIKeys[i]
+		live, ok := current[key.ID]
+		if !ok {
+			continue
+		}
+		if last, seen := known[key.ID]; !seen || key.UsedCount == last {
+			key.UsedCount = live.UsedCount
+		}
+		// Token usage and spend only grow between saves, so lower values in the file are
+		// stale.
+		key.UsedTokens = max(key.UsedTokens, live.UsedTokens)
+		key.SpentUSD = max(key.SpentUSD, live.SpentUSD)
+		if live.LastUsedAt.After(key.LastUsedAt) {
+			key.LastUsedAt, key.LastUsedIP = live.LastUsedAt, live.LastUsedIP
+		}
+		// The counters above may have been reset after the file was written.
+		if key.ResetInterval == live.ResetInterval && live.LastResetAt.After(key.LastResetAt) {
+			key.LastResetAt = live.LastResetAt
+		}
+	}
+	// Edits made in the file are recorded as changes of this store.
+	data.Revision, data.Changes = s.data.Revision, s.data.Changes
+	s.data = data
+	s.tampered = false
+	unlock()
+	s.noteFileState(loaded)
+	return nil
+}
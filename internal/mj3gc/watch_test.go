@@ -0,0 +1,52 @@
+package mj3gc
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReloadMergedKeepsLiveCounters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mj3gc.json")
+	s := NewStore()
+	s.SetPath(path)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	a, _ := s.UpsertAPIKey(APIKey{Key: "sk-a", Label: "a", Enabled: true})
+	b, _ := s.UpsertAPIKey(APIKey{Key: "sk-b", Label: "b", Enabled: true})
+	if err := s.Save(); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if s.fileChanged(path) {
+		t.Fatal("own save reported as an external change")
+	}
+	s.setUsedCount(a.ID, 7)
+	s.setUsedCount(b.ID, 3)
+
+	// Hand edit: relabel key a and reset key b's counter.
+	stored, _ := (&fileBackend{path: path}).Load()
+	stored.APIKeys[0].Label = "renamed"
+	stored.APIKeys[1].UsedCount = 1
+	if err := (&fileBackend{path: path}).Save(stored); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Second)
+	_ = os.Chtimes(path, future, future)
+	if !s.fileChanged(path) {
+		t.Fatal("external edit not detected")
+	}
+
+	if err := s.ReloadMerged(); err != nil {
+		t.Fatalf("ReloadMerged: %v", err)
+	}
+	gotA, _ := s.FindAPIKeyByID(a.ID)
+	gotB, _ := s.FindAPIKeyByID(b.ID)
+	if gotA.Label != "renamed" || gotA.UsedCount != 7 {
+		t.Fatalf("key a = label %q used %d, want renamed and live counter 7", gotA.Label, gotA.UsedCount)
+	}
+	if gotB.UsedCount != 1 {
+		t.Fatalf("key b used %d, want edited counter 1", gotB.UsedCount)
+	}
+}
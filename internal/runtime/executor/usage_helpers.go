@@ -81,6 +81,7 @@ func (r *usageReporter) publishWithOutcome(ctx context.Context, detail usage.Det
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
+			Latency:     time.Since(r.requestedAt),
 			Failed:      failed,
 			Detail:      detail,
 		})
@@ -104,6 +105,7 @@ func (r *usageReporter) ensurePublished(ctx context.Context) {
 			AuthID:      r.authID,
 			AuthIndex:   r.authIndex,
 			RequestedAt: r.requestedAt,
+			Latency:     time.Since(r.requestedAt),
 			Failed:      false,
 			Detail:      usage.Detail{},
 		})
@@ -86,13 +86,14 @@ type modelStats struct {
 	Details       []RequestDetail
 }
 
-// RequestDetail stores the timestamp and token usage for a single request.
+// RequestDetail stores the timestamp, token usage and latency of a single request.
 type RequestDetail struct {
 	Timestamp time.Time  `json:"timestamp"`
 	Source    string     `json:"source"`
 	AuthIndex uint64     `json:"auth_index"`
 	Tokens    TokenStats `json:"tokens"`
 	Failed    bool       `json:"failed"`
+	LatencyMs int64      `json:"latency_ms,omitempty"`
 }
 
 // TokenStats captures the token usage breakdown for a request.
@@ -201,6 +202,7 @@ func (s *RequestStatistics) Record(ctx context.Context, record coreusage.Record)
 		AuthIndex: record.AuthIndex,
 		Tokens:    detail,
 		Failed:    failed,
+		LatencyMs: record.Latency.Milliseconds(),
 	})
 
 	s.requestsByDay[dayKey]++
@@ -222,6 +224,32 @@ func (s *RequestStatistics) updateAPIStats(stats *apiStats, model string, detail
 	modelStatsValue.Details = append(modelStatsValue.Details, detail)
 }
 
+// PruneDetails drops per-request details recorded before cutoff and returns how many
+// were removed. Aggregated request and token totals are left untouched.
+func (s *RequestStatistics) PruneDetails(cutoff time.Time) int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for _, stats := range s.apis {
+		for _, modelStatsValue := range stats.Models {
+			kept := modelStatsValue.Details[:0]
+			for _, detail := range modelStatsValue.Details {
+				if detail.Timestamp.Before(cutoff) {
+					removed++
+					continue
+				}
+				kept = append(kept, detail)
+			}
+			modelStatsValue.Details = kept
+		}
+	}
+	return removed
+}
+
 // Snapshot returns a copy of the aggregated metrics for external consumption.
 func (s *RequestStatistics) Snapshot() StatisticsSnapshot {
 	result := StatisticsSnapshot{}
@@ -108,3 +108,17 @@ func addConfigHeadersToAttrs(headers map[string]string, attrs map[string]string)
 		attrs["header:"+key] = val
 	}
 }
+
+// addResidencyToAttrs records the residency tag of a config credential.
+func addResidencyToAttrs(residency string, attrs map[string]string) {
+	if residency = strings.ToLower(strings.TrimSpace(residency)); residency != "" && attrs != nil {
+		attrs["residency"] = residency
+	}
+}
+
+// addPoolToAttrs records the upstream account pool of a config credential.
+func addPoolToAttrs(pool string, attrs map[string]string) {
+	if pool = strings.ToLower(strings.TrimSpace(pool)); pool != "" && attrs != nil {
+		attrs["pool"] = pool
+	}
+}
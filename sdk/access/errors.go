@@ -1,6 +1,9 @@
 package access
 
-import "errors"
+import (
+	"errors"
+	"time"
+)
 
 var (
 	// ErrNoCredentials indicates no recognizable credentials were supplied.
@@ -10,3 +13,24 @@ var (
 	// ErrNotHandled tells the manager to continue trying other providers.
 	ErrNotHandled = errors.New("access: not handled")
 )
+
+// RejectionError is returned by providers that recognise a credential but refuse it
+// for a reason the client can act on, such as a disabled key (401) or an exhausted
+// quota (429). It matches ErrInvalidCredential, so callers that only look for invalid
+// credentials keep working.
+type RejectionError struct {
+	// Status is the HTTP status to answer with.
+	Status int
+	// Code is a stable machine-readable reason such as "key_disabled".
+	Code    string
+	Message string
+	// DocURL optionally points to documentation on how to resolve the rejection.
+	DocURL string
+	// RetryAfter, when positive, is when the request may succeed again.
+	RetryAfter time.Duration
+}
+
+func (e *RejectionError) Error() string { return "access: " + e.Message }
+
+// Is reports whether target is ErrInvalidCredential.
+func (e *RejectionError) Is(target error) bool { return target == ErrInvalidCredential }
@@ -54,7 +54,7 @@ func (m *Manager) Authenticate(ctx context.Context, r *http.Request) (*Result, e
 
 	var (
 		missing bool
-		invalid bool
+		invalid error
 	)
 
 	for _, provider := range providers {
@@ -73,13 +73,21 @@ func (m *Manager) Authenticate(ctx context.Context, r *http.Request) (*Result, e
 			continue
 		}
 		if errors.Is(err, ErrInvalidCredential) {
-			invalid = true
+			// Keep the first detailed rejection so clients learn why they were refused.
+			var rejection *RejectionError
+			if invalid == nil || (!errors.As(invalid, &rejection) && errors.As(err, &rejection)) {
+				invalid = err
+			}
 			continue
 		}
 		return nil, err
 	}
 
-	if invalid {
+	if invalid != nil {
+		var rejection *RejectionError
+		if errors.As(invalid, &rejection) {
+			return nil, rejection
+		}
 		return nil, ErrInvalidCredential
 	}
 	if missing {
@@ -125,19 +125,39 @@ func StreamingBootstrapRetries(cfg *config.SDKConfig) int {
 	return retries
 }
 
+// routingMetadataKeys are the access metadata entries forwarded to credential selection.
+var routingMetadataKeys = []string{
+	coreexecutor.ResidencyMetadataKey,
+	coreexecutor.PoolMetadataKey,
+	coreexecutor.PinnedAuthsMetadataKey,
+	coreexecutor.PinnedProvidersMetadataKey,
+}
+
 func requestExecutionMetadata(ctx context.Context) map[string]any {
 	// Idempotency-Key is an optional client-supplied header used to correlate retries.
 	// It is forwarded as execution metadata; when absent we generate a UUID.
 	key := ""
+	var routing map[string]string
 	if ctx != nil {
 		if ginCtx, ok := ctx.Value("gin").(*gin.Context); ok && ginCtx != nil && ginCtx.Request != nil {
 			key = strings.TrimSpace(ginCtx.GetHeader("Idempotency-Key"))
+			// Access providers may bind the caller to a data residency, an upstream
+			// account pool or pinned credentials that credential selection has to honour.
+			if accessMeta, ok := ginCtx.Get("accessMetadata"); ok {
+				routing, _ = accessMeta.(map[string]string)
+			}
 		}
 	}
 	if key == "" {
 		key = uuid.NewString()
 	}
-	return map[string]any{idempotencyKeyMetadataKey: key}
+	meta := map[string]any{idempotencyKeyMetadataKey: key}
+	for _, name := range routingMetadataKeys {
+		if value := routing[name]; value != "" {
+			meta[name] = value
+		}
+	}
+	return meta
 }
 
 func mergeMetadata(base, overlay map[string]any) map[string]any {
@@ -242,11 +262,13 @@ func (h *BaseAPIHandler) GetContextWithCancel(handler interfaces.APIHandler, c *
 	}
 	newCtx, cancel := context.WithCancel(parentCtx)
 	if requestCtx != nil && requestCtx != parentCtx {
+		// Capture the channel before newCtx is rewrapped below.
+		done := newCtx.Done()
 		go func() {
 			select {
 			case <-requestCtx.Done():
 				cancel()
-			case <-newCtx.Done():
+			case <-done:
 			}
 		}()
 	}
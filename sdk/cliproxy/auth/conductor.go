@@ -262,6 +262,10 @@ func (m *Manager) Execute(ctx context.Context, providers []string, req cliproxye
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	normalized, errPin := pinnedProviders(normalized, opts)
+	if errPin != nil {
+		return cliproxyexecutor.Response{}, errPin
+	}
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
@@ -301,6 +305,10 @@ func (m *Manager) ExecuteCount(ctx context.Context, providers []string, req clip
 	if len(normalized) == 0 {
 		return cliproxyexecutor.Response{}, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	normalized, errPin := pinnedProviders(normalized, opts)
+	if errPin != nil {
+		return cliproxyexecutor.Response{}, errPin
+	}
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
@@ -340,6 +348,10 @@ func (m *Manager) ExecuteStream(ctx context.Context, providers []string, req cli
 	if len(normalized) == 0 {
 		return nil, &Error{Code: "provider_not_found", Message: "no provider supplied"}
 	}
+	normalized, errPin := pinnedProviders(normalized, opts)
+	if errPin != nil {
+		return nil, errPin
+	}
 	rotated := m.rotateProviders(req.Model, normalized)
 	defer m.advanceProviderCursor(req.Model, normalized)
 
@@ -1181,6 +1193,57 @@ func (m *Manager) GetByID(id string) (*Auth, bool) {
 	return auth.Clone(), true
 }
 
+// requestResidency returns the residency a request is bound to, if any.
+func requestResidency(opts cliproxyexecutor.Options) string {
+	v, _ := opts.Metadata[cliproxyexecutor.ResidencyMetadataKey].(string)
+	return strings.ToLower(strings.TrimSpace(v))
+}
+
+// requestPool returns the upstream account pool a request is bound to, if any.
+func requestPool(opts cliproxyexecutor.Options) string {
+	v, _ := opts.Metadata[cliproxyexecutor.PoolMetadataKey].(string)
+	return strings.ToLower(strings.TrimSpace(v))
+}
+
+// requestPinned returns the comma separated values of a pinning metadata entry as a set.
+func requestPinned(opts cliproxyexecutor.Options, key string) map[string]struct{} {
+	v, _ := opts.Metadata[key].(string)
+	var out map[string]struct{}
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			if out == nil {
+				out = make(map[string]struct{})
+			}
+			out[item] = struct{}{}
+		}
+	}
+	return out
+}
+
+// pinnedProviders drops the providers a request is not pinned to, comparing names
+// case-insensitively. It fails when the request is pinned and none of the providers
+// serving the model remain.
+func pinnedProviders(providers []string, opts cliproxyexecutor.Options) ([]string, error) {
+	requested := requestPinned(opts, cliproxyexecutor.PinnedProvidersMetadataKey)
+	if len(requested) == 0 {
+		return providers, nil
+	}
+	pinned := make(map[string]struct{}, len(requested))
+	for provider := range requested {
+		pinned[strings.ToLower(provider)] = struct{}{}
+	}
+	kept := make([]string, 0, len(providers))
+	for _, provider := range providers {
+		if _, ok := pinned[strings.ToLower(provider)]; ok {
+			kept = append(kept, provider)
+		}
+	}
+	if len(kept) == 0 {
+		return nil, &Error{Code: "pin_violation", Message: "no pinned provider serves this model", HTTPStatus: http.StatusForbidden}
+	}
+	return kept, nil
+}
+
 func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cliproxyexecutor.Options, tried map[string]struct{}) (*Auth, ProviderExecutor, error) {
 	m.mu.RLock()
 	executor, okExecutor := m.executors[provider]
@@ -1191,6 +1254,12 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 	candidates := make([]*Auth, 0, len(m.auths))
 	modelKey := strings.TrimSpace(model)
 	registryRef := registry.GetGlobalRegistry()
+	residency := requestResidency(opts)
+	outsideResidency := 0
+	pool := requestPool(opts)
+	outsidePool := 0
+	pinnedAuths := requestPinned(opts, cliproxyexecutor.PinnedAuthsMetadataKey)
+	unpinned := 0
 	for _, candidate := range m.auths {
 		if candidate.Provider != provider || candidate.Disabled {
 			continue
@@ -1201,10 +1270,34 @@ func (m *Manager) pickNext(ctx context.Context, provider, model string, opts cli
 		if modelKey != "" && registryRef != nil && !registryRef.ClientSupportsModel(candidate.ID, modelKey) {
 			continue
 		}
+		if residency != "" && candidate.Residency() != residency {
+			outsideResidency++
+			continue
+		}
+		if pool != "" && candidate.Pool() != pool {
+			outsidePool++
+			continue
+		}
+		if _, ok := pinnedAuths[candidate.ID]; len(pinnedAuths) > 0 && !ok {
+			unpinned++
+			continue
+		}
 		candidates = append(candidates, candidate)
 	}
 	if len(candidates) == 0 {
 		m.mu.RUnlock()
+		if outsideResidency > 0 {
+			log.Warnf("blocked %s request for model %s: %d %s credentials are outside residency %q", provider, model, outsideResidency, provider, residency)
+			return nil, nil, &Error{Code: "residency_violation", Message: "no credential satisfies the required data residency " + residency, HTTPStatus: http.StatusForbidden}
+		}
+		if outsidePool > 0 {
+			log.Warnf("blocked %s request for model %s: %d %s credentials are outside pool %q", provider, model, outsidePool, provider, pool)
+			return nil, nil, &Error{Code: "pool_violation", Message: "no credential of the assigned upstream pool " + pool + " is available", HTTPStatus: http.StatusForbidden}
+		}
+		if unpinned > 0 {
+			log.Warnf("blocked %s request for model %s: %d %s credentials are not pinned for the caller", provider, model, unpinned, provider)
+			return nil, nil, &Error{Code: "pin_violation", Message: "no pinned credential is available", HTTPStatus: http.StatusForbidden}
+		}
 		return nil, nil, &Error{Code: "auth_not_found", Message: "no auth available"}
 	}
 	selected, errPick := m.selector.Pick(ctx, provider, model, opts, candidates)
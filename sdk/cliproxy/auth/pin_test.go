@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestPickNextHonoursPinnedAuths(t *testing.T) {
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(residencyTestExecutor{})
+	ctx := context.Background()
+	for _, id := range []string{"a-trial", "b-premium", "c-premium"} {
+		if _, err := m.Register(ctx, &Auth{ID: id, Provider: "gemini"}); err != nil {
+			t.Fatalf("Register(%s): %v", id, err)
+		}
+	}
+
+	premium := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.PinnedAuthsMetadataKey: "b-premium, c-premium"}}
+	picked, _, err := m.pickNext(ctx, "gemini", "", premium, nil)
+	if err != nil || picked.ID != "b-premium" {
+		t.Fatalf("pickNext(pinned) = %v, %v, want b-premium", picked, err)
+	}
+	_, _, err = m.pickNext(ctx, "gemini", "", premium, map[string]struct{}{"b-premium": {}, "c-premium": {}})
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.Code != "pin_violation" || authErr.HTTPStatus != http.StatusForbidden {
+		t.Fatalf("pickNext with the pinned auths exhausted err = %v, want pin_violation", err)
+	}
+}
+
+func TestPinnedProviders(t *testing.T) {
+	opts := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.PinnedProvidersMetadataKey: "claude"}}
+	got, err := pinnedProviders([]string{"gemini", "claude"}, opts)
+	if err != nil || !reflect.DeepEqual(got, []string{"claude"}) {
+		t.Fatalf("pinnedProviders = %v, %v", got, err)
+	}
+	if _, err := pinnedProviders([]string{"gemini"}, opts); err == nil {
+		t.Fatal("pinnedProviders without a pinned provider succeeded")
+	}
+	if got, _ := pinnedProviders([]string{"gemini"}, cliproxyexecutor.Options{}); !reflect.DeepEqual(got, []string{"gemini"}) {
+		t.Fatalf("unpinned providers = %v", got)
+	}
+
+	mixed := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.PinnedProvidersMetadataKey: "Claude, OpenAI-Compat"}}
+	got, err = pinnedProviders([]string{"gemini", "claude", "openai-compat"}, mixed)
+	if err != nil || !reflect.DeepEqual(got, []string{"claude", "openai-compat"}) {
+		t.Fatalf("pinnedProviders with mixed-case pins = %v, %v", got, err)
+	}
+	got, err = pinnedProviders([]string{"Gemini", "Claude"}, opts)
+	if err != nil || !reflect.DeepEqual(got, []string{"Claude"}) {
+		t.Fatalf("pinnedProviders with mixed-case providers = %v, %v", got, err)
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+func TestPickNextHonoursPool(t *testing.T) {
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(residencyTestExecutor{})
+	ctx := context.Background()
+	for _, auth := range []*Auth{
+		{ID: "a-shared", Provider: "gemini"},
+		{ID: "b-acme", Provider: "gemini", Attributes: map[string]string{"pool": "acme"}},
+		{ID: "c-acme", Provider: "gemini", Metadata: map[string]any{"pool": "ACME"}},
+	} {
+		if _, err := m.Register(ctx, auth); err != nil {
+			t.Fatalf("Register(%s): %v", auth.ID, err)
+		}
+	}
+
+	acme := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.PoolMetadataKey: "acme"}}
+	picked, _, err := m.pickNext(ctx, "gemini", "", acme, map[string]struct{}{"b-acme": {}})
+	if err != nil || picked.ID != "c-acme" {
+		t.Fatalf("pickNext(acme) = %v, %v, want c-acme", picked, err)
+	}
+
+	_, _, err = m.pickNext(ctx, "gemini", "", acme, map[string]struct{}{"b-acme": {}, "c-acme": {}})
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.Code != "pool_violation" || authErr.HTTPStatus != http.StatusForbidden {
+		t.Fatalf("pickNext with the pool exhausted err = %v, want pool_violation", err)
+	}
+
+	picked, _, err = m.pickNext(ctx, "gemini", "", cliproxyexecutor.Options{}, nil)
+	if err != nil || picked.ID != "a-shared" {
+		t.Fatalf("pickNext(unbound) = %v, %v, want a-shared", picked, err)
+	}
+}
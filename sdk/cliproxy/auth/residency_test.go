@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	cliproxyexecutor "github.com/router-for-me/CLIProxyAPI/v6/sdk/cliproxy/executor"
+)
+
+type residencyTestExecutor struct{}
+
+func (residencyTestExecutor) Identifier() string { return "gemini" }
+
+func (residencyTestExecutor) Execute(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func (residencyTestExecutor) ExecuteStream(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (<-chan cliproxyexecutor.StreamChunk, error) {
+	return nil, nil
+}
+
+func (residencyTestExecutor) Refresh(_ context.Context, auth *Auth) (*Auth, error) { return auth, nil }
+
+func (residencyTestExecutor) CountTokens(context.Context, *Auth, cliproxyexecutor.Request, cliproxyexecutor.Options) (cliproxyexecutor.Response, error) {
+	return cliproxyexecutor.Response{}, nil
+}
+
+func TestPickNextHonoursResidency(t *testing.T) {
+	m := NewManager(nil, &FillFirstSelector{}, nil)
+	m.RegisterExecutor(residencyTestExecutor{})
+	ctx := context.Background()
+	for _, auth := range []*Auth{
+		{ID: "a-us", Provider: "gemini", Attributes: map[string]string{"residency": "us"}},
+		{ID: "b-eu", Provider: "gemini", Metadata: map[string]any{"residency": "EU"}},
+	} {
+		if _, err := m.Register(ctx, auth); err != nil {
+			t.Fatalf("Register(%s): %v", auth.ID, err)
+		}
+	}
+
+	eu := cliproxyexecutor.Options{Metadata: map[string]any{cliproxyexecutor.ResidencyMetadataKey: "eu"}}
+	picked, _, err := m.pickNext(ctx, "gemini", "", eu, nil)
+	if err != nil || picked.ID != "b-eu" {
+		t.Fatalf("pickNext(eu) = %v, %v, want b-eu", picked, err)
+	}
+
+	_, _, err = m.pickNext(ctx, "gemini", "", eu, map[string]struct{}{"b-eu": {}})
+	var authErr *Error
+	if !errors.As(err, &authErr) || authErr.Code != "residency_violation" || authErr.HTTPStatus != http.StatusForbidden {
+		t.Fatalf("pickNext without eu credentials err = %v, want residency_violation", err)
+	}
+
+	picked, _, err = m.pickNext(ctx, "gemini", "", cliproxyexecutor.Options{}, nil)
+	if err != nil || picked.ID != "a-us" {
+		t.Fatalf("pickNext(unbound) = %v, %v, want a-us", picked, err)
+	}
+}
@@ -159,6 +159,36 @@ func (m *ModelState) Clone() *ModelState {
 	return &copyState
 }
 
+// Residency returns the data residency tag of the auth, taken from the "residency"
+// attribute of config credentials or the "residency" field of auth files.
+func (a *Auth) Residency() string {
+	if a == nil {
+		return ""
+	}
+	if v := strings.TrimSpace(a.Attributes["residency"]); v != "" {
+		return strings.ToLower(v)
+	}
+	if v, ok := a.Metadata["residency"].(string); ok {
+		return strings.ToLower(strings.TrimSpace(v))
+	}
+	return ""
+}
+
+// Pool returns the upstream account pool of the auth, taken from the "pool" attribute
+// of config credentials or the "pool" field of auth files.
+func (a *Auth) Pool() string {
+	if a == nil {
+		return ""
+	}
+	if v := strings.TrimSpace(a.Attributes["pool"]); v != "" {
+		return strings.ToLower(v)
+	}
+	if v, ok := a.Metadata["pool"].(string); ok {
+		return strings.ToLower(strings.TrimSpace(v))
+	}
+	return ""
+}
+
 func (a *Auth) ProxyInfo() string {
 	if a == nil {
 		return ""
@@ -19,6 +19,22 @@ type Request struct {
 	Metadata map[string]any
 }
 
+// ResidencyMetadataKey is the Options.Metadata entry naming the data residency a request
+// is bound to. Only auths tagged with the same residency are selected for it.
+const ResidencyMetadataKey = "residency"
+
+// PoolMetadataKey is the Options.Metadata entry naming the upstream account pool a
+// request is bound to. Only auths in the same pool are selected for it.
+const PoolMetadataKey = "pool"
+
+// PinnedAuthsMetadataKey is the Options.Metadata entry listing, comma separated, the
+// auth IDs a request is pinned to. Only those auths are selected for it.
+const PinnedAuthsMetadataKey = "pinned_auths"
+
+// PinnedProvidersMetadataKey is the Options.Metadata entry listing, comma separated, the
+// providers a request is pinned to. Other providers of the model are skipped.
+const PinnedProvidersMetadataKey = "pinned_providers"
+
 // Options controls execution behavior for both streaming and non-streaming calls.
 type Options struct {
 	// Stream toggles streaming mode.
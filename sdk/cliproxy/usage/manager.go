@@ -17,8 +17,11 @@ type Record struct {
 	AuthIndex   uint64
 	Source      string
 	RequestedAt time.Time
-	Failed      bool
-	Detail      Detail
+	// Latency is the time from RequestedAt until the usage was reported, which for
+	// streaming responses is usually the end of the stream.
+	Latency time.Duration
+	Failed  bool
+	Detail  Detail
 }
 
 // Detail holds the token usage breakdown.
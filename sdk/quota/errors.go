@@ -0,0 +1,64 @@
+package quota
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error is a key admission error. Every sentinel below is an *Error, so callers can
+// compare with errors.Is or extract the code and HTTP status with errors.As.
+type Error struct {
+	code    string
+	status  int
+	message string
+}
+
+func (e *Error) Error() string { return e.message }
+
+// Code is a stable machine-readable identifier such as "quota_exceeded".
+func (e *Error) Code() string { return e.code }
+
+// HTTPStatus is the status the built-in middleware answers with.
+func (e *Error) HTTPStatus() int { return e.status }
+
+var (
+	// ErrKeyNotFound indicates the presented key is unknown.
+	ErrKeyNotFound error = &Error{code: "key_not_found", status: http.StatusUnauthorized, message: "api key not found"}
+	// ErrKeyDisabled indicates the key exists but is disabled.
+	ErrKeyDisabled error = &Error{code: "key_disabled", status: http.StatusUnauthorized, message: "api key disabled"}
+	// ErrKeyExpired indicates the key is past its expiry time.
+	ErrKeyExpired error = &Error{code: "key_expired", status: http.StatusUnauthorized, message: "api key expired"}
+	// ErrKeySuspended indicates the key was suspended at its sunset date.
+	ErrKeySuspended error = &Error{code: "key_suspended", status: http.StatusForbidden, message: "api key suspended"}
+	// ErrOutsideSchedule indicates the key's schedule does not allow requests right now.
+	ErrOutsideSchedule error = &Error{code: "outside_schedule", status: http.StatusForbidden, message: "api key not usable at this time"}
+	// ErrQuotaExceeded indicates the key has used its total request allowance.
+	ErrQuotaExceeded error = &Error{code: "quota_exceeded", status: http.StatusTooManyRequests, message: "quota exceeded"}
+	// ErrTokenQuotaExceeded indicates the key has used its total token allowance.
+	ErrTokenQuotaExceeded error = &Error{code: "token_quota_exceeded", status: http.StatusTooManyRequests, message: "token quota exceeded"}
+	// ErrBudgetExceeded indicates the key has spent its monetary budget.
+	ErrBudgetExceeded error = &Error{code: "budget_exceeded", status: http.StatusTooManyRequests, message: "budget exceeded"}
+	// ErrConcurrencyExceeded indicates the key already has the maximum requests in flight.
+	ErrConcurrencyExceeded error = &Error{code: "concurrency_exceeded", status: http.StatusTooManyRequests, message: "concurrency exceeded"}
+	// ErrRateLimited indicates the key has made its maximum requests in the last minute.
+	ErrRateLimited error = &Error{code: "rate_limited", status: http.StatusTooManyRequests, message: "rate limit exceeded"}
+)
+
+// Code returns the code of the first *Error in err's chain, or "" when there is none.
+func Code(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code()
+	}
+	return ""
+}
+
+// HTTPStatus returns the HTTP status of the first *Error in err's chain, or 0 when
+// there is none so callers can apply their own default.
+func HTTPStatus(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.HTTPStatus()
+	}
+	return 0
+}
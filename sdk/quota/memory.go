@@ -0,0 +1,54 @@
+package quota
+
+import "sync"
+
+// MemoryCounters is a process-local Counters implementation.
+type MemoryCounters struct {
+	mu       sync.Mutex
+	inflight map[string]int
+	used     map[string]int64
+}
+
+// NewMemoryCounters returns empty in-memory counters.
+func NewMemoryCounters() *MemoryCounters {
+	return &MemoryCounters{inflight: make(map[string]int), used: make(map[string]int64)}
+}
+
+func (m *MemoryCounters) Acquire(id string, limit int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if limit > 0 && m.inflight[id] >= limit {
+		return false, nil
+	}
+	m.inflight[id]++
+	return true, nil
+}
+
+func (m *MemoryCounters) Release(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.inflight[id] > 0 {
+		m.inflight[id]--
+	}
+	return nil
+}
+
+func (m *MemoryCounters) Usage(id string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.used[id], nil
+}
+
+func (m *MemoryCounters) AddUsage(id string, delta int64) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.used[id] += delta
+	return m.used[id], nil
+}
+
+// SetUsage overwrites the usage counter of id, for seeding from persisted state.
+func (m *MemoryCounters) SetUsage(id string, used int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.used[id] = used
+}
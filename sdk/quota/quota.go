@@ -0,0 +1,94 @@
+// Package quota implements the per-key quota and concurrency enforcement used by the
+// built-in mj3gc key store. Storage is abstracted behind Counters so other services
+// can apply the same semantics against their own backends.
+package quota
+
+// Limits are the enforcement settings of one key. Zero limits mean unlimited.
+type Limits struct {
+	Enabled          bool
+	TotalLimit       int64
+	ConcurrencyLimit int
+}
+
+// Counters stores in-flight and usage counters. Implementations must make Acquire and
+// AddUsage atomic when shared between processes.
+type Counters interface {
+	// Acquire reserves an in-flight slot for id and reports false when limit is reached.
+	Acquire(id string, limit int) (bool, error)
+	// Release frees a slot reserved by Acquire.
+	Release(id string) error
+	// Usage returns the usage counter of id.
+	Usage(id string) (int64, error)
+	// AddUsage increments the usage counter and returns the new total.
+	AddUsage(id string, delta int64) (int64, error)
+}
+
+// Check reports whether a request may start for a key with the given limits and usage,
+// ignoring concurrency.
+func Check(limits Limits, used int64) error {
+	if !limits.Enabled {
+		return ErrKeyDisabled
+	}
+	if limits.TotalLimit > 0 && used >= limits.TotalLimit {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// Enforcer admits and completes requests against Counters. Counter errors fail open so
+// a storage outage degrades enforcement instead of rejecting all traffic; OnError, when
+// set, is told about every such error.
+type Enforcer struct {
+	Counters Counters
+	OnError  func(op, id string, err error)
+}
+
+func (e *Enforcer) report(op, id string, err error) {
+	if e.OnError != nil {
+		e.OnError(op, id, err)
+	}
+}
+
+// Begin admits a request for id. A nil error means the caller must call End once the
+// request completes.
+func (e *Enforcer) Begin(id string, limits Limits) error {
+	if !limits.Enabled {
+		return ErrKeyDisabled
+	}
+	if limits.TotalLimit > 0 {
+		used, err := e.Counters.Usage(id)
+		if err != nil {
+			e.report("usage", id, err)
+		} else if err := Check(limits, used); err != nil {
+			return err
+		}
+	}
+	if limits.ConcurrencyLimit > 0 {
+		admitted, err := e.Counters.Acquire(id, limits.ConcurrencyLimit)
+		if err != nil {
+			e.report("acquire", id, err)
+		} else if !admitted {
+			return ErrConcurrencyExceeded
+		}
+	}
+	return nil
+}
+
+// End releases the slot taken by Begin and, when count is true, charges one request.
+// It returns the usage total after charging, or -1 when usage was not updated.
+func (e *Enforcer) End(id string, limits Limits, count bool) int64 {
+	if limits.ConcurrencyLimit > 0 {
+		if err := e.Counters.Release(id); err != nil {
+			e.report("release", id, err)
+		}
+	}
+	if !count {
+		return -1
+	}
+	total, err := e.Counters.AddUsage(id, 1)
+	if err != nil {
+		e.report("add_usage", id, err)
+		return -1
+	}
+	return total
+}
@@ -0,0 +1,71 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+type failingCounters struct{ *MemoryCounters }
+
+func (*failingCounters) Usage(string) (int64, error) { return 0, errors.New("down") }
+
+func TestEnforcerLimits(t *testing.T) {
+	e := &Enforcer{Counters: NewMemoryCounters()}
+	limits := Limits{Enabled: true, TotalLimit: 2, ConcurrencyLimit: 1}
+
+	if err := e.Begin("k", limits); err != nil {
+		t.Fatalf("first Begin: %v", err)
+	}
+	if err := e.Begin("k", limits); !errors.Is(err, ErrConcurrencyExceeded) {
+		t.Fatalf("second concurrent Begin: err = %v, want ErrConcurrencyExceeded", err)
+	}
+	if total := e.End("k", limits, true); total != 1 {
+		t.Fatalf("End total = %d, want 1", total)
+	}
+	if err := e.Begin("k", limits); err != nil {
+		t.Fatalf("Begin after release: %v", err)
+	}
+	e.End("k", limits, true)
+	if err := e.Begin("k", limits); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Begin over quota: err = %v, want ErrQuotaExceeded", err)
+	}
+	if err := e.Begin("k", Limits{}); !errors.Is(err, ErrKeyDisabled) {
+		t.Fatalf("disabled key: err = %v, want ErrKeyDisabled", err)
+	}
+}
+
+func TestEnforcerFailsOpen(t *testing.T) {
+	var reported []string
+	e := &Enforcer{
+		Counters: &failingCounters{NewMemoryCounters()},
+		OnError:  func(op, _ string, _ error) { reported = append(reported, op) },
+	}
+	if err := e.Begin("k", Limits{Enabled: true, TotalLimit: 1}); err != nil {
+		t.Fatalf("Begin with failing usage counter: %v", err)
+	}
+	if len(reported) != 1 || reported[0] != "usage" {
+		t.Fatalf("reported = %v, want [usage]", reported)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	wrapped := fmt.Errorf("begin request: %w", ErrQuotaExceeded)
+	if !errors.Is(wrapped, ErrQuotaExceeded) {
+		t.Fatal("wrapped error lost its sentinel")
+	}
+	if got := HTTPStatus(wrapped); got != http.StatusTooManyRequests {
+		t.Fatalf("HTTPStatus = %d, want 429", got)
+	}
+	if got := Code(wrapped); got != "quota_exceeded" {
+		t.Fatalf("Code = %q", got)
+	}
+	var qe *Error
+	if !errors.As(ErrKeySuspended, &qe) || qe.HTTPStatus() != http.StatusForbidden {
+		t.Fatalf("ErrKeySuspended = %+v", qe)
+	}
+	if HTTPStatus(errors.New("other")) != 0 || Code(nil) != "" {
+		t.Fatal("errors outside the taxonomy must map to zero values")
+	}
+}